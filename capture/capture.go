@@ -0,0 +1,69 @@
+/*
+	swiftcap's capture engine as a library, for tools that would otherwise
+	run the binary and scrape its output. the swiftcap CLI and app are built
+	on it too.
+
+		img, err := capture.Screenshot(ctx, capture.ScreenshotOptions{
+			Target: capture.Monitor("primary"),
+		})
+
+		s, err := capture.StartRecording(ctx, capture.RecordOptions{
+			Target:   capture.ActiveWindow(),
+			Out:      "demo-{date}.mp4",
+			Pausable: true,
+		})
+		for e := range s.Events() {
+			...
+		}
+		err = s.Wait()
+
+	compatibility: from Version 1.0.0 on, nothing exported here is removed,
+	renamed or changes meaning until a 2.0.0. minor versions may add fields,
+	functions, event kinds, stop reasons and backends, so switch statements
+	over them want a default. backend names, state names and the exit codes
+	ExitCode returns are covered by the promise; error messages are not.
+*/
+
+package capture
+
+import (
+	"context"
+	"errors"
+
+	"github.com/almightynan/swiftcap/internal/backend"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+)
+
+// Version is the version of this API; see the compatibility promise above.
+const Version = "1.0.0"
+
+var (
+	// ErrNotPausable is what Pause and Resume return for a recording that
+	// wasn't started Pausable, or whose backend can't pause.
+	ErrNotPausable = errors.New("capture: this recording can't pause")
+
+	// ErrStopped is what Pause and Resume return once a recording is over.
+	ErrStopped = errors.New("capture: the recording has stopped")
+)
+
+// ExitCode is the exit status the swiftcap CLI would report err with, from
+// the table in the README: 0 for nil, 12 for a cancelled context, 25 for
+// one past its deadline and 100 for an error swiftcap knows nothing more
+// about.
+func ExitCode(err error) int {
+	code := scerr.CodeOf(err)
+	switch {
+	case code != scerr.Runtime:
+	case errors.Is(err, context.Canceled):
+		code = scerr.Cancelled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = scerr.Timeout
+	}
+	return int(code)
+}
+
+// Backends lists the recording and the screenshot backends by name, for
+// RecordOptions.Backend and ScreenshotOptions.Backend.
+func Backends() (recorders, screenshotters []string) {
+	return backend.RecorderNames(), backend.ScreenshotterNames()
+}
@@ -0,0 +1,133 @@
+package capture
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/record"
+)
+
+// RecordOptions describes one recording. The zero value of every field but
+// Out is a sensible default.
+type RecordOptions struct {
+	Target Target
+	// Out is the file to write, or a naming template such as
+	// "{date}/{time}_{mode}.{ext}", filled in when recording starts and
+	// numbered past files that already exist.
+	Out     string
+	Backend string // a name from Backends, or "" to pick the best one here
+	// Pausable lets Pause and Resume work, by recording a run of segments
+	// that are joined when the recording stops.
+	Pausable bool
+
+	FPS         int  // 0 = 30, or the Animation's rate for gif, webp and apng
+	Cursor      bool // draw the pointer in
+	MaxDuration time.Duration
+	Container   string // mp4, mkv, mov, avi, webm, gif, webp or apng; "" = from Out's extension, else mp4
+
+	Codec   string // x264, x265, vp9, av1, av1-aom or ffv1; "" = x264
+	Preset  string // encoder preset, "" = the codec's fastest sensible one
+	CRF     *int   // constant rate factor, used instead of Bitrate when set
+	QP      int    // constant quantizer, 0 = unset
+	Bitrate int    // kbit/s; 0 = 4000
+	Threads int    // encoder threads, 0 = the encoder decides
+	Nice    int    // niceness of the capture process
+
+	Audio bool
+	// AudioSources are NAME[:GAIN]: "desktop", "mic" or a sound server
+	// source name, with a gain as a factor (0.8) or in dB (-6dB). None is
+	// the default source.
+	AudioSources []string
+	AudioTracks  string // with several sources, "mix" (the default) or "separate"
+	AudioCodec   string // aac, opus, vorbis, mp3, flac or pcm; "" = per container
+	AudioBitrate int    // kbit/s; 0 = 128
+
+	// Animation is how gif, webp and apng recordings are exported; nil is
+	// DefaultAnimation.
+	Animation *Animation
+}
+
+// Animation is how a gif, webp or apng recording is exported once the
+// capture stops.
+type Animation struct {
+	FPS        int    // output frame rate, 0 = as captured
+	Width      int    // widest output, height follows; 0 = as captured
+	Dither     string // gif and apng palette dither, "" = sierra2_4a
+	Loop       int    // times it plays, 0 = forever
+	SizeBudget int64  // bytes; a bigger file gets an EventWarning, 0 = none
+}
+
+// DefaultAnimation suits a chat or PR: 15 fps, 960 wide, looping, and a
+// warning past 10 MiB.
+func DefaultAnimation() Animation {
+	a := record.DefaultAnimOptions()
+	return Animation{FPS: a.Fps, Width: a.Width, Dither: a.Dither, Loop: a.Loop, SizeBudget: a.Budget}
+}
+
+// Validate checks that the options go together, without starting anything.
+func (o RecordOptions) Validate() error {
+	_, err := o.encode()
+	return err
+}
+
+// encode is o as the recorders take it.
+func (o RecordOptions) encode() (record.Options, error) {
+	e := record.DefaultOptions()
+	e.Out = o.Out
+	e.Fps = o.FPS
+	if e.Fps <= 0 {
+		e.Fps = 30
+	}
+	e.Cursor = o.Cursor
+	e.MaxDur = int(math.Ceil(o.MaxDuration.Seconds()))
+	e.Container = o.Container
+	if e.Container == "" {
+		e.Container = "mp4"
+		ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(o.Out)), ".")
+		for _, c := range record.Containers() {
+			if c == ext {
+				e.Container = c
+			}
+		}
+	}
+	if o.Codec != "" {
+		e.Codec = o.Codec
+	}
+	e.Preset = o.Preset
+	if o.CRF != nil {
+		e.CRF = *o.CRF
+	}
+	e.QP = o.QP
+	e.Bitrate = o.Bitrate
+	if e.Bitrate <= 0 {
+		e.Bitrate = 4000
+	}
+	e.Threads = o.Threads
+	e.Nice = o.Nice
+
+	e.Audio = o.Audio
+	for _, s := range o.AudioSources {
+		src, err := record.ParseAudioSource(s)
+		if err != nil {
+			return e, scerr.Wrap(scerr.InvalidArgs, err)
+		}
+		e.ASrc = append(e.ASrc, src)
+	}
+	e.AudioTracks = o.AudioTracks
+	e.AudioCodec = o.AudioCodec
+	e.AudioBitrate = o.AudioBitrate
+
+	a := DefaultAnimation()
+	if o.Animation != nil {
+		a = *o.Animation
+	}
+	e.Anim = record.AnimOptions{Fps: a.FPS, Width: a.Width, Dither: a.Dither, Loop: a.Loop, Budget: a.SizeBudget}
+	if record.IsAnimated(e.Container) && o.FPS <= 0 && a.FPS > 0 {
+		// no point capturing frames the export drops
+		e.Fps = a.FPS
+	}
+	return e, scerr.Wrap(scerr.InvalidArgs, e.Validate())
+}
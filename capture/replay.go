@@ -0,0 +1,179 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/almightynan/swiftcap/internal/backend"
+	"github.com/almightynan/swiftcap/internal/detect"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/naming"
+	"github.com/almightynan/swiftcap/internal/pulse"
+	"github.com/almightynan/swiftcap/internal/record"
+)
+
+// Replay is a running replay buffer: it keeps the last Window of the
+// screen, and Save writes that out while the capture carries on. Nothing is
+// saved when it stops. Its methods are safe to call from any goroutine.
+type Replay struct {
+	buf       *record.ReplayBuffer
+	run       backend.Recording
+	container string
+	region    string
+	session   detect.SessionType
+
+	mu       sync.Mutex // one Save or prune at a time; held closing done
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	err      error // set before done is closed
+}
+
+// StartReplay starts buffering opts.Target. opts.Out and MaxDuration don't
+// apply, and the container must be a video one. Only the ffmpeg-x11grab
+// backend can buffer. Cancelling ctx stops it like Stop.
+func StartReplay(ctx context.Context, opts RecordOptions, window time.Duration) (*Replay, error) {
+	if window < 2*record.ReplaySegment*time.Second || window > time.Hour {
+		return nil, scerr.Errorf(scerr.InvalidArgs, "replay window %s out of range (%ds-1h)", window, 2*record.ReplaySegment)
+	}
+	opts.MaxDuration = 0
+	enc, err := opts.encode()
+	if err != nil {
+		return nil, err
+	}
+	if record.IsAnimated(enc.Container) {
+		return nil, scerr.Errorf(scerr.InvalidArgs, "the replay buffer saves video; export a saved replay to %s afterwards", enc.Container)
+	}
+	session, err := detect.Session()
+	if err != nil {
+		return nil, err
+	}
+	need := opts.Target.needs()
+	need.Audio = enc.Audio
+	rec, err := backend.PickRecorder(opts.Backend, session, need)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Name() != "ffmpeg-x11grab" {
+		return nil, scerr.Errorf(scerr.InvalidArgs, "the replay buffer needs the ffmpeg-x11grab backend, not %s", rec.Name())
+	}
+	if enc.Region, err = opts.Target.resolve(ctx); err != nil {
+		return nil, err
+	}
+	if enc.Audio {
+		for i := range enc.ASrc {
+			if enc.ASrc[i].Name, err = pulse.Resolve(enc.ASrc[i].Name); err != nil {
+				return nil, fmt.Errorf("audio source %s: %w", opts.AudioSources[i], err)
+			}
+		}
+	}
+	enc.Display = os.Getenv("DISPLAY")
+
+	buf, err := record.NewReplayBuffer(window, enc.Container)
+	if err != nil {
+		return nil, err
+	}
+	run, err := rec.Start(buf.Options(enc), nil)
+	if err != nil {
+		buf.Close()
+		return nil, err
+	}
+	r := &Replay{
+		buf:       buf,
+		run:       run,
+		container: enc.Container,
+		region:    enc.Region,
+		session:   session,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go r.loop(ctx)
+	return r, nil
+}
+
+// Save writes what the buffer holds to out, a file or a naming template
+// like RecordOptions.Out, and returns the file's name. A name that's taken
+// gets -2, -3, ... added.
+func (r *Replay) Save(out string) (string, error) {
+	select {
+	case <-r.done:
+		return "", ErrStopped
+	default:
+	}
+	path, err := fileName(out, "replay", r.container, r.region, r.session)
+	if err != nil {
+		return "", err
+	}
+	path = naming.Unique(path)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// the buffer may have been closed since the check above
+	select {
+	case <-r.done:
+		return "", ErrStopped
+	default:
+	}
+	if err := r.buf.Save(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Buffered is roughly how much the buffer holds now: it fills up to
+// Window.
+func (r *Replay) Buffered() time.Duration { return r.buf.Buffered() }
+
+// Window is how much the buffer keeps.
+func (r *Replay) Window() time.Duration { return r.buf.Window() }
+
+// Stop ends the capture and deletes the buffer, and returns Wait's error.
+func (r *Replay) Stop() error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	return r.Wait()
+}
+
+// Done is closed once the buffer has stopped, however it stopped.
+func (r *Replay) Done() <-chan struct{} { return r.done }
+
+// Wait blocks until the buffer has stopped. It returns nil when it was
+// stopped, and why the capture failed otherwise.
+func (r *Replay) Wait() error {
+	<-r.done
+	return r.err
+}
+
+// loop prunes the buffer until it's stopped or the capture dies.
+func (r *Replay) loop(ctx context.Context) {
+	prune := time.NewTicker(record.ReplaySegment * time.Second)
+	defer prune.Stop()
+	for stopped := false; !stopped; {
+		select {
+		case <-ctx.Done():
+			stopped = true
+		case <-r.stop:
+			stopped = true
+		case <-r.run.Done():
+			if r.err = r.run.Wait(); r.err == nil {
+				r.err = errors.New("FFmpeg failed: exited early")
+			}
+			stopped = true
+		case <-prune.C:
+			// a failure here is retried on the next tick; one that lasts
+			// fills the disk, which fails the capture
+			r.mu.Lock()
+			r.buf.Prune()
+			r.mu.Unlock()
+		}
+	}
+	r.run.Interrupt()
+	r.run.Wait()
+	// done closes with the buffer, so a Save waiting on mu sees it
+	r.mu.Lock()
+	r.buf.Close()
+	close(r.done)
+	r.mu.Unlock()
+}
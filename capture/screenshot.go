@@ -0,0 +1,58 @@
+package capture
+
+import (
+	"context"
+	"image"
+
+	"github.com/almightynan/swiftcap/internal/backend"
+	"github.com/almightynan/swiftcap/internal/detect"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+)
+
+// ScreenshotOptions says what to grab.
+type ScreenshotOptions struct {
+	Target  Target
+	Cursor  bool   // draw the pointer in, where the backend can
+	Backend string // a name from Backends, or "" to pick the best one here
+}
+
+// Screenshot grabs opts.Target. When ctx ends first it returns ctx's error;
+// a backend that's already grabbing finishes in the background.
+func Screenshot(ctx context.Context, opts ScreenshotOptions) (image.Image, error) {
+	session, err := detect.Session()
+	if err != nil {
+		return nil, err
+	}
+	need := opts.Target.needs()
+	need.Cursor = opts.Cursor
+	shot, err := backend.PickScreenshotter(opts.Backend, session, need)
+	if err != nil {
+		return nil, err
+	}
+	if need.Windows && !shot.Caps().Windows {
+		return nil, scerr.Errorf(scerr.InvalidArgs, "capturing %s needs a backend that can capture windows; %s can't", opts.Target, shot.Name())
+	}
+	region, err := opts.Target.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		img image.Image
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		img, err := shot.Capture(region, opts.Cursor)
+		done <- result{img, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-done:
+		if r.err != nil && scerr.CodeOf(r.err) == scerr.Runtime {
+			r.err = scerr.Wrap(scerr.Screenshot, r.err)
+		}
+		return r.img, r.err
+	}
+}
@@ -0,0 +1,488 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/almightynan/swiftcap/internal/backend"
+	"github.com/almightynan/swiftcap/internal/detect"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/naming"
+	"github.com/almightynan/swiftcap/internal/pulse"
+	"github.com/almightynan/swiftcap/internal/record"
+	"github.com/almightynan/swiftcap/internal/x11"
+)
+
+// State is where a recording is.
+type State string
+
+const (
+	StateRecording State = "recording"
+	StatePaused    State = "paused"
+	StateStopping  State = "stopping" // finishing, joining or exporting the file
+	StateStopped   State = "stopped"
+)
+
+// EventKind says what an Event reports.
+type EventKind string
+
+const (
+	EventStarted   EventKind = "started"
+	EventProgress  EventKind = "progress"
+	EventPaused    EventKind = "paused"
+	EventResumed   EventKind = "resumed"
+	EventExporting EventKind = "exporting" // converting to gif, webp or apng; Message names it
+	EventWarning   EventKind = "warning"
+	EventStopped   EventKind = "stopped" // always the last one
+)
+
+// StopReason says why a recording stopped.
+type StopReason string
+
+const (
+	StopRequested   StopReason = "stop"      // Stop was called
+	StopCancelled   StopReason = "cancelled" // the context ended
+	StopMaxDuration StopReason = "max-dur"   // RecordOptions.MaxDuration was reached
+	StopEnded       StopReason = "done"      // the backend finished by itself
+	StopFailed      StopReason = "failed"    // see Event.Err and Wait
+)
+
+// Progress is how far a recording has got, pauses left out.
+type Progress struct {
+	Frames   int64
+	FPS      float64
+	Bitrate  float64 // kbit/s
+	Size     int64   // bytes written so far
+	Dropped  int64   // frames
+	Duration time.Duration
+}
+
+// Event is something a recording did. Only the fields its Kind mentions
+// are set.
+type Event struct {
+	Kind     EventKind
+	Time     time.Time
+	Out      string     // EventStarted, EventStopped: the file
+	Progress Progress   // EventProgress; not every backend reports it
+	Reason   StopReason // EventStopped
+	Err      error      // EventStopped with StopFailed
+	Message  string     // EventWarning, EventExporting
+}
+
+// events is how many Events a Session holds for a reader that's behind.
+const events = 64
+
+// stopTimeout is how long a backend gets to finish its file before it's
+// killed.
+const stopTimeout = 10 * time.Second
+
+// Session is a recording in progress. Its methods are safe to call from
+// any goroutine.
+type Session struct {
+	rec      backend.Recorder
+	opts     record.Options   // what every capture run records
+	out      string           // the finished file
+	segs     *record.Segments // nil when recording straight to out
+	pausable bool
+
+	events chan Event
+	cmds   chan command
+	done   chan struct{}
+	err    error // set before done is closed
+
+	mu       sync.Mutex
+	state    State
+	run      backend.Recording // nil while paused
+	recorded time.Duration     // finished runs
+	base     record.Progress   // their totals
+	started  time.Time         // of the current run
+}
+
+type op int
+
+const (
+	opPause op = iota
+	opResume
+	opStop
+)
+
+type command struct {
+	op    op
+	reply chan error
+}
+
+// StartRecording starts recording opts.Target to opts.Out and returns once
+// the capture is running. Cancelling ctx stops the recording the way Stop
+// does, keeping what was recorded; while StartRecording is still finding
+// the target (a PickWindow, say) it abandons the start instead.
+func StartRecording(ctx context.Context, opts RecordOptions) (*Session, error) {
+	if opts.Out == "" {
+		return nil, scerr.New(scerr.InvalidArgs, "no output file")
+	}
+	enc, err := opts.encode()
+	if err != nil {
+		return nil, err
+	}
+	session, err := detect.Session()
+	if err != nil {
+		return nil, err
+	}
+	need := opts.Target.needs()
+	need.Audio = enc.Audio && !record.IsAnimated(enc.Container)
+	need.Pause = opts.Pausable
+	rec, err := backend.PickRecorder(opts.Backend, session, need)
+	if err != nil {
+		return nil, err
+	}
+	caps := rec.Caps()
+	if need.Windows && !caps.Windows {
+		return nil, scerr.Errorf(scerr.InvalidArgs, "recording %s needs a backend that can capture windows; %s can't", opts.Target, rec.Name())
+	}
+	s := &Session{
+		rec:      rec,
+		pausable: opts.Pausable && caps.Pause,
+		events:   make(chan Event, events),
+		cmds:     make(chan command),
+		done:     make(chan struct{}),
+		state:    StateRecording,
+	}
+	for _, what := range caps.Lacks(backend.Caps{Regions: need.Regions, Audio: need.Audio}) {
+		msg := fmt.Sprintf("the %s backend can't record %s; recording without", rec.Name(), what)
+		if what == "regions" {
+			msg = fmt.Sprintf("the %s backend can't capture part of the screen; recording all of it", rec.Name())
+		}
+		s.emit(Event{Kind: EventWarning, Message: msg})
+	}
+
+	if caps.Regions {
+		if enc.Region, err = opts.Target.resolve(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if enc.Audio {
+		for i := range enc.ASrc {
+			if enc.ASrc[i].Name, err = pulse.Resolve(enc.ASrc[i].Name); err != nil {
+				return nil, fmt.Errorf("audio source %s: %w", opts.AudioSources[i], err)
+			}
+		}
+	}
+	enc.Display = os.Getenv("DISPLAY")
+	if s.out, err = fileName(enc.Out, "recording", enc.Container, enc.Region, session); err != nil {
+		return nil, err
+	}
+	enc.Out = s.out
+	s.opts = enc
+
+	if s.pausable || record.IsAnimated(enc.Container) || record.Fragmented(enc.Container) {
+		// gif, webp and apng are exported from their segments once joined,
+		// and mp4 and mov, fragmented while capturing, remuxed to regular
+		// faststart files
+		s.segs = record.NewSegments(strings.TrimSuffix(s.out, filepath.Ext(s.out))+".part", enc.Container)
+		s.segs.Animate(enc.Anim)
+		if err := s.segs.Journaled(s.out); err != nil {
+			return nil, fmt.Errorf("failed to write the recording journal: %w", err)
+		}
+	}
+	if err := s.start(); err != nil {
+		if s.segs != nil {
+			s.segs.Discard()
+		}
+		return nil, err
+	}
+	s.emit(Event{Kind: EventStarted, Out: s.out})
+	go s.loop(ctx)
+	return s, nil
+}
+
+// fileName is out, or out filled in when it's a naming template. mode and
+// ext fill {mode} and {ext}; region, on X11, {monitor} and {window_class}.
+func fileName(out, mode, ext, region string, session detect.SessionType) (string, error) {
+	if !naming.IsTemplate(out) {
+		return out, nil
+	}
+	t, err := naming.Parse(out)
+	if err != nil {
+		return "", scerr.Wrap(scerr.InvalidArgs, err)
+	}
+	f := naming.Fields{Time: time.Now(), Mode: mode, Ext: ext}
+	if session == detect.SessionX11 {
+		f.Monitor, f.WindowClass = x11.RegionSource(region)
+	}
+	return t.Create("", f)
+}
+
+// Pause ends the current capture run; Resume starts the next. A recording
+// that isn't Pausable returns ErrNotPausable, and one that has stopped
+// ErrStopped. A run that fails as it ends (a full disk, say) stops the
+// recording: Pause returns the error, and Wait and EventStopped report it.
+func (s *Session) Pause() error { return s.do(opPause) }
+
+// Resume carries on a paused recording.
+func (s *Session) Resume() error { return s.do(opResume) }
+
+// Stop ends the recording, finishes the file and returns Wait's error.
+// Calling it again just waits.
+func (s *Session) Stop() error {
+	select {
+	case s.cmds <- command{op: opStop, reply: make(chan error, 1)}:
+	case <-s.done:
+	}
+	return s.Wait()
+}
+
+// Wait blocks until the recording has stopped, however it stopped, and its
+// file is finished. It returns nil if the file is good.
+func (s *Session) Wait() error {
+	<-s.done
+	return s.err
+}
+
+// Events reports what the recording does, from EventStarted (after any
+// warnings about what the backend can't do) to EventStopped, and is closed
+// after that. It holds a few dozen events for a slow reader; one that falls
+// further behind misses progress first and the oldest events after that.
+func (s *Session) Events() <-chan Event { return s.events }
+
+// Out is the file the recording is written to, with any template filled in.
+func (s *Session) Out() string { return s.out }
+
+// Backend is the name of the backend recording.
+func (s *Session) Backend() string { return s.rec.Name() }
+
+// State is where the recording is now.
+func (s *Session) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// Elapsed is how long has been recorded, pauses left out.
+func (s *Session) Elapsed() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.run != nil {
+		return s.recorded + time.Since(s.started)
+	}
+	return s.recorded
+}
+
+func (s *Session) do(o op) error {
+	c := command{op: o, reply: make(chan error, 1)}
+	select {
+	case s.cmds <- c:
+		return <-c.reply
+	case <-s.done:
+		return ErrStopped
+	}
+}
+
+// emit queues e, dropping progress rather than waiting on a slow reader,
+// and the oldest event when it must keep another.
+func (s *Session) emit(e Event) {
+	e.Time = time.Now()
+	for {
+		select {
+		case s.events <- e:
+			return
+		default:
+		}
+		if e.Kind == EventProgress {
+			return
+		}
+		select {
+		case <-s.events:
+		default:
+		}
+	}
+}
+
+// start begins a capture run, into the next segment if there are any.
+func (s *Session) start() error {
+	o := s.opts.CaptureOptions()
+	if s.segs != nil {
+		o.Out = s.segs.Next()
+	}
+	s.mu.Lock()
+	recorded, prev := s.recorded, s.base
+	s.mu.Unlock()
+	if o.MaxDur > 0 {
+		o.MaxDur = int(math.Ceil(float64(o.MaxDur) - recorded.Seconds()))
+	}
+	r, err := s.rec.Start(o, func(p record.Progress) {
+		p = p.After(prev)
+		s.emit(Event{Kind: EventProgress, Progress: Progress{
+			Frames:   p.Frame,
+			FPS:      p.FPS,
+			Bitrate:  p.Bitrate,
+			Size:     p.Size,
+			Dropped:  p.Dropped,
+			Duration: record.ParseOutTime(p.OutTime),
+		}})
+	})
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.run, s.started = r, time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// stopRun finishes the capture run, killing it if it won't, and banks
+// what it recorded.
+func (s *Session) stopRun() error {
+	r := s.current()
+	r.Interrupt()
+	select {
+	case <-r.Done():
+	case <-time.After(stopTimeout):
+		r.Kill()
+	}
+	err := r.Wait()
+	s.bank()
+	return err
+}
+
+// bank adds the finished run to the totals.
+func (s *Session) bank() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recorded += time.Since(s.started)
+	s.base = s.run.Progress().After(s.base)
+	s.run = nil
+}
+
+func (s *Session) current() backend.Recording {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.run
+}
+
+func (s *Session) setState(st State) {
+	s.mu.Lock()
+	s.state = st
+	s.mu.Unlock()
+}
+
+// loop owns the recording until it stops, then finishes the file.
+func (s *Session) loop(ctx context.Context) {
+	var (
+		reason StopReason
+		err    error
+	)
+	for reason == "" {
+		var runDone <-chan struct{}
+		if r := s.current(); r != nil {
+			runDone = r.Done()
+		}
+		select {
+		case <-ctx.Done():
+			reason = StopCancelled
+		case <-runDone:
+			err = s.current().Wait()
+			s.bank()
+			switch {
+			case err != nil:
+				reason = StopFailed
+			case s.opts.MaxDur > 0:
+				reason = StopMaxDuration
+			default:
+				reason = StopEnded
+			}
+		case c := <-s.cmds:
+			if c.op == opStop {
+				reason = StopRequested
+			}
+			reply, failed := s.handle(c.op)
+			c.reply <- reply
+			if failed != nil {
+				reason, err = StopFailed, failed
+			}
+		}
+	}
+	s.setState(StateStopping)
+	if s.current() != nil {
+		err = s.stopRun()
+	}
+	if err == nil {
+		err = s.finish()
+	}
+	if err != nil {
+		reason = StopFailed
+	}
+	s.err = err
+	s.setState(StateStopped)
+	s.emit(Event{Kind: EventStopped, Out: s.out, Reason: reason, Err: err})
+	close(s.events)
+	close(s.done)
+}
+
+// handle carries out o for the loop. reply is the caller's answer; failed
+// is set when the capture run failed on the way, which ends the recording.
+func (s *Session) handle(o op) (reply, failed error) {
+	switch o {
+	case opPause:
+		if !s.pausable {
+			return s.notPausable(), nil
+		}
+		if s.State() != StateRecording {
+			return errors.New("not recording"), nil
+		}
+		if err := s.stopRun(); err != nil {
+			return err, err
+		}
+		s.setState(StatePaused)
+		s.emit(Event{Kind: EventPaused})
+	case opResume:
+		if !s.pausable {
+			return s.notPausable(), nil
+		}
+		if s.State() != StatePaused {
+			return errors.New("not paused"), nil
+		}
+		if s.opts.MaxDur > 0 && s.Elapsed() >= time.Duration(s.opts.MaxDur)*time.Second {
+			return errors.New("the maximum duration is already reached"), nil
+		}
+		if err := s.start(); err != nil {
+			return err, nil
+		}
+		s.setState(StateRecording)
+		s.emit(Event{Kind: EventResumed})
+	}
+	return nil, nil
+}
+
+func (s *Session) notPausable() error {
+	if !s.rec.Caps().Pause {
+		return fmt.Errorf("%w: the %s backend can't", ErrNotPausable, s.rec.Name())
+	}
+	return fmt.Errorf("%w: it wasn't started Pausable", ErrNotPausable)
+}
+
+// finish joins the segments into the file and exports gif, webp and apng.
+// On failure the segments are kept, and `swiftcap recover` can retry.
+func (s *Session) finish() error {
+	if s.segs == nil {
+		return nil
+	}
+	animated := record.IsAnimated(s.opts.Container)
+	if animated {
+		s.emit(Event{Kind: EventExporting, Message: strings.ToUpper(s.opts.Container)})
+	}
+	if err := s.segs.Join(s.out); err != nil {
+		return err
+	}
+	if size, over := s.opts.Anim.OverBudget(s.out); animated && over {
+		s.emit(Event{Kind: EventWarning, Message: fmt.Sprintf(
+			"%s is %.1f MiB, over the %.1f MiB size budget; try a lower frame rate or width, or a shorter clip",
+			s.out, float64(size)/(1<<20), float64(s.opts.Anim.Budget)/(1<<20))})
+	}
+	return nil
+}
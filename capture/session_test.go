@@ -0,0 +1,360 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/almightynan/swiftcap/internal/backend"
+	"github.com/almightynan/swiftcap/internal/detect"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/record"
+)
+
+// fakeRecorder hands out capture runs that end when they're told to.
+type fakeRecorder struct {
+	caps backend.Caps
+	// stopErr is what a run reports once it's interrupted.
+	stopErr error
+
+	mu   sync.Mutex
+	runs []*fakeRun
+}
+
+func (f *fakeRecorder) Name() string                            { return "fake" }
+func (f *fakeRecorder) Caps() backend.Caps                      { return f.caps }
+func (f *fakeRecorder) Usable(session detect.SessionType) error { return nil }
+
+func (f *fakeRecorder) Start(o record.Options, onProgress func(record.Progress)) (backend.Recording, error) {
+	if err := os.WriteFile(o.Out, []byte("frames"), 0o644); err != nil {
+		return nil, err
+	}
+	r := &fakeRun{rec: f, opts: o, done: make(chan struct{})}
+	f.mu.Lock()
+	f.runs = append(f.runs, r)
+	f.mu.Unlock()
+	return r, nil
+}
+
+// run is the i'th capture run started.
+func (f *fakeRecorder) run(i int) *fakeRun {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.runs[i]
+}
+
+func (f *fakeRecorder) started() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.runs)
+}
+
+type fakeRun struct {
+	rec  *fakeRecorder
+	opts record.Options
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+// end finishes the run with err, as if the capture had exited.
+func (r *fakeRun) end(err error) {
+	r.once.Do(func() {
+		r.err = err
+		close(r.done)
+	})
+}
+
+func (r *fakeRun) Interrupt()                { r.end(r.rec.stopErr) }
+func (r *fakeRun) Kill()                     { r.end(errors.New("killed")) }
+func (r *fakeRun) Done() <-chan struct{}     { return r.done }
+func (r *fakeRun) Progress() record.Progress { return record.Progress{} }
+
+func (r *fakeRun) Wait() error {
+	<-r.done
+	return r.err
+}
+
+// startFake starts a Session on rec the way StartRecording does once it has
+// picked a backend. It records straight to opts.Out unless segs is set.
+func startFake(t *testing.T, ctx context.Context, rec *fakeRecorder, opts record.Options, pausable bool, segs *record.Segments) *Session {
+	t.Helper()
+	if opts.Out == "" {
+		opts.Out = filepath.Join(t.TempDir(), "out.mkv")
+	}
+	if opts.Container == "" {
+		opts.Container = "mkv"
+	}
+	s := &Session{
+		rec:      rec,
+		opts:     opts,
+		out:      opts.Out,
+		segs:     segs,
+		pausable: pausable && rec.caps.Pause,
+		events:   make(chan Event, events),
+		cmds:     make(chan command),
+		done:     make(chan struct{}),
+		state:    StateRecording,
+	}
+	if err := s.start(); err != nil {
+		t.Fatal(err)
+	}
+	s.emit(Event{Kind: EventStarted, Out: s.out})
+	go s.loop(ctx)
+	return s
+}
+
+// kinds drains the events of a stopped session.
+func kinds(s *Session) (got []EventKind, last Event) {
+	for e := range s.Events() {
+		got = append(got, e.Kind)
+		last = e
+	}
+	return got, last
+}
+
+func TestSessionPauseResume(t *testing.T) {
+	rec := &fakeRecorder{caps: backend.Caps{Pause: true}}
+	s := startFake(t, context.Background(), rec, record.Options{}, true, nil)
+
+	if err := s.Resume(); err == nil {
+		t.Error("Resume while recording succeeded")
+	}
+	if err := s.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	if st := s.State(); st != StatePaused {
+		t.Errorf("State after Pause = %s", st)
+	}
+	if err := s.Pause(); err == nil {
+		t.Error("Pause while paused succeeded")
+	}
+	if err := s.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if n := rec.started(); n != 2 {
+		t.Errorf("%d capture runs, want 2", n)
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if st := s.State(); st != StateStopped {
+		t.Errorf("State after Stop = %s", st)
+	}
+
+	// the session is over
+	if err := s.Pause(); !errors.Is(err, ErrStopped) {
+		t.Errorf("Pause after Stop = %v, want ErrStopped", err)
+	}
+	if err := s.Resume(); !errors.Is(err, ErrStopped) {
+		t.Errorf("Resume after Stop = %v, want ErrStopped", err)
+	}
+	if err := s.Stop(); err != nil {
+		t.Errorf("second Stop = %v", err)
+	}
+
+	got, last := kinds(s)
+	want := []EventKind{EventStarted, EventPaused, EventResumed, EventStopped}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("events %v, want %v", got, want)
+	}
+	if last.Reason != StopRequested || last.Err != nil || last.Out != s.Out() {
+		t.Errorf("EventStopped = %+v", last)
+	}
+	if _, open := <-s.Events(); open {
+		t.Error("Events is still open")
+	}
+}
+
+func TestSessionNotPausable(t *testing.T) {
+	tests := []struct {
+		name     string
+		caps     backend.Caps
+		pausable bool
+	}{
+		{"backend can't", backend.Caps{}, true},
+		{"not asked for", backend.Caps{Pause: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &fakeRecorder{caps: tt.caps}
+			s := startFake(t, context.Background(), rec, record.Options{}, tt.pausable, nil)
+			defer s.Stop()
+			if err := s.Pause(); !errors.Is(err, ErrNotPausable) {
+				t.Errorf("Pause = %v, want ErrNotPausable", err)
+			}
+			if err := s.Resume(); !errors.Is(err, ErrNotPausable) {
+				t.Errorf("Resume = %v, want ErrNotPausable", err)
+			}
+			if st := s.State(); st != StateRecording {
+				t.Errorf("State = %s, want recording", st)
+			}
+		})
+	}
+}
+
+func TestSessionStops(t *testing.T) {
+	diskFull := scerr.New(scerr.DiskFull, "No space left on device")
+	tests := []struct {
+		name   string
+		maxDur int
+		// stop ends the recording and returns what the call it made did
+		stop    func(s *Session, rec *fakeRecorder, cancel context.CancelFunc) error
+		stopErr error
+		reason  StopReason
+		wantErr error
+	}{
+		{
+			name:   "stop",
+			stop:   func(s *Session, _ *fakeRecorder, _ context.CancelFunc) error { return s.Stop() },
+			reason: StopRequested,
+		},
+		{
+			name:   "cancelled",
+			stop:   func(s *Session, _ *fakeRecorder, cancel context.CancelFunc) error { cancel(); return s.Wait() },
+			reason: StopCancelled,
+		},
+		{
+			name:   "ended",
+			stop:   func(s *Session, rec *fakeRecorder, _ context.CancelFunc) error { rec.run(0).end(nil); return s.Wait() },
+			reason: StopEnded,
+		},
+		{
+			name:   "max duration",
+			maxDur: 5,
+			stop:   func(s *Session, rec *fakeRecorder, _ context.CancelFunc) error { rec.run(0).end(nil); return s.Wait() },
+			reason: StopMaxDuration,
+		},
+		{
+			name: "capture failed",
+			stop: func(s *Session, rec *fakeRecorder, _ context.CancelFunc) error {
+				rec.run(0).end(diskFull)
+				return s.Wait()
+			},
+			reason:  StopFailed,
+			wantErr: diskFull,
+		},
+		{
+			name:    "failed stopping",
+			stop:    func(s *Session, _ *fakeRecorder, _ context.CancelFunc) error { return s.Stop() },
+			stopErr: diskFull,
+			reason:  StopFailed,
+			wantErr: diskFull,
+		},
+		{
+			name:    "failed pausing",
+			stop:    func(s *Session, _ *fakeRecorder, _ context.CancelFunc) error { return s.Pause() },
+			stopErr: diskFull,
+			reason:  StopFailed,
+			wantErr: diskFull,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			rec := &fakeRecorder{caps: backend.Caps{Pause: true}, stopErr: tt.stopErr}
+			s := startFake(t, ctx, rec, record.Options{MaxDur: tt.maxDur}, true, nil)
+			if err := tt.stop(s, rec, cancel); !errors.Is(err, tt.wantErr) {
+				t.Errorf("stopping returned %v, want %v", err, tt.wantErr)
+			}
+			if err := s.Wait(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Wait = %v, want %v", err, tt.wantErr)
+			}
+			_, last := kinds(s)
+			if last.Kind != EventStopped || last.Reason != tt.reason || !errors.Is(last.Err, tt.wantErr) {
+				t.Errorf("last event %+v, want stopped with %s", last, tt.reason)
+			}
+			if st := s.State(); st != StateStopped {
+				t.Errorf("State = %s", st)
+			}
+		})
+	}
+}
+
+func TestSessionSegments(t *testing.T) {
+	rec := &fakeRecorder{caps: backend.Caps{Pause: true}}
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	out := filepath.Join(t.TempDir(), "out.mkv")
+	segs := record.NewSegments(out+".part", "mkv")
+	if err := segs.Journaled(out); err != nil {
+		t.Fatal(err)
+	}
+	s := startFake(t, context.Background(), rec, record.Options{Out: out, Container: "mkv"}, true, segs)
+	if seg := rec.run(0).opts.Out; seg == out {
+		t.Errorf("a segmented recording captured straight to %s", out)
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(out); err != nil || string(b) != "frames" {
+		t.Errorf("%s = %q, %v after Stop", out, b, err)
+	}
+	if orphans, err := record.Orphans(); err != nil || len(orphans) != 0 {
+		t.Errorf("Orphans = %v, %v after Stop", orphans, err)
+	}
+}
+
+func TestSessionElapsed(t *testing.T) {
+	rec := &fakeRecorder{caps: backend.Caps{Pause: true}}
+	s := startFake(t, context.Background(), rec, record.Options{}, true, nil)
+	defer s.Stop()
+	time.Sleep(20 * time.Millisecond)
+	if err := s.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	paused := s.Elapsed()
+	if paused < 20*time.Millisecond {
+		t.Errorf("Elapsed = %s after 20ms", paused)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if e := s.Elapsed(); e != paused {
+		t.Errorf("Elapsed went from %s to %s while paused", paused, e)
+	}
+}
+
+func TestReplaySaveAfterStop(t *testing.T) {
+	buf, err := record.NewReplayBuffer(time.Minute, "mkv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &fakeRecorder{}
+	run, err := rec.Start(buf.Options(record.Options{Out: filepath.Join(t.TempDir(), "first.mkv")}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Replay{buf: buf, run: run, container: "mkv", stop: make(chan struct{}), done: make(chan struct{})}
+	go r.loop(context.Background())
+	if err := r.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Save(filepath.Join(t.TempDir(), "replay.mkv")); !errors.Is(err, ErrStopped) {
+		t.Errorf("Save after Stop = %v, want ErrStopped", err)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, 0},
+		{context.Canceled, 12},
+		{fmt.Errorf("picking a window: %w", context.Canceled), 12},
+		{context.DeadlineExceeded, 25},
+		{scerr.New(scerr.DiskFull, "No space left on device"), 24},
+		{scerr.Wrap(scerr.PortalDenied, context.Canceled), 11},
+		{fmt.Errorf("audio source mic: %w", scerr.New(scerr.Pulse, "no such source")), 23},
+		{errors.New("something else"), 100},
+	}
+	for _, tt := range tests {
+		if got := ExitCode(tt.err); got != tt.want {
+			t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/almightynan/swiftcap/internal/backend"
+	"github.com/almightynan/swiftcap/internal/detect"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/shoot"
+	"github.com/almightynan/swiftcap/internal/wayland"
+	"github.com/almightynan/swiftcap/internal/x11"
+)
+
+// Target is what to capture. The zero Target is the whole screen; the
+// functions below make the others.
+type Target struct {
+	kind    targetKind
+	rect    image.Rectangle
+	spec    string // monitor spec, or window id or class
+	noDecor bool
+}
+
+type targetKind int
+
+const (
+	targetScreen targetKind = iota
+	targetRegion
+	targetMonitor
+	targetActiveWindow
+	targetPickWindow
+	targetWindow
+)
+
+// FullScreen is the whole screen, every monitor of it.
+func FullScreen() Target { return Target{} }
+
+// Region is a rectangle in screen coordinates. An empty one is FullScreen.
+func Region(r image.Rectangle) Target {
+	if r.Empty() {
+		return FullScreen()
+	}
+	return Target{kind: targetRegion, rect: r.Canon()}
+}
+
+// Monitor is one monitor, by output name ("DP-1"), index as `swiftcap
+// monitors` lists them, "primary", or "focused" for the one under the
+// pointer. Wayland has neither a primary nor a pointer clients can see, so
+// there both are the first output.
+func Monitor(spec string) Target { return Target{kind: targetMonitor, spec: spec} }
+
+// ActiveWindow is the window that has focus when the capture starts.
+func ActiveWindow() Target { return Target{kind: targetActiveWindow} }
+
+// PickWindow lets the user click the window to capture; Esc cancels, which
+// fails the capture with ExitCode 12.
+func PickWindow() Target { return Target{kind: targetPickWindow} }
+
+// Window is a window by X11 id (decimal or 0x hex) or WM_CLASS.
+func Window(idOrClass string) Target { return Target{kind: targetWindow, spec: idOrClass} }
+
+// WithoutDecorations leaves out the title bar and borders the window
+// manager draws round a window target. Other targets ignore it.
+func (t Target) WithoutDecorations() Target {
+	t.noDecor = true
+	return t
+}
+
+// IsWindow reports whether t is one of the window targets.
+func (t Target) IsWindow() bool {
+	return t.kind == targetActiveWindow || t.kind == targetPickWindow || t.kind == targetWindow
+}
+
+// String describes t, e.g. "window:firefox" or "1280x720+0+0".
+func (t Target) String() string {
+	switch t.kind {
+	case targetRegion:
+		return regionString(t.rect)
+	case targetMonitor:
+		return "monitor:" + t.spec
+	case targetActiveWindow:
+		return "active-window"
+	case targetPickWindow:
+		return "pick-window"
+	case targetWindow:
+		return "window:" + t.spec
+	}
+	return "fullscreen"
+}
+
+// Resolve returns the rectangle t covers right now, in screen coordinates;
+// an empty one is the whole screen. Monitors are looked up on the X server
+// or the Wayland compositor, windows on the X server only. A PickWindow
+// waits for the click, or for ctx.
+func (t Target) Resolve(ctx context.Context) (image.Rectangle, error) {
+	region, err := t.resolve(ctx)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	return shoot.ParseRegion(region)
+}
+
+// resolve is Resolve as a WxH+X+Y region, "" for the whole screen.
+func (t Target) resolve(ctx context.Context) (string, error) {
+	switch t.kind {
+	case targetScreen:
+		return "", nil
+	case targetRegion:
+		return regionString(t.rect), nil
+	case targetMonitor:
+		if session, _ := detect.Session(); session == detect.SessionWayland {
+			o, err := wayland.ResolveOutput(t.spec)
+			if err != nil {
+				return "", targetError(err)
+			}
+			return o.Region(), nil
+		}
+		m, err := x11.ResolveMonitor(t.spec)
+		if err != nil {
+			return "", targetError(err)
+		}
+		return m.Region(), nil
+	}
+	spec := map[targetKind]string{
+		targetActiveWindow: "active-window",
+		targetPickWindow:   "pick-window",
+		targetWindow:       "window:" + t.spec,
+	}[t.kind]
+	w, err := x11.ResolveWindowContext(ctx, spec, !t.noDecor)
+	if err != nil {
+		return "", targetError(err)
+	}
+	return w.Region(), nil
+}
+
+// needs is what capturing t asks of a backend.
+func (t Target) needs() backend.Caps {
+	return backend.Caps{Regions: t.kind != targetScreen, Windows: t.IsWindow()}
+}
+
+// targetError files a failed lookup: backing out of pick-window is a
+// cancel, no X server or compositor is no display, and the rest are bad
+// targets.
+func targetError(err error) error {
+	switch {
+	case errors.Is(err, x11.ErrPickCancelled), errors.Is(err, context.Canceled):
+		return scerr.Wrap(scerr.Cancelled, err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return scerr.Wrap(scerr.Timeout, err)
+	case errors.Is(err, x11.ErrNoDisplay), errors.Is(err, wayland.ErrNoDisplay):
+		return scerr.Wrap(scerr.NoDisplay, err)
+	}
+	return scerr.Wrap(scerr.InvalidArgs, err)
+}
+
+// ParseRegion reads the WxH+X+Y form the CLI's --region takes.
+func ParseRegion(s string) (image.Rectangle, error) {
+	r, err := shoot.ParseRegion(s)
+	return r, scerr.Wrap(scerr.InvalidArgs, err)
+}
+
+func regionString(r image.Rectangle) string {
+	return fmt.Sprintf("%dx%d+%d+%d", r.Dx(), r.Dy(), r.Min.X, r.Min.Y)
+}
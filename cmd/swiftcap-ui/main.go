@@ -3,7 +3,7 @@ package main
 import (
 	"log"
 
-	"swiftcap/internal/uiapp"
+	"github.com/almightynan/swiftcap/internal/uiapp"
 )
 
 func main() {
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/almightynan/swiftcap/internal/cli"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/pulse"
+)
+
+// audioSourcesMain lists what --a-src can record: inputs and the monitors
+// of every output, straight from the sound server.
+func audioSourcesMain(cfg cli.Config) {
+	srcs, _, err := pulse.ListSources()
+	if err != nil {
+		fail(scerr.Pulse, err.Error())
+	}
+	if cfg.JSON {
+		if srcs == nil {
+			srcs = []pulse.Source{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(srcs)
+		return
+	}
+	for _, s := range srcs {
+		mark := " "
+		if s.Default {
+			mark = "*"
+		}
+		kind := "input"
+		if s.MonitorOf != "" {
+			kind = "monitor"
+		}
+		alias := ""
+		if s.Alias != "" {
+			alias = "  (" + s.Alias + ")"
+		}
+		fmt.Printf("%s %-8s %s%s\n    %s, %s\n", mark, kind, s.Name, alias, s.Description, s.Spec)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/almightynan/swiftcap/internal/backend"
+	"github.com/almightynan/swiftcap/internal/cli"
+	"github.com/almightynan/swiftcap/internal/detect"
+)
+
+// backendInfo is one backend as `swiftcap backends --json` lists it.
+type backendInfo struct {
+	Name   string       `json:"name"`
+	Caps   backend.Caps `json:"caps"`
+	Usable bool         `json:"usable"`
+	Why    string       `json:"why,omitempty"` // why it isn't usable
+	Auto   bool         `json:"auto"`          // what --backend auto picks with no other needs
+}
+
+// backendsMain lists the capture backends, what each can do and whether it
+// works in this session.
+func backendsMain(cfg cli.Config) {
+	session, _ := detect.Session()
+	var recs, shots []backendInfo
+	auto, _ := backend.PickRecorder(backend.Auto, session, backend.Caps{})
+	for _, b := range backend.Recorders() {
+		recs = append(recs, describeBackend(b.Name(), b.Caps(), b.Usable(session), auto != nil && auto.Name() == b.Name()))
+	}
+	autoShot, _ := backend.PickScreenshotter(backend.Auto, session, backend.Caps{})
+	for _, b := range backend.Screenshotters() {
+		shots = append(shots, describeBackend(b.Name(), b.Caps(), b.Usable(session), autoShot != nil && autoShot.Name() == b.Name()))
+	}
+	if cfg.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(struct {
+			Recorders      []backendInfo `json:"recorders"`
+			Screenshotters []backendInfo `json:"screenshotters"`
+		}{recs, shots})
+		return
+	}
+	printBackends("Recording", recs)
+	fmt.Println()
+	printBackends("Screenshots", shots)
+}
+
+func describeBackend(name string, caps backend.Caps, err error, auto bool) backendInfo {
+	b := backendInfo{Name: name, Caps: caps, Usable: err == nil, Auto: auto}
+	if err != nil {
+		b.Why = err.Error()
+	}
+	return b
+}
+
+func printBackends(title string, all []backendInfo) {
+	fmt.Println(title + ":")
+	for _, b := range all {
+		mark := " "
+		if b.Auto {
+			mark = "*"
+		}
+		can := strings.Join(backend.Caps{}.Lacks(b.Caps), ", ")
+		if can == "" {
+			can = "whole screen only"
+		}
+		line := fmt.Sprintf("%s %-20s %s", mark, b.Name, can)
+		if !b.Usable {
+			line = fmt.Sprintf("\033[2m%s  (%s)\033[0m", line, b.Why)
+		}
+		fmt.Println(line)
+	}
+}
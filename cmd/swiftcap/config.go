@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/almightynan/swiftcap/internal/backend"
+	"github.com/almightynan/swiftcap/internal/cli"
+	"github.com/almightynan/swiftcap/internal/config"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/record"
+	"github.com/almightynan/swiftcap/internal/shoot"
+)
+
+// configMain is `swiftcap config show|validate`. show prints the settings a
+// capture with the same flags would use and where each came from; validate
+// checks every profile in config.toml.
+func configMain(cfg cli.Config) {
+	sub := ""
+	if len(cfg.Args) > 0 {
+		sub = cfg.Args[0]
+	}
+	switch sub {
+	case "show":
+		configShow(cfg)
+	case "validate":
+		configValidate()
+	default:
+		fail(scerr.InvalidArgs, "usage: swiftcap config show|validate [--profile <name>] [--json]")
+	}
+}
+
+func configShow(cfg cli.Config) {
+	path := config.Path()
+	file, err := config.Load(path)
+	if err != nil {
+		fail(scerr.InvalidArgs, err.Error())
+	}
+	if cfg.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(struct {
+			Path     string           `json:"path"`
+			Profile  string           `json:"profile,omitempty"`
+			Profiles []string         `json:"profiles"`
+			Settings []config.Setting `json:"settings"`
+		}{path, cfg.Profile, file.Names(), cfg.Settings})
+		return
+	}
+	fmt.Printf("# %s\n", path)
+	if names := file.Names(); len(names) > 0 {
+		fmt.Printf("# profiles: %s\n", strings.Join(names, ", "))
+	}
+	for _, s := range cfg.Settings {
+		fmt.Printf("%-13s %-16s %s\n", s.Name, s.Value, s.Origin)
+	}
+}
+
+// configValidate loads config.toml and runs each profile through the same
+// checks a recording and a screenshot would.
+func configValidate() {
+	path := config.Path()
+	file, err := config.Load(path)
+	if err != nil {
+		fail(scerr.InvalidArgs, err.Error())
+	}
+	if len(file.Profiles) == 0 {
+		fmt.Printf("%s: no profiles to check\n", path)
+		return
+	}
+	bad := 0
+	for _, name := range file.Names() {
+		if err := validateProfile(name); err != nil {
+			fmt.Fprintf(os.Stderr, "\033[1;31m%s:\033[0m %v\n", name, err)
+			bad++
+			continue
+		}
+		fmt.Printf("%s: ok\n", name)
+	}
+	if bad > 0 {
+		fail(scerr.InvalidArgs, fmt.Sprintf("%s: %d of %d profile(s) broken", path, bad, len(file.Profiles)))
+	}
+	fmt.Printf("%s: %d profile(s) valid\n", path, len(file.Profiles))
+}
+
+func validateProfile(name string) error {
+	cfg, err := cli.Parse([]string{"record", "--out", "check.mp4", "--profile", name})
+	if err != nil {
+		return err
+	}
+	if !backend.Known(cfg.Backend) {
+		return fmt.Errorf("unknown backend %q, see swiftcap backends", cfg.Backend)
+	}
+	if _, err := recordOptions(cfg); err != nil {
+		return err
+	}
+	budget, _ := record.ParseSize(cfg.SizeBudget)
+	anim := record.AnimOptions{Fps: cfg.AnimFps, Width: cfg.AnimWidth, Dither: cfg.Dither, Loop: cfg.Loop, Budget: budget}
+	if err := anim.Validate(); err != nil { // checked for every profile, not just gif ones
+		return err
+	}
+	shot := shoot.Options{Format: cfg.Format, Quality: cfg.Quality, Compression: cfg.Compression}
+	return shot.Validate()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/almightynan/swiftcap/internal/cli"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/record"
+)
+
+// ctlCall is a control request waiting for the record loop to answer it.
+type ctlCall struct {
+	req   record.ControlRequest
+	reply chan record.ControlReply
+}
+
+// serveControl opens --control-socket and hands its requests to the record
+// loop over the returned channel, which is nil (never ready) without a
+// socket. close may be called more than once.
+func serveControl(path string) (<-chan ctlCall, func(), error) {
+	if path == "" {
+		return nil, func() {}, nil
+	}
+	calls := make(chan ctlCall)
+	done := make(chan struct{})
+	srv, err := record.ServeControl(path, func(req record.ControlRequest) record.ControlReply {
+		c := ctlCall{req: req, reply: make(chan record.ControlReply, 1)}
+		select {
+		case calls <- c:
+			return <-c.reply
+		case <-done:
+			return record.ControlReply{State: "stopping", Error: "recording is finishing"}
+		}
+	})
+	if err != nil {
+		return nil, nil, scerr.Errorf(scerr.InvalidArgs, "--control-socket: %v", err)
+	}
+	var once sync.Once
+	return calls, func() {
+		once.Do(func() {
+			close(done)
+			srv.Close()
+		})
+	}, nil
+}
+
+// saveMarkers writes the markers set over the control socket next to out,
+// as <out>.markers.json. no markers, no file.
+func saveMarkers(out string, markers []record.Marker) error {
+	if len(markers) == 0 {
+		return nil
+	}
+	b, err := json.MarshalIndent(markers, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(out+".markers.json", append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to save markers: %w", err)
+	}
+	return nil
+}
+
+// ctlMain sends one command to a running `swiftcap record --control-socket`.
+func ctlMain(cfg cli.Config) {
+	path := cfg.ControlSocket
+	if path == "" {
+		path = os.Getenv("SWIFTCAP_CONTROL_SOCKET")
+	}
+	if len(cfg.Args) == 0 || path == "" {
+		fail(scerr.InvalidArgs, "usage: swiftcap ctl status|pause|resume|stop|marker [label]|save-replay [file] --control-socket PATH")
+	}
+	req := record.ControlRequest{Cmd: cfg.Args[0]}
+	switch req.Cmd {
+	case record.CtlMarker:
+		req.Label = strings.Join(cfg.Args[1:], " ")
+	case record.CtlSaveReplay:
+		if len(cfg.Args) > 1 {
+			req.Out = cfg.Args[1]
+			if abs, err := filepath.Abs(req.Out); err == nil {
+				req.Out = abs // the recorder may run somewhere else
+			}
+		}
+	}
+	reply, err := record.SendControl(path, req)
+	if err != nil {
+		fail(scerr.CtlUnreachable, fmt.Sprintf("no recorder on %s: %v", path, err))
+	}
+	if cfg.JSON {
+		json.NewEncoder(os.Stdout).Encode(reply)
+	} else if reply.OK && req.Cmd == record.CtlSaveReplay {
+		fmt.Println("Replay saved to", reply.Out)
+	} else if reply.OK {
+		elapsed := (time.Duration(reply.Elapsed * float64(time.Second))).Truncate(time.Second)
+		fmt.Printf("%s  %s  segments: %d  markers: %d  %s\n", reply.State, elapsed, reply.Segments, reply.Markers, reply.Out)
+		if m := reply.Marker; m != nil {
+			fmt.Printf("marker at %.3fs %s\n", m.At, m.Label)
+		}
+	}
+	if !reply.OK {
+		fail(scerr.CtlRefused, reply.Error)
+	}
+}
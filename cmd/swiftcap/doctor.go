@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/almightynan/swiftcap/internal/cli"
+	"github.com/almightynan/swiftcap/internal/doctor"
+	"github.com/almightynan/swiftcap/internal/naming"
+)
+
+var doctorColors = map[doctor.Status]string{
+	doctor.Pass: "\033[1;32m",
+	doctor.Warn: "\033[1;33m",
+	doctor.Fail: "\033[1;31m",
+}
+
+// doctorMain checks what captures need from this system and exits with the
+// first failure's code, so it can gate a script.
+func doctorMain(cfg cli.Config) {
+	r := doctor.Run(doctor.Options{Dirs: doctorDirs(cfg)})
+	if cfg.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(r)
+	} else {
+		for _, c := range r.Checks {
+			fmt.Printf("%s%-4s\033[0m  %-18s %s\n", doctorColors[c.Status], c.Status, c.Name, c.Detail)
+			if c.Hint != "" && c.Status != doctor.Pass {
+				fmt.Printf("      %-18s \033[2m-> %s\033[0m\n", "", c.Hint)
+			}
+		}
+	}
+	if c, ok := r.Failed(); ok {
+		n := 0
+		for _, c := range r.Checks {
+			if c.Status == doctor.Fail {
+				n++
+			}
+		}
+		fail(c.Code, fmt.Sprintf("%d check(s) failed, first: %s", n, c.Name))
+	}
+}
+
+// doctorDirs are where the CLI writes: the working directory and the
+// folder --out (or the profile's out) names.
+func doctorDirs(cfg cli.Config) []doctor.Dir {
+	dirs := []doctor.Dir{{Name: "working dir", Path: "."}}
+	out := cfg.Out
+	if naming.IsTemplate(out) {
+		// only the part before the first field is fixed
+		out = out[:strings.Index(out, "{")]
+	}
+	if dir := filepath.Dir(out); out != "" && out != "-" && dir != "." {
+		dirs = append(dirs, doctor.Dir{Name: "--out dir", Path: dir})
+	}
+	return dirs
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/almightynan/swiftcap/internal/cli"
+	"github.com/almightynan/swiftcap/internal/edit"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/naming"
+	"github.com/almightynan/swiftcap/internal/record"
+)
+
+// editMain trims, crops, scales or speeds up one finished file.
+//
+//	swiftcap edit in.mp4 --out out.mp4 [--trim A-B] [--crop WxH+X+Y] [--scale WxH] [--speed N] [--precise]
+func editMain(cfg cli.Config) {
+	if len(cfg.Args) != 1 {
+		fail(scerr.InvalidArgs, "edit takes one input file")
+	}
+	in := cfg.Args[0]
+	if sameFile(in, cfg.Out) {
+		fail(scerr.InvalidArgs, "--out must differ from the input")
+	}
+	var e edit.Edit
+	var err error
+	if cfg.Trim != "" {
+		if e.Trim, err = edit.ParseTrim(cfg.Trim); err != nil {
+			fail(scerr.InvalidArgs, err.Error())
+		}
+	}
+	if cfg.Crop != "" {
+		c, err := edit.ParseCrop(cfg.Crop)
+		if err != nil {
+			fail(scerr.InvalidArgs, err.Error())
+		}
+		e.Crop = &c
+	}
+	if cfg.Scale != "" {
+		s, err := edit.ParseScale(cfg.Scale)
+		if err != nil {
+			fail(scerr.InvalidArgs, err.Error())
+		}
+		e.Scale = &s
+	}
+	e.Speed = cfg.Speed
+	e.Precise = cfg.Precise
+	if err := e.Validate(); err != nil {
+		fail(scerr.InvalidArgs, err.Error())
+	}
+	opts, err := encodeOptions(cfg)
+	if err != nil {
+		fail(scerr.InvalidArgs, err.Error())
+	}
+	if _, err := os.Stat(in); err != nil {
+		fail(scerr.InvalidArgs, err.Error())
+	}
+
+	if !e.CanCopy() {
+		fmt.Printf("Re-encoding with %s...\n", opts.Codec)
+	}
+	res, err := edit.Apply(in, cfg.Out, e, opts.Encoding())
+	if err != nil {
+		failErr(err)
+	}
+	fmt.Println("Saved to", cfg.Out)
+	if res.Copied && res.Start != e.Trim.Start {
+		fmt.Printf("Cut at the keyframe at %s, %.2fs before the trim start; add --precise for an exact cut.\n",
+			edit.FormatTime(res.Start), (e.Trim.Start - res.Start).Seconds())
+	}
+}
+
+// concatMain joins files end to end.
+//
+//	swiftcap concat a.mp4 b.mp4 ... [--out joined.mp4]
+func concatMain(cfg cli.Config) {
+	if len(cfg.Args) < 2 {
+		fail(scerr.InvalidArgs, "concat takes two or more input files")
+	}
+	out := cfg.Out
+	if out == "" {
+		first := cfg.Args[0]
+		out = naming.Unique(strings.TrimSuffix(first, filepath.Ext(first)) + "-joined" + filepath.Ext(first))
+	}
+	for _, in := range cfg.Args {
+		if sameFile(in, out) {
+			fail(scerr.InvalidArgs, "--out must differ from the inputs")
+		}
+	}
+	opts, err := encodeOptions(cfg)
+	if err != nil {
+		fail(scerr.InvalidArgs, err.Error())
+	}
+	copied, err := edit.Concat(cfg.Args, out, opts.Encoding())
+	if err != nil {
+		failErr(err)
+	}
+	how := "stream copy"
+	if !copied {
+		how = "re-encoded with " + opts.Codec + "; the inputs differ"
+	}
+	fmt.Printf("Saved to %s (%s)\n", out, how)
+}
+
+// encodeOptions is what edit and concat re-encode with: the recorder's
+// encoder flags, at the codec's default CRF unless a rate is given.
+func encodeOptions(cfg cli.Config) (record.Options, error) {
+	o := record.DefaultOptions()
+	o.Container = cfg.Container
+	o.Codec = cfg.Codec
+	o.Preset = cfg.Preset
+	o.CRF = cfg.Crf
+	o.QP = cfg.Qp
+	o.Bitrate = cfg.Bitrate
+	o.Threads = cfg.Threads
+	o.Audio = true // whatever audio the input has is carried over
+	o.AudioCodec = cfg.ACodec
+	o.AudioBitrate = cfg.ABitrate
+	if record.IsAnimated(o.Container) {
+		return o, fmt.Errorf("edit and concat write video files; make a %s with record --container %s", o.Container, o.Container)
+	}
+	if enc, ok := record.LookupEncoder(o.Codec); ok && o.CRF < 0 && o.QP <= 0 && o.Bitrate <= 0 {
+		o.CRF = enc.DefaultCRF
+		if enc.MaxCRF == 0 {
+			o.CRF = -1
+		}
+	}
+	return o, o.Validate()
+}
+
+// sameFile reports whether a and b name the same file, existing or not.
+func sameFile(a, b string) bool {
+	sa, errA := os.Stat(a)
+	sb, errB := os.Stat(b)
+	if errA == nil && errB == nil {
+		return os.SameFile(sa, sb)
+	}
+	absA, _ := filepath.Abs(a)
+	absB, _ := filepath.Abs(b)
+	return absA == absB
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/almightynan/swiftcap/internal/backend"
+	"github.com/almightynan/swiftcap/internal/cli"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/headless"
+)
+
+// headlessMain is `record --headless`: it starts a private Xvfb display,
+// records the command after -- on it, stops when the command exits and
+// exits with the command's status. a recording that fails exits with its
+// own code instead, and a command that swiftcap ends, because --max-dur
+// stopped the recording, counts as a success.
+func headlessMain(cfg cli.Config) {
+	if len(cfg.Args) == 0 {
+		fail(scerr.InvalidArgs, "usage: swiftcap record --headless [--size WxH] [--wm <cmd>] --out <file> -- <command> [args]")
+	}
+	if cfg.ReplayBuffer != "" {
+		fail(scerr.InvalidArgs, "--headless records a command and can't run a replay buffer")
+	}
+	w, h, err := headless.ParseSize(cfg.Size)
+	if err != nil {
+		fail(scerr.InvalidArgs, "--size: "+err.Error())
+	}
+	d, err := headless.Start(headless.Options{Width: w, Height: h, WM: cfg.WM})
+	if err != nil {
+		failErr(err)
+	}
+	// everything from here on, the command included, uses the new display
+	os.Setenv("DISPLAY", d.Name)
+	os.Unsetenv("WAYLAND_DISPLAY")
+	if cfg.Backend == backend.Auto {
+		cfg.Backend = "ffmpeg-x11grab"
+	}
+	c := newCommand(cfg.Args, cfg.Progress == "json")
+	err = runRecord(cfg, c)
+	d.Close()
+	if err != nil {
+		failErr(err)
+	}
+	os.Exit(c.code)
+}
+
+// killTimeout is how long the command gets to exit after SIGTERM.
+const killTimeout = 5 * time.Second
+
+// command is the program a headless recording runs.
+type command struct {
+	cmd  *exec.Cmd
+	done chan struct{}
+	code int // its exit status, once done is closed
+}
+
+// newCommand prepares args with this process's stdin and output; with
+// --progress=json its stdout goes to stderr, keeping stdout NDJSON.
+func newCommand(args []string, jsonOut bool) *command {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if jsonOut {
+		cmd.Stdout = os.Stderr
+	}
+	return &command{cmd: cmd, done: make(chan struct{})}
+}
+
+func (c *command) start() error {
+	if err := c.cmd.Start(); err != nil {
+		return scerr.Classify(c.cmd.Args[0], err, "")
+	}
+	go func() {
+		c.cmd.Wait()
+		c.code = exitStatus(c.cmd.ProcessState)
+		close(c.done)
+	}()
+	return nil
+}
+
+// stop ends the command if it's still running and waits for it. dying of
+// the signal that ends it isn't a failure, so its status is then 0.
+func (c *command) stop() {
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	c.cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-c.done:
+	case <-time.After(killTimeout):
+		c.cmd.Process.Kill()
+		<-c.done
+	}
+	if ws, ok := c.cmd.ProcessState.Sys().(syscall.WaitStatus); ok && ws.Signaled() &&
+		(ws.Signal() == syscall.SIGTERM || ws.Signal() == syscall.SIGKILL) {
+		c.code = 0
+	}
+}
+
+// exitStatus is the status a shell would report: the exit code, or 128
+// plus the signal that killed it.
+func exitStatus(ps *os.ProcessState) int {
+	if ws, ok := ps.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return ps.ExitCode()
+}
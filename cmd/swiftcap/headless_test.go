@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestCommandStop(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		stop   bool // stop it rather than wait for it
+		want   int
+	}{
+		{"exits", "exit 3", false, 3},
+		{"killed", "kill -TERM $$", false, 143},
+		{"ended by swiftcap", "sleep 30", true, 0},
+		{"exits when ended", "trap 'exit 7' TERM; while :; do sleep 0.1; done", true, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCommand([]string{"sh", "-c", tt.script}, false)
+			if err := c.start(); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.stop {
+				<-c.done
+			}
+			c.stop()
+			if c.code != tt.want {
+				t.Errorf("status %d, want %d", c.code, tt.want)
+			}
+		})
+	}
+}
@@ -1,264 +1,194 @@
 package main
 
 import (
-	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"os/signal"
-	"regexp"
-	"swiftcap/internal/cli"
-	"swiftcap/internal/detect"
-	"swiftcap/internal/portal"
-	"swiftcap/internal/record"
-	"swiftcap/internal/shoot"
 	"syscall"
 	"time"
-)
 
-// splitLines splits a string into lines (cross-platform)
-func splitLines(s string) []string {
-	var lines []string
-	l := 0
-	for i := range s {
-		if s[i] == '\n' {
-			lines = append(lines, s[l:i])
-			l = i + 1
-		}
-	}
-	if l < len(s) {
-		lines = append(lines, s[l:])
-	}
-	return lines
-}
+	"github.com/almightynan/swiftcap/capture"
+	"github.com/almightynan/swiftcap/internal/cli"
+	"github.com/almightynan/swiftcap/internal/detect"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/naming"
+	"github.com/almightynan/swiftcap/internal/shoot"
+	"github.com/almightynan/swiftcap/internal/wayland"
+	"github.com/almightynan/swiftcap/internal/x11"
+)
 
 func main() {
 	args := os.Args[1:]
 	cfg, err := cli.Parse(args)
+	jsonErrors = cfg.JSONErrors
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		failErr(err)
+	}
+
+	// these don't capture anything, so they need no display
+	if cfg.Mode == "ctl" {
+		ctlMain(cfg)
+		return
+	}
+	if cfg.Mode == "recover" {
+		recoverMain(cfg)
+		return
+	}
+	if cfg.Mode == "audio-sources" {
+		audioSourcesMain(cfg)
+		return
+	}
+	if cfg.Mode == "edit" {
+		editMain(cfg)
+		return
+	}
+	if cfg.Mode == "concat" {
+		concatMain(cfg)
+		return
+	}
+	if cfg.Mode == "config" {
+		configMain(cfg)
+		return
+	}
+	if cfg.Mode == "backends" {
+		backendsMain(cfg)
+		return
+	}
+	if cfg.Mode == "record" && cfg.Headless {
+		// brings its own display
+		headlessMain(cfg)
+		return
+	}
+	if cfg.Mode == "doctor" {
+		// reports a missing display rather than failing on it
+		doctorMain(cfg)
+		return
 	}
 
 	session, err := detect.Session()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
-		os.Exit(10)
+		failErr(err)
 	}
 
 	switch cfg.Mode {
 	case "record":
-		recordMain(cfg, session)
+		recordMain(cfg)
 	case "screenshot":
 		screenshotMain(cfg, session)
+	case "monitors":
+		monitorsMain(cfg)
 	default:
-		fmt.Fprintf(os.Stderr, "Unknown mode: %s\n", cfg.Mode)
-		os.Exit(1)
+		fail(scerr.InvalidArgs, "unknown mode: "+cfg.Mode)
 	}
 }
 
 func screenshotMain(cfg cli.Config, session detect.SessionType) {
-	region := cfg.Region
-	if region == "" {
-		region = "640x360+0+0"
+	opts := shoot.Options{Format: cfg.Format, Quality: cfg.Quality, Compression: cfg.Compression}
+	if err := opts.Validate(); err != nil {
+		fail(scerr.InvalidArgs, err.Error())
 	}
-	out := cfg.Out
-	format := cfg.Format
-	var err error
-	switch session {
-	case detect.SessionX11:
-		// remove offset for -video_size, pass offset in -i
-		var w, h, x, y int
-		wxh := ""
-		offset := "+0,0"
-		n, scanErr := fmt.Sscanf(region, "%dx%d+%d+%d", &w, &h, &x, &y)
-		if n == 4 && scanErr == nil {
-			wxh = fmt.Sprintf("%dx%d", w, h)
-			offset = fmt.Sprintf("+%d,%d", x, y)
-		} else {
-			wxh = region
+	target, err := captureTarget(cfg)
+	if err != nil {
+		failErr(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if naming.IsTemplate(cfg.Out) {
+		// {monitor} and {window_class} need the region; resolving it once
+		// keeps pick-window to one click
+		region := ""
+		if session == detect.SessionX11 && target != capture.FullScreen() {
+			r, err := target.Resolve(ctx)
+			if err != nil {
+				failErr(err)
+			}
+			target = capture.Region(r)
+			region = fmt.Sprintf("%dx%d+%d+%d", r.Dx(), r.Dy(), r.Min.X, r.Min.Y)
 		}
-		display := os.Getenv("DISPLAY")
-		cmd := exec.Command("ffmpeg", "-y", "-f", "x11grab", "-video_size", wxh, "-i", display+offset, "-vframes", "1", out)
-		err = cmd.Run()
-	case detect.SessionWayland:
-		err = portal.TakeScreenshot(region, out)
-	case detect.SessionWindows, detect.SessionMac:
-		// use cross-platform fallback
-		err = shoot.ScreenshotCross(region, out, format)
-	default:
-		err = fmt.Errorf("no supported display/session for screenshot")
+		cfg.Out = outName(cfg, region, session)
+	}
+	img, err := capture.Screenshot(ctx, capture.ScreenshotOptions{Target: target, Cursor: cfg.Cursor != "off", Backend: cfg.Backend})
+	if err == nil {
+		err = shoot.Save(cfg.Out, img, opts)
 	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Screenshot failed: %v\n", err)
-		os.Exit(30)
+		code := scerr.Code(capture.ExitCode(err))
+		if code == scerr.Runtime {
+			code = scerr.Screenshot
+		}
+		failErr(fmt.Errorf("Screenshot failed: %w", scerr.Wrap(code, err)))
+	}
+	if cfg.Out != "-" {
+		// stdout carries the image itself otherwise
+		fmt.Println("Screenshot saved to", cfg.Out)
 	}
-	fmt.Println("Screenshot saved to", out)
 }
 
-func recordMain(cfg cli.Config, session detect.SessionType) {
-	if session == detect.SessionWayland {
-		err := portal.StartScreencast()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "%v\n", err)
-			os.Exit(21)
-		}
-		return
+// outName is the file a screenshot of region writes: --out filled in from
+// its template and numbered past existing files.
+func outName(cfg cli.Config, region string, session detect.SessionType) string {
+	t, err := naming.Parse(cfg.Out)
+	if err != nil {
+		fail(scerr.InvalidArgs, err.Error())
 	}
-
+	f := naming.Fields{Time: time.Now(), Mode: "screenshot", Ext: shoot.Ext(cfg.Format)}
 	if session == detect.SessionX11 {
-		// validate audio source
-		audioSrc := cfg.ASrc
-		if cfg.Audio == "on" && audioSrc == "" {
-			audioSrc = "default"
-		}
-		// aggressive resource saving defaults
-		fps := cfg.Fps
-		if fps <= 0 {
-			fps = 10
-		}
-		threads := cfg.Threads
-		if threads <= 0 {
-			threads = 1
-		}
-		region := cfg.Region
-		if region == "" {
-			// auto-detect full display size
-			out, err := exec.Command("xdpyinfo").Output()
-			wxh := ""
-			if err == nil {
-				var w, h int
-				lines := string(out)
-				for _, line := range splitLines(lines) {
-					if n, _ := fmt.Sscanf(line, "dimensions: %dx%d", &w, &h); n == 2 {
-						wxh = fmt.Sprintf("%dx%d+0+0", w, h)
-						break
-					}
-				}
-			}
-			if wxh == "" {
-				wxh = "1024x768+0+0" // fallback
-			}
-			region = wxh
-		}
+		f.Monitor, f.WindowClass = x11.RegionSource(region)
+	}
+	out, err := t.Create("", f)
+	if err != nil {
+		failErr(fmt.Errorf("--out: %w", err))
+	}
+	return out
+}
 
-		bitrate := cfg.Bitrate
-		if bitrate <= 0 {
-			bitrate = 400
-		}
-		args := record.FFmpegCmd(
-			os.Getenv("DISPLAY"),
-			region,
-			fps,
-			cfg.Out,
-			cfg.Audio == "on",
-			audioSrc,
-			bitrate,
-			cfg.Qp,
-			cfg.Container,
-			cfg.MaxDur,
-			threads,
-			cfg.Cursor == "on",
-		)
-		// hide ffmpeg logs, show pretty progress
-		fmt.Printf("\033[1;36mRecording...\033[0m (Ctrl+C to stop)\nSaving to: \033[1;32m%s\033[0m\n", cfg.Out)
-		var cmd *exec.Cmd
-		var ctx context.Context
-		var cancel context.CancelFunc
-		if cfg.MaxDur > 0 {
-			ctx, cancel = context.WithTimeout(context.Background(), time.Duration(cfg.MaxDur+5)*time.Second)
-			defer cancel()
-			cmd = exec.CommandContext(ctx, "ffmpeg", args...)
-		} else {
-			cmd = exec.Command("ffmpeg", args...)
+func monitorsMain(cfg cli.Config) {
+	if session, _ := detect.Session(); session == detect.SessionWayland {
+		waylandOutputsMain(cfg)
+		return
+	}
+	mons, err := x11.Monitors()
+	if err != nil {
+		if errors.Is(err, x11.ErrNoDisplay) {
+			fail(scerr.NoDisplay, err.Error())
 		}
-		// trying to show user-friendly progress, but its a fucking mess so i need to rewrite this
-		stderr, _ := cmd.StderrPipe()
-		cmd.Stdout = nil
-		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
-		if cfg.Nice != 0 {
-			syscall.Setpriority(syscall.PRIO_PROCESS, 0, cfg.Nice)
+		failErr(err)
+	}
+	if cfg.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(mons)
+		return
+	}
+	for _, m := range mons {
+		primary := ""
+		if m.Primary {
+			primary = "  primary"
 		}
+		fmt.Printf("%d  %-10s %-20s scale %.2g%s\n", m.Index, m.Name, m.Region(), m.Scale, primary)
+	}
+}
 
-		// handle ctrl+c: forward SIGINT to ffmpeg process group
-		sigCh := make(chan os.Signal, 1)
-		signal.Notify(sigCh, os.Interrupt)
-		userStopped := false
-		ctxTimer, cancelTimer := context.WithCancel(context.Background())
-		defer cancelTimer()
-
-		progressRe := regexp.MustCompile(`frame= *([0-9]+).*fps= *([0-9.]+).*time= *([0-9:.]+)`)
-		spinner := []string{"|", "/", "-", "\\"}
-		spinIdx := 0
-		startTime := time.Now()
-		lastFrame, lastFps, lastTime := "", "", ""
-		progressCh := make(chan struct{}, 1)
-
-		// timer goroutine for real-time updates
-		go func() {
-			for {
-				select {
-				case <-ctxTimer.Done():
-					return
-				case <-time.After(time.Second):
-					progressCh <- struct{}{}
-				}
-			}
-		}()
-
-		err := cmd.Start()
-		if err == nil {
-			scanner := bufio.NewScanner(stderr)
-			readDone := make(chan struct{})
-			go func() {
-				for scanner.Scan() {
-					line := scanner.Text()
-					m := progressRe.FindStringSubmatch(line)
-					if m != nil {
-						lastFrame, lastFps, lastTime = m[1], m[2], m[3]
-					}
-				}
-				close(readDone)
-			}()
-		loop:
-			for {
-				select {
-				case <-progressCh:
-					t := time.Since(startTime).Truncate(time.Second)
-					fmt.Printf("\r\033[1;36mRecording %s\033[0m | Elapsed: %s | Time: %s | Frame: %s | FPS: %s", spinner[spinIdx], t, lastTime, lastFrame, lastFps)
-					spinIdx = (spinIdx + 1) % len(spinner)
-				case <-sigCh:
-					userStopped = true
-					if cmd.Process != nil {
-						pgid, _ := syscall.Getpgid(cmd.Process.Pid)
-						syscall.Kill(-pgid, syscall.SIGINT)
-					}
-					break loop
-				case <-readDone:
-					break loop
-				}
-			}
-			err = cmd.Wait()
-		}
-		cancelTimer()
-		signal.Stop(sigCh)
-		close(sigCh)
-		if userStopped {
-			fmt.Printf("\n\033[1;33mRecording stopped by user.\033[0m Saved to: %s\n", cfg.Out)
-			return
-		}
-		if err != nil {
-			if cfg.MaxDur > 0 && ctx != nil && ctx.Err() == context.DeadlineExceeded && (cmd.ProcessState == nil || !cmd.ProcessState.Exited()) {
-				pgid, _ := syscall.Getpgid(cmd.Process.Pid)
-				syscall.Kill(-pgid, syscall.SIGTERM)
-				fmt.Fprintf(os.Stderr, "\033[1;31mE_RUNTIME=100:\033[0m FFmpeg timed out\n")
-				os.Exit(100)
-			}
-			fmt.Fprintf(os.Stderr, "\033[1;31mE_FFMPEG_MISSING=20:\033[0m FFmpeg failed: %v\n", err)
-			os.Exit(20)
+// waylandOutputsMain is monitorsMain for the compositor's outputs, which
+// have no primary.
+func waylandOutputsMain(cfg cli.Config) {
+	outs, err := wayland.Outputs()
+	if err != nil {
+		if errors.Is(err, wayland.ErrNoDisplay) {
+			fail(scerr.NoDisplay, err.Error())
 		}
-		fmt.Printf("\n\033[1;32mRecording complete!\033[0m Saved to: %s\n", cfg.Out)
+		failErr(err)
+	}
+	if cfg.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(outs)
 		return
 	}
+	for _, o := range outs {
+		fmt.Printf("%d  %-10s %-20s scale %.2g\n", o.Index, o.Name, o.Region(), o.Scale)
+	}
 }
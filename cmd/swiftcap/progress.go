@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/record"
+)
+
+// progressView shows recording events, as NDJSON or for a person.
+type progressView interface {
+	emit(e record.Event)
+}
+
+// view is where the current command reports to; record swaps it for
+// --progress.
+var view progressView = &ttyView{}
+
+func newProgressView(mode string) (progressView, error) {
+	switch mode {
+	case "", "tty":
+		return &ttyView{}, nil
+	case "json":
+		return &jsonView{enc: json.NewEncoder(os.Stdout)}, nil
+	case "none":
+		return quietView{}, nil
+	}
+	return nil, fmt.Errorf("unknown --progress %q, want tty|json|none", mode)
+}
+
+// jsonErrors is --json-errors: fatal errors go to stderr as a JSON error
+// event instead of a coloured line.
+var jsonErrors bool
+
+// fail reports a fatal error through view and exits with its code.
+func fail(code scerr.Code, msg string) {
+	e := record.NewEvent(record.EventError)
+	e.Code, e.Name, e.Message = int(code), code.Name(), msg
+	if jsonErrors {
+		json.NewEncoder(os.Stderr).Encode(e)
+	}
+	if _, ok := view.(*jsonView); ok || !jsonErrors {
+		view.emit(e)
+	}
+	os.Exit(int(code))
+}
+
+// failErr is fail for an error, exiting with its code; an error without one
+// is a runtime error.
+func failErr(err error) {
+	fail(scerr.CodeOf(err), err.Error())
+}
+
+// jsonView writes one JSON object per line to stdout.
+type jsonView struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (v *jsonView) emit(e record.Event) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.enc.Encode(e)
+}
+
+// ttyView is the spinner line.
+type ttyView struct {
+	start time.Time
+	spin  int
+	out   string
+}
+
+var spinner = []string{"|", "/", "-", "\\"}
+
+func (v *ttyView) emit(e record.Event) {
+	switch e.Event {
+	case record.EventStarted:
+		v.start, v.out = time.Now(), e.Out
+		if e.Out == "" { // replay buffer
+			fmt.Printf("\033[1;36mReplay buffer running,\033[0m %s (Ctrl+C to stop)\n", e.Message)
+			break
+		}
+		fmt.Printf("\033[1;36mRecording...\033[0m (Ctrl+C to stop)\nSaving to: \033[1;32m%s\033[0m\n", e.Out)
+	case record.EventProgress:
+		p := e.Progress
+		t := time.Since(v.start).Truncate(time.Second)
+		fmt.Printf("\r\033[1;36mRecording %s\033[0m | Elapsed: %s | Time: %s | Frame: %d | FPS: %.1f | Size: %s",
+			spinner[v.spin], t, trimMicros(p.OutTime), p.Frame, p.FPS, humanBytes(p.Size))
+		if p.Dropped > 0 {
+			fmt.Printf(" | Dropped: %d", p.Dropped)
+		}
+		v.spin = (v.spin + 1) % len(spinner)
+	case record.EventPaused:
+		fmt.Printf("\n\033[1;33mPaused.\033[0m\n")
+	case record.EventResumed:
+		fmt.Printf("\033[1;36mResumed.\033[0m\n")
+	case record.EventMarker:
+		fmt.Printf("\nMarker at %.1fs %s\n", e.At, e.Label)
+	case record.EventExporting:
+		fmt.Printf("\nExporting %s...\n", e.Message)
+	case record.EventWarning:
+		fmt.Fprintf(os.Stderr, "\033[1;33mWarning:\033[0m %s\n", e.Message)
+	case record.EventReplaySaved:
+		fmt.Printf("Replay saved to: \033[1;32m%s\033[0m\n", e.Out)
+	case record.EventStopped:
+		if v.out == "" {
+			fmt.Printf("\033[1;33mReplay buffer stopped.\033[0m\n")
+			break
+		}
+		if e.Reason == "user" || e.Reason == "ctl" {
+			fmt.Printf("\n\033[1;33mRecording stopped by user.\033[0m Saved to: %s\n", v.out)
+		} else {
+			fmt.Printf("\n\033[1;32mRecording complete!\033[0m Saved to: %s\n", v.out)
+		}
+	case record.EventError:
+		fmt.Fprintf(os.Stderr, "\033[1;31m%s=%d:\033[0m %s\n", e.Name, e.Code, e.Message)
+	}
+}
+
+// quietView drops everything but errors.
+type quietView struct{}
+
+func (quietView) emit(e record.Event) {
+	if e.Event == record.EventError {
+		(&ttyView{}).emit(e)
+	}
+}
+
+// trimMicros cuts ffmpeg's HH:MM:SS.micro down to whole seconds.
+func trimMicros(t string) string {
+	if len(t) > 8 && t[8] == '.' {
+		return t[:8]
+	}
+	return t
+}
+
+func humanBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fGiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.0fKiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/almightynan/swiftcap/capture"
+	"github.com/almightynan/swiftcap/internal/cli"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/record"
+)
+
+// recordMain records with the capture package, relaying its events to
+// --progress and --control-socket commands to the session. with a control
+// socket the recording is pausable, which records into segments that are
+// joined into --out when it stops. with a headless command, the command
+// starts once the recording has and the recording stops when it exits.
+func recordMain(cfg cli.Config) {
+	if err := runRecord(cfg, nil); err != nil {
+		failErr(err)
+	}
+}
+
+// runRecord is recordMain, returning what fails so that a headless
+// recording can take its display down first.
+func runRecord(cfg cli.Config, child *command) error {
+	v, err := newProgressView(cfg.Progress)
+	if err != nil {
+		return scerr.Wrap(scerr.InvalidArgs, err)
+	}
+	view = v
+	opts, err := recordOptions(cfg)
+	if err != nil {
+		return err
+	}
+
+	// handle ctrl+c: the backend finishes the file
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.ReplayBuffer != "" {
+		recordReplay(ctx, cfg, opts)
+		return nil
+	}
+	ctl, closeCtl, err := serveControl(cfg.ControlSocket)
+	if err != nil {
+		return err
+	}
+	defer closeCtl()
+
+	s, err := capture.StartRecording(ctx, opts)
+	if err != nil {
+		return err
+	}
+	var cmdDone <-chan struct{}
+	if child != nil {
+		if err := child.start(); err != nil {
+			s.Stop()
+			return err
+		}
+		cmdDone = child.done
+	}
+
+	var (
+		markers []record.Marker
+		resumes int
+		reason  string
+		exited  bool // the headless command ended the recording
+	)
+	reply := func() record.ControlReply {
+		return record.ControlReply{OK: true, State: string(s.State()), Out: s.Out(), Elapsed: s.Elapsed().Seconds(), Markers: len(markers), Segments: 1 + resumes}
+	}
+	refuse := func(err error) record.ControlReply {
+		r := reply()
+		r.OK, r.Error = false, err.Error()
+		return r
+	}
+	// pausing needs a control socket, so only the backend can refuse it
+	pauseErr := func(cmd string, err error) error {
+		if errors.Is(err, capture.ErrNotPausable) {
+			return fmt.Errorf("%s is not supported by the %s backend", cmd, s.Backend())
+		}
+		return err
+	}
+
+	events := s.Events()
+	for events != nil {
+		select {
+		case e, ok := <-events:
+			switch {
+			case !ok:
+				events = nil
+			case e.Kind == capture.EventStopped:
+				reason = stopReason(e.Reason)
+				if exited && e.Reason == capture.StopRequested {
+					reason = "exited"
+				}
+			default:
+				view.emit(recordEvent(e))
+			}
+		case <-cmdDone:
+			cmdDone, exited = nil, true
+			go s.Stop()
+		case c := <-ctl:
+			switch c.req.Cmd {
+			case record.CtlStatus:
+				c.reply <- reply()
+			case record.CtlPause:
+				if err := s.Pause(); err != nil {
+					c.reply <- refuse(pauseErr("pause", err))
+					break
+				}
+				c.reply <- reply()
+			case record.CtlResume:
+				if err := s.Resume(); err != nil {
+					c.reply <- refuse(pauseErr("resume", err))
+					break
+				}
+				resumes++
+				c.reply <- reply()
+			case record.CtlStop:
+				r := reply()
+				r.State = string(capture.StateStopping)
+				c.reply <- r
+				// the session finishes the file; its events end this loop
+				go s.Stop()
+			case record.CtlMarker:
+				m := record.Marker{At: s.Elapsed().Seconds(), Label: c.req.Label}
+				markers = append(markers, m)
+				e := record.NewEvent(record.EventMarker)
+				e.Marker = &m
+				view.emit(e)
+				r := reply()
+				r.Marker = &m
+				c.reply <- r
+			default:
+				c.reply <- refuse(fmt.Errorf("unknown command %q", c.req.Cmd))
+			}
+		}
+	}
+	closeCtl()
+	if child != nil {
+		child.stop()
+	}
+
+	if err := s.Wait(); err != nil {
+		return err
+	}
+	if err := saveMarkers(s.Out(), markers); err != nil {
+		return err
+	}
+	stopped := record.NewEvent(record.EventStopped)
+	stopped.Out = s.Out()
+	stopped.Reason = reason
+	if child != nil {
+		stopped.Code = child.code
+	}
+	view.emit(stopped)
+	return nil
+}
+
+// stopReason is how --progress has always named why a recording stopped.
+func stopReason(r capture.StopReason) string {
+	switch r {
+	case capture.StopCancelled:
+		return "user"
+	case capture.StopRequested:
+		return "ctl"
+	}
+	return string(r)
+}
+
+// recordEvent is e as --progress reports it.
+func recordEvent(e capture.Event) record.Event {
+	out := record.NewEvent(string(e.Kind))
+	out.Out, out.Message = e.Out, e.Message
+	if e.Kind == capture.EventProgress {
+		p := e.Progress
+		out.Progress = &record.Progress{
+			Frame:   p.Frames,
+			FPS:     p.FPS,
+			Bitrate: p.Bitrate,
+			Size:    p.Size,
+			Dropped: p.Dropped,
+			OutTime: record.FormatOutTime(p.Duration),
+		}
+	}
+	return out
+}
+
+// recordOptions turns the record flags into capture options, filling in
+// the resource-saving defaults, and checks that they go together.
+func recordOptions(cfg cli.Config) (capture.RecordOptions, error) {
+	target, err := captureTarget(cfg)
+	if err != nil {
+		return capture.RecordOptions{}, err
+	}
+	budget, err := record.ParseSize(cfg.SizeBudget)
+	if err != nil {
+		return capture.RecordOptions{}, scerr.Errorf(scerr.InvalidArgs, "--size-budget: %v", err)
+	}
+	opts := capture.RecordOptions{
+		Target:      target,
+		Out:         cfg.Out,
+		Backend:     cfg.Backend,
+		Pausable:    cfg.ControlSocket != "",
+		FPS:         cfg.Fps,
+		Cursor:      cfg.Cursor == "on",
+		MaxDuration: time.Duration(cfg.MaxDur) * time.Second,
+		Container:   cfg.Container,
+		Codec:       cfg.Codec,
+		Preset:      cfg.Preset,
+		QP:          cfg.Qp,
+		Bitrate:     cfg.Bitrate,
+		Threads:     cfg.Threads,
+		Nice:        cfg.Nice,
+
+		Audio:        cfg.Audio == "on",
+		AudioSources: cfg.ASrc,
+		AudioTracks:  cfg.ATracks,
+		AudioCodec:   cfg.ACodec,
+		AudioBitrate: cfg.ABitrate,
+
+		Animation: &capture.Animation{FPS: cfg.AnimFps, Width: cfg.AnimWidth, Dither: cfg.Dither, Loop: cfg.Loop, SizeBudget: budget},
+	}
+	if opts.Container == "" {
+		opts.Container = "mp4"
+	}
+	if opts.FPS <= 0 && (!record.IsAnimated(opts.Container) || cfg.AnimFps <= 0) {
+		// gif, webp and apng capture at --anim-fps otherwise
+		opts.FPS = 10
+	}
+	if opts.Bitrate <= 0 {
+		opts.Bitrate = 400
+	}
+	// aggressive resource saving defaults
+	if opts.Threads <= 0 {
+		opts.Threads = 1
+	}
+	if cfg.Crf >= 0 {
+		opts.CRF = &cfg.Crf
+	}
+	return opts, opts.Validate()
+}
+
+// captureTarget turns --region, --target and --monitor into what to
+// capture.
+func captureTarget(cfg cli.Config) (capture.Target, error) {
+	if cfg.Region != "" {
+		r, err := capture.ParseRegion(cfg.Region)
+		return capture.Region(r), err
+	}
+	var t capture.Target
+	switch cfg.Target {
+	case "", "fullscreen":
+		if cfg.MonitorID == "" {
+			return capture.FullScreen(), nil
+		}
+		t = capture.Monitor(cfg.MonitorID)
+	case "monitor":
+		spec := cfg.MonitorID
+		if spec == "" {
+			spec = "focused"
+		}
+		t = capture.Monitor(spec)
+	case "active-window":
+		t = capture.ActiveWindow()
+	case "pick-window":
+		t = capture.PickWindow()
+	default:
+		id, ok := strings.CutPrefix(cfg.Target, "window:")
+		if !ok {
+			return t, scerr.Errorf(scerr.InvalidArgs, "unknown --target %q, want fullscreen|monitor|active-window|pick-window|window:<id|class>", cfg.Target)
+		}
+		t = capture.Window(id)
+	}
+	if cfg.Decor == "off" {
+		t = t.WithoutDecorations()
+	}
+	return t, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/almightynan/swiftcap/internal/cli"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/record"
+)
+
+// recoverMain lists, recovers or discards recordings a crash left behind.
+//
+//	swiftcap recover                      list them
+//	swiftcap recover <id>|all [--out F]   remux and join their segments
+//	swiftcap recover --discard <id>|all   delete them
+func recoverMain(cfg cli.Config) {
+	orphans, err := record.Orphans()
+	if err != nil {
+		failErr(fmt.Errorf("failed to read the recording journal: %w", err))
+	}
+	if len(cfg.Args) == 0 {
+		listOrphans(orphans, cfg.JSON)
+		return
+	}
+
+	var todo []*record.Journal
+	if cfg.Args[0] == "all" {
+		todo = orphans
+	} else {
+		for _, id := range cfg.Args {
+			j, err := record.FindOrphan(id)
+			if err != nil {
+				fail(scerr.InvalidArgs, err.Error())
+			}
+			todo = append(todo, j)
+		}
+	}
+	if cfg.Out != "" && len(todo) > 1 {
+		fail(scerr.InvalidArgs, "--out names one file; recover one recording at a time with it")
+	}
+
+	failed := scerr.OK
+	for _, j := range todo {
+		if cfg.Discard {
+			j.Discard()
+			fmt.Printf("Discarded %s\n", j.ID)
+			continue
+		}
+		out, err := j.Recover(cfg.Out)
+		if err != nil {
+			code := scerr.CodeOf(err)
+			fmt.Fprintf(os.Stderr, "\033[1;31m%s:\033[0m %s: %v\n", code, j.ID, err)
+			if failed == scerr.OK {
+				failed = code
+			}
+			continue
+		}
+		fmt.Printf("Recovered %s to %s\n", j.ID, out)
+	}
+	if failed != scerr.OK {
+		// the first failure's code, each having been reported above
+		os.Exit(int(failed))
+	}
+}
+
+func listOrphans(orphans []*record.Journal, asJSON bool) {
+	if asJSON {
+		if orphans == nil {
+			orphans = []*record.Journal{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(orphans)
+		return
+	}
+	if len(orphans) == 0 {
+		fmt.Println("No unfinished recordings.")
+		return
+	}
+	for _, j := range orphans {
+		fmt.Printf("%s  %s  %d segment(s), %s  -> %s\n", j.ID, j.Started.Format("2006-01-02 15:04:05"),
+			len(j.Segments), humanBytes(j.Size()), j.DefaultOut())
+	}
+	fmt.Println("\nswiftcap recover <id>|all to keep them, swiftcap recover --discard <id>|all to delete them.")
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/almightynan/swiftcap/capture"
+	"github.com/almightynan/swiftcap/internal/cli"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/record"
+)
+
+// recordReplay keeps the last --replay-buffer of the screen in a rolling
+// buffer until ctx ends or it's stopped. `ctl save-replay` or a SIGUSR1
+// writes the buffer out while capture goes on; --out names the saves,
+// numbered after the first, or filled in afresh for each save when it's a
+// template. nothing is saved on stop.
+func recordReplay(ctx context.Context, cfg cli.Config, opts capture.RecordOptions) {
+	window, err := record.ParseReplayWindow(cfg.ReplayBuffer)
+	if err != nil {
+		fail(scerr.InvalidArgs, err.Error())
+	}
+	ctl, closeCtl, err := serveControl(cfg.ControlSocket)
+	if err != nil {
+		failErr(err)
+	}
+	defer closeCtl()
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, syscall.SIGUSR1)
+	defer signal.Stop(usr1)
+
+	r, err := capture.StartReplay(ctx, opts, window)
+	if err != nil {
+		failErr(err)
+	}
+	started := record.NewEvent(record.EventStarted)
+	started.Message = fmt.Sprintf("keeping the last %s", window)
+	view.emit(started)
+
+	reply := func() record.ControlReply {
+		return record.ControlReply{OK: true, State: "buffering", Elapsed: r.Buffered().Seconds()}
+	}
+	save := func(out string) (string, error) {
+		if out == "" {
+			out = cfg.Out
+		}
+		out, err := r.Save(out)
+		if err != nil {
+			return "", err
+		}
+		e := record.NewEvent(record.EventReplaySaved)
+		e.Out = out
+		view.emit(e)
+		return out, nil
+	}
+
+	reason := ""
+	for reason == "" {
+		select {
+		case <-ctx.Done():
+			reason = "user"
+		case <-r.Done():
+			if err := r.Wait(); err != nil {
+				failErr(err)
+			}
+			reason = "done"
+		case <-usr1:
+			if _, err := save(""); err != nil {
+				e := record.NewEvent(record.EventWarning)
+				e.Message = err.Error()
+				view.emit(e)
+			}
+		case c := <-ctl:
+			switch c.req.Cmd {
+			case record.CtlStatus:
+				c.reply <- reply()
+			case record.CtlSaveReplay:
+				rep := reply()
+				if out, err := save(c.req.Out); err != nil {
+					rep.OK, rep.Error = false, err.Error()
+				} else {
+					rep.Out = out
+				}
+				c.reply <- rep
+			case record.CtlStop:
+				reason = "ctl"
+				rep := reply()
+				rep.State = "stopping"
+				c.reply <- rep
+			default:
+				rep := reply()
+				rep.OK, rep.Error = false, fmt.Sprintf("%q is not supported by the replay buffer", c.req.Cmd)
+				c.reply <- rep
+			}
+		}
+	}
+	closeCtl()
+	if err := r.Stop(); err != nil {
+		failErr(err)
+	}
+
+	stopped := record.NewEvent(record.EventStopped)
+	stopped.Reason = reason
+	view.emit(stopped)
+}
@@ -0,0 +1,215 @@
+/*
+	capture backends. a Recorder turns record.Options into a file, a
+	Screenshotter grabs an image; each says what it can do (Caps) and whether
+	it works in the current session (Usable). the cli and the app pick one
+	with Pick*, which takes --backend or, for "auto", the first usable
+	backend in preference order that can do what was asked.
+
+	adding a backend: implement the interface, add it to recorders or
+	screenshotters below in the order auto should try it.
+*/
+
+package backend
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os/exec"
+	"strings"
+
+	"github.com/almightynan/swiftcap/internal/detect"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/record"
+)
+
+// Caps is what a backend can do.
+type Caps struct {
+	Regions bool `json:"regions"` // capture a WxH+X+Y part of the screen
+	Cursor  bool `json:"cursor"`  // show or hide the pointer
+	Audio   bool `json:"audio"`   // record sound alongside the video
+	Windows bool `json:"windows"` // window targets, which need a window tree
+	Pause   bool `json:"pause"`   // stop and start again without asking the user, so a pause is a new segment
+}
+
+// Lacks lists what of need c can't do, by name.
+func (c Caps) Lacks(need Caps) []string {
+	var out []string
+	for _, f := range []struct {
+		need, have bool
+		name       string
+	}{
+		{need.Regions, c.Regions, "regions"},
+		{need.Cursor, c.Cursor, "cursor"},
+		{need.Audio, c.Audio, "audio"},
+		{need.Windows, c.Windows, "window targets"},
+		{need.Pause, c.Pause, "pause"},
+	} {
+		if f.need && !f.have {
+			out = append(out, f.name)
+		}
+	}
+	return out
+}
+
+// Recorder records the screen into a file.
+type Recorder interface {
+	Name() string
+	Caps() Caps
+	// Usable says why the backend can't record in session; nil if it can.
+	Usable(session detect.SessionType) error
+	// Start begins recording per o. onProgress, if set, gets the running
+	// totals of backends that report them.
+	Start(o record.Options, onProgress func(record.Progress)) (Recording, error)
+}
+
+// Recording is a capture in progress.
+type Recording interface {
+	// Interrupt asks the capture to finish its file and returns at once.
+	Interrupt()
+	// Kill stops it now, leaving the file as it is.
+	Kill()
+	// Done is closed once the capture has ended, for whatever reason.
+	Done() <-chan struct{}
+	// Wait blocks until Done. The error says why the capture failed; an
+	// interrupted capture, or one that reached MaxDur, didn't.
+	Wait() error
+	// Progress is the totals of the last report; zero if the backend
+	// doesn't report.
+	Progress() record.Progress
+}
+
+// Screenshotter grabs the screen.
+type Screenshotter interface {
+	Name() string
+	Caps() Caps
+	Usable(session detect.SessionType) error
+	// Capture grabs region (WxH+X+Y, "" for everything), with the pointer
+	// painted in when cursor is set and the backend can.
+	Capture(region string, cursor bool) (image.Image, error)
+}
+
+// recorders and screenshotters are every backend, in the order auto tries
+// them.
+var (
+	recorders      = []Recorder{ffmpegX11{}, gstX11{}, wlrScreencopy{}, portalRecorder{}}
+	screenshotters = []Screenshotter{nativeX11{}, ffmpegX11Shot{}, wlrShot{}, portalShot{}, ffmpegDesktop{}}
+)
+
+// Auto is the --backend that picks one.
+const Auto = "auto"
+
+// Recorders lists the recording backends, in preference order.
+func Recorders() []Recorder { return recorders }
+
+// Screenshotters lists the screenshot backends, in preference order.
+func Screenshotters() []Screenshotter { return screenshotters }
+
+// RecorderNames and ScreenshotterNames are the --backend values for each.
+func RecorderNames() []string      { return names(recorders) }
+func ScreenshotterNames() []string { return names(screenshotters) }
+
+// Known reports whether name is Auto or a recorder or screenshotter.
+func Known(name string) bool {
+	for _, n := range append(RecorderNames(), ScreenshotterNames()...) {
+		if n == name {
+			return true
+		}
+	}
+	return name == Auto
+}
+
+// PickRecorder returns the recorder called name or, for "" and Auto, the
+// first usable one in session that can do everything in need, falling back
+// to the first usable one at all. Check Caps().Lacks(need) on the result.
+func PickRecorder(name string, session detect.SessionType, need Caps) (Recorder, error) {
+	return pick("recording", recorders, name, session, need)
+}
+
+// PickScreenshotter is PickRecorder for screenshots.
+func PickScreenshotter(name string, session detect.SessionType, need Caps) (Screenshotter, error) {
+	return pick("screenshot", screenshotters, name, session, need)
+}
+
+type named interface {
+	Name() string
+	Caps() Caps
+	Usable(session detect.SessionType) error
+}
+
+func names[B named](all []B) []string {
+	out := make([]string, len(all))
+	for i, b := range all {
+		out[i] = b.Name()
+	}
+	return out
+}
+
+func pick[B named](kind string, all []B, name string, session detect.SessionType, need Caps) (B, error) {
+	var zero B
+	if name != "" && name != Auto {
+		for _, b := range all {
+			if b.Name() == name {
+				if err := b.Usable(session); err != nil {
+					return zero, err
+				}
+				return b, nil
+			}
+		}
+		return zero, scerr.Errorf(scerr.InvalidArgs, "unknown %s backend %q, want %s|%s", kind, name, Auto, strings.Join(names(all), "|"))
+	}
+	var (
+		fallback B
+		found    bool
+		why      error
+	)
+	for _, b := range all {
+		err := b.Usable(session)
+		if err != nil {
+			// a backend for this session that's missing something says more
+			// than one for another session
+			if why == nil || scerr.CodeOf(why) == scerr.NoDisplay {
+				why = err
+			}
+			continue
+		}
+		if len(b.Caps().Lacks(need)) == 0 {
+			return b, nil
+		}
+		if !found {
+			fallback, found = b, true
+		}
+	}
+	if found {
+		return fallback, nil
+	}
+	if why == nil || scerr.CodeOf(why) == scerr.NoDisplay {
+		return zero, scerr.Errorf(scerr.NoDisplay, "no %s backend works in this session", kind)
+	}
+	return zero, why
+}
+
+// needSession is the Usable error of a backend for another kind of session.
+func needSession(name, want string) error {
+	return scerr.Errorf(scerr.NoDisplay, "the %s backend needs %s", name, want)
+}
+
+// needTool is the Usable error of a backend whose program isn't installed.
+func needTool(name, tool string) error {
+	if _, err := exec.LookPath(tool); err != nil {
+		return scerr.Errorf(scerr.DepMissing, "the %s backend needs %s, which is not installed", name, tool)
+	}
+	return nil
+}
+
+// command runs tool with args at niceness nice, through nice(1) so the
+// process starts at it with every thread it makes. nice execs the tool, so
+// signals reach it as usual.
+func command(ctx context.Context, nice int, tool string, args ...string) *exec.Cmd {
+	if nice != 0 {
+		if _, err := exec.LookPath("nice"); err == nil {
+			return exec.CommandContext(ctx, "nice", append([]string{"-n", fmt.Sprint(nice), tool}, args...)...)
+		}
+	}
+	return exec.CommandContext(ctx, tool, args...)
+}
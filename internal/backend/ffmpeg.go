@@ -0,0 +1,228 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/png"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/almightynan/swiftcap/internal/detect"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/record"
+	"github.com/almightynan/swiftcap/internal/shoot"
+	"github.com/almightynan/swiftcap/internal/x11"
+)
+
+// ffmpegX11 records with ffmpeg's x11grab: the fastest path on X11, and the
+// only one with progress reports and the segment muxer the replay buffer
+// needs.
+type ffmpegX11 struct{}
+
+func (ffmpegX11) Name() string { return "ffmpeg-x11grab" }
+
+func (ffmpegX11) Caps() Caps {
+	return Caps{Regions: true, Cursor: true, Audio: true, Windows: true, Pause: true}
+}
+
+func (b ffmpegX11) Usable(session detect.SessionType) error {
+	if session != detect.SessionX11 {
+		return needSession(b.Name(), "an X11 session")
+	}
+	return needTool(b.Name(), "ffmpeg")
+}
+
+// Start runs ffmpeg for o. An empty Display is $DISPLAY and an empty Region
+// the whole screen. With a MaxDur a watchdog kills ffmpeg if it overruns -t
+// by five seconds.
+func (ffmpegX11) Start(o record.Options, onProgress func(record.Progress)) (Recording, error) {
+	if o.Display == "" {
+		o.Display = os.Getenv("DISPLAY")
+	}
+	if o.Region == "" {
+		region, err := x11.GetGeometry()
+		if err != nil {
+			return nil, scerr.Wrap(scerr.NoDisplay, err)
+		}
+		o.Region = region
+	}
+	r, err := startFFmpeg(o, record.FFmpegCmd(o), nil, onProgress)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// startFFmpeg runs ffmpeg with args, which must send -progress to stdout,
+// reading its stdin from stdin if set.
+func startFFmpeg(o record.Options, args []string, stdin io.Reader, onProgress func(record.Progress)) (*ffmpegRun, error) {
+	r := &ffmpegRun{done: make(chan struct{})}
+	if o.MaxDur > 0 {
+		r.ctx, r.cancel = context.WithTimeout(context.Background(), time.Duration(o.MaxDur+5)*time.Second)
+	} else {
+		r.ctx, r.cancel = context.WithCancel(context.Background())
+	}
+	r.cmd = command(r.ctx, o.Nice, "ffmpeg", args...)
+	r.cmd.Stdin = stdin
+	// ffmpeg writes -progress reports to stdout and only errors to stderr
+	stdout, err := r.cmd.StdoutPipe()
+	if err != nil {
+		r.cancel()
+		return nil, err
+	}
+	r.cmd.Stderr = &r.stderr
+	r.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := r.cmd.Start(); err != nil {
+		r.cancel()
+		return nil, fmt.Errorf("FFmpeg failed to start: %w", scerr.Classify("ffmpeg", err, ""))
+	}
+	go func() {
+		record.ReadProgress(stdout, func(p record.Progress) {
+			r.mu.Lock()
+			r.last = p
+			r.mu.Unlock()
+			if onProgress != nil {
+				onProgress(p)
+			}
+		})
+		close(r.done)
+	}()
+	return r, nil
+}
+
+// ffmpegRun is one ffmpeg process.
+type ffmpegRun struct {
+	cmd    *exec.Cmd
+	ctx    context.Context
+	cancel context.CancelFunc
+	stderr bytes.Buffer
+	done   chan struct{} // closed when ffmpeg closes its progress stream
+
+	mu          sync.Mutex
+	last        record.Progress
+	interrupted bool
+	waited      sync.Once
+	err         error
+}
+
+// Interrupt asks ffmpeg to finish the file, like ctrl+c would.
+func (r *ffmpegRun) Interrupt() {
+	r.mu.Lock()
+	r.interrupted = true
+	r.mu.Unlock()
+	signalGroup(r.cmd, syscall.SIGINT)
+}
+
+func (r *ffmpegRun) Kill() { signalGroup(r.cmd, syscall.SIGTERM) }
+
+func (r *ffmpegRun) Done() <-chan struct{} { return r.done }
+
+// Wait reaps ffmpeg once its progress stream is done. ffmpeg exits 255
+// after finishing the file on SIGINT, so after Interrupt that doesn't count
+// as failing; any other exit still does.
+func (r *ffmpegRun) Wait() error {
+	<-r.done
+	r.waited.Do(func() {
+		err := r.cmd.Wait()
+		timedOut := r.ctx.Err() == context.DeadlineExceeded && (r.cmd.ProcessState == nil || !r.cmd.ProcessState.Exited())
+		r.cancel()
+		r.mu.Lock()
+		interrupted := r.interrupted
+		r.mu.Unlock()
+		switch {
+		case err == nil || interrupted && interruptedExit(err):
+		case timedOut:
+			r.Kill()
+			r.err = scerr.New(scerr.Timeout, "FFmpeg timed out")
+		default:
+			r.err = fmt.Errorf("FFmpeg failed: %w", scerr.Classify("ffmpeg", err, r.stderr.String()))
+		}
+	})
+	return r.err
+}
+
+// interruptedExit reports whether err is how ffmpeg ends on SIGINT: exit
+// status 255 once the file is finished, or death by the signal itself when
+// it came before ffmpeg set up its handler.
+func interruptedExit(err error) bool {
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) {
+		return false
+	}
+	if exit.ExitCode() == 255 {
+		return true
+	}
+	ws, ok := exit.Sys().(syscall.WaitStatus)
+	return ok && ws.Signaled() && ws.Signal() == syscall.SIGINT
+}
+
+func (r *ffmpegRun) Progress() record.Progress {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+// signalGroup sends sig to the process group cmd leads.
+func signalGroup(cmd *exec.Cmd, sig syscall.Signal) {
+	if cmd.Process == nil {
+		return
+	}
+	if pgid, err := syscall.Getpgid(cmd.Process.Pid); err == nil {
+		syscall.Kill(-pgid, sig)
+	}
+}
+
+// ffmpegX11Shot is a one-frame x11grab: slower than native-x11, but it
+// works wherever ffmpeg can reach the display.
+type ffmpegX11Shot struct{}
+
+func (ffmpegX11Shot) Name() string { return "ffmpeg-x11grab" }
+
+func (ffmpegX11Shot) Caps() Caps { return Caps{Regions: true, Cursor: true, Windows: true} }
+
+func (ffmpegX11Shot) Usable(session detect.SessionType) error {
+	return ffmpegX11{}.Usable(session)
+}
+
+func (ffmpegX11Shot) Capture(region string, cursor bool) (image.Image, error) {
+	r, err := shoot.ParseRegion(region)
+	if err != nil {
+		return nil, err
+	}
+	draw := "0"
+	if cursor {
+		draw = "1"
+	}
+	args := []string{"-hide_banner", "-loglevel", "error", "-f", "x11grab", "-draw_mouse", draw}
+	input := os.Getenv("DISPLAY")
+	if !r.Empty() {
+		args = append(args, "-video_size", fmt.Sprintf("%dx%d", r.Dx(), r.Dy()))
+		input += fmt.Sprintf("+%d,%d", r.Min.X, r.Min.Y)
+	}
+	args = append(args, "-i", input, "-frames:v", "1", "-f", "image2pipe", "-c:v", "png", "pipe:1")
+	return grabPNG(args)
+}
+
+// grabPNG runs ffmpeg with args, which must write one png to stdout, and
+// decodes it.
+func grabPNG(args []string) (image.Image, error) {
+	var out, stderr bytes.Buffer
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, scerr.Classify("ffmpeg", err, stderr.String())
+	}
+	img, _, err := image.Decode(&out)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg wrote no image: %w", err)
+	}
+	return img, nil
+}
@@ -0,0 +1,28 @@
+package backend
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestInterruptedExit(t *testing.T) {
+	tests := []struct {
+		script string
+		want   bool
+	}{
+		{"exit 255", true},       // finished the file after SIGINT
+		{"kill -INT $$", true},   // SIGINT before ffmpeg handled it
+		{"exit 1", false},        // e.g. No space left on device
+		{"kill -KILL $$", false}, // the OOM killer
+	}
+	for _, tt := range tests {
+		err := exec.Command("sh", "-c", tt.script).Run()
+		if got := interruptedExit(err); got != tt.want {
+			t.Errorf("%s: interruptedExit(%v) = %v, want %v", tt.script, err, got, tt.want)
+		}
+	}
+	if interruptedExit(errors.New("exec: \"ffmpeg\": executable file not found in $PATH")) {
+		t.Error("a failed start counts as interrupted")
+	}
+}
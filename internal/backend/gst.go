@@ -0,0 +1,160 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/almightynan/swiftcap/internal/detect"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/portal"
+	"github.com/almightynan/swiftcap/internal/record"
+	"github.com/almightynan/swiftcap/internal/x11"
+)
+
+// gstX11 records X11 with GStreamer's ximagesrc, for systems whose ffmpeg
+// lacks x11grab or an encoder GStreamer has.
+type gstX11 struct{}
+
+func (gstX11) Name() string { return "gstreamer-ximagesrc" }
+
+func (gstX11) Caps() Caps {
+	return Caps{Regions: true, Cursor: true, Audio: true, Windows: true, Pause: true}
+}
+
+func (b gstX11) Usable(session detect.SessionType) error {
+	if session != detect.SessionX11 {
+		return needSession(b.Name(), "an X11 session")
+	}
+	return needTool(b.Name(), "gst-launch-1.0")
+}
+
+func (gstX11) Start(o record.Options, _ func(record.Progress)) (Recording, error) {
+	if o.Display == "" {
+		o.Display = os.Getenv("DISPLAY")
+	}
+	if o.Region == "" {
+		region, err := x11.GetGeometry()
+		if err != nil {
+			return nil, scerr.Wrap(scerr.NoDisplay, err)
+		}
+		o.Region = region
+	}
+	return startGst(record.XImageSource(o.Display, o.Region, o.Cursor), o, nil, nil)
+}
+
+// portalRecorder records Wayland through the ScreenCast portal: the portal
+// hands us a PipeWire node and remote fd, gst-launch encodes it. The portal
+// asks the user what to share every time, so a pause can't be a new
+// segment, and the source is whatever they picked.
+type portalRecorder struct{}
+
+func (portalRecorder) Name() string { return "portal-pipewire" }
+
+func (portalRecorder) Caps() Caps { return Caps{Cursor: true, Audio: true} }
+
+func (b portalRecorder) Usable(session detect.SessionType) error {
+	if session != detect.SessionWayland {
+		return needSession(b.Name(), "a Wayland session")
+	}
+	return needTool(b.Name(), "gst-launch-1.0")
+}
+
+// Start asks the portal for a stream and records it. The remote becomes fd
+// 3 in the child (the first of ExtraFiles). SWIFTCAP_GST_SOURCE swaps the
+// pipewire source for another element, e.g. "videotestsrc is-live=true"
+// when running against a mock portal.
+func (portalRecorder) Start(o record.Options, _ func(record.Progress)) (Recording, error) {
+	cast, err := portal.StartScreencast(o.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	source := record.PipeWireSource(3, cast.NodeID)
+	if s := os.Getenv("SWIFTCAP_GST_SOURCE"); s != "" {
+		source = strings.Fields(s)
+	}
+	r, err := startGst(source, o, cast.Remote, cast)
+	if err != nil {
+		cast.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// gstRun is one gst-launch-1.0 pipeline. gst-launch has no progress
+// stream, so there are no progress reports.
+type gstRun struct {
+	cmd    *exec.Cmd
+	stderr bytes.Buffer
+	done   chan struct{}
+	timer  *time.Timer // MaxDur
+	closer io.Closer   // the portal session, ended with the pipeline
+
+	mu          sync.Mutex
+	interrupted bool
+	err         error
+}
+
+// startGst runs a pipeline from source per o; remote, if set, is passed
+// down as fd 3, and closer is closed when the pipeline ends.
+func startGst(source []string, o record.Options, remote *os.File, closer io.Closer) (*gstRun, error) {
+	r := &gstRun{done: make(chan struct{}), closer: closer}
+	r.cmd = command(context.Background(), o.Nice, "gst-launch-1.0", record.GStreamerCmd(source, o)...)
+	if remote != nil {
+		r.cmd.ExtraFiles = []*os.File{remote}
+	}
+	r.cmd.Stderr = &r.stderr
+	r.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := r.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("gst-launch-1.0 failed to start: %w", scerr.Classify("gst-launch-1.0", err, ""))
+	}
+	if o.MaxDur > 0 {
+		// reaching it finishes the file like a stop does
+		r.timer = time.AfterFunc(time.Duration(o.MaxDur)*time.Second, r.Interrupt)
+	}
+	go func() {
+		err := r.cmd.Wait()
+		if r.timer != nil {
+			r.timer.Stop()
+		}
+		r.mu.Lock()
+		if r.closer != nil {
+			r.closer.Close()
+		}
+		if err != nil && !r.interrupted {
+			r.err = fmt.Errorf("GStreamer failed: %w", scerr.Classify("gst-launch-1.0", err, r.stderr.String()))
+		}
+		r.mu.Unlock()
+		close(r.done)
+	}()
+	return r, nil
+}
+
+// Interrupt stops the pipeline; gst-launch -e turns SIGINT into an EOS, so
+// the muxer finalises the file.
+func (r *gstRun) Interrupt() {
+	r.mu.Lock()
+	r.interrupted = true
+	r.mu.Unlock()
+	signalGroup(r.cmd, syscall.SIGINT)
+}
+
+func (r *gstRun) Kill() { signalGroup(r.cmd, syscall.SIGTERM) }
+
+func (r *gstRun) Done() <-chan struct{} { return r.done }
+
+func (r *gstRun) Wait() error {
+	<-r.done
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *gstRun) Progress() record.Progress { return record.Progress{} }
@@ -0,0 +1,48 @@
+package backend
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/almightynan/swiftcap/internal/portal/portaltest"
+	"github.com/almightynan/swiftcap/internal/record"
+)
+
+func TestPortalRecorder(t *testing.T) {
+	if _, err := exec.LookPath("gst-launch-1.0"); err != nil {
+		t.Skip("gst-launch-1.0 is not installed")
+	}
+	portaltest.Bus(t)
+	p := &portaltest.Portal{NodeID: 42}
+	p.Serve(t, "ScreenCast")
+	// the portal's node is a stand-in, so is the source
+	t.Setenv("SWIFTCAP_GST_SOURCE", "videotestsrc is-live=true")
+
+	o := record.DefaultOptions()
+	o.Fps = 30
+	o.Container = "mkv"
+	o.MaxDur = 1
+	o.Out = filepath.Join(t.TempDir(), "out.mkv")
+	r, err := portalRecorder{}.Start(o, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-r.Done():
+	case <-time.After(30 * time.Second):
+		r.Kill()
+		t.Fatal("the recording didn't stop at MaxDur")
+	}
+	if err := r.Wait(); err != nil {
+		t.Fatalf("Wait = %v", err)
+	}
+	if fi, err := os.Stat(o.Out); err != nil || fi.Size() == 0 {
+		t.Errorf("no recording: %v", err)
+	}
+	if len(p.Closed()) != 1 {
+		t.Errorf("the portal session wasn't closed with the pipeline")
+	}
+}
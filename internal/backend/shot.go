@@ -0,0 +1,64 @@
+package backend
+
+import (
+	"image"
+
+	"github.com/almightynan/swiftcap/internal/detect"
+	"github.com/almightynan/swiftcap/internal/shoot"
+)
+
+// nativeX11 grabs straight from the X server over MIT-SHM: no process to
+// start, so it's the quickest screenshot there is.
+type nativeX11 struct{}
+
+func (nativeX11) Name() string { return "native-x11" }
+
+func (nativeX11) Caps() Caps { return Caps{Regions: true, Cursor: true, Windows: true} }
+
+func (b nativeX11) Usable(session detect.SessionType) error {
+	if session != detect.SessionX11 {
+		return needSession(b.Name(), "an X11 session")
+	}
+	return nil
+}
+
+func (nativeX11) Capture(region string, cursor bool) (image.Image, error) {
+	return shoot.CaptureX11(region, cursor)
+}
+
+// portalShot asks the Screenshot portal for the screen and crops it. The
+// portal decides about the pointer.
+type portalShot struct{}
+
+func (portalShot) Name() string { return "portal-pipewire" }
+
+func (portalShot) Caps() Caps { return Caps{Regions: true} }
+
+func (b portalShot) Usable(session detect.SessionType) error {
+	if session != detect.SessionWayland {
+		return needSession(b.Name(), "a Wayland session")
+	}
+	return nil
+}
+
+func (portalShot) Capture(region string, _ bool) (image.Image, error) {
+	return shoot.CaptureWayland(region)
+}
+
+// ffmpegDesktop is ffmpeg's gdigrab on Windows and avfoundation on macOS.
+type ffmpegDesktop struct{}
+
+func (ffmpegDesktop) Name() string { return "ffmpeg-desktop" }
+
+func (ffmpegDesktop) Caps() Caps { return Caps{Regions: true} }
+
+func (b ffmpegDesktop) Usable(session detect.SessionType) error {
+	if session != detect.SessionWindows && session != detect.SessionMac {
+		return needSession(b.Name(), "Windows or macOS")
+	}
+	return needTool(b.Name(), "ffmpeg")
+}
+
+func (ffmpegDesktop) Capture(region string, _ bool) (image.Image, error) {
+	return shoot.CaptureCross(region)
+}
@@ -0,0 +1,203 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"time"
+
+	"github.com/almightynan/swiftcap/internal/detect"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/record"
+	"github.com/almightynan/swiftcap/internal/shoot"
+	"github.com/almightynan/swiftcap/internal/wayland"
+)
+
+// wlrScreencopy records wlroots compositors (Sway, Hyprland, river...)
+// with their screencopy protocol and pipes the frames into ffmpeg. There's
+// no portal, so nobody has to pick a screen and a pause can be a new
+// segment.
+type wlrScreencopy struct{}
+
+func (wlrScreencopy) Name() string { return "wlr-screencopy" }
+
+func (wlrScreencopy) Caps() Caps {
+	return Caps{Regions: true, Cursor: true, Audio: true, Pause: true}
+}
+
+func (b wlrScreencopy) Usable(session detect.SessionType) error {
+	if err := wlrUsable(b.Name(), session); err != nil {
+		return err
+	}
+	return needTool(b.Name(), "ffmpeg")
+}
+
+// wlrUsable is the Usable of both wlr-screencopy backends.
+func wlrUsable(name string, session detect.SessionType) error {
+	if session != detect.SessionWayland {
+		return needSession(name, "a Wayland session")
+	}
+	if !detect.Screencopy() {
+		return scerr.Errorf(scerr.DepMissing, "the %s backend needs a compositor with zwlr_screencopy_manager_v1, such as Sway or Hyprland", name)
+	}
+	return nil
+}
+
+// Start records one output: the one most of o.Region is on, cut to the
+// region, or the first for an empty Region. Frames go to ffmpeg at o.Fps;
+// when the compositor falls behind the last one is sent again, so the
+// video keeps time with the audio.
+func (wlrScreencopy) Start(o record.Options, onProgress func(record.Progress)) (Recording, error) {
+	cl, err := wayland.Connect()
+	if err != nil {
+		return nil, scerr.Wrap(scerr.NoDisplay, err)
+	}
+	out, rect, err := wlrTarget(cl.Outputs(), o.Region)
+	if err != nil {
+		cl.Close()
+		return nil, scerr.Wrap(scerr.InvalidArgs, err)
+	}
+	// the first frame tells ffmpeg what to expect
+	f, err := cl.Capture(out, rect, o.Cursor)
+	if err != nil {
+		cl.Close()
+		return nil, err
+	}
+	// yuv420p wants even sizes; the odd row and column are left out
+	width, height := f.Width&^1, f.Height&^1
+	if width == 0 || height == 0 {
+		cl.Close()
+		return nil, scerr.Errorf(scerr.InvalidArgs, "a %dx%d region is too small to record", f.Width, f.Height)
+	}
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		cl.Close()
+		return nil, err
+	}
+	run, err := startFFmpeg(o, record.ScreencopyCmd(o, width, height, f.PixFmt()), pr, onProgress)
+	// ffmpeg has the read end; ours would keep the pipe open after it exits
+	pr.Close()
+	if err != nil {
+		pw.Close()
+		cl.Close()
+		return nil, err
+	}
+	r := &screencopyRun{ffmpegRun: run, fed: make(chan struct{})}
+	go r.feed(cl, out, rect, o, f, pw, width, height)
+	return r, nil
+}
+
+// wlrTarget finds the output most of region is on and the part of it the
+// region covers, in the output's coordinates; empty means all of it.
+func wlrTarget(outs []wayland.Output, region string) (wayland.Output, image.Rectangle, error) {
+	r, err := shoot.ParseRegion(region)
+	if err != nil {
+		return wayland.Output{}, r, err
+	}
+	if len(outs) == 0 {
+		return wayland.Output{}, r, errors.New("the compositor has no outputs")
+	}
+	if r.Empty() {
+		return outs[0], r, nil
+	}
+	best, area := -1, 0
+	for i, o := range outs {
+		part := r.Intersect(o.Rect())
+		if a := part.Dx() * part.Dy(); a > area {
+			best, area = i, a
+		}
+	}
+	if best < 0 {
+		return wayland.Output{}, r, fmt.Errorf("region %s is on no output", region)
+	}
+	out := outs[best]
+	part := r.Intersect(out.Rect())
+	if part == out.Rect() {
+		return out, image.Rectangle{}, nil
+	}
+	return out, part.Sub(out.Rect().Min), nil
+}
+
+// screencopyRun is ffmpeg and the goroutine feeding it frames.
+type screencopyRun struct {
+	*ffmpegRun
+	fed chan struct{} // closed once the feeding has stopped
+	err error         // why the frames stopped coming, set before fed closes
+}
+
+// feed writes width x height frames to w until ffmpeg stops taking them
+// or the compositor stops giving them. Closing w ends ffmpeg's input, so it
+// finishes the file either way.
+func (r *screencopyRun) feed(cl *wayland.Client, out wayland.Output, rect image.Rectangle, o record.Options, f *wayland.Frame, w io.WriteCloser, width, height int) {
+	defer close(r.fed)
+	defer cl.Close()
+	defer w.Close()
+
+	frame := make([]byte, width*height*4)
+	pack := func(f *wayland.Frame) {
+		for y := 0; y < height; y++ {
+			copy(frame[y*width*4:], f.Row(y)[:width*4])
+		}
+	}
+	pack(f)
+	tick := time.NewTicker(time.Second / time.Duration(o.Fps))
+	defer tick.Stop()
+	start, sent := time.Now(), 0
+	for {
+		due := int(time.Since(start)*time.Duration(o.Fps)/time.Second) + 1
+		if due-sent > o.Fps {
+			// more than a second behind: that's the encoder, and repeats
+			// would only bury it further
+			sent = due - 1
+		}
+		for ; sent < due; sent++ {
+			if _, err := w.Write(frame); err != nil {
+				return // ffmpeg is finishing: interrupted, -t reached, or failed
+			}
+		}
+		select {
+		case <-r.done:
+			return
+		case <-tick.C:
+		}
+		f, err := cl.Capture(out, rect, o.Cursor)
+		if err != nil {
+			r.err = err
+			return
+		}
+		if f.Width < width || f.Height < height {
+			r.err = fmt.Errorf("%s changed size", out.Name)
+			return
+		}
+		pack(f)
+	}
+}
+
+// Wait is ffmpeg's Wait or, when ffmpeg took the end of its input in its
+// stride, why the frames stopped.
+func (r *screencopyRun) Wait() error {
+	err := r.ffmpegRun.Wait()
+	<-r.fed
+	if err == nil && r.err != nil {
+		return fmt.Errorf("screencopy stopped: %w", r.err)
+	}
+	return err
+}
+
+// wlrShot copies the outputs with wlr-screencopy: no portal dialog, and
+// the pointer is ours to show or hide.
+type wlrShot struct{}
+
+func (wlrShot) Name() string { return "wlr-screencopy" }
+
+func (wlrShot) Caps() Caps { return Caps{Regions: true, Cursor: true} }
+
+func (b wlrShot) Usable(session detect.SessionType) error {
+	return wlrUsable(b.Name(), session)
+}
+
+func (wlrShot) Capture(region string, cursor bool) (image.Image, error) {
+	return shoot.CaptureWlr(region, cursor)
+}
@@ -0,0 +1,44 @@
+package backend
+
+import (
+	"image"
+	"testing"
+
+	"github.com/almightynan/swiftcap/internal/wayland"
+)
+
+func TestWlrTarget(t *testing.T) {
+	outs := []wayland.Output{
+		{Index: 0, Name: "eDP-1", Width: 1920, Height: 1080},
+		{Index: 1, Name: "HDMI-A-1", X: 1920, Width: 2560, Height: 1440},
+	}
+	tests := []struct {
+		region  string
+		out     string
+		rect    image.Rectangle
+		wantErr bool
+	}{
+		{"", "eDP-1", image.Rectangle{}, false},
+		{"1920x1080+0+0", "eDP-1", image.Rectangle{}, false},
+		{"2560x1440+1920+0", "HDMI-A-1", image.Rectangle{}, false},
+		{"200x100+10+20", "eDP-1", image.Rect(10, 20, 210, 120), false},
+		{"200x100+2000+50", "HDMI-A-1", image.Rect(80, 50, 280, 150), false},
+		// mostly on the second output, cut to it
+		{"400x100+1800+0", "HDMI-A-1", image.Rect(0, 0, 280, 100), false},
+		{"100x100+5000+5000", "", image.Rectangle{}, true},
+		{"bogus", "", image.Rectangle{}, true},
+	}
+	for _, tt := range tests {
+		out, rect, err := wlrTarget(outs, tt.region)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: err = %v, want an error: %v", tt.region, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (out.Name != tt.out || rect != tt.rect) {
+			t.Errorf("%q: got %s %v, want %s %v", tt.region, out.Name, rect, tt.out, tt.rect)
+		}
+	}
+	if _, _, err := wlrTarget(nil, ""); err == nil {
+		t.Error("no outputs: no error")
+	}
+}
@@ -7,8 +7,14 @@ package cli
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/pflag"
+
+	"github.com/almightynan/swiftcap/internal/config"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/naming"
 )
 
 type Config struct {
@@ -17,8 +23,11 @@ type Config struct {
 	Fps       int
 	Region    string
 	MonitorID string
+	Target    string
+	Decor     string
 	Audio     string
-	ASrc      string
+	ASrc      []string
+	ATracks   string
 	Bitrate   int
 	Container string
 	Cursor    string
@@ -28,50 +37,244 @@ type Config struct {
 	Nice      int
 	Format    string
 	Quality   int
+
+	Compression string
+	Backend     string // capture backend, or auto
+
+	Codec    string
+	Preset   string
+	Crf      int
+	ACodec   string
+	ABitrate int
+
+	AnimFps    int
+	AnimWidth  int
+	Dither     string
+	Loop       int
+	SizeBudget string
+
+	JSON       bool
+	JSONErrors bool // fatal errors as JSON on stderr
+	Progress   string
+
+	Profile  string           // config.toml profile under the flags
+	Settings []config.Setting // effective value of each profile key, after Parse
+
+	ControlSocket string
+	ReplayBuffer  string
+	Discard       bool
+	Args          []string // positional arguments after the mode (ctl, recover, edit, concat, record --headless)
+
+	Headless bool   // record Args on a private Xvfb display
+	Size     string // its screen, WxH
+	WM       string // window manager to run on it
+
+	Trim    string
+	Crop    string
+	Scale   string
+	Speed   float64
+	Precise bool
 }
 
 func Parse(args []string) (Config, error) {
 	var cfg Config
 	flags := pflag.NewFlagSet("swiftcap", pflag.ContinueOnError)
-	flags.StringVar(&cfg.Out, "out", "", "Output file")
+	flags.StringVar(&cfg.Out, "out", "", "Output file, or a name template like {date}/{time}_{mode}.{ext} (record, screenshot)")
 	flags.IntVar(&cfg.Fps, "fps", 0, "Frames per second")
 	flags.StringVar(&cfg.Region, "region", "", "Region WxH+X+Y (default: full display)")
-	flags.StringVar(&cfg.MonitorID, "monitor", "", "Monitor ID")
+	flags.StringVar(&cfg.MonitorID, "monitor", "", "Monitor name|index|primary|focused")
+	flags.StringVar(&cfg.Target, "target", "", "Capture target fullscreen|monitor|active-window|pick-window|window:<id|class> (X11)")
+	flags.StringVar(&cfg.Decor, "decorations", "on", "Include window decorations for window targets on|off")
 	flags.StringVar(&cfg.Audio, "audio", "off", "Audio on|off")
-	flags.StringVar(&cfg.ASrc, "a-src", "default", "Audio source name")
+	flags.StringSliceVar(&cfg.ASrc, "a-src", []string{"default"}, "Audio source NAME[:GAIN], desktop or mic, repeatable; gain as a factor or in dB, e.g. mic:-6dB")
+	flags.StringVar(&cfg.ATracks, "audio-tracks", "mix", "With several --a-src: mix into one track, or separate tracks (mp4|mkv|mov)")
 	flags.IntVar(&cfg.Bitrate, "bitrate", 0, "Bitrate in kbit")
-	flags.StringVar(&cfg.Container, "container", "mp4", "Container mp4|mkv")
+	flags.StringVar(&cfg.Container, "container", "mp4", "Container mp4|mkv|mov|avi|webm|gif|webp|apng")
+	flags.StringVar(&cfg.Codec, "codec", "x264", "Video codec x264|x265|vp9|av1|av1-aom|ffv1")
+	flags.StringVar(&cfg.Preset, "preset", "", "Encoder preset (default: codec's fastest sensible)")
+	flags.IntVar(&cfg.Crf, "crf", -1, "Constant rate factor, overrides --bitrate (-1 = off)")
+	flags.StringVar(&cfg.ACodec, "a-codec", "", "Audio codec aac|opus|vorbis|mp3|flac|pcm (default: per container)")
+	flags.IntVar(&cfg.ABitrate, "a-bitrate", 128, "Audio bitrate in kbit")
+	flags.IntVar(&cfg.AnimFps, "anim-fps", 15, "Frame rate of gif|webp|apng output (0 = as recorded)")
+	flags.IntVar(&cfg.AnimWidth, "anim-width", 960, "Max width of gif|webp|apng output, height follows (0 = as recorded)")
+	flags.StringVar(&cfg.Dither, "dither", "sierra2_4a", "GIF/APNG dithering sierra2_4a|sierra2|floyd_steinberg|bayer|heckbert|none")
+	flags.IntVar(&cfg.Loop, "loop", 0, "Times gif|webp|apng output plays (0 = forever)")
+	flags.StringVar(&cfg.SizeBudget, "size-budget", "10M", "Warn when gif|webp|apng output is bigger than this (0 = never)")
 	flags.StringVar(&cfg.Cursor, "cursor", "on", "Cursor on|off")
+	flags.StringVar(&cfg.Backend, "backend", "auto", "Capture backend, or auto to pick one (see swiftcap backends)")
 	flags.IntVar(&cfg.MaxDur, "max-dur", 0, "Max duration (secs)")
 	flags.IntVar(&cfg.Threads, "threads", 0, "Threads")
 	flags.IntVar(&cfg.Qp, "qp", 0, "QP value")
 	flags.IntVar(&cfg.Nice, "nice", 0, "Nice value")
-	flags.StringVar(&cfg.Format, "format", "png", "Screenshot format png|jpg")
-	flags.IntVar(&cfg.Quality, "quality", 100, "Screenshot quality 1-100")
+	flags.StringVar(&cfg.Format, "format", "png", "Screenshot format png|jpg|webp|webp-lossless (default: from --out, else png)")
+	flags.IntVar(&cfg.Quality, "quality", 100, "Screenshot quality 1-100 (jpg, webp)")
+	flags.StringVar(&cfg.Compression, "compression", "default", "PNG compression default|none|fast|best")
+	flags.BoolVar(&cfg.JSON, "json", false, "Print machine-readable JSON (monitors, audio-sources, config, doctor, backends)")
+	flags.BoolVar(&cfg.JSONErrors, "json-errors", false, "Report fatal errors as a JSON object on stderr, with the exit code and its E_NAME")
+	flags.StringVar(&cfg.Progress, "progress", "tty", "Record progress output tty|json|none (json: NDJSON events on stdout)")
+	flags.StringVar(&cfg.Profile, "profile", "", "Settings profile from "+config.Path()+"; flags override it")
+	flags.StringVar(&cfg.ControlSocket, "control-socket", "", "Unix socket for pausing and controlling a recording (record, ctl)")
+	flags.StringVar(&cfg.ReplayBuffer, "replay-buffer", "", "Keep the last N of the screen, e.g. 60s, and save it on `ctl save-replay` or SIGUSR1 (record, X11)")
+	flags.BoolVar(&cfg.Headless, "headless", false, "Record the command after -- on a private Xvfb display, stop when it exits and exit with its status (record)")
+	flags.StringVar(&cfg.Size, "size", "1920x1080", "Screen size WxH of the --headless display")
+	flags.StringVar(&cfg.WM, "wm", "", "Window manager to run on the --headless display, e.g. openbox (default: none)")
+	flags.BoolVar(&cfg.Discard, "discard", false, "Delete unfinished recordings instead of recovering them (recover)")
+	flags.StringVar(&cfg.Trim, "trim", "", "Keep START-END, e.g. 00:05-01:20; either side may be left out (edit)")
+	flags.StringVar(&cfg.Crop, "crop", "", "Crop to WxH+X+Y (edit)")
+	flags.StringVar(&cfg.Scale, "scale", "", "Scale to WxH, one side -1 or -2 to keep the aspect ratio, e.g. 1280x-2 (edit)")
+	flags.Float64Var(&cfg.Speed, "speed", 1, "Playback speed, e.g. 1.5 or 0.5 (edit)")
+	flags.BoolVar(&cfg.Precise, "precise", false, "Re-encode a trim to cut on the exact frame instead of the keyframe before it (edit)")
 
 	if len(args) == 0 {
 		fmt.Println("\033[1;36mSwiftCap\033[0m - Fast, low-resource, cross-platform screen recorder and screenshot CLI")
 		fmt.Println()
 		fmt.Println("Usage:")
 		fmt.Println("  swiftcap record --out <file> [options]   Record screen")
+		fmt.Println("  swiftcap record --headless --out <file> [options] -- <command> [args]   Record a command on a virtual display")
 		fmt.Println("  swiftcap screenshot --out <file> [options]   Take screenshot")
+		fmt.Println("  swiftcap monitors [--json]   List monitors")
+		fmt.Println("  swiftcap audio-sources [--json]   List audio sources for --a-src")
+		fmt.Println("  swiftcap ctl status|pause|resume|stop|marker [label]|save-replay [file] --control-socket <path>   Control a recording")
+		fmt.Println("  swiftcap recover [<id>|all] [--discard]   List, recover or discard recordings a crash left behind")
+		fmt.Println("  swiftcap edit <in> --out <file> [--trim A-B] [--crop WxH+X+Y] [--scale WxH] [--speed N]   Edit a capture")
+		fmt.Println("  swiftcap concat <a> <b>... [--out <file>]   Join captures end to end")
+		fmt.Println("  swiftcap config show|validate [--profile <name>]   Print the effective settings or check config.toml")
+		fmt.Println("  swiftcap doctor [--json]   Check ffmpeg, GStreamer, portals and the rest of what captures need")
+		fmt.Println("  swiftcap backends [--json]   List capture backends, what they can do and which auto picks")
 		fmt.Println()
 		fmt.Println("Options:")
 		flags.PrintDefaults()
 		fmt.Println()
 		fmt.Println("Examples:")
 		fmt.Println("  swiftcap record --out video.mp4 --audio on")
+		fmt.Println("  swiftcap record --out demo.mkv --a-src desktop --a-src mic:1.5 --audio-tracks separate")
+		fmt.Println("  swiftcap record --out video.webm --container webm --codec vp9 --crf 32")
+		fmt.Println("  swiftcap record --out demo.gif --container gif --anim-width 640 --max-dur 10")
+		fmt.Println("  swiftcap edit talk.mp4 --trim 00:05-01:20 --scale 1280x-2 --out clip.mp4")
 		fmt.Println("  swiftcap screenshot --out shot.png --region 800x600+100+100")
+		fmt.Println("  swiftcap record --out video.mp4 --monitor primary")
+		fmt.Println("  swiftcap record --out 'captures/{date}/{time}_{monitor}_{window_class}.{ext}' --target active-window")
+		fmt.Println("  swiftcap record --out demo.mp4 --profile demo-60fps --fps 30")
+		fmt.Println("  swiftcap record --out video.mp4 --progress=json | jq -c .")
+		fmt.Println("  swiftcap screenshot --out win.png --target pick-window")
+		fmt.Println("  swiftcap record --out video.mkv --backend gstreamer-ximagesrc --codec vp9")
+		fmt.Println("  swiftcap screenshot --out - --format webp --quality 80 > shot.webp")
+		fmt.Println("  swiftcap record --out talk.mp4 --control-socket /tmp/sc.sock & swiftcap ctl pause --control-socket /tmp/sc.sock")
+		fmt.Println("  swiftcap record --headless --size 1280x720 --wm openbox --out e2e.mp4 -- npm test")
+		fmt.Println("  swiftcap record --out bug.mp4 --replay-buffer 60s --control-socket /tmp/sc.sock & swiftcap ctl save-replay --control-socket /tmp/sc.sock")
+		fmt.Println()
+		fmt.Println("Exit codes:")
+		for _, c := range scerr.Codes {
+			fmt.Printf("  %-4d%-19s%s\n", int(c.Code), c.Code.Name(), c.Desc)
+		}
 		os.Exit(0)
 	}
 
 	cfg.Mode = args[0]
 	args = args[1:]
 	if err := flags.Parse(args); err != nil {
-		return cfg, fmt.Errorf("\033[1;31mError:\033[0m %v", err)
+		// the flag may be past the one that failed
+		for _, a := range args {
+			cfg.JSONErrors = cfg.JSONErrors || a == "--json-errors"
+		}
+		return cfg, scerr.Wrap(scerr.InvalidArgs, err)
+	}
+	cfg.Args = flags.Args()
+	if err := applyProfile(flags, &cfg); err != nil {
+		return cfg, scerr.Wrap(scerr.InvalidArgs, err)
+	}
+	if flags.Changed("a-src") && !flags.Changed("audio") {
+		// naming a source means you want it recorded
+		cfg.Audio = "on"
+	}
+	if cfg.Mode == "screenshot" && !flags.Changed("format") {
+		if f := formatFromExt(cfg.Out); f != "" {
+			cfg.Format = f
+		}
 	}
-	if (cfg.Mode == "record" || cfg.Mode == "screenshot") && cfg.Out == "" {
-		return cfg, fmt.Errorf("\033[1;31mError:\033[0m --out is required for %s", cfg.Mode)
+	if (cfg.Mode == "edit" || cfg.Mode == "concat") && !flags.Changed("container") {
+		// the output's extension says what to write, like --format for screenshots
+		name := cfg.Out
+		if name == "" && len(cfg.Args) > 0 {
+			name = cfg.Args[0]
+		}
+		if c := containerFromExt(name); c != "" {
+			cfg.Container = c
+		}
+	}
+	if (cfg.Mode == "record" || cfg.Mode == "screenshot" || cfg.Mode == "edit") && cfg.Out == "" {
+		return cfg, scerr.New(scerr.InvalidArgs, "--out is required for "+cfg.Mode)
+	}
+	if (cfg.Mode == "record" || cfg.Mode == "screenshot") && naming.IsTemplate(cfg.Out) {
+		// filled in once the capture target is known
+		if _, err := naming.Parse(cfg.Out); err != nil {
+			return cfg, scerr.Errorf(scerr.InvalidArgs, "--out: %v", err)
+		}
 	}
 	return cfg, nil
 }
+
+// applyProfile fills every setting the command line left alone from
+// --profile, then records where each setting's value came from.
+func applyProfile(flags *pflag.FlagSet, cfg *Config) error {
+	origin := map[string]string{}
+	flags.Visit(func(f *pflag.Flag) { origin[f.Name] = "flag" })
+	if cfg.Profile != "" {
+		file, err := config.Load(config.Path())
+		if err != nil {
+			return err
+		}
+		p, err := file.Profile(cfg.Profile)
+		if err != nil {
+			return err
+		}
+		for _, s := range p.Settings() {
+			if origin[s.Name] == "flag" {
+				continue
+			}
+			if err := flags.Set(s.Name, s.Value); err != nil {
+				return fmt.Errorf("profile %s: %s = %q: %v", cfg.Profile, s.Name, s.Value, err)
+			}
+			origin[s.Name] = "profile " + cfg.Profile
+		}
+	}
+	cfg.Settings = cfg.Settings[:0]
+	for _, name := range config.Keys {
+		f := flags.Lookup(name)
+		value := f.Value.String()
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			value = strings.Join(sv.GetSlice(), ",")
+		}
+		o := origin[name]
+		if o == "" {
+			o = "default"
+		}
+		cfg.Settings = append(cfg.Settings, config.Setting{Name: name, Value: value, Origin: o})
+	}
+	return nil
+}
+
+// containerFromExt picks a container from a video file's extension.
+func containerFromExt(name string) string {
+	switch ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), ".")); ext {
+	case "mp4", "mkv", "mov", "avi", "webm":
+		return ext
+	case "m4v":
+		return "mp4"
+	}
+	return ""
+}
+
+// formatFromExt picks a screenshot format from the output name, so
+// --out shot.jpg does what it says without --format.
+func formatFromExt(out string) string {
+	switch strings.ToLower(filepath.Ext(out)) {
+	case ".png":
+		return "png"
+	case ".jpg", ".jpeg":
+		return "jpg"
+	case ".webp":
+		return "webp"
+	}
+	return ""
+}
@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+)
+
+const profiles = `
+[profiles.demo]
+fps = 60
+crf = 20
+container = "mkv"
+a-src = ["desktop", "mic"]
+
+[profiles.quiet]
+audio = false
+cursor = false
+`
+
+func TestApplyProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(profiles), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SWIFTCAP_CONFIG", path)
+
+	tests := []struct {
+		name string
+		args []string
+		want map[string]string // setting = value (origin)
+	}{
+		{
+			name: "defaults",
+			args: []string{"record", "--out", "a.mp4"},
+			want: map[string]string{"fps": "0 (default)", "crf": "-1 (default)", "container": "mp4 (default)", "a-src": "default (default)"},
+		},
+		{
+			name: "profile",
+			args: []string{"record", "--out", "a.mkv", "--profile", "demo"},
+			want: map[string]string{
+				"fps":       "60 (profile demo)",
+				"crf":       "20 (profile demo)",
+				"container": "mkv (profile demo)",
+				"a-src":     "desktop,mic (profile demo)",
+				"codec":     "x264 (default)",
+			},
+		},
+		{
+			name: "flag beats profile",
+			args: []string{"record", "--out", "a.mkv", "--profile", "demo", "--fps", "30", "--a-src", "mic"},
+			want: map[string]string{
+				"fps":   "30 (flag)",
+				"crf":   "20 (profile demo)",
+				"a-src": "mic (flag)",
+			},
+		},
+		{
+			name: "profile turns off",
+			args: []string{"record", "--out", "a.mp4", "--profile", "quiet", "--audio", "on"},
+			want: map[string]string{"audio": "on (flag)", "cursor": "off (profile quiet)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]string{}
+			for _, s := range cfg.Settings {
+				got[s.Name] = s.Value + " (" + s.Origin + ")"
+			}
+			if len(cfg.Settings) != len(got) {
+				t.Errorf("a setting is reported twice: %v", cfg.Settings)
+			}
+			for name, want := range tt.want {
+				if got[name] != want {
+					t.Errorf("%s = %s, want %s", name, got[name], want)
+				}
+			}
+		})
+	}
+
+	// the profile lands in the Config as well as the report, and its a-src
+	// turns audio on like the flag does
+	cfg, err := Parse([]string{"record", "--out", "a.mkv", "--profile", "demo", "--fps", "30"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Fps != 30 || cfg.Crf != 20 || cfg.Container != "mkv" || strings.Join(cfg.ASrc, ",") != "desktop,mic" || cfg.Audio != "on" {
+		t.Errorf("Parse = fps %d crf %d container %s a-src %v audio %s", cfg.Fps, cfg.Crf, cfg.Container, cfg.ASrc, cfg.Audio)
+	}
+}
+
+func TestApplyProfileErrors(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(good, []byte(profiles), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(dir, "bad.toml")
+	if err := os.WriteFile(bad, []byte("[profiles.demo]\nfsp = 60\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		config  string
+		profile string
+		wantErr string
+	}{
+		{"no such profile", good, "nope", `no profile "nope"`},
+		{"no config", filepath.Join(dir, "missing.toml"), "demo", "has no profiles"},
+		{"unknown key", bad, "demo", "unknown setting profiles.demo.fsp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SWIFTCAP_CONFIG", tt.config)
+			_, err := Parse([]string{"record", "--out", "a.mp4", "--profile", tt.profile})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Parse = %v, want an error about %q", err, tt.wantErr)
+			}
+			if code := scerr.CodeOf(err); code != scerr.InvalidArgs {
+				t.Errorf("exit code %v, want InvalidArgs", code)
+			}
+		})
+	}
+}
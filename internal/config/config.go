@@ -0,0 +1,191 @@
+/*
+	the shared config file, ~/.config/swiftcap/config.toml. it holds named
+	profiles of capture settings that the cli (--profile) and the app (the
+	profile switcher) both read, so the two agree. a profile's keys are the
+	record and screenshot flags' names:
+
+		[profiles.demo-60fps]
+		fps = 60
+		crf = 20
+		audio = true
+		a-src = ["desktop", "mic"]
+
+	flags beat the profile and the profile beats the defaults.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Profile is one named set of settings. nil fields are left alone.
+type Profile struct {
+	Fps         *int     `toml:"fps"`
+	Bitrate     *int     `toml:"bitrate"`
+	Container   *string  `toml:"container"`
+	Codec       *string  `toml:"codec"`
+	Preset      *string  `toml:"preset"`
+	Crf         *int     `toml:"crf"`
+	Audio       *bool    `toml:"audio"`
+	ASrc        []string `toml:"a-src"`
+	AudioTracks *string  `toml:"audio-tracks"`
+	ACodec      *string  `toml:"a-codec"`
+	ABitrate    *int     `toml:"a-bitrate"`
+	Cursor      *bool    `toml:"cursor"`
+	MaxDur      *int     `toml:"max-dur"`
+	Threads     *int     `toml:"threads"`
+	Qp          *int     `toml:"qp"`
+	Nice        *int     `toml:"nice"`
+	AnimFps     *int     `toml:"anim-fps"`
+	AnimWidth   *int     `toml:"anim-width"`
+	Dither      *string  `toml:"dither"`
+	Loop        *int     `toml:"loop"`
+	SizeBudget  *string  `toml:"size-budget"`
+	Format      *string  `toml:"format"`
+	Quality     *int     `toml:"quality"`
+	Compression *string  `toml:"compression"`
+	Backend     *string  `toml:"backend"`
+}
+
+// Keys are the settings a profile can hold, in the order they're shown.
+var Keys = []string{
+	"fps", "bitrate", "container", "codec", "preset", "crf",
+	"audio", "a-src", "audio-tracks", "a-codec", "a-bitrate", "cursor",
+	"max-dur", "threads", "qp", "nice",
+	"anim-fps", "anim-width", "dither", "loop", "size-budget",
+	"format", "quality", "compression", "backend",
+}
+
+// Setting is one key's value as the flag would take it. Origin says where
+// an effective value came from: "flag", "profile <name>" or "default".
+type Setting struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Origin string `json:"origin,omitempty"`
+}
+
+// Settings lists the keys p sets as flag values. a-src comes once per
+// source, the way the flag repeats.
+func (p Profile) Settings() []Setting {
+	var out []Setting
+	add := func(name, value string) { out = append(out, Setting{Name: name, Value: value}) }
+	num := func(name string, v *int) {
+		if v != nil {
+			add(name, strconv.Itoa(*v))
+		}
+	}
+	text := func(name string, v *string) {
+		if v != nil {
+			add(name, *v)
+		}
+	}
+	onOff := func(name string, v *bool) {
+		switch {
+		case v == nil:
+		case *v:
+			add(name, "on")
+		default:
+			add(name, "off")
+		}
+	}
+	num("fps", p.Fps)
+	num("bitrate", p.Bitrate)
+	text("container", p.Container)
+	text("codec", p.Codec)
+	text("preset", p.Preset)
+	num("crf", p.Crf)
+	onOff("audio", p.Audio)
+	for _, s := range p.ASrc {
+		add("a-src", s)
+	}
+	text("audio-tracks", p.AudioTracks)
+	text("a-codec", p.ACodec)
+	num("a-bitrate", p.ABitrate)
+	onOff("cursor", p.Cursor)
+	num("max-dur", p.MaxDur)
+	num("threads", p.Threads)
+	num("qp", p.Qp)
+	num("nice", p.Nice)
+	num("anim-fps", p.AnimFps)
+	num("anim-width", p.AnimWidth)
+	text("dither", p.Dither)
+	num("loop", p.Loop)
+	text("size-budget", p.SizeBudget)
+	text("format", p.Format)
+	num("quality", p.Quality)
+	text("compression", p.Compression)
+	text("backend", p.Backend)
+	return out
+}
+
+// File is a parsed config.toml.
+type File struct {
+	Path     string             `toml:"-"`
+	Profiles map[string]Profile `toml:"profiles"`
+}
+
+// Path is where the config lives: $SWIFTCAP_CONFIG, else
+// $XDG_CONFIG_HOME/swiftcap/config.toml (~/.config by default).
+func Path() string {
+	if p := os.Getenv("SWIFTCAP_CONFIG"); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = filepath.Join(os.TempDir(), "swiftcap-config")
+	}
+	return filepath.Join(dir, "swiftcap", "config.toml")
+}
+
+// Load reads the config at path. a missing file is an empty config, not an
+// error; a key that isn't a setting is.
+func Load(path string) (*File, error) {
+	f := &File{Path: path, Profiles: map[string]Profile{}}
+	md, err := toml.DecodeFile(path, f)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if keys := md.Undecoded(); len(keys) > 0 {
+		names := make([]string, len(keys))
+		for i, k := range keys {
+			names[i] = k.String()
+		}
+		return nil, fmt.Errorf("%s: unknown setting %s (profile keys are: %s)",
+			path, strings.Join(names, ", "), strings.Join(Keys, ", "))
+	}
+	return f, nil
+}
+
+// Names lists the profiles, sorted.
+func (f *File) Names() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for n := range f.Profiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Profile looks up a profile by name.
+func (f *File) Profile(name string) (Profile, error) {
+	p, ok := f.Profiles[name]
+	if !ok {
+		if len(f.Profiles) == 0 {
+			return p, fmt.Errorf("no profile %q: %s has no profiles", name, f.Path)
+		}
+		return p, fmt.Errorf("no profile %q in %s (have: %s)", name, f.Path, strings.Join(f.Names(), ", "))
+	}
+	return p, nil
+}
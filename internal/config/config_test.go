@@ -0,0 +1,134 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		toml    string // "" = no file
+		wantErr string
+		want    []Setting // the demo profile's settings
+	}{
+		{name: "missing file"},
+		{name: "empty", toml: "\n"},
+		{
+			name: "profile",
+			toml: `
+[profiles.demo]
+fps = 60
+crf = 20
+audio = true
+a-src = ["desktop", "mic"]
+cursor = false
+size-budget = "8M"
+`,
+			want: []Setting{
+				{Name: "fps", Value: "60"},
+				{Name: "crf", Value: "20"},
+				{Name: "audio", Value: "on"},
+				{Name: "a-src", Value: "desktop"},
+				{Name: "a-src", Value: "mic"},
+				{Name: "cursor", Value: "off"},
+				{Name: "size-budget", Value: "8M"},
+			},
+		},
+		{name: "unknown key", toml: "[profiles.demo]\nfsp = 60\n", wantErr: "unknown setting profiles.demo.fsp"},
+		{name: "unknown table", toml: "[profile.demo]\nfps = 60\n", wantErr: "unknown setting profile"},
+		{name: "wrong type", toml: "[profiles.demo]\nfps = \"sixty\"\n", wantErr: "fps"},
+		{name: "broken", toml: "[profiles.demo\n", wantErr: "config.toml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if tt.toml != "" {
+				if err := os.WriteFile(path, []byte(tt.toml), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			f, err := Load(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load = %v, want an error about %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Path != path {
+				t.Errorf("Path = %q, want %q", f.Path, path)
+			}
+			if tt.want == nil {
+				if len(f.Profiles) != 0 {
+					t.Errorf("Profiles = %v, want none", f.Profiles)
+				}
+				return
+			}
+			p, err := f.Profile("demo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := p.Settings()
+			if len(got) != len(tt.want) {
+				t.Fatalf("Settings = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Settings[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestProfile(t *testing.T) {
+	empty := &File{Path: "config.toml"}
+	if _, err := empty.Profile("demo"); err == nil || !strings.Contains(err.Error(), "has no profiles") {
+		t.Errorf("Profile on an empty file = %v", err)
+	}
+	f := &File{Path: "config.toml", Profiles: map[string]Profile{"b": {}, "a": {}}}
+	if _, err := f.Profile("c"); err == nil || !strings.Contains(err.Error(), "(have: a, b)") {
+		t.Errorf("Profile(%q) = %v, want the profiles listed", "c", err)
+	}
+	if _, err := f.Profile("b"); err != nil {
+		t.Errorf("Profile(%q) = %v", "b", err)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	// every key is a profile field, so Load and Settings agree with Keys
+	var body strings.Builder
+	body.WriteString("[profiles.all]\n")
+	for _, k := range Keys {
+		switch k {
+		case "audio", "cursor":
+			body.WriteString(k + " = true\n")
+		case "a-src":
+			body.WriteString(k + " = [\"mic\"]\n")
+		case "container", "codec", "preset", "audio-tracks", "a-codec", "dither", "size-budget", "format", "compression", "backend":
+			body.WriteString(k + " = \"x\"\n")
+		default:
+			body.WriteString(k + " = 1\n")
+		}
+	}
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(body.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range f.Profiles["all"].Settings() {
+		names = append(names, s.Name)
+	}
+	if strings.Join(names, " ") != strings.Join(Keys, " ") {
+		t.Errorf("Settings names = %v, want %v", names, Keys)
+	}
+}
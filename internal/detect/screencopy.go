@@ -0,0 +1,10 @@
+package detect
+
+import "github.com/almightynan/swiftcap/internal/wayland"
+
+// Screencopy reports whether the Wayland compositor advertises wlroots'
+// screencopy protocol (Sway, Hyprland, river, Wayfire...), which lets us
+// capture without the portal and its chooser.
+func Screencopy() bool {
+	return wayland.Advertises("zwlr_screencopy_manager_v1")
+}
@@ -8,6 +8,8 @@ package detect
 import (
 	"os"
 	"runtime"
+
+	scerr "github.com/almightynan/swiftcap/internal/errors"
 )
 
 type SessionType int
@@ -41,10 +43,4 @@ func Session() (SessionType, error) {
 	}
 }
 
-var ErrNoDisplay = &NoDisplayError{}
-
-type NoDisplayError struct{}
-
-func (e *NoDisplayError) Error() string {
-	return "E_NO_DISPLAY=10: No display/session detected or unsupported OS."
-}
+var ErrNoDisplay = scerr.New(scerr.NoDisplay, "No display/session detected or unsupported OS.")
@@ -0,0 +1,458 @@
+/*
+	doctor looks at what swiftcap needs from the system - a session, ffmpeg
+	and its encoders, gstreamer, the portals, a clipboard tool, a notification
+	service, a sound server, somewhere to write - and says what's missing and
+	how to get it. the cli prints the report, the ui shows it in the
+	diagnostics panel.
+
+	every check is independent and cheap; external tools are run with a
+	timeout so a wedged one can't hang the report.
+*/
+
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/almightynan/swiftcap/internal/detect"
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/portal"
+	"github.com/almightynan/swiftcap/internal/pulse"
+	"github.com/almightynan/swiftcap/internal/record"
+)
+
+// Status is how a check went.
+type Status string
+
+const (
+	Pass Status = "pass"
+	Warn Status = "warn" // works, but something is limited
+	Fail Status = "fail" // a capture path swiftcap uses here won't work
+)
+
+// Check is one line of the report. Code is the exit code the failure maps
+// to; Hint says how to fix it.
+type Check struct {
+	Name   string     `json:"name"`
+	Status Status     `json:"status"`
+	Detail string     `json:"detail"`
+	Hint   string     `json:"hint,omitempty"`
+	Code   scerr.Code `json:"code,omitempty"`
+}
+
+// Report is the outcome of every check, in the order they ran.
+type Report struct {
+	Session string  `json:"session"`
+	Checks  []Check `json:"checks"`
+}
+
+// Dir is an output folder to check for writability.
+type Dir struct {
+	Name string
+	Path string
+}
+
+// Options say what else to look at.
+type Options struct {
+	Dirs  []Dir
+	Extra []Check // checks the caller already made, appended as they are
+}
+
+// timeout bounds each external command.
+const timeout = 5 * time.Second
+
+// Run makes every check.
+func Run(opts Options) Report {
+	session, serr := detect.Session()
+	r := Report{Session: sessionName(session)}
+	add := func(c Check) { r.Checks = append(r.Checks, c) }
+
+	add(checkSession(session, serr))
+	ff := checkFFmpeg()
+	add(ff)
+	if ff.Status != Fail {
+		add(checkEncoders())
+		add(checkMuxers())
+		add(checkDevice("x11grab", "X11 capture", session == detect.SessionX11,
+			"install an ffmpeg built with --enable-libxcb (most distro packages are)"))
+		add(checkDevice("pulse", "PulseAudio input", false,
+			"install an ffmpeg built with --enable-libpulse to record audio"))
+	}
+	// wlroots compositors can do without the portal and GStreamer
+	portalOnly := session == detect.SessionWayland && !detect.Screencopy()
+	if session == detect.SessionWayland {
+		add(checkScreencopy(!portalOnly))
+	}
+	add(checkGStreamer(portalOnly))
+	add(checkPortal("ScreenCast", portalOnly))
+	add(checkPortal("Screenshot", portalOnly))
+	add(checkSoundServer())
+	add(checkClipboard(session))
+	add(checkXvfb())
+	add(checkNotifications())
+	for _, d := range opts.Dirs {
+		add(checkDir(d))
+	}
+	r.Checks = append(r.Checks, opts.Extra...)
+	return r
+}
+
+// Worst is the worst status in the report.
+func (r Report) Worst() Status {
+	worst := Pass
+	for _, c := range r.Checks {
+		switch {
+		case c.Status == Fail:
+			return Fail
+		case c.Status == Warn:
+			worst = Warn
+		}
+	}
+	return worst
+}
+
+// Failed is the first failed check, if any.
+func (r Report) Failed() (Check, bool) {
+	for _, c := range r.Checks {
+		if c.Status == Fail {
+			return c, true
+		}
+	}
+	return Check{}, false
+}
+
+func sessionName(s detect.SessionType) string {
+	switch s {
+	case detect.SessionWayland:
+		return "wayland"
+	case detect.SessionX11:
+		return "x11"
+	case detect.SessionWindows:
+		return "windows"
+	case detect.SessionMac:
+		return "mac"
+	case detect.SessionNone:
+		return "none"
+	}
+	return "unknown"
+}
+
+func checkSession(s detect.SessionType, err error) Check {
+	c := Check{Name: "session", Status: Pass, Detail: sessionName(s)}
+	if err != nil {
+		c.Status, c.Code = Fail, scerr.NoDisplay
+		c.Detail = err.Error()
+		c.Hint = "run from a desktop session, or set DISPLAY or WAYLAND_DISPLAY"
+	}
+	return c
+}
+
+// output runs name with args and returns what it printed.
+func output(name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var out, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", scerr.Errorf(scerr.Timeout, "%s did not answer in %s", name, timeout)
+		}
+		return "", scerr.Classify(name, err, stderr.String())
+	}
+	return out.String(), nil
+}
+
+func checkFFmpeg() Check {
+	c := Check{Name: "ffmpeg", Status: Pass}
+	out, err := output("ffmpeg", "-hide_banner", "-version")
+	if err != nil {
+		c.Status, c.Code = Fail, scerr.CodeOf(err)
+		c.Detail = err.Error()
+		c.Hint = "install ffmpeg from your package manager"
+		return c
+	}
+	c.Detail = strings.TrimSpace(strings.SplitN(out, "\n", 2)[0])
+	return c
+}
+
+// listed parses the table ffmpeg prints for -encoders, -muxers and
+// -devices: a flags column, the name(s), a description. It maps each name
+// to its flags.
+func listed(arg string) map[string]string {
+	out, err := output("ffmpeg", "-hide_banner", arg)
+	if err != nil {
+		return nil
+	}
+	m := map[string]string{}
+	body := false
+	for _, l := range strings.Split(out, "\n") {
+		f := strings.Fields(l)
+		if len(f) >= 1 && strings.HasPrefix(f[0], "--") {
+			body = true // the legend is above the dashes
+			continue
+		}
+		if !body || len(f) < 2 {
+			continue
+		}
+		for _, name := range strings.Split(f[1], ",") {
+			m[name] = f[0]
+		}
+	}
+	return m
+}
+
+func checkEncoders() Check {
+	c := Check{Name: "ffmpeg encoders", Status: Pass}
+	have := listed("-encoders")
+	var missing []string
+	for _, n := range record.EncoderNames() {
+		if e, _ := record.LookupEncoder(n); have[e.FFmpeg] == "" {
+			missing = append(missing, fmt.Sprintf("%s (%s)", n, e.FFmpeg))
+		}
+	}
+	for _, n := range record.AudioCodecNames() {
+		if a, _ := record.LookupAudioCodec(n); have[a.FFmpeg] == "" {
+			missing = append(missing, fmt.Sprintf("%s audio (%s)", n, a.FFmpeg))
+		}
+	}
+	x264, _ := record.LookupEncoder("x264")
+	switch {
+	case have[x264.FFmpeg] == "":
+		c.Status, c.Code = Fail, scerr.DepMissing
+		c.Detail = "missing the default encoder, " + strings.Join(missing, ", ")
+		c.Hint = "install an ffmpeg built with libx264 (e.g. from RPM Fusion or the ffmpeg-full package)"
+	case len(missing) > 0:
+		c.Status = Warn
+		c.Detail = "not available: " + strings.Join(missing, ", ")
+		c.Hint = "those --codec/--a-codec values won't work; a fuller ffmpeg build has them"
+	default:
+		c.Detail = fmt.Sprintf("all %d codecs available", len(record.EncoderNames())+len(record.AudioCodecNames()))
+	}
+	return c
+}
+
+func checkMuxers() Check {
+	c := Check{Name: "ffmpeg muxers", Status: Pass}
+	have := listed("-muxers")
+	var missing []string
+	for _, ct := range record.Containers() {
+		if !strings.Contains(have[record.Muxer(ct)], "E") {
+			missing = append(missing, ct)
+		}
+	}
+	if len(missing) > 0 {
+		c.Status = Warn
+		c.Detail = "can't write: " + strings.Join(missing, ", ")
+		c.Hint = "those --container values won't work; a fuller ffmpeg build has them"
+		if !strings.Contains(have["mp4"], "E") {
+			c.Status, c.Code = Fail, scerr.DepMissing
+		}
+		return c
+	}
+	c.Detail = strings.Join(record.Containers(), ", ")
+	return c
+}
+
+// checkDevice looks for an ffmpeg input device; required makes its absence
+// a failure rather than a warning.
+func checkDevice(name, what string, required bool, hint string) Check {
+	c := Check{Name: "ffmpeg " + name, Status: Pass, Detail: what + " available"}
+	if strings.Contains(listed("-devices")[name], "D") {
+		return c
+	}
+	c.Status, c.Detail, c.Hint = Warn, what+" not built in", hint
+	if required {
+		c.Status, c.Code = Fail, scerr.DepMissing
+	}
+	return c
+}
+
+// gstCore are the elements a default Wayland recording needs; gstExtra the
+// ones other codecs, containers and audio need.
+var (
+	gstCore  = []string{"pipewiresrc", "videoconvert", "videorate", "x264enc", "h264parse", "mp4mux", "queue", "filesink"}
+	gstExtra = []string{"pulsesrc", "x265enc", "h265parse", "vp9enc", "svtav1enc", "av1enc", "av1parse", "aacparse",
+		"opusenc", "vorbisenc", "lamemp3enc", "flacenc", "qtmux", "matroskamux", "webmmux", "avimux"}
+)
+
+func checkGStreamer(required bool) Check {
+	c := Check{Name: "gstreamer", Status: Pass}
+	bad := Warn
+	if required {
+		bad = Fail
+	}
+	if _, err := exec.LookPath("gst-launch-1.0"); err != nil {
+		c.Status, c.Detail = bad, "gst-launch-1.0 is not installed"
+		c.Hint = "install gstreamer1.0-tools (Debian/Ubuntu) or gstreamer1 (Fedora); Wayland recording needs it"
+		if required {
+			c.Code = scerr.DepMissing
+		}
+		return c
+	}
+	var core, extra []string
+	for _, e := range gstCore {
+		if !gstHas(e) {
+			core = append(core, e)
+		}
+	}
+	for _, e := range gstExtra {
+		if !gstHas(e) {
+			extra = append(extra, e)
+		}
+	}
+	switch {
+	case len(core) > 0:
+		c.Status, c.Detail = bad, "missing elements: "+strings.Join(append(core, extra...), ", ")
+		c.Hint = "install gst-plugins-good, gst-plugins-ugly and the pipewire gstreamer plugin"
+		if required {
+			c.Code = scerr.DepMissing
+		}
+	case len(extra) > 0:
+		c.Status, c.Detail = Warn, "optional elements missing: "+strings.Join(extra, ", ")
+		c.Hint = "some codecs, containers or audio won't work on Wayland; install gst-plugins-bad/ugly"
+	default:
+		c.Detail = fmt.Sprintf("all %d elements available", len(gstCore)+len(gstExtra))
+	}
+	return c
+}
+
+func gstHas(element string) bool {
+	_, err := output("gst-inspect-1.0", "--exists", element)
+	return err == nil
+}
+
+// checkScreencopy reports whether the compositor offers wlr-screencopy.
+// without it captures go through the portal, which is fine too, so it
+// never fails.
+func checkScreencopy(advertised bool) Check {
+	c := Check{Name: "wlr-screencopy", Status: Pass, Detail: "zwlr_screencopy_manager_v1"}
+	if !advertised {
+		c.Detail = "not offered; captures go through the portal"
+	}
+	return c
+}
+
+func checkPortal(iface string, required bool) Check {
+	c := Check{Name: iface + " portal", Status: Pass}
+	v, err := portal.Version(iface)
+	if err != nil {
+		c.Status, c.Detail = Warn, err.Error()
+		c.Hint = "install xdg-desktop-portal and the backend for your desktop (-gnome, -kde, -wlr, -hyprland)"
+		if required {
+			c.Status, c.Code = Fail, scerr.CodeOf(err)
+		}
+		return c
+	}
+	c.Detail = fmt.Sprintf("version %d", v)
+	return c
+}
+
+func checkSoundServer() Check {
+	c := Check{Name: "sound server", Status: Pass}
+	cl, err := pulse.Dial()
+	if err == nil {
+		defer cl.Close()
+		var info pulse.ServerInfo
+		if info, err = cl.ServerInfo(); err == nil {
+			c.Detail = strings.TrimSpace(info.Name + " " + info.Version)
+			return c
+		}
+	}
+	c.Status, c.Detail = Warn, err.Error()
+	c.Hint = "audio recording needs PulseAudio or pipewire-pulse running"
+	return c
+}
+
+func checkClipboard(s detect.SessionType) Check {
+	tool, pkg := "xclip", "xclip"
+	if s == detect.SessionWayland {
+		tool, pkg = "wl-copy", "wl-clipboard"
+	}
+	c := Check{Name: "clipboard", Status: Pass}
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		c.Status, c.Detail = Warn, tool+" is not installed"
+		c.Hint = "install " + pkg + " to copy screenshots to the clipboard"
+		return c
+	}
+	c.Detail = path
+	return c
+}
+
+// checkXvfb looks for the X server record --headless runs.
+func checkXvfb() Check {
+	c := Check{Name: "Xvfb", Status: Pass}
+	path, err := exec.LookPath("Xvfb")
+	if err != nil {
+		c.Status, c.Detail = Warn, "Xvfb is not installed"
+		c.Hint = "install Xvfb (xvfb or xorg-x11-server-Xvfb) to record with --headless"
+		return c
+	}
+	c.Detail = path
+	return c
+}
+
+func checkNotifications() Check {
+	c := Check{Name: "notifications", Status: Pass}
+	var name, vendor, version, spec string
+	conn, err := dbus.SessionBus()
+	if err == nil {
+		obj := conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications")
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		err = obj.CallWithContext(ctx, "org.freedesktop.Notifications.GetServerInformation", 0).
+			Store(&name, &vendor, &version, &spec)
+	}
+	if err != nil {
+		c.Status, c.Detail = Warn, err.Error()
+		c.Hint = "run a notification daemon (your desktop's, or mako/dunst) to get capture notifications"
+		return c
+	}
+	c.Detail = strings.TrimSpace(name + " " + version)
+	return c
+}
+
+// checkDir checks that files can be made in d, or in the folder it would
+// be made in if it doesn't exist yet. It leaves nothing behind.
+func checkDir(d Dir) Check {
+	c := Check{Name: d.Name, Status: Pass, Detail: d.Path}
+	if abs, err := filepath.Abs(d.Path); err == nil {
+		c.Detail = abs
+	}
+	dir := c.Detail
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	f, err := os.CreateTemp(dir, ".swiftcap-doctor-*")
+	if err != nil {
+		c.Status, c.Code = Fail, scerr.Runtime
+		if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT) {
+			c.Code = scerr.DiskFull
+		}
+		c.Detail = err.Error()
+		c.Hint = "pick another folder, or fix its permissions"
+		return c
+	}
+	f.Close()
+	os.Remove(f.Name())
+	if dir != c.Detail {
+		c.Detail += " (will be created)"
+	}
+	return c
+}
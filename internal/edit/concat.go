@@ -0,0 +1,165 @@
+package edit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Concat joins files end to end into out. files whose streams match are
+// copied through the concat demuxer; otherwise they are scaled to the first
+// file's size and re-encoded with enc. it reports whether it copied.
+func Concat(files []string, out string, enc Encoding) (bool, error) {
+	if err := checkFiles(files); err != nil {
+		return false, err
+	}
+	probes := make([][]Stream, len(files))
+	for i, f := range files {
+		s, err := Probe(f)
+		if err != nil {
+			return false, err
+		}
+		probes[i] = s
+	}
+	if compatible(probes) {
+		return true, ConcatCopy(files, out, enc.Format, "")
+	}
+	return false, concatEncode(files, probes, out, enc)
+}
+
+// ConcatCopy joins files into out with the concat demuxer, without
+// re-encoding; they must share codecs and parameters, as the segments of
+// one recording do. the list goes to listPath, or a temp file when it's
+// empty. files are left alone.
+func ConcatCopy(files []string, out, format, listPath string) error {
+	if err := checkFiles(files); err != nil {
+		return err
+	}
+	var list *os.File
+	var err error
+	if listPath != "" {
+		list, err = os.Create(listPath)
+	} else {
+		list, err = os.CreateTemp(filepath.Dir(out), ".swiftcap_concat_*.txt")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create concat list: %w", err)
+	}
+	defer os.Remove(list.Name())
+	for _, f := range files {
+		if abs, err := filepath.Abs(f); err == nil {
+			f = abs // the demuxer resolves names against the list's directory
+		}
+		// the concat demuxer quotes with ' and escapes it as '\''
+		fmt.Fprintf(list, "file '%s'\n", strings.ReplaceAll(f, "'", `'\''`))
+	}
+	if err := list.Close(); err != nil {
+		return fmt.Errorf("failed to write concat list: %w", err)
+	}
+
+	args := []string{"-y", "-loglevel", "error", "-f", "concat", "-safe", "0", "-i", list.Name(), "-c", "copy"}
+	args = append(args, formatArgs(format)...)
+	args = append(args, out)
+	if err := run("ffmpeg", args...); err != nil {
+		return fmt.Errorf("ffmpeg failed to join files: %w", err)
+	}
+	if _, err := os.Stat(out); err != nil {
+		return fmt.Errorf("output file was not created: %w", err)
+	}
+	return nil
+}
+
+// concatEncode joins files with the concat filter. every file is fitted
+// into the first one's frame; audio is kept only if every file has some.
+func concatEncode(files []string, probes [][]Stream, out string, enc Encoding) error {
+	w, h := 0, 0
+	if v := firstOf(probes[0], "video"); v != nil {
+		w, h = v.Width, v.Height
+	}
+	if w <= 0 || h <= 0 {
+		return fmt.Errorf("%s has no video to join", files[0])
+	}
+	audio := true
+	for _, p := range probes {
+		audio = audio && firstOf(p, "audio") != nil
+	}
+
+	var args []string
+	args = append(args, "-y", "-hide_banner", "-loglevel", "error")
+	for _, f := range files {
+		args = append(args, "-i", f)
+	}
+	var graph []string
+	var ins string
+	for i := range files {
+		graph = append(graph, fmt.Sprintf(
+			"[%d:v:0]scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1[v%d]",
+			i, w, h, w, h, i))
+		ins += fmt.Sprintf("[v%d]", i)
+		if audio {
+			ins += fmt.Sprintf("[%d:a:0]", i)
+		}
+	}
+	maps := []string{"-map", "[v]"}
+	if audio {
+		maps = append(maps, "-map", "[a]")
+		graph = append(graph, fmt.Sprintf("%sconcat=n=%d:v=1:a=1[v][a]", ins, len(files)))
+	} else {
+		graph = append(graph, fmt.Sprintf("%sconcat=n=%d:v=1:a=0[v]", ins, len(files)))
+	}
+	args = append(args, "-filter_complex", strings.Join(graph, ";"))
+	args = append(args, maps...)
+	args = append(args, enc.Video...)
+	if audio {
+		args = append(args, enc.Audio...)
+	}
+	args = append(args, formatArgs(enc.Format)...)
+	args = append(args, out)
+	if err := run("ffmpeg", args...); err != nil {
+		return fmt.Errorf("ffmpeg failed to join files: %w", err)
+	}
+	return nil
+}
+
+// compatible reports whether every file has the same streams as the first,
+// which is what the concat demuxer needs to copy them.
+func compatible(probes [][]Stream) bool {
+	for _, p := range probes[1:] {
+		if len(p) != len(probes[0]) {
+			return false
+		}
+		for i := range p {
+			if p[i] != probes[0][i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func firstOf(streams []Stream, typ string) *Stream {
+	for i := range streams {
+		if streams[i].Type == typ {
+			return &streams[i]
+		}
+	}
+	return nil
+}
+
+func checkFiles(files []string) error {
+	if len(files) == 0 {
+		return errors.New("no files to join")
+	}
+	var missing []string
+	for _, f := range files {
+		if _, err := os.Stat(f); err != nil {
+			missing = append(missing, f)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing files: %v", missing)
+	}
+	return nil
+}
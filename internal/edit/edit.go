@@ -0,0 +1,239 @@
+/*
+	post-processing of finished captures: trim, crop, scale and speed, and
+	joining files end to end. a trim on its own is a stream copy cut at the
+	keyframe before the in point; anything that touches pixels, or a trim
+	asked to be precise, is re-encoded with an Encoding the caller builds
+	from the recorder's options.
+*/
+
+package edit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Encoding is how a re-encode writes its output: the ffmpeg codec
+// arguments and the muxer.
+type Encoding struct {
+	Video  []string // e.g. -c:v libx264 -preset veryfast ...
+	Audio  []string // e.g. -c:a aac -b:a 128k
+	Format string   // ffmpeg -f value
+}
+
+// Trim is a time range; a zero End runs to the end of the file.
+type Trim struct {
+	Start, End time.Duration
+}
+
+// IsZero reports whether t keeps the whole file.
+func (t Trim) IsZero() bool { return t.Start == 0 && t.End == 0 }
+
+// ParseTrim reads START-END, where either side may be left out ("00:05-"
+// or "-01:20") and each is a time ParseTime takes.
+func ParseTrim(s string) (Trim, error) {
+	a, b, ok := strings.Cut(s, "-")
+	if !ok {
+		return Trim{}, fmt.Errorf("invalid trim %q, want START-END, e.g. 00:05-01:20", s)
+	}
+	var t Trim
+	var err error
+	if a != "" {
+		if t.Start, err = ParseTime(a); err != nil {
+			return Trim{}, err
+		}
+	}
+	if b != "" {
+		if t.End, err = ParseTime(b); err != nil {
+			return Trim{}, err
+		}
+		if t.End <= t.Start {
+			return Trim{}, fmt.Errorf("trim %q ends before it starts", s)
+		}
+	}
+	return t, nil
+}
+
+// ParseTime reads SS, MM:SS or HH:MM:SS, each with optional fractional
+// seconds.
+func ParseTime(s string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time %q, want [[HH:]MM:]SS[.frac]", s)
+	}
+	var secs float64
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		last := i == len(parts)-1
+		if err != nil || v < 0 || (!last && v != math.Trunc(v)) || (i > 0 && v >= 60) {
+			return 0, fmt.Errorf("invalid time %q, want [[HH:]MM:]SS[.frac]", s)
+		}
+		secs = secs*60 + v
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
+
+// FormatTime writes d as HH:MM:SS.mmm, which ffmpeg takes for -ss and -t.
+func FormatTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// Crop is a WxH+X+Y rectangle of the picture.
+type Crop struct {
+	W, H, X, Y int
+}
+
+// ParseCrop reads WxH+X+Y.
+func ParseCrop(s string) (Crop, error) {
+	var c Crop
+	if n, _ := fmt.Sscanf(s, "%dx%d+%d+%d", &c.W, &c.H, &c.X, &c.Y); n != 4 || c.W <= 0 || c.H <= 0 || c.X < 0 || c.Y < 0 {
+		return Crop{}, fmt.Errorf("invalid crop %q, want WxH+X+Y", s)
+	}
+	return c, nil
+}
+
+// Scale is a target size; -1 keeps the aspect ratio for that side and -2
+// does too, rounded to an even number as most encoders want.
+type Scale struct {
+	W, H int
+}
+
+// ParseScale reads WxH, where one side may be -1 or -2.
+func ParseScale(s string) (Scale, error) {
+	var sc Scale
+	a, b, ok := strings.Cut(strings.ToLower(s), "x")
+	w, errW := strconv.Atoi(a)
+	h, errH := strconv.Atoi(b)
+	sc.W, sc.H = w, h
+	bad := func(v int) bool { return v == 0 || v < -2 }
+	if !ok || errW != nil || errH != nil || bad(w) || bad(h) || (w < 0 && h < 0) {
+		return Scale{}, fmt.Errorf("invalid scale %q, want WxH with at most one side -1 or -2, e.g. 1280x-2", s)
+	}
+	return sc, nil
+}
+
+// Edit is everything `swiftcap edit` can do to a file in one pass.
+type Edit struct {
+	Trim    Trim
+	Crop    *Crop
+	Scale   *Scale
+	Speed   float64 // playback rate, 0 or 1 = unchanged
+	Precise bool    // re-encode a trim to cut on the exact frame
+}
+
+// Validate checks the speed; the rest is checked while parsing.
+func (e Edit) Validate() error {
+	if e.Speed < 0 || e.Speed > 100 {
+		return fmt.Errorf("speed %g out of range (0-100]", e.Speed)
+	}
+	if e.Trim.IsZero() && e.Crop == nil && e.Scale == nil && !e.speedChanged() {
+		return errors.New("nothing to do: give --trim, --crop, --scale or --speed")
+	}
+	return nil
+}
+
+func (e Edit) speedChanged() bool { return e.Speed != 0 && e.Speed != 1 }
+
+// CanCopy reports whether e can be done without re-encoding: a trim alone,
+// not asked to be precise.
+func (e Edit) CanCopy() bool {
+	return e.Crop == nil && e.Scale == nil && !e.speedChanged() && !e.Precise
+}
+
+// videoFilter is the -vf chain for e, "" when there is none.
+func (e Edit) videoFilter() string {
+	var f []string
+	if c := e.Crop; c != nil {
+		f = append(f, fmt.Sprintf("crop=%d:%d:%d:%d", c.W, c.H, c.X, c.Y))
+	}
+	if s := e.Scale; s != nil {
+		f = append(f, fmt.Sprintf("scale=%d:%d:flags=lanczos", s.W, s.H))
+	}
+	if e.speedChanged() {
+		f = append(f, fmt.Sprintf("setpts=PTS/%g", e.Speed))
+	}
+	return strings.Join(f, ",")
+}
+
+// audioFilter is the -af chain for e. atempo only takes 0.5-2 on older
+// ffmpeg, so bigger changes are chained.
+func (e Edit) audioFilter() string {
+	if !e.speedChanged() {
+		return ""
+	}
+	var f []string
+	s := e.Speed
+	for ; s > 2; s /= 2 {
+		f = append(f, "atempo=2")
+	}
+	for ; s < 0.5; s /= 0.5 {
+		f = append(f, "atempo=0.5")
+	}
+	return strings.Join(append(f, fmt.Sprintf("atempo=%g", s)), ",")
+}
+
+// Result says how Apply did the edit.
+type Result struct {
+	Copied bool          // stream copy, no re-encode
+	Start  time.Duration // where the cut really starts; a copy snaps to a keyframe
+}
+
+// Apply writes in, edited per e, to out. enc is used when the edit needs a
+// re-encode, and its Format always picks the muxer.
+func Apply(in, out string, e Edit, enc Encoding) (Result, error) {
+	if err := e.Validate(); err != nil {
+		return Result{}, err
+	}
+	res := Result{Copied: e.CanCopy(), Start: e.Trim.Start}
+	if res.Copied && e.Trim.Start > 0 {
+		// a copy can only start on a keyframe; say which one rather than
+		// let ffmpeg pick it silently
+		kf, err := KeyframeBefore(in, e.Trim.Start)
+		if err != nil {
+			return res, err
+		}
+		res.Start = kf
+	}
+
+	args := []string{"-y", "-hide_banner", "-loglevel", "error"}
+	if res.Start > 0 {
+		args = append(args, "-ss", FormatTime(res.Start))
+	}
+	if e.Trim.End > 0 {
+		args = append(args, "-t", FormatTime(e.Trim.End-res.Start))
+	}
+	args = append(args, "-i", in, "-map", "0:v:0", "-map", "0:a?")
+	if res.Copied {
+		args = append(args, "-c", "copy", "-avoid_negative_ts", "make_zero")
+	} else {
+		if vf := e.videoFilter(); vf != "" {
+			args = append(args, "-vf", vf)
+		}
+		if af := e.audioFilter(); af != "" {
+			args = append(args, "-af", af)
+		}
+		args = append(args, enc.Video...)
+		args = append(args, enc.Audio...)
+	}
+	args = append(args, formatArgs(enc.Format)...)
+	args = append(args, out)
+	if err := run("ffmpeg", args...); err != nil {
+		return res, fmt.Errorf("ffmpeg failed to edit %s: %w", in, err)
+	}
+	return res, nil
+}
+
+// formatArgs picks the muxer, moving an mp4's index to the front so it
+// plays while downloading.
+func formatArgs(format string) []string {
+	args := []string{"-f", format}
+	if format == "mp4" || format == "mov" {
+		args = append(args, "-movflags", "+faststart")
+	}
+	return args
+}
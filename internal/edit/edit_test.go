@@ -0,0 +1,183 @@
+package edit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"5", 5 * time.Second, false},
+		{"2.5", 2500 * time.Millisecond, false},
+		{"01:20", 80 * time.Second, false},
+		{"1:02:03.25", time.Hour + 2*time.Minute + 3250*time.Millisecond, false},
+		{" 00:05 ", 5 * time.Second, false},
+		{"90:00", 90 * time.Minute, false},
+		{"00:60", 0, true},
+		{"1.5:00", 0, true},
+		{"-3", 0, true},
+		{"1:2:3:4", 0, true},
+		{"", 0, true},
+		{"five", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTime(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseTime(%q) = %v, %v; want %v, an error: %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		0:                       "00:00:00.000",
+		1500 * time.Millisecond: "00:00:01.500",
+		time.Hour + 2*time.Minute + 3*time.Second: "01:02:03.000",
+		100 * time.Hour: "100:00:00.000",
+	} {
+		if got := FormatTime(d); got != want {
+			t.Errorf("FormatTime(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestParseTrim(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Trim
+		wantErr bool
+	}{
+		{"00:05-01:20", Trim{5 * time.Second, 80 * time.Second}, false},
+		{"00:05-", Trim{Start: 5 * time.Second}, false},
+		{"-01:20", Trim{End: 80 * time.Second}, false},
+		{"-", Trim{}, false},
+		{"10-5", Trim{}, true},
+		{"5-5", Trim{}, true},
+		{"00:05", Trim{}, true},
+		{"a-b", Trim{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTrim(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseTrim(%q) = %+v, %v; want %+v, an error: %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseCrop(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Crop
+		wantErr bool
+	}{
+		{"1280x720+0+0", Crop{1280, 720, 0, 0}, false},
+		{"640x480+100+50", Crop{640, 480, 100, 50}, false},
+		{"0x480+0+0", Crop{}, true},
+		{"640x480+-1+0", Crop{}, true},
+		{"640x480", Crop{}, true},
+		{"", Crop{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCrop(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseCrop(%q) = %+v, %v; want %+v, an error: %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseScale(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Scale
+		wantErr bool
+	}{
+		{"1280x720", Scale{1280, 720}, false},
+		{"1280x-2", Scale{1280, -2}, false},
+		{"-1X720", Scale{-1, 720}, false},
+		{"-1x-2", Scale{}, true},
+		{"1280x0", Scale{}, true},
+		{"1280x-3", Scale{}, true},
+		{"1280", Scale{}, true},
+		{"wide", Scale{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseScale(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseScale(%q) = %+v, %v; want %+v, an error: %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestEdit(t *testing.T) {
+	trim := Trim{Start: time.Second}
+	tests := []struct {
+		name    string
+		e       Edit
+		wantErr bool
+		canCopy bool
+		vf, af  string // the filter chains
+	}{
+		{"nothing", Edit{}, true, false, "", ""},
+		{"speed 1 is nothing", Edit{Speed: 1}, true, false, "", ""},
+		{"negative speed", Edit{Trim: trim, Speed: -2}, true, false, "", ""},
+		{"too fast", Edit{Speed: 101}, true, false, "", ""},
+		{"trim", Edit{Trim: trim}, false, true, "", ""},
+		{"precise trim", Edit{Trim: trim, Precise: true}, false, false, "", ""},
+		{
+			"crop and scale",
+			Edit{Crop: &Crop{640, 480, 10, 20}, Scale: &Scale{320, -2}},
+			false, false,
+			"crop=640:480:10:20,scale=320:-2:flags=lanczos", "",
+		},
+		{"double speed", Edit{Speed: 2}, false, false, "setpts=PTS/2", "atempo=2"},
+		{"8x speed", Edit{Speed: 8}, false, false, "setpts=PTS/8", "atempo=2,atempo=2,atempo=2"},
+		{"quarter speed", Edit{Speed: 0.2}, false, false, "setpts=PTS/0.2", "atempo=0.5,atempo=0.5,atempo=0.8"},
+		{"100x speed", Edit{Speed: 100}, false, false, "setpts=PTS/100", "atempo=2,atempo=2,atempo=2,atempo=2,atempo=2,atempo=2,atempo=1.5625"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.e.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want an error: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return // nothing else runs on an edit that doesn't validate
+			}
+			if got := tt.e.CanCopy(); got != tt.canCopy {
+				t.Errorf("CanCopy() = %v, want %v", got, tt.canCopy)
+			}
+			if got := tt.e.videoFilter(); got != tt.vf {
+				t.Errorf("videoFilter() = %q, want %q", got, tt.vf)
+			}
+			if got := tt.e.audioFilter(); got != tt.af {
+				t.Errorf("audioFilter() = %q, want %q", got, tt.af)
+			}
+		})
+	}
+}
+
+func TestCompatible(t *testing.T) {
+	v := Stream{Type: "video", Codec: "h264", Width: 1920, Height: 1080, PixFmt: "yuv420p"}
+	a := Stream{Type: "audio", Codec: "aac", SampleRate: "48000", Channels: 2}
+	smaller := v
+	smaller.Width, smaller.Height = 1280, 720
+	tests := []struct {
+		name   string
+		probes [][]Stream
+		want   bool
+	}{
+		{"one file", [][]Stream{{v, a}}, true},
+		{"same streams", [][]Stream{{v, a}, {v, a}, {v, a}}, true},
+		{"one without audio", [][]Stream{{v, a}, {v}}, false},
+		{"another size", [][]Stream{{v, a}, {smaller, a}}, false},
+		{"streams in another order", [][]Stream{{v, a}, {a, v}}, false},
+	}
+	for _, tt := range tests {
+		if got := compatible(tt.probes); got != tt.want {
+			t.Errorf("%s: compatible = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package edit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+)
+
+// Stream is what ffprobe says about one stream, as far as joining files
+// cares.
+type Stream struct {
+	Type       string `json:"codec_type"`
+	Codec      string `json:"codec_name"`
+	Width      int    `json:"width,omitempty"`
+	Height     int    `json:"height,omitempty"`
+	PixFmt     string `json:"pix_fmt,omitempty"`
+	SampleRate string `json:"sample_rate,omitempty"`
+	Channels   int    `json:"channels,omitempty"`
+}
+
+// Probe lists the streams of path.
+func Probe(path string) ([]Stream, error) {
+	out, err := output("ffprobe", "-v", "error",
+		"-show_entries", "stream=codec_type,codec_name,width,height,pix_fmt,sample_rate,channels",
+		"-of", "json", path)
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed on %s: %w", path, err)
+	}
+	var r struct {
+		Streams []Stream `json:"streams"`
+	}
+	if err := json.Unmarshal(out, &r); err != nil {
+		return nil, fmt.Errorf("ffprobe gave unreadable output for %s: %w", path, err)
+	}
+	return r.Streams, nil
+}
+
+// Keyframes returns the times of path's video keyframes, in order. It reads
+// packet flags, so nothing is decoded.
+func Keyframes(path string) ([]time.Duration, error) {
+	out, err := output("ffprobe", "-v", "error", "-select_streams", "v:0",
+		"-show_entries", "packet=pts_time,flags", "-of", "csv=p=0", path)
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed on %s: %w", path, err)
+	}
+	var kfs []time.Duration
+	for _, line := range strings.Split(string(out), "\n") {
+		pts, flags, ok := strings.Cut(strings.TrimSpace(line), ",")
+		if !ok || !strings.Contains(flags, "K") {
+			continue
+		}
+		if s, err := strconv.ParseFloat(pts, 64); err == nil {
+			kfs = append(kfs, time.Duration(s*float64(time.Second)))
+		}
+	}
+	return kfs, nil
+}
+
+// KeyframeBefore returns the last keyframe at or before at, which is where
+// a stream copy starting at at really starts.
+func KeyframeBefore(path string, at time.Duration) (time.Duration, error) {
+	kfs, err := Keyframes(path)
+	if err != nil {
+		return 0, err
+	}
+	var best time.Duration
+	for _, kf := range kfs {
+		if kf > at {
+			break
+		}
+		best = kf
+	}
+	return best, nil
+}
+
+// output runs a command and returns its stdout, with what its stderr says
+// went wrong in the error.
+func output(name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, scerr.Classify(name, err, stderr.String())
+	}
+	return out, nil
+}
+
+func run(name string, args ...string) error {
+	_, err := output(name, args...)
+	return err
+}
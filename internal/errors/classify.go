@@ -0,0 +1,76 @@
+package errors
+
+import (
+	stderrors "errors"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// stderr lines that say what class a failed ffmpeg or GStreamer run is,
+// checked in order.
+var patterns = []struct {
+	text string
+	code Code
+}{
+	{"No space left on device", DiskFull},
+	{"Disk quota exceeded", DiskFull},
+	{"File too large", DiskFull},
+	{"Unknown encoder", DepMissing},
+	{"Encoder not found", DepMissing},
+	{"Unknown decoder", DepMissing},
+	{"Unknown input format", DepMissing},
+	{"no element", DepMissing}, // gst-launch: no element "x264enc"
+	{"Cannot open display", NoDisplay},
+	{"Can't open display", NoDisplay},
+	{"Connection timed out", Timeout},
+}
+
+// Classify files a failed run of tool (ffmpeg, gst-launch-1.0, ...) by how
+// it failed and what it printed to stderr: a tool that isn't installed or
+// lacks an encoder is DepMissing, a full disk DiskFull, anything else
+// EncoderFailed. The message is the line that gave it away, else the last
+// one, which is where both tools put the reason; callers say which step
+// failed.
+func Classify(tool string, err error, stderr string) error {
+	if err == nil {
+		return nil
+	}
+	if stderrors.Is(err, exec.ErrNotFound) || stderrors.Is(err, os.ErrNotExist) {
+		return &Error{Code: DepMissing, Msg: tool + " is not installed", Err: err}
+	}
+	if stderrors.Is(err, syscall.ENOSPC) || stderrors.Is(err, syscall.EDQUOT) {
+		return &Error{Code: DiskFull, Err: err}
+	}
+	code, msg := EncoderFailed, lastLine(stderr)
+	for _, p := range patterns {
+		if i := strings.Index(stderr, p.text); i >= 0 {
+			code, msg = p.code, lineAt(stderr, i)
+			break
+		}
+	}
+	return &Error{Code: code, Msg: msg, Err: err}
+}
+
+// lastLine is the last non-empty, non-progress line of out.
+func lastLine(out string) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		l := strings.TrimSpace(lines[i])
+		if l != "" && !strings.HasPrefix(l, "frame=") {
+			return l
+		}
+	}
+	return ""
+}
+
+// lineAt is the line of out around byte i.
+func lineAt(out string, i int) string {
+	start := strings.LastIndexByte(out[:i], '\n') + 1
+	end := strings.IndexByte(out[i:], '\n')
+	if end < 0 {
+		return strings.TrimSpace(out[start:])
+	}
+	return strings.TrimSpace(out[start : i+end])
+}
@@ -0,0 +1,139 @@
+/*
+	typed failures. every error the cli can exit on carries a Code, which is
+	also its exit status, so scripts can tell a missing ffmpeg from a full
+	disk without reading the message. the cli prints them in the
+	"E_NAME=code: message" form it has always used.
+
+	the codes are a contract: add new ones, never renumber.
+*/
+
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+)
+
+// Code is a failure class and the exit status that reports it.
+type Code int
+
+const (
+	OK             Code = 0
+	InvalidArgs    Code = 1   // bad flags or arguments
+	NoDisplay      Code = 10  // no X11/Wayland session, or the display can't be opened
+	PortalDenied   Code = 11  // the desktop portal refused or failed
+	Cancelled      Code = 12  // the user backed out of a dialog or picker
+	PortalMissing  Code = 13  // xdg-desktop-portal isn't running, or has no backend
+	DepMissing     Code = 20  // ffmpeg, gst-launch or an encoder isn't installed
+	EncoderFailed  Code = 22  // ffmpeg or GStreamer failed while encoding
+	Pulse          Code = 23  // no sound server answered
+	DiskFull       Code = 24  // no space left for the output
+	Timeout        Code = 25  // something stopped answering
+	Screenshot     Code = 30  // a screenshot couldn't be taken or saved
+	CtlUnreachable Code = 40  // ctl: nothing listening on the socket
+	CtlRefused     Code = 41  // ctl: the recorder refused the command
+	Runtime        Code = 100 // anything else
+)
+
+// Codes lists every code with what it means, for --help and the docs.
+var Codes = []struct {
+	Code Code
+	Desc string
+}{
+	{InvalidArgs, "invalid arguments"},
+	{NoDisplay, "no display"},
+	{PortalDenied, "portal denied"},
+	{Cancelled, "cancelled by the user"},
+	{PortalMissing, "desktop portal missing"},
+	{DepMissing, "dependency missing"},
+	{EncoderFailed, "encoder failed"},
+	{Pulse, "sound server unreachable"},
+	{DiskFull, "disk full"},
+	{Timeout, "timed out"},
+	{Screenshot, "screenshot failed"},
+	{CtlUnreachable, "no recorder on the control socket"},
+	{CtlRefused, "recorder refused the command"},
+	{Runtime, "other runtime error"},
+}
+
+var names = map[Code]string{
+	InvalidArgs:    "E_INVALID_ARGS",
+	NoDisplay:      "E_NO_DISPLAY",
+	PortalDenied:   "E_PORTAL_DENIED",
+	Cancelled:      "E_CANCELLED",
+	PortalMissing:  "E_PORTAL_MISSING",
+	DepMissing:     "E_DEP_MISSING",
+	EncoderFailed:  "E_ENCODER_FAILED",
+	Pulse:          "E_PULSE",
+	DiskFull:       "E_DISK_FULL",
+	Timeout:        "E_TIMEOUT",
+	Screenshot:     "E_SCREENSHOT",
+	CtlUnreachable: "E_CTL_UNREACHABLE",
+	CtlRefused:     "E_CTL_REFUSED",
+	Runtime:        "E_RUNTIME",
+}
+
+// Name is the E_NAME form of c.
+func (c Code) Name() string {
+	if n, ok := names[c]; ok {
+		return n
+	}
+	return fmt.Sprintf("E_%d", int(c))
+}
+
+func (c Code) String() string { return fmt.Sprintf("%s=%d", c.Name(), int(c)) }
+
+// Error is a failure of a known class. Msg says what went wrong and Err,
+// when set, why.
+type Error struct {
+	Code Code
+	Msg  string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	cause := ""
+	if e.Err != nil {
+		cause = e.Err.Error()
+	}
+	switch {
+	case e.Msg == "":
+		return cause
+	case cause == "":
+		return e.Msg
+	}
+	return e.Msg + ": " + cause
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// New is an error of class code.
+func New(code Code, msg string) error {
+	return &Error{Code: code, Msg: msg}
+}
+
+// Errorf is New with formatting; %w wraps the way fmt.Errorf does.
+func Errorf(code Code, format string, args ...any) error {
+	return &Error{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// Wrap files err under code, nil staying nil.
+func Wrap(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// CodeOf is the class of err: OK for nil, Runtime when nothing in its chain
+// has one.
+func CodeOf(err error) Code {
+	if err == nil {
+		return OK
+	}
+	var e *Error
+	if stderrors.As(err, &e) {
+		return e.Code
+	}
+	return Runtime
+}
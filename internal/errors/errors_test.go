@@ -0,0 +1,118 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	exit := stderrors.New("exit status 1")
+	tests := []struct {
+		name   string
+		err    error
+		stderr string
+		code   Code
+		msg    string
+	}{
+		{"success", nil, "No space left on device", OK, ""},
+		{"not installed", exec.ErrNotFound, "", DepMissing, "ffmpeg is not installed"},
+		{"no such file", &fs.PathError{Op: "fork/exec", Path: "/usr/bin/ffmpeg", Err: fs.ErrNotExist}, "", DepMissing, "ffmpeg is not installed"},
+		{"ENOSPC", &fs.PathError{Op: "write", Path: "out.mp4", Err: syscall.ENOSPC}, "", DiskFull, ""},
+		{
+			"disk full",
+			exit,
+			"frame=  10 fps=0.0\nav_interleaved_write_frame(): No space left on device\nError writing trailer\n",
+			DiskFull,
+			"av_interleaved_write_frame(): No space left on device",
+		},
+		{"unknown encoder", exit, "Unknown encoder 'libsvtav1'\n", DepMissing, "Unknown encoder 'libsvtav1'"},
+		{"gst element", exit, "WARNING: erroneous pipeline: no element \"x264enc\"", DepMissing, "WARNING: erroneous pipeline: no element \"x264enc\""},
+		{"display", exit, "[x11grab @ 0x1] Cannot open display :9, error 1.\n:9: Input/output error\n", NoDisplay, "[x11grab @ 0x1] Cannot open display :9, error 1."},
+		{
+			"anything else is the last line",
+			exit,
+			"[libx264 @ 0x1] width not divisible by 2 (1279x720)\nError initializing output stream 0:0\n\nframe=    0 fps=0.0\n",
+			EncoderFailed,
+			"Error initializing output stream 0:0",
+		},
+		{"nothing printed", exit, "", EncoderFailed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Classify("ffmpeg", tt.err, tt.stderr)
+			if c := CodeOf(got); c != tt.code {
+				t.Fatalf("code = %v, want %v", c, tt.code)
+			}
+			if got == nil {
+				return
+			}
+			var e *Error
+			if !stderrors.As(got, &e) || e.Msg != tt.msg {
+				t.Errorf("message = %q, want %q", e.Msg, tt.msg)
+			}
+			if !stderrors.Is(got, tt.err) {
+				t.Errorf("%v doesn't wrap %v", got, tt.err)
+			}
+		})
+	}
+}
+
+func TestError(t *testing.T) {
+	cause := stderrors.New("connection refused")
+	tests := []struct {
+		err  error
+		code Code
+		text string
+	}{
+		{nil, OK, ""},
+		{cause, Runtime, "connection refused"},
+		{New(InvalidArgs, "bad --fps"), InvalidArgs, "bad --fps"},
+		{Errorf(Pulse, "pulse: %w", cause), Pulse, "pulse: connection refused"},
+		{Wrap(CtlUnreachable, cause), CtlUnreachable, "connection refused"},
+		{Wrap(CtlUnreachable, nil), OK, ""},
+		{&Error{Code: Screenshot, Msg: "saving", Err: cause}, Screenshot, "saving: connection refused"},
+		// the innermost class doesn't win over the outer one
+		{fmt.Errorf("recording: %w", Wrap(DiskFull, Wrap(EncoderFailed, cause))), DiskFull, "recording: connection refused"},
+	}
+	for _, tt := range tests {
+		if c := CodeOf(tt.err); c != tt.code {
+			t.Errorf("CodeOf(%v) = %v, want %v", tt.err, c, tt.code)
+		}
+		if tt.err != nil && tt.err.Error() != tt.text {
+			t.Errorf("Error() = %q, want %q", tt.err.Error(), tt.text)
+		}
+	}
+}
+
+func TestCodes(t *testing.T) {
+	// scripts check these numbers; they must never change
+	stable := map[string]Code{
+		"E_INVALID_ARGS": 1, "E_NO_DISPLAY": 10, "E_PORTAL_DENIED": 11, "E_CANCELLED": 12,
+		"E_PORTAL_MISSING": 13, "E_DEP_MISSING": 20, "E_ENCODER_FAILED": 22, "E_PULSE": 23,
+		"E_DISK_FULL": 24, "E_TIMEOUT": 25, "E_SCREENSHOT": 30, "E_CTL_UNREACHABLE": 40,
+		"E_CTL_REFUSED": 41, "E_RUNTIME": 100,
+	}
+	seen := map[Code]bool{}
+	for _, c := range Codes {
+		if seen[c.Code] {
+			t.Errorf("%v is listed twice", c.Code)
+		}
+		seen[c.Code] = true
+		if want, ok := stable[c.Code.Name()]; !ok || want != c.Code {
+			t.Errorf("%v: want %s=%d", c.Code, c.Code.Name(), want)
+		}
+		if c.Desc == "" {
+			t.Errorf("%v has no description", c.Code)
+		}
+	}
+	if len(Codes) != len(stable) || len(names) != len(stable) {
+		t.Errorf("%d codes and %d names, want %d of each", len(Codes), len(names), len(stable))
+	}
+	if s := Code(77).String(); s != "E_77=77" {
+		t.Errorf("an unnamed code prints as %q", s)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+	a private Xvfb display to record a program on, for CI and anywhere
+	else that has no screen of its own.
+*/
+
+package headless
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+)
+
+// readyTimeout is how long Xvfb gets to start taking connections.
+const readyTimeout = 10 * time.Second
+
+// Options is the display to start.
+type Options struct {
+	Width, Height int
+	WM            string // window manager command to run on it, "" for none
+}
+
+// Display is a running Xvfb, with its window manager if it has one. Point
+// DISPLAY at Name to use it.
+type Display struct {
+	Name string // e.g. ":99"
+
+	xvfb   *exec.Cmd
+	exited chan struct{} // closed once Xvfb has exited
+	wm     *exec.Cmd
+}
+
+// Start runs Xvfb on a display number nothing else uses, waits until it
+// takes connections and starts opts.WM on it. Both die with this process.
+func Start(opts Options) (*Display, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	// -displayfd picks a free display and writes its number to fd 3 once
+	// the server is ready
+	cmd := exec.Command("Xvfb", "-displayfd", "3", "-nolisten", "tcp",
+		"-screen", "0", fmt.Sprintf("%dx%dx24", opts.Width, opts.Height))
+	cmd.ExtraFiles = []*os.File{w}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	cmd.SysProcAttr = procAttr()
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		return nil, fmt.Errorf("Xvfb failed to start: %w", scerr.Classify("Xvfb", err, ""))
+	}
+	d := &Display{xvfb: cmd, exited: make(chan struct{})}
+	var waitErr error
+	go func() {
+		waitErr = cmd.Wait()
+		close(d.exited)
+	}()
+
+	num := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(r).ReadString('\n')
+		num <- strings.TrimSpace(line)
+	}()
+	select {
+	case n := <-num:
+		if n == "" {
+			// the pipe closed without a number: Xvfb gave up
+			<-d.exited
+			return nil, scerr.Errorf(scerr.NoDisplay, "Xvfb failed: %s", reason(waitErr, stderr.String()))
+		}
+		d.Name = ":" + n
+	case <-time.After(readyTimeout):
+		d.Close()
+		return nil, scerr.Errorf(scerr.Timeout, "Xvfb didn't start within %s", readyTimeout)
+	}
+
+	if opts.WM != "" {
+		args := strings.Fields(opts.WM)
+		d.wm = exec.Command(args[0], args[1:]...)
+		d.wm.Env = append(os.Environ(), "DISPLAY="+d.Name)
+		d.wm.SysProcAttr = procAttr()
+		if err := d.wm.Start(); err != nil {
+			d.wm = nil
+			d.Close()
+			return nil, fmt.Errorf("the window manager failed to start: %w", scerr.Classify(args[0], err, ""))
+		}
+	}
+	return d, nil
+}
+
+// reason is why Xvfb exited: the last thing it printed, else the exit
+// status.
+func reason(err error, stderr string) string {
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		return last
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return "exited early"
+}
+
+// Close stops the window manager and Xvfb.
+func (d *Display) Close() error {
+	if d.wm != nil {
+		d.wm.Process.Signal(syscall.SIGTERM)
+		go d.wm.Wait()
+	}
+	d.xvfb.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-d.exited:
+	case <-time.After(5 * time.Second):
+		d.xvfb.Process.Kill()
+		<-d.exited
+	}
+	return nil
+}
+
+// ParseSize reads a WxH screen size.
+func ParseSize(s string) (w, h int, err error) {
+	ws, hs, ok := strings.Cut(s, "x")
+	if ok {
+		w, err = strconv.Atoi(ws)
+	}
+	if ok && err == nil {
+		h, err = strconv.Atoi(hs)
+	}
+	if !ok || err != nil || w <= 0 || h <= 0 {
+		return 0, 0, fmt.Errorf("invalid size %q, want WxH, e.g. 1920x1080", s)
+	}
+	return w, h, nil
+}
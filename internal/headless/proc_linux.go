@@ -0,0 +1,9 @@
+package headless
+
+import "syscall"
+
+// procAttr ties the display's processes to this one, so they go even when
+// it exits without closing the display.
+func procAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package headless
+
+import "syscall"
+
+// procAttr is empty off linux, which can't tie a child to its parent; Close
+// stops the display.
+func procAttr() *syscall.SysProcAttr { return nil }
@@ -0,0 +1,286 @@
+/*
+	output name templates. a template is a path with fields in braces:
+
+		{date:2006-01-02}/{time}_{mode}_{monitor}_{window_class}.{ext}
+
+	date and time take an optional Go time layout after a colon. a field
+	with nothing to say (no window for {window_class}) takes one separator
+	next to it along when it drops out, so names don't end up with "__" in
+	them. when the name is taken, -2, -3, ... goes before the extension.
+*/
+
+package naming
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Modes are the values {mode} takes.
+var Modes = []string{"recording", "screenshot", "markup", "replay"}
+
+// Fields are what a template is filled in with.
+type Fields struct {
+	Time        time.Time
+	Mode        string // one of Modes
+	Monitor     string // output name, empty when the capture spans them all
+	WindowClass string // WM_CLASS of the captured window, empty if it isn't one
+	Ext         string // without the dot
+}
+
+const (
+	defaultDate = "2006-01-02"
+	defaultTime = "15-04-05"
+)
+
+// part is a run of literal text or a {field}.
+type part struct {
+	text   string // literal, or the field name
+	field  bool
+	layout string // date and time
+}
+
+// Template is a parsed name template.
+type Template struct {
+	raw   string
+	parts []part
+}
+
+// IsTemplate reports whether s has any fields in it, as opposed to being a
+// plain file name.
+func IsTemplate(s string) bool {
+	return strings.Contains(s, "{") && strings.Contains(s, "}")
+}
+
+// Parse reads a template, rejecting unknown fields and stray braces.
+func Parse(s string) (Template, error) {
+	t := Template{raw: s}
+	rest := s
+	for rest != "" {
+		open := strings.IndexByte(rest, '{')
+		if close := strings.IndexByte(rest, '}'); close >= 0 && (open < 0 || close < open) {
+			return Template{}, fmt.Errorf("template %q: unmatched }", s)
+		}
+		if open < 0 {
+			t.parts = append(t.parts, part{text: rest})
+			break
+		}
+		if open > 0 {
+			t.parts = append(t.parts, part{text: rest[:open]})
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return Template{}, fmt.Errorf("template %q: unclosed {", s)
+		}
+		name, layout, _ := strings.Cut(rest[open+1:open+end], ":")
+		p := part{text: name, field: true, layout: layout}
+		switch name {
+		case "date":
+			if p.layout == "" {
+				p.layout = defaultDate
+			}
+		case "time":
+			if p.layout == "" {
+				p.layout = defaultTime
+			}
+		case "mode", "monitor", "window_class", "ext":
+			if layout != "" {
+				return Template{}, fmt.Errorf("template %q: {%s} takes no layout", s, name)
+			}
+		default:
+			return Template{}, fmt.Errorf("template %q: unknown field {%s} (want date, time, mode, monitor, window_class or ext)", s, name)
+		}
+		t.parts = append(t.parts, p)
+		rest = rest[open+end+1:]
+	}
+	return t, nil
+}
+
+// String is the template as written.
+func (t Template) String() string { return t.raw }
+
+// value is what a field expands to; "" drops it.
+func (p part) value(f Fields) string {
+	switch p.text {
+	case "date", "time":
+		return f.Time.Format(p.layout)
+	case "mode":
+		return clean(f.Mode)
+	case "monitor":
+		return clean(f.Monitor)
+	case "window_class":
+		return clean(f.WindowClass)
+	case "ext":
+		return clean(f.Ext)
+	}
+	return ""
+}
+
+// clean keeps a value inside one path element.
+func clean(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', 0:
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+func isSep(b byte) bool { return b == '_' || b == '-' || b == ' ' }
+
+// elementStart reports whether s ends where a path element begins.
+func elementStart(s string) bool { return s == "" || s[len(s)-1] == '/' }
+
+// Expand fills the template in. An empty field takes the separator before it
+// along, or the one after it at the start of a name. One that is a whole
+// directory takes its slash, so the name never turns absolute.
+func (t Template) Expand(f Fields) string {
+	var b strings.Builder
+	skipSep := false
+	for _, p := range t.parts {
+		if !p.field {
+			text := p.text
+			if skipSep && text != "" && (isSep(text[0]) || text[0] == '/' && elementStart(b.String())) {
+				text = text[1:]
+			}
+			skipSep = false
+			b.WriteString(text)
+			continue
+		}
+		v := p.value(f)
+		if v != "" {
+			skipSep = false
+			b.WriteString(v)
+			continue
+		}
+		s := b.String()
+		if n := len(s); n > 0 && isSep(s[n-1]) {
+			b.Reset()
+			b.WriteString(s[:n-1])
+		} else {
+			skipSep = true
+		}
+	}
+	return b.String()
+}
+
+// Create expands the template under dir (unless it's absolute), numbers the
+// name if it's taken and makes the directories it needs.
+func (t Template) Create(dir string, f Fields) (string, error) {
+	path := t.Expand(f)
+	if !filepath.IsAbs(path) && dir != "" {
+		path = filepath.Join(dir, path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	return Unique(path), nil
+}
+
+// Unique is path, numbered -2, -3, ... before the extension if a file by
+// that name already exists.
+func Unique(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+}
+
+// Depth is how many directories deep the template's names go, at most.
+func (t Template) Depth() int {
+	f := Fields{Time: time.Now(), Mode: "x", Monitor: "x", WindowClass: "x", Ext: "x"}
+	return strings.Count(filepath.ToSlash(t.Expand(f)), "/")
+}
+
+// Matcher returns a regexp for the relative paths the template makes,
+// numbered ones included, for finding them again. Separators are optional,
+// since an empty field takes one with it, and so is a directory that is
+// only a field.
+func (t Template) Matcher() *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	skipSlash := false
+	for i, p := range t.parts {
+		if !p.field {
+			text := p.text
+			if skipSlash {
+				text = text[1:]
+				skipSlash = false
+			}
+			last := i == len(t.parts)-1 || (i == len(t.parts)-2 && t.parts[i+1].text == "ext")
+			if dot := strings.LastIndexByte(text, '.'); last && dot >= 0 && !strings.Contains(text[dot:], "/") {
+				// the collision number goes before the extension
+				b.WriteString(literalPattern(text[:dot]) + `(?:-\d+)?` + literalPattern(text[dot:]))
+			} else {
+				b.WriteString(literalPattern(text))
+			}
+			continue
+		}
+		switch p.text {
+		case "date", "time":
+			b.WriteString(layoutPattern(p.layout))
+		case "mode":
+			b.WriteString("(?:" + strings.Join(Modes, "|") + ")")
+		case "ext":
+			b.WriteString(`[A-Za-z0-9]+`)
+		default:
+			if (i == 0 || !t.parts[i-1].field && strings.HasSuffix(t.parts[i-1].text, "/")) &&
+				i+1 < len(t.parts) && !t.parts[i+1].field && strings.HasPrefix(t.parts[i+1].text, "/") {
+				b.WriteString(`(?:[^/]+/)?`)
+				skipSlash = true
+			} else {
+				b.WriteString(`[^/]*`)
+			}
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+func literalPattern(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if isSep(text[i]) {
+			b.WriteString(`[-_ ]?`)
+		} else {
+			b.WriteString(regexp.QuoteMeta(text[i : i+1]))
+		}
+	}
+	return b.String()
+}
+
+// layoutPattern generalizes a time layout into a pattern: digit runs for
+// numbers, letter runs for month and day names.
+func layoutPattern(layout string) string {
+	sample := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC).Format(layout)
+	var b strings.Builder
+	for i := 0; i < len(sample); {
+		c := sample[i]
+		j := i + 1
+		switch {
+		case c >= '0' && c <= '9':
+			for j < len(sample) && sample[j] >= '0' && sample[j] <= '9' {
+				j++
+			}
+			b.WriteString(`\d+`)
+		case c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+			for j < len(sample) && (sample[j] >= 'A' && sample[j] <= 'Z' || sample[j] >= 'a' && sample[j] <= 'z') {
+				j++
+			}
+			b.WriteString(`[A-Za-z]+`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+		i = j
+	}
+	return b.String()
+}
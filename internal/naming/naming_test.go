@@ -0,0 +1,177 @@
+package naming
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var when = time.Date(2026, 3, 7, 9, 5, 30, 0, time.Local)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr string // "" for valid
+	}{
+		{"capture.png", ""},
+		{"{date}/{time}_{mode}.{ext}", ""},
+		{"{date:Jan 2}/{time:15h04}_{monitor}_{window_class}.{ext}", ""},
+		{"{mode", "unclosed {"},
+		{"mode}", "unmatched }"},
+		{"{date}}", "unmatched }"},
+		{"{host}.png", "unknown field {host}"},
+		{"{}.png", "unknown field {}"},
+		{"{mode:upper}.png", "{mode} takes no layout"},
+	}
+	for _, tt := range tests {
+		tmpl, err := Parse(tt.in)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("Parse(%q) = %v", tt.in, err)
+		case tt.wantErr == "" && tmpl.String() != tt.in:
+			t.Errorf("Parse(%q).String() = %q", tt.in, tmpl.String())
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("Parse(%q) = %v, want %q", tt.in, err, tt.wantErr)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	full := Fields{Time: when, Mode: "recording", Monitor: "HDMI-1", WindowClass: "firefox", Ext: "mp4"}
+	bare := Fields{Time: when, Mode: "screenshot", Ext: "png"}
+	tests := []struct {
+		tmpl string
+		f    Fields
+		want string
+	}{
+		{"{date}/{time}_{mode}.{ext}", full, "2026-03-07/09-05-30_recording.mp4"},
+		{"{date:Jan 2}/{time:15h04}.{ext}", full, "Mar 7/09h05.mp4"},
+		{"{mode}_{monitor}_{window_class}.{ext}", full, "recording_HDMI-1_firefox.mp4"},
+		// an empty field takes the separator before it along
+		{"{mode}_{monitor}_{window_class}.{ext}", bare, "screenshot.png"},
+		{"{mode}-{window_class} {monitor}.{ext}", bare, "screenshot.png"},
+		// or the one after it at the start of a name
+		{"{window_class}_{mode}.{ext}", bare, "screenshot.png"},
+		{"{date}/{window_class}_{time}.{ext}", bare, "2026-03-07/09-05-30.png"},
+		// or its directory, and the name stays relative
+		{"{monitor}/{window_class}_{time}.{ext}", bare, "09-05-30.png"},
+		{"{date}/{monitor}/{window_class}/{time}.{ext}", bare, "2026-03-07/09-05-30.png"},
+		{"{mode}_{monitor}/{time}.{ext}", bare, "screenshot/09-05-30.png"},
+		{"plain.png", full, "plain.png"},
+		{"{window_class}.{ext}", Fields{WindowClass: "a/b\\c", Ext: "png"}, "a_b_c.png"},
+	}
+	for _, tt := range tests {
+		tmpl, err := Parse(tt.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tmpl.Expand(tt.f); got != tt.want {
+			t.Errorf("%q expanded to %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	tests := []struct {
+		tmpl    string
+		match   []string
+		noMatch []string
+	}{
+		{
+			"{date}/{time}_{mode}_{monitor}.{ext}",
+			[]string{
+				"2026-03-07/09-05-30_recording_HDMI-1.mp4",
+				"2026-03-07/09-05-30_screenshot.png",
+				"2026-03-07/09-05-30_screenshot-2.png",
+				"2026-03-07/09-05-30_screenshot-12.png",
+			},
+			[]string{
+				"09-05-30_screenshot.png",
+				"2026-03-07/09-05-30_upload.png",
+				"2026-03-07/x/09-05-30_screenshot.png",
+				"2026-03-07/09-05-30_screenshot",
+			},
+		},
+		{
+			"swiftcap_{date:Jan-02}_{time}.png",
+			[]string{"swiftcap_Mar-07_09-05-30.png", "swiftcap_Mar-07_09-05-30-3.png"},
+			[]string{"swiftcap_03-07_09-05-30.png", "swiftcap_Mar-07_09-05-30.jpg"},
+		},
+		{
+			"{monitor}/{date}/{time}.{ext}",
+			[]string{"HDMI-1/2026-03-07/09-05-30.png", "2026-03-07/09-05-30.png"},
+			[]string{"HDMI-1/DP-2/2026-03-07/09-05-30.png", "/2026-03-07/09-05-30.png"},
+		},
+	}
+	for _, tt := range tests {
+		tmpl, err := Parse(tt.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		re := tmpl.Matcher()
+		for _, name := range tt.match {
+			if !re.MatchString(name) {
+				t.Errorf("%q doesn't match %q (%s)", tt.tmpl, name, re)
+			}
+		}
+		for _, name := range tt.noMatch {
+			if re.MatchString(name) {
+				t.Errorf("%q matches %q (%s)", tt.tmpl, name, re)
+			}
+		}
+	}
+}
+
+func TestMatcherFindsExpanded(t *testing.T) {
+	for _, s := range []string{
+		"{date}/{time}_{mode}_{monitor}_{window_class}.{ext}",
+		"{mode}/{date:2006}/{date:Jan}/{time:150405}.{ext}",
+		"{window_class} - {date:Mon 2 Jan} {time}.{ext}",
+		"{date}/{monitor}/{window_class}/{time}.{ext}",
+	} {
+		tmpl, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range []Fields{
+			{Time: when, Mode: "markup", Monitor: "DP-2", WindowClass: "Alacritty", Ext: "png"},
+			{Time: when, Mode: "replay", Ext: "mkv"},
+		} {
+			if name := tmpl.Expand(f); !tmpl.Matcher().MatchString(name) {
+				t.Errorf("%q: its own %q doesn't match", s, name)
+			}
+		}
+	}
+}
+
+func TestDepth(t *testing.T) {
+	for s, want := range map[string]int{"a.png": 0, "{date}/{time}.{ext}": 1, "{mode}/{date}/{time}.{ext}": 2, "{monitor}/{time}.{ext}": 1} {
+		tmpl, _ := Parse(s)
+		if got := tmpl.Depth(); got != want {
+			t.Errorf("%q Depth = %d, want %d", s, got, want)
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	tmpl, err := Parse("{date}/{mode}.{ext}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := Fields{Time: when, Mode: "screenshot", Ext: "png"}
+	for _, want := range []string{"screenshot.png", "screenshot-2.png", "screenshot-3.png"} {
+		path, err := tmpl.Create(dir, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want = filepath.Join(dir, "2026-03-07", want); path != want {
+			t.Fatalf("Create = %s, want %s", path, want)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+/*
+	a fake org.freedesktop.portal.Desktop on a private session bus, for tests
+	of the code that talks to the portal. Bus starts a dbus-daemon for the
+	test and points DBUS_SESSION_BUS_ADDRESS at it; Portal.Serve answers the
+	Screenshot and ScreenCast calls on it the way xdg-desktop-portal does,
+	through Request objects and their Response signal.
+*/
+
+package portaltest
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	dest = "org.freedesktop.portal.Desktop"
+	path = dbus.ObjectPath("/org/freedesktop/portal/desktop")
+)
+
+// Bus starts a dbus-daemon that lives as long as the test, and makes it the
+// session bus. It skips the test when dbus-daemon isn't installed.
+func Bus(t testing.TB) {
+	t.Helper()
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address",
+		"--address=unix:path="+t.TempDir()+"/bus")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	addr, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil {
+		t.Fatalf("dbus-daemon didn't print its address: %v", err)
+	}
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", strings.TrimSpace(addr))
+}
+
+// Portal is the fake portal. Set its fields before Serve.
+type Portal struct {
+	// Response is what every request answers with: 0 granted, 1 cancelled
+	// by the user, 2 refused some other way.
+	Response uint32
+	// URI is the file Screenshot hands back.
+	URI string
+	// Shoot, if set, runs before Screenshot answers, e.g. to write the file.
+	Shoot func()
+	// NodeID is the PipeWire node ScreenCast.Start hands back.
+	NodeID uint32
+
+	conn *dbus.Conn
+
+	mu      sync.Mutex
+	calls   []string
+	opts    map[string]map[string]dbus.Variant
+	closed  []dbus.ObjectPath
+	remotes []*os.File
+}
+
+// Serve claims the portal's name on the test's session bus (see Bus) and
+// implements ifaces there, "Screenshot" and "ScreenCast"; both when none
+// are given.
+func (p *Portal) Serve(t testing.TB, ifaces ...string) {
+	t.Helper()
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		for _, f := range p.remotes {
+			f.Close()
+		}
+	})
+	p.conn = conn
+	p.opts = map[string]map[string]dbus.Variant{}
+	if len(ifaces) == 0 {
+		ifaces = []string{"Screenshot", "ScreenCast"}
+	}
+	versions := map[string]dbus.Variant{}
+	for _, iface := range ifaces {
+		var v interface{}
+		switch iface {
+		case "Screenshot":
+			v = screenshot{p}
+		case "ScreenCast":
+			v = screencast{p}
+		default:
+			t.Fatalf("portaltest: no fake for %s", iface)
+		}
+		if err := conn.Export(v, path, "org.freedesktop.portal."+iface); err != nil {
+			t.Fatal(err)
+		}
+		versions["org.freedesktop.portal."+iface] = dbus.MakeVariant(uint32(4))
+	}
+	if err := conn.Export(properties(versions), path, "org.freedesktop.DBus.Properties"); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := conn.RequestName(dest, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("portaltest: can't own %s: %v", dest, err)
+	}
+}
+
+// Calls lists the methods called so far, without their interface.
+func (p *Portal) Calls() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.calls...)
+}
+
+// Options returns the options the last call to method passed.
+func (p *Portal) Options(method string) map[string]dbus.Variant {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.opts[method]
+}
+
+// Closed lists the sessions closed through org.freedesktop.portal.Session.
+func (p *Portal) Closed() []dbus.ObjectPath {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]dbus.ObjectPath(nil), p.closed...)
+}
+
+// respond records a call and answers it the way a portal does: it hands
+// back the request's object path and emits Response on it, with results
+// when p.Response is 0.
+func (p *Portal) respond(sender dbus.Sender, method string, options map[string]dbus.Variant, results map[string]dbus.Variant) (dbus.ObjectPath, *dbus.Error) {
+	p.mu.Lock()
+	p.calls = append(p.calls, method)
+	p.opts[method] = options
+	p.mu.Unlock()
+
+	token, _ := options["handle_token"].Value().(string)
+	handle := dbus.ObjectPath("/org/freedesktop/portal/desktop/request/" +
+		strings.ReplaceAll(strings.TrimPrefix(string(sender), ":"), ".", "_") + "/" + token)
+	if p.Response != 0 {
+		results = map[string]dbus.Variant{}
+	}
+	if err := p.conn.Emit(handle, "org.freedesktop.portal.Request.Response", p.Response, results); err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return handle, nil
+}
+
+type screenshot struct{ p *Portal }
+
+func (s screenshot) Screenshot(sender dbus.Sender, parent string, options map[string]dbus.Variant) (dbus.ObjectPath, *dbus.Error) {
+	if s.p.Shoot != nil && s.p.Response == 0 {
+		s.p.Shoot()
+	}
+	return s.p.respond(sender, "Screenshot", options, map[string]dbus.Variant{"uri": dbus.MakeVariant(s.p.URI)})
+}
+
+type screencast struct{ p *Portal }
+
+func (s screencast) CreateSession(sender dbus.Sender, options map[string]dbus.Variant) (dbus.ObjectPath, *dbus.Error) {
+	token, _ := options["session_handle_token"].Value().(string)
+	session := dbus.ObjectPath("/org/freedesktop/portal/desktop/session/" +
+		strings.ReplaceAll(strings.TrimPrefix(string(sender), ":"), ".", "_") + "/" + token)
+	if err := s.p.conn.Export(sessionObject{s.p, session}, session, "org.freedesktop.portal.Session"); err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return s.p.respond(sender, "CreateSession", options, map[string]dbus.Variant{"session_handle": dbus.MakeVariant(string(session))})
+}
+
+func (s screencast) SelectSources(sender dbus.Sender, session dbus.ObjectPath, options map[string]dbus.Variant) (dbus.ObjectPath, *dbus.Error) {
+	return s.p.respond(sender, "SelectSources", options, map[string]dbus.Variant{})
+}
+
+func (s screencast) Start(sender dbus.Sender, session dbus.ObjectPath, parent string, options map[string]dbus.Variant) (dbus.ObjectPath, *dbus.Error) {
+	streams := []struct {
+		NodeID uint32
+		Props  map[string]dbus.Variant
+	}{{s.p.NodeID, map[string]dbus.Variant{"size": dbus.MakeVariant([]int32{1920, 1080})}}}
+	return s.p.respond(sender, "Start", options, map[string]dbus.Variant{"streams": dbus.MakeVariant(streams)})
+}
+
+// OpenPipeWireRemote hands out /dev/null: the tests swap the pipewire
+// source for another element, so nothing reads it.
+func (s screencast) OpenPipeWireRemote(session dbus.ObjectPath, options map[string]dbus.Variant) (dbus.UnixFD, *dbus.Error) {
+	s.p.mu.Lock()
+	s.p.calls = append(s.p.calls, "OpenPipeWireRemote")
+	defer s.p.mu.Unlock()
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		return -1, dbus.MakeFailedError(err)
+	}
+	// the fd goes out with the reply, after we return, so it stays open
+	s.p.remotes = append(s.p.remotes, f)
+	return dbus.UnixFD(f.Fd()), nil
+}
+
+type sessionObject struct {
+	p    *Portal
+	path dbus.ObjectPath
+}
+
+func (s sessionObject) Close() *dbus.Error {
+	s.p.mu.Lock()
+	s.p.closed = append(s.p.closed, s.path)
+	s.p.mu.Unlock()
+	return nil
+}
+
+// properties answers Get for the version of each interface served.
+type properties map[string]dbus.Variant
+
+func (p properties) Get(iface, name string) (dbus.Variant, *dbus.Error) {
+	if v, ok := p[iface]; ok && name == "version" {
+		return v, nil
+	}
+	return dbus.Variant{}, dbus.NewError("org.freedesktop.DBus.Error.UnknownProperty", []interface{}{iface + "." + name})
+}
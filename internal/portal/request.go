@@ -0,0 +1,135 @@
+package portal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+)
+
+const (
+	portalDest = "org.freedesktop.portal.Desktop"
+	portalPath = dbus.ObjectPath("/org/freedesktop/portal/desktop")
+)
+
+// how long we wait for a Response signal. the user may be sitting in a
+// source chooser dialog, so this is deliberately generous.
+const responseTimeout = 5 * time.Minute
+
+var (
+	// ErrCancelled is wrapped by errors for requests the user dismissed.
+	ErrCancelled = errors.New("request cancelled by user")
+	// ErrMissing is wrapped when no portal (or no backend for the
+	// interface) is running on the session bus.
+	ErrMissing = errors.New("xdg-desktop-portal is not available")
+)
+
+var tokenSeq uint32
+
+// newToken returns a unique handle_token for this process.
+func newToken() string {
+	return fmt.Sprintf("swiftcap%d", atomic.AddUint32(&tokenSeq, 1))
+}
+
+// requestPath predicts the Request object path the portal will create for
+// token, so we can subscribe to its Response before making the call.
+func requestPath(conn *dbus.Conn, token string) dbus.ObjectPath {
+	sender := ""
+	if names := conn.Names(); len(names) > 0 {
+		sender = strings.ReplaceAll(strings.TrimPrefix(names[0], ":"), ".", "_")
+	}
+	return dbus.ObjectPath("/org/freedesktop/portal/desktop/request/" + sender + "/" + token)
+}
+
+// request calls a portal method that answers through an
+// org.freedesktop.portal.Request object and blocks until its Response signal
+// arrives. options is the trailing a{sv} argument; a handle_token is added to
+// it. args are the positional arguments that come before options.
+func request(conn *dbus.Conn, method string, options map[string]dbus.Variant, args ...interface{}) (map[string]dbus.Variant, error) {
+	if options == nil {
+		options = map[string]dbus.Variant{}
+	}
+	token := newToken()
+	options["handle_token"] = dbus.MakeVariant(token)
+	expected := requestPath(conn, token)
+
+	signals := make(chan *dbus.Signal, 8)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+
+	match := []dbus.MatchOption{
+		dbus.WithMatchInterface("org.freedesktop.portal.Request"),
+		dbus.WithMatchMember("Response"),
+		dbus.WithMatchObjectPath(expected),
+	}
+	if err := conn.AddMatchSignal(match...); err != nil {
+		return nil, scerr.Errorf(scerr.PortalDenied, "%s: subscribe to response: %w", method, err)
+	}
+	defer conn.RemoveMatchSignal(match...)
+
+	var handle dbus.ObjectPath
+	callArgs := append(append([]interface{}{}, args...), options)
+	if err := conn.Object(portalDest, portalPath).Call(method, 0, callArgs...).Store(&handle); err != nil {
+		return nil, callError(method, err)
+	}
+
+	// portals older than 0.9 don't honour handle_token and hand back a
+	// different path; follow that one instead.
+	if handle != expected {
+		legacy := []dbus.MatchOption{
+			dbus.WithMatchInterface("org.freedesktop.portal.Request"),
+			dbus.WithMatchMember("Response"),
+			dbus.WithMatchObjectPath(handle),
+		}
+		if err := conn.AddMatchSignal(legacy...); err == nil {
+			defer conn.RemoveMatchSignal(legacy...)
+		}
+	}
+
+	timeout := time.After(responseTimeout)
+	for {
+		select {
+		case sig, ok := <-signals:
+			if !ok {
+				return nil, scerr.Errorf(scerr.PortalDenied, "%s: D-Bus connection closed", method)
+			}
+			if sig.Name != "org.freedesktop.portal.Request.Response" || sig.Path != handle || len(sig.Body) < 2 {
+				continue
+			}
+			code, _ := sig.Body[0].(uint32)
+			results, _ := sig.Body[1].(map[string]dbus.Variant)
+			switch code {
+			case 0:
+			case 1:
+				return nil, scerr.Errorf(scerr.Cancelled, "%s: %w", method, ErrCancelled)
+			default:
+				return nil, scerr.Errorf(scerr.PortalDenied, "%s was denied (response %d)", method, code)
+			}
+			return results, nil
+		case <-timeout:
+			return nil, scerr.Errorf(scerr.Timeout, "%s: timed out waiting for portal response", method)
+		}
+	}
+}
+
+// callError classifies a failed method call. the bus answers with one of the
+// Unknown* errors when the portal service or the interface isn't there.
+func callError(method string, err error) error {
+	var derr dbus.Error
+	if errors.As(err, &derr) {
+		switch derr.Name {
+		case "org.freedesktop.DBus.Error.ServiceUnknown",
+			"org.freedesktop.DBus.Error.NameHasNoOwner",
+			"org.freedesktop.DBus.Error.UnknownMethod",
+			"org.freedesktop.DBus.Error.UnknownInterface",
+			"org.freedesktop.DBus.Error.UnknownObject":
+			return scerr.Errorf(scerr.PortalMissing, "%s: %w (%v)", method, ErrMissing, err)
+		}
+	}
+	return scerr.Errorf(scerr.PortalDenied, "%s failed: %w", method, err)
+}
@@ -0,0 +1,41 @@
+package portal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+)
+
+func TestCallError(t *testing.T) {
+	tests := []struct {
+		err     error
+		code    scerr.Code
+		missing bool
+	}{
+		{dbus.Error{Name: "org.freedesktop.DBus.Error.ServiceUnknown"}, scerr.PortalMissing, true},
+		{dbus.Error{Name: "org.freedesktop.DBus.Error.NameHasNoOwner"}, scerr.PortalMissing, true},
+		{dbus.Error{Name: "org.freedesktop.DBus.Error.UnknownMethod"}, scerr.PortalMissing, true},
+		{dbus.Error{Name: "org.freedesktop.DBus.Error.UnknownInterface"}, scerr.PortalMissing, true},
+		{dbus.Error{Name: "org.freedesktop.DBus.Error.UnknownObject"}, scerr.PortalMissing, true},
+		{dbus.Error{Name: "org.freedesktop.DBus.Error.AccessDenied"}, scerr.PortalDenied, false},
+		{errors.New("connection reset"), scerr.PortalDenied, false},
+	}
+	for _, tt := range tests {
+		err := callError("Screenshot.Screenshot", tt.err)
+		if c := scerr.CodeOf(err); c != tt.code {
+			t.Errorf("%v: code %v, want %v", tt.err, c, tt.code)
+		}
+		if errors.Is(err, ErrMissing) != tt.missing {
+			t.Errorf("%v: wraps ErrMissing = %v, want %v", tt.err, !tt.missing, tt.missing)
+		}
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	if a, b := newToken(), newToken(); a == b {
+		t.Errorf("two requests got the same token %q", a)
+	}
+}
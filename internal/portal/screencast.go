@@ -1,61 +1,140 @@
 package portal
 
 import (
-	"fmt"
+	"os"
 
 	"github.com/godbus/dbus/v5"
+
+	scerr "github.com/almightynan/swiftcap/internal/errors"
 )
 
-func StartScreencast() error {
-	conn, err := dbus.SessionBus()
+// Screencast is a running org.freedesktop.portal.ScreenCast session. Remote is
+// the PipeWire socket handed out by OpenPipeWireRemote and NodeID the stream
+// to consume from it. Close must be called to end the session.
+type Screencast struct {
+	NodeID uint32
+	Remote *os.File
+
+	conn    *dbus.Conn
+	session dbus.ObjectPath
+}
+
+// StartScreencast walks the ScreenCast portal through CreateSession,
+// SelectSources, Start and OpenPipeWireRemote. cursor embeds the pointer into
+// the stream instead of leaving it out.
+func StartScreencast(cursor bool) (*Screencast, error) {
+	// private connection: the portal tears the session down when its owner
+	// disconnects, so it has to live exactly as long as the recording.
+	conn, err := dbus.ConnectSessionBus()
 	if err != nil {
-		return fmt.Errorf("E_PORTAL_DENIED=11: Failed to connect to D-Bus session: %w", err)
+		return nil, scerr.Errorf(scerr.PortalMissing, "Failed to connect to D-Bus session: %w", err)
 	}
-
-	obj := conn.Object("org.freedesktop.portal.Desktop", "/org/freedesktop/portal/desktop")
+	sc := &Screencast{conn: conn}
 
 	// create a session to cast the screen
-	var sessionHandle dbus.ObjectPath
-	err = obj.Call("org.freedesktop.portal.ScreenCast.CreateSession", 0, map[string]map[string]interface{}{}).Store(&sessionHandle)
+	res, err := request(conn, "org.freedesktop.portal.ScreenCast.CreateSession", map[string]dbus.Variant{
+		"session_handle_token": dbus.MakeVariant(newToken()),
+	})
 	if err != nil {
-		return fmt.Errorf("E_PORTAL_DENIED=11: CreateSession failed: %w", err)
+		conn.Close()
+		return nil, err
+	}
+	sc.session = sessionHandle(res)
+	if sc.session == "" {
+		conn.Close()
+		return nil, scerr.Errorf(scerr.PortalDenied, "CreateSession returned no session handle")
 	}
 
 	// SelectSources (monitor, cursor)
-	var selectSourcesHandle dbus.ObjectPath
-	selectSourcesOpts := map[string]map[string]interface{}{
-		"sources": {
-			"types":       uint32(1), // 1=monitor, 2=window
-			"multiple":    false,
-			"cursor_mode": uint32(2), // 2=metadata
-		},
-	}
-	err = obj.Call("org.freedesktop.portal.ScreenCast.SelectSources", 0, sessionHandle, selectSourcesOpts).Store(&selectSourcesHandle)
+	cursorMode := uint32(1) // 1=hidden
+	if cursor {
+		cursorMode = 2 // 2=embedded
+	}
+	_, err = request(conn, "org.freedesktop.portal.ScreenCast.SelectSources", map[string]dbus.Variant{
+		"types":       dbus.MakeVariant(uint32(1)), // 1=monitor, 2=window
+		"multiple":    dbus.MakeVariant(false),
+		"cursor_mode": dbus.MakeVariant(cursorMode),
+	}, sc.session)
 	if err != nil {
-		return fmt.Errorf("E_PORTAL_DENIED=11: SelectSources failed: %w", err)
+		sc.Close()
+		return nil, err
 	}
 
-	// start
-	var startResult map[string]dbus.Variant
-	err = obj.Call("org.freedesktop.portal.ScreenCast.Start", 0, sessionHandle, map[string]map[string]interface{}{}).Store(&startResult)
+	// start (empty parent window: we have no toplevel to attach the chooser to)
+	res, err = request(conn, "org.freedesktop.portal.ScreenCast.Start", nil, sc.session, "")
+	if err != nil {
+		sc.Close()
+		return nil, err
+	}
+	nodeID, err := firstStream(res)
 	if err != nil {
-		return fmt.Errorf("E_PORTAL_DENIED=11: Start failed: %w", err)
+		sc.Close()
+		return nil, err
 	}
+	sc.NodeID = nodeID
 
-	// get PipeWire node ID
-	nodes, ok := startResult["streams"]
-	if !ok {
-		return fmt.Errorf("E_PORTAL_DENIED=11: No streams returned")
+	// get the PipeWire remote the node lives on
+	var fd dbus.UnixFD
+	err = conn.Object(portalDest, portalPath).Call("org.freedesktop.portal.ScreenCast.OpenPipeWireRemote", 0,
+		sc.session, map[string]dbus.Variant{}).Store(&fd)
+	if err != nil {
+		sc.Close()
+		return nil, callError("OpenPipeWireRemote", err)
 	}
-	streams := nodes.Value().([]map[string]interface{})
-	if len(streams) == 0 {
-		return fmt.Errorf("E_PORTAL_DENIED=11: No PipeWire streams available")
+	sc.Remote = os.NewFile(uintptr(fd), "pipewire-remote")
+	return sc, nil
+}
+
+// Close ends the portal session and releases the PipeWire remote.
+func (sc *Screencast) Close() error {
+	if sc.Remote != nil {
+		sc.Remote.Close()
+		sc.Remote = nil
+	}
+	if sc.session != "" {
+		sc.conn.Object(portalDest, sc.session).Call("org.freedesktop.portal.Session.Close", 0)
+		sc.session = ""
 	}
-	nodeID, ok := streams[0]["node_id"].(uint32)
+	return sc.conn.Close()
+}
+
+// sessionHandle pulls session_handle out of a CreateSession response. the
+// spec says it's a string, some older portals send an object path.
+func sessionHandle(res map[string]dbus.Variant) dbus.ObjectPath {
+	v, ok := res["session_handle"]
 	if !ok {
-		return fmt.Errorf("E_PORTAL_DENIED=11: PipeWire node_id missing")
+		return ""
 	}
+	switch h := v.Value().(type) {
+	case string:
+		return dbus.ObjectPath(h)
+	case dbus.ObjectPath:
+		return h
+	}
+	return ""
+}
 
-	fmt.Printf("Wayland screencast PipeWire node ID: %d\n", nodeID)
-	return nil
+// firstStream returns the PipeWire node ID of the first stream in a Start
+// response. streams is a(ua{sv}), which godbus decodes as [][]interface{}.
+func firstStream(res map[string]dbus.Variant) (uint32, error) {
+	v, ok := res["streams"]
+	if !ok {
+		return 0, scerr.Errorf(scerr.PortalDenied, "No streams returned")
+	}
+	switch streams := v.Value().(type) {
+	case [][]interface{}:
+		if len(streams) > 0 && len(streams[0]) > 0 {
+			if id, ok := streams[0][0].(uint32); ok {
+				return id, nil
+			}
+		}
+	case []struct {
+		NodeID uint32
+		Props  map[string]dbus.Variant
+	}:
+		if len(streams) > 0 {
+			return streams[0].NodeID, nil
+		}
+	}
+	return 0, scerr.Errorf(scerr.PortalDenied, "No PipeWire streams available")
 }
@@ -0,0 +1,119 @@
+package portal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/portal/portaltest"
+)
+
+func TestSessionHandle(t *testing.T) {
+	tests := []struct {
+		name string
+		res  map[string]dbus.Variant
+		want dbus.ObjectPath
+	}{
+		{"string", map[string]dbus.Variant{"session_handle": dbus.MakeVariant("/org/freedesktop/portal/desktop/session/1_2/t")}, "/org/freedesktop/portal/desktop/session/1_2/t"},
+		{"object path", map[string]dbus.Variant{"session_handle": dbus.MakeVariant(dbus.ObjectPath("/s/1"))}, "/s/1"},
+		{"missing", map[string]dbus.Variant{}, ""},
+		{"wrong type", map[string]dbus.Variant{"session_handle": dbus.MakeVariant(uint32(1))}, ""},
+	}
+	for _, tt := range tests {
+		if got := sessionHandle(tt.res); got != tt.want {
+			t.Errorf("%s: sessionHandle = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFirstStream(t *testing.T) {
+	props := map[string]dbus.Variant{"size": dbus.MakeVariant([]int32{1920, 1080})}
+	tests := []struct {
+		name    string
+		streams any
+		want    uint32
+		wantErr bool
+	}{
+		{"generic", [][]interface{}{{uint32(57), props}, {uint32(58), props}}, 57, false},
+		{"typed", []struct {
+			NodeID uint32
+			Props  map[string]dbus.Variant
+		}{{NodeID: 61, Props: props}}, 61, false},
+		{"empty", [][]interface{}{}, 0, true},
+		{"node isn't a uint32", [][]interface{}{{"57", props}}, 0, true},
+		{"not a stream list", "57", 0, true},
+		{"no streams key", nil, 0, true},
+	}
+	for _, tt := range tests {
+		res := map[string]dbus.Variant{}
+		if tt.streams != nil {
+			res["streams"] = dbus.MakeVariant(tt.streams)
+		}
+		got, err := firstStream(res)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%s: firstStream = %d, %v; want %d, an error: %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+		if err != nil && scerr.CodeOf(err) != scerr.PortalDenied {
+			t.Errorf("%s: %v is %v, want PortalDenied", tt.name, err, scerr.CodeOf(err))
+		}
+	}
+}
+
+func TestStartScreencast(t *testing.T) {
+	portaltest.Bus(t)
+	p := &portaltest.Portal{NodeID: 57}
+	p.Serve(t, "ScreenCast")
+
+	sc, err := StartScreencast(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.NodeID != 57 || sc.Remote == nil {
+		t.Errorf("node %d, remote %v; want 57 and a remote", sc.NodeID, sc.Remote)
+	}
+	if mode, _ := p.Options("SelectSources")["cursor_mode"].Value().(uint32); mode != 2 {
+		t.Errorf("cursor_mode = %d, want 2 (embedded)", mode)
+	}
+	session := sc.session
+	if err := sc.Close(); err != nil {
+		t.Error(err)
+	}
+	want := []string{"CreateSession", "SelectSources", "Start", "OpenPipeWireRemote"}
+	if got := p.Calls(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("calls = %v, want %v", got, want)
+	}
+	if closed := p.Closed(); len(closed) != 1 || closed[0] != session {
+		t.Errorf("closed %v, want the session %s", closed, session)
+	}
+}
+
+func TestStartScreencastRefused(t *testing.T) {
+	tests := []struct {
+		name     string
+		response uint32
+		ifaces   []string
+		code     scerr.Code
+	}{
+		{"cancelled", 1, []string{"ScreenCast"}, scerr.Cancelled},
+		{"denied", 2, []string{"ScreenCast"}, scerr.PortalDenied},
+		{"no screencast interface", 0, []string{"Screenshot"}, scerr.PortalMissing},
+		{"no portal", 0, nil, scerr.PortalMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			portaltest.Bus(t)
+			if tt.ifaces != nil {
+				(&portaltest.Portal{Response: tt.response}).Serve(t, tt.ifaces...)
+			}
+			sc, err := StartScreencast(false)
+			if err == nil {
+				sc.Close()
+			}
+			if c := scerr.CodeOf(err); c != tt.code {
+				t.Errorf("StartScreencast = %v (%v), want %v", err, c, tt.code)
+			}
+		})
+	}
+}
@@ -1,9 +1,70 @@
 package portal
 
-func TakeScreenshot(region string, out string) error {
-	return nil
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+)
+
+// Screenshot asks the Screenshot portal for a full-screen capture and
+// decodes the file it hands back. The portal saves that file where the
+// user keeps pictures; once decoded it's removed, unless it was there
+// before we asked. cropping and encoding are the caller's job.
+func Screenshot() (image.Image, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, scerr.Errorf(scerr.PortalMissing, "Failed to connect to D-Bus session: %w", err)
+	}
+	defer conn.Close()
+
+	// file times can be as coarse as a second
+	asked := time.Now().Truncate(time.Second)
+	res, err := request(conn, "org.freedesktop.portal.Screenshot.Screenshot", map[string]dbus.Variant{
+		"interactive": dbus.MakeVariant(false),
+		"modal":       dbus.MakeVariant(true),
+	}, "")
+	if err != nil {
+		return nil, err
+	}
+	v, ok := res["uri"]
+	if !ok {
+		return nil, scerr.Errorf(scerr.PortalDenied, "Screenshot returned no uri")
+	}
+	uri, _ := v.Value().(string)
+	src, err := uriPath(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, fmt.Errorf("open portal screenshot: %w", err)
+	}
+	img, _, err := image.Decode(in)
+	fi, statErr := in.Stat()
+	in.Close()
+	if err != nil {
+		return nil, fmt.Errorf("decode portal screenshot: %w", err)
+	}
+	if statErr == nil && !fi.ModTime().Before(asked) {
+		os.Remove(src)
+	}
+	return img, nil
 }
 
-/*
-	just a placeholder for now, will or will not use this to replace shoot/shot_x11.go
-*/
+// uriPath turns the portal's file:// uri into a local path.
+func uriPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" || u.Path == "" {
+		return "", scerr.Errorf(scerr.PortalDenied, "unexpected screenshot uri %q", uri)
+	}
+	return u.Path, nil
+}
@@ -0,0 +1,131 @@
+package portal
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+	"github.com/almightynan/swiftcap/internal/portal/portaltest"
+)
+
+func TestURIPath(t *testing.T) {
+	tests := []struct {
+		uri     string
+		want    string
+		wantErr bool
+	}{
+		{"file:///home/me/Pictures/Screenshot.png", "/home/me/Pictures/Screenshot.png", false},
+		{"file:///tmp/Screenshot%20from%202026-03-07.png", "/tmp/Screenshot from 2026-03-07.png", false},
+		{"file://localhost/tmp/s.png", "/tmp/s.png", false},
+		{"https://example.com/s.png", "", true},
+		{"/tmp/s.png", "", true},
+		{"file://", "", true},
+		{"", "", true},
+		{"file:///%zz", "", true},
+	}
+	for _, tt := range tests {
+		got, err := uriPath(tt.uri)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("uriPath(%q) = %q, %v; want %q, an error: %v", tt.uri, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// writePNG saves a w x h picture at path.
+func writePNG(t *testing.T, path string, w, h int) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScreenshot(t *testing.T) {
+	portaltest.Bus(t)
+	path := filepath.Join(t.TempDir(), "Screenshot from 2026-03-07.png")
+	p := &portaltest.Portal{URI: "file://" + path, Shoot: func() { writePNG(t, path, 64, 48) }}
+	p.Serve(t, "Screenshot")
+
+	img, err := Screenshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size != image.Pt(64, 48) {
+		t.Errorf("screenshot is %v, want 64x48", size)
+	}
+	if interactive, _ := p.Options("Screenshot")["interactive"].Value().(bool); interactive {
+		t.Error("asked for an interactive screenshot")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the portal's file was left behind: %v", err)
+	}
+}
+
+func TestScreenshotKeepsOlderFile(t *testing.T) {
+	portaltest.Bus(t)
+	// a portal that hands back a picture the user already had
+	path := filepath.Join(t.TempDir(), "wallpaper.png")
+	writePNG(t, path, 8, 8)
+	hour := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, hour, hour); err != nil {
+		t.Fatal(err)
+	}
+	(&portaltest.Portal{URI: "file://" + path}).Serve(t, "Screenshot")
+
+	if _, err := Screenshot(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("removed a file we didn't ask for: %v", err)
+	}
+}
+
+func TestScreenshotRefused(t *testing.T) {
+	tests := []struct {
+		name     string
+		response uint32
+		ifaces   []string
+		uri      string
+		code     scerr.Code
+	}{
+		{"cancelled", 1, []string{"Screenshot"}, "", scerr.Cancelled},
+		{"denied", 2, []string{"Screenshot"}, "", scerr.PortalDenied},
+		{"not a file", 0, []string{"Screenshot"}, "https://example.com/s.png", scerr.PortalDenied},
+		{"no screenshot interface", 0, []string{"ScreenCast"}, "", scerr.PortalMissing},
+		{"no portal", 0, nil, "", scerr.PortalMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			portaltest.Bus(t)
+			if tt.ifaces != nil {
+				(&portaltest.Portal{Response: tt.response, URI: tt.uri}).Serve(t, tt.ifaces...)
+			}
+			_, err := Screenshot()
+			if c := scerr.CodeOf(err); c != tt.code {
+				t.Errorf("Screenshot = %v (%v), want %v", err, c, tt.code)
+			}
+		})
+	}
+}
+
+func TestNoSessionBus(t *testing.T) {
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path="+t.TempDir()+"/bus")
+	if _, err := Screenshot(); scerr.CodeOf(err) != scerr.PortalMissing {
+		t.Errorf("Screenshot = %v (%v), want %v", err, scerr.CodeOf(err), scerr.PortalMissing)
+	}
+	sc, err := StartScreencast(false)
+	if err == nil {
+		sc.Close()
+	}
+	if scerr.CodeOf(err) != scerr.PortalMissing {
+		t.Errorf("StartScreencast = %v (%v), want %v", err, scerr.CodeOf(err), scerr.PortalMissing)
+	}
+}
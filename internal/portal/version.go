@@ -0,0 +1,25 @@
+package portal
+
+import (
+	"github.com/godbus/dbus/v5"
+
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+)
+
+// Version asks the running portal which version of iface ("ScreenCast",
+// "Screenshot") it implements. A portal without the interface is PortalMissing.
+func Version(iface string) (uint32, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return 0, scerr.Errorf(scerr.PortalMissing, "Failed to connect to D-Bus session: %w", err)
+	}
+	v, err := conn.Object(portalDest, portalPath).GetProperty("org.freedesktop.portal." + iface + ".version")
+	if err != nil {
+		return 0, callError(iface+".version", err)
+	}
+	n, ok := v.Value().(uint32)
+	if !ok {
+		return 0, scerr.Errorf(scerr.PortalDenied, "%s.version is %s, not a number", iface, v.Signature())
+	}
+	return n, nil
+}
@@ -0,0 +1,237 @@
+/*
+	a tiny client for the pulseaudio native protocol, which pipewire-pulse
+	speaks too. it only lists sources, so it can name them for --a-src
+	without pactl. the server is found the way libpulse finds it
+	($PULSE_SERVER, then the runtime dir), which is also how a fake server
+	gets swapped in.
+*/
+
+package pulse
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// command numbers from pulsecore/native-common.h
+const (
+	cmdError             = 0
+	cmdReply             = 2
+	cmdAuth              = 8
+	cmdSetClientName     = 9
+	cmdGetServerInfo     = 20
+	cmdGetSourceInfoList = 24
+)
+
+// protocolVersion is deliberately old: the server answers in the client's
+// version, and at 13 the replies we parse carry the fewest fields.
+const protocolVersion = 13
+
+// controlChannel marks packets that carry commands rather than audio.
+const controlChannel = 0xffffffff
+
+// ServerInfo is what GET_SERVER_INFO returns that swiftcap cares about.
+type ServerInfo struct {
+	Name          string `json:"name"`
+	Version       string `json:"version"`
+	DefaultSink   string `json:"default_sink"`
+	DefaultSource string `json:"default_source"`
+}
+
+// Client is one connection to the sound server.
+type Client struct {
+	conn net.Conn
+	tag  uint32
+}
+
+// ServerAddress returns the network and address of the sound server:
+// $PULSE_SERVER's first entry (unix:PATH, tcp:HOST[:PORT] or a bare path),
+// else $PULSE_RUNTIME_PATH/native, else $XDG_RUNTIME_DIR/pulse/native.
+func ServerAddress() (network, addr string) {
+	if s := strings.Fields(os.Getenv("PULSE_SERVER")); len(s) > 0 {
+		a := s[0]
+		if strings.HasPrefix(a, "{") {
+			// {machine-id} prefix: only meant for that machine
+			if i := strings.Index(a, "}"); i >= 0 {
+				a = a[i+1:]
+			}
+		}
+		switch {
+		case strings.HasPrefix(a, "unix:"):
+			return "unix", strings.TrimPrefix(a, "unix:")
+		case strings.HasPrefix(a, "/"):
+			return "unix", a
+		case strings.HasPrefix(a, "tcp:"), strings.HasPrefix(a, "tcp4:"), strings.HasPrefix(a, "tcp6:"):
+			a = a[strings.Index(a, ":")+1:]
+		}
+		if _, _, err := net.SplitHostPort(a); err != nil {
+			a = net.JoinHostPort(a, "4713")
+		}
+		return "tcp", a
+	}
+	if p := os.Getenv("PULSE_RUNTIME_PATH"); p != "" {
+		return "unix", filepath.Join(p, "native")
+	}
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = fmt.Sprintf("/run/user/%d", os.Getuid())
+	}
+	return "unix", filepath.Join(dir, "pulse", "native")
+}
+
+// Dial connects to the server ServerAddress names and authenticates.
+func Dial() (*Client, error) {
+	network, addr := ServerAddress()
+	return DialAddr(network, addr)
+}
+
+// DialAddr connects to the server at addr and authenticates.
+func DialAddr(network, addr string) (*Client, error) {
+	conn, err := net.DialTimeout(network, addr, 2*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("no sound server at %s: %w", addr, err)
+	}
+	c := &Client{conn: conn}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if err := c.auth(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close hangs up.
+func (c *Client) Close() error { return c.conn.Close() }
+
+// auth sends the cookie and, over a unix socket, our credentials, which is
+// what lets a server without the cookie file trust a same-user client.
+func (c *Client) auth() error {
+	var w tagWriter
+	w.u32(protocolVersion)
+	w.arbitrary(cookie())
+	if _, err := c.request(cmdAuth, &w); err != nil {
+		return fmt.Errorf("sound server refused us: %w", err)
+	}
+	var name tagWriter
+	name.proplist(map[string]string{"application.name": "swiftcap"})
+	_, err := c.request(cmdSetClientName, &name)
+	return err
+}
+
+// cookie is the 256-byte auth cookie, or zeros when there is none.
+func cookie() []byte {
+	var paths []string
+	if p := os.Getenv("PULSE_COOKIE"); p != "" {
+		paths = append(paths, p)
+	}
+	if cfg, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(cfg, "pulse", "cookie"))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".pulse-cookie"))
+	}
+	for _, p := range paths {
+		if b, err := os.ReadFile(p); err == nil && len(b) >= 256 {
+			return b[:256]
+		}
+	}
+	return make([]byte, 256)
+}
+
+// request sends cmd with the arguments in args and returns the reply's
+// arguments.
+func (c *Client) request(cmd uint32, args *tagWriter) (*tagReader, error) {
+	c.tag++
+	tag := c.tag
+	var w tagWriter
+	w.u32(cmd)
+	w.u32(tag)
+	if args != nil {
+		w.b = append(w.b, args.b...)
+	}
+	if err := c.send(cmd, w.b); err != nil {
+		return nil, err
+	}
+	for {
+		payload, err := c.recv()
+		if err != nil {
+			return nil, err
+		}
+		r := &tagReader{b: payload}
+		command, replyTag := r.u32(), r.u32()
+		if r.err != nil {
+			return nil, r.err
+		}
+		if replyTag != tag {
+			continue // an event or a stale reply
+		}
+		switch command {
+		case cmdReply:
+			return r, nil
+		case cmdError:
+			return nil, errorCode(r.u32())
+		}
+		return nil, fmt.Errorf("pulse: unexpected command %d in reply", command)
+	}
+}
+
+func (c *Client) send(cmd uint32, payload []byte) error {
+	pkt := make([]byte, 20, 20+len(payload))
+	binary.BigEndian.PutUint32(pkt[0:], uint32(len(payload)))
+	binary.BigEndian.PutUint32(pkt[4:], controlChannel)
+	pkt = append(pkt, payload...)
+	if uc, ok := c.conn.(*net.UnixConn); ok && cmd == cmdAuth {
+		creds := syscall.UnixCredentials(&syscall.Ucred{
+			Pid: int32(os.Getpid()), Uid: uint32(os.Getuid()), Gid: uint32(os.Getgid()),
+		})
+		_, _, err := uc.WriteMsgUnix(pkt, creds, nil)
+		return err
+	}
+	_, err := c.conn.Write(pkt)
+	return err
+}
+
+// recv returns the payload of the next control packet, skipping any audio.
+func (c *Client) recv() ([]byte, error) {
+	var hdr [20]byte
+	for {
+		if _, err := io.ReadFull(c.conn, hdr[:]); err != nil {
+			return nil, fmt.Errorf("pulse: %w", err)
+		}
+		n := binary.BigEndian.Uint32(hdr[0:])
+		if n > maxPacketBytes {
+			return nil, fmt.Errorf("pulse: %d byte packet is too big", n)
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(c.conn, payload); err != nil {
+			return nil, fmt.Errorf("pulse: %w", err)
+		}
+		if binary.BigEndian.Uint32(hdr[4:]) == controlChannel {
+			return payload, nil
+		}
+	}
+}
+
+// errorCode names the common pa_error_code values.
+func errorCode(code uint32) error {
+	names := map[uint32]string{
+		1: "access denied", 2: "unknown command", 3: "invalid argument",
+		4: "entity exists", 5: "no such entity", 6: "connection refused",
+		7: "protocol error", 8: "timeout", 9: "no authentication key",
+		10: "internal error", 11: "connection terminated", 12: "entity killed",
+		13: "invalid server", 19: "not supported", 21: "obsolete functionality",
+		22: "missing implementation", 24: "I/O error",
+	}
+	if s, ok := names[code]; ok {
+		return errors.New("pulse: " + s)
+	}
+	return fmt.Errorf("pulse: error %d", code)
+}
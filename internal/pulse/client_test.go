@@ -0,0 +1,145 @@
+package pulse
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// fakeServer answers GET_SERVER_INFO and GET_SOURCE_INFO_LIST on the
+// other end of a pipe, sending an audio packet and an event first so the
+// client has to skip them.
+func fakeServer(conn net.Conn, info ServerInfo, sources []Source) {
+	defer conn.Close()
+	packet := func(channel uint32, payload []byte) {
+		hdr := make([]byte, 20)
+		binary.BigEndian.PutUint32(hdr, uint32(len(payload)))
+		binary.BigEndian.PutUint32(hdr[4:], channel)
+		conn.Write(append(hdr, payload...))
+	}
+	for {
+		hdr := make([]byte, 20)
+		if _, err := io.ReadFull(conn, hdr); err != nil {
+			return
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(hdr))
+		if _, err := io.ReadFull(conn, payload); err != nil {
+			return
+		}
+		r := &tagReader{b: payload}
+		cmd, tag := r.u32(), r.u32()
+
+		packet(0, []byte{1, 2, 3, 4})
+		var event tagWriter
+		event.u32(cmdReply)
+		event.u32(0xffffffff)
+		packet(controlChannel, event.b)
+
+		var w tagWriter
+		w.u32(cmdReply)
+		w.u32(tag)
+		spec := []byte{tagSampleSpec, 3, 2, 0, 0, 0xbb, 0x80}
+		usec := []byte{tagUsec, 0, 0, 0, 0, 0, 0, 0, 0}
+		switch cmd {
+		case cmdGetServerInfo:
+			w.str(info.Name)
+			w.str(info.Version)
+			w.str("user")
+			w.str("host")
+			w.b = append(w.b, spec...)
+			w.str(info.DefaultSink)
+			w.str(info.DefaultSource)
+		case cmdGetSourceInfoList:
+			for _, s := range sources {
+				w.u32(s.Index)
+				w.str(s.Name)
+				w.str(s.Description)
+				w.b = append(w.b, spec...)
+				w.b = append(w.b, tagChannelMap, 2, 1, 2)
+				w.u32(0)
+				w.b = append(w.b, tagCVolume, 2, 0, 1, 0, 0, 0, 1, 0, 0)
+				w.b = append(w.b, tagFalse)
+				w.u32(0xffffffff)
+				if s.MonitorOf == "" {
+					w.b = append(w.b, tagStringNull)
+				} else {
+					w.str(s.MonitorOf)
+				}
+				w.b = append(w.b, usec...)
+				w.str(s.Driver)
+				w.u32(0)
+				w.proplist(map[string]string{"device.class": "sound"})
+				w.b = append(w.b, usec...)
+			}
+		default:
+			w = tagWriter{}
+			w.u32(cmdError)
+			w.u32(tag)
+			w.u32(2)
+		}
+		packet(controlChannel, w.b)
+	}
+}
+
+func TestSources(t *testing.T) {
+	info := ServerInfo{Name: "pulseaudio", Version: "16.1", DefaultSink: "speakers", DefaultSource: "usb-mic"}
+	spec := SampleSpec{Format: 3, Channels: 2, Rate: 48000}
+	want := []Source{
+		{Index: 0, Name: "speakers.monitor", Description: "Monitor of Speakers", Spec: spec, MonitorOf: "speakers", Driver: "module-alsa-card.c", Alias: AliasDesktop},
+		{Index: 1, Name: "hdmi.monitor", Description: "Monitor of HDMI", Spec: spec, MonitorOf: "hdmi", Driver: "module-alsa-card.c"},
+		{Index: 2, Name: "usb-mic", Description: "USB Microphone", Spec: spec, Driver: "module-alsa-card.c", Default: true, Alias: AliasMic},
+	}
+	ours, theirs := net.Pipe()
+	go fakeServer(theirs, info, want)
+	c := &Client{conn: ours}
+	defer c.Close()
+
+	got, gotInfo, err := c.Sources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotInfo != info {
+		t.Errorf("info = %+v, want %+v", gotInfo, info)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sources =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestRequestError(t *testing.T) {
+	ours, theirs := net.Pipe()
+	go fakeServer(theirs, ServerInfo{}, nil)
+	c := &Client{conn: ours}
+	defer c.Close()
+	_, err := c.request(99, nil)
+	if err == nil || err.Error() != "pulse: unknown command" {
+		t.Errorf("err = %v, want pulse: unknown command", err)
+	}
+}
+
+func TestServerAddress(t *testing.T) {
+	tests := []struct {
+		server, runtime, xdg string
+		network, addr        string
+	}{
+		{"unix:/tmp/pulse.sock", "", "", "unix", "/tmp/pulse.sock"},
+		{"/tmp/pulse.sock other", "", "", "unix", "/tmp/pulse.sock"},
+		{"{0123abcd}unix:/run/p", "", "", "unix", "/run/p"},
+		{"tcp:10.0.0.2", "", "", "tcp", "10.0.0.2:4713"},
+		{"tcp6:[::1]:4000", "", "", "tcp", "[::1]:4000"},
+		{"media-box", "", "", "tcp", "media-box:4713"},
+		{"", "/run/pa", "/run/user/1000", "unix", "/run/pa/native"},
+		{"", "", "/run/user/1000", "unix", "/run/user/1000/pulse/native"},
+	}
+	for _, tt := range tests {
+		t.Setenv("PULSE_SERVER", tt.server)
+		t.Setenv("PULSE_RUNTIME_PATH", tt.runtime)
+		t.Setenv("XDG_RUNTIME_DIR", tt.xdg)
+		network, addr := ServerAddress()
+		if network != tt.network || addr != tt.addr {
+			t.Errorf("PULSE_SERVER=%q: got %s %s, want %s %s", tt.server, network, addr, tt.network, tt.addr)
+		}
+	}
+}
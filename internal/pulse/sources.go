@@ -0,0 +1,162 @@
+package pulse
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Source aliases for --a-src.
+const (
+	AliasDesktop = "desktop" // the default sink's monitor: what you hear
+	AliasMic     = "mic"     // the default source
+)
+
+// SampleSpec is a source's native format.
+type SampleSpec struct {
+	Format   uint8
+	Channels uint8
+	Rate     uint32
+}
+
+// formats are pa_sample_format names, by value.
+var formats = []string{"u8", "aLaw", "uLaw", "s16le", "s16be", "float32le", "float32be",
+	"s32le", "s32be", "s24le", "s24be", "s24-32le", "s24-32be"}
+
+// FormatName is the format as pactl prints it.
+func (s SampleSpec) FormatName() string {
+	if int(s.Format) < len(formats) {
+		return formats[s.Format]
+	}
+	return fmt.Sprintf("format%d", s.Format)
+}
+
+func (s SampleSpec) String() string {
+	return fmt.Sprintf("%s %dch %dHz", s.FormatName(), s.Channels, s.Rate)
+}
+
+func (s SampleSpec) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Format   string `json:"format"`
+		Channels uint8  `json:"channels"`
+		Rate     uint32 `json:"rate"`
+	}{s.FormatName(), s.Channels, s.Rate})
+}
+
+// Source is one capture source: an input, or a sink's monitor.
+type Source struct {
+	Index       uint32     `json:"index"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Spec        SampleSpec `json:"sample_spec"`
+	MonitorOf   string     `json:"monitor_of,omitempty"` // the sink this monitors, "" for an input
+	Driver      string     `json:"driver"`
+	Default     bool       `json:"default"`         // the server's default source
+	Alias       string     `json:"alias,omitempty"` // AliasDesktop or AliasMic when it stands for one
+}
+
+// ServerInfo asks the server for its name and defaults.
+func (c *Client) ServerInfo() (ServerInfo, error) {
+	r, err := c.request(cmdGetServerInfo, nil)
+	if err != nil {
+		return ServerInfo{}, err
+	}
+	var info ServerInfo
+	info.Name = r.str()
+	info.Version = r.str()
+	r.str() // user
+	r.str() // host
+	r.sampleSpec()
+	info.DefaultSink = r.str()
+	info.DefaultSource = r.str()
+	return info, r.err
+}
+
+// Sources lists every source, sink monitors included, with Default and
+// Alias filled in from the server's defaults.
+func (c *Client) Sources() ([]Source, ServerInfo, error) {
+	info, err := c.ServerInfo()
+	if err != nil {
+		return nil, info, err
+	}
+	r, err := c.request(cmdGetSourceInfoList, nil)
+	if err != nil {
+		return nil, info, err
+	}
+	var out []Source
+	for r.more() {
+		// field order of protocol version 13, see source_fill_tagstruct
+		var s Source
+		s.Index = r.u32()
+		s.Name = r.str()
+		s.Description = r.str()
+		s.Spec = r.sampleSpec()
+		r.channelMap()
+		r.u32() // owner module
+		r.cvolume()
+		r.boolean() // muted
+		r.u32()     // monitor_of_sink index
+		s.MonitorOf = r.str()
+		r.u64(tagUsec) // latency
+		s.Driver = r.str()
+		r.u32() // flags
+		r.proplist()
+		r.u64(tagUsec) // configured latency
+		if r.err != nil {
+			return nil, info, fmt.Errorf("pulse: bad source list: %w", r.err)
+		}
+		s.Default = s.Name == info.DefaultSource
+		out = append(out, s)
+	}
+	markAliases(out, info)
+	return out, info, nil
+}
+
+// markAliases tags the sources desktop and mic resolve to.
+func markAliases(srcs []Source, info ServerInfo) {
+	for i := range srcs {
+		switch {
+		case info.DefaultSink != "" && srcs[i].MonitorOf == info.DefaultSink:
+			srcs[i].Alias = AliasDesktop
+		case srcs[i].Default:
+			srcs[i].Alias = AliasMic
+		}
+	}
+}
+
+// ListSources connects to the default server and lists its sources.
+func ListSources() ([]Source, ServerInfo, error) {
+	c, err := Dial()
+	if err != nil {
+		return nil, ServerInfo{}, err
+	}
+	defer c.Close()
+	return c.Sources()
+}
+
+// Resolve turns the aliases desktop and mic into the source they stand for
+// right now; any other name comes back as it is, without asking the server.
+func Resolve(name string) (string, error) {
+	if name != AliasDesktop && name != AliasMic {
+		return name, nil
+	}
+	srcs, info, err := ListSources()
+	if err != nil {
+		return "", err
+	}
+	for _, s := range srcs {
+		if s.Alias == name {
+			return s.Name, nil
+		}
+	}
+	if name == AliasDesktop {
+		if info.DefaultSink == "" {
+			return "", fmt.Errorf("the sound server has no default output to record")
+		}
+		// monitors are named after their sink by convention
+		return info.DefaultSink + ".monitor", nil
+	}
+	if info.DefaultSource == "" {
+		return "", fmt.Errorf("the sound server has no default input")
+	}
+	return info.DefaultSource, nil
+}
@@ -0,0 +1,211 @@
+/*
+	the pulseaudio native protocol's "tagstruct": every value on the wire is
+	a one-byte type tag followed by the value, big-endian. only the types
+	swiftcap reads or writes are here.
+*/
+
+package pulse
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	tagString     = 't'
+	tagStringNull = 'N'
+	tagU32        = 'L'
+	tagSampleSpec = 'a'
+	tagArbitrary  = 'x'
+	tagTrue       = '1'
+	tagFalse      = '0'
+	tagUsec       = 'U'
+	tagChannelMap = 'm'
+	tagCVolume    = 'v'
+	tagPropList   = 'P'
+
+	maxPacketBytes = 16 << 20
+)
+
+var errShort = errors.New("pulse: truncated message")
+
+// tagWriter builds a tagstruct.
+type tagWriter struct{ b []byte }
+
+func (w *tagWriter) u32(v uint32) {
+	w.b = append(w.b, tagU32)
+	w.b = binary.BigEndian.AppendUint32(w.b, v)
+}
+
+func (w *tagWriter) str(s string) {
+	w.b = append(w.b, tagString)
+	w.b = append(w.b, s...)
+	w.b = append(w.b, 0)
+}
+
+func (w *tagWriter) arbitrary(p []byte) {
+	w.b = append(w.b, tagArbitrary)
+	w.b = binary.BigEndian.AppendUint32(w.b, uint32(len(p)))
+	w.b = append(w.b, p...)
+}
+
+func (w *tagWriter) proplist(props map[string]string) {
+	w.b = append(w.b, tagPropList)
+	for k, v := range props {
+		w.str(k)
+		w.u32(uint32(len(v) + 1))
+		w.arbitrary(append([]byte(v), 0))
+	}
+	w.b = append(w.b, tagStringNull)
+}
+
+// tagReader walks a tagstruct.
+type tagReader struct {
+	b   []byte
+	err error
+}
+
+func (r *tagReader) more() bool { return r.err == nil && len(r.b) > 0 }
+
+func (r *tagReader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.b) < n {
+		r.err = errShort
+		return nil
+	}
+	p := r.b[:n]
+	r.b = r.b[n:]
+	return p
+}
+
+func (r *tagReader) tag(want byte) bool {
+	p := r.take(1)
+	if p == nil {
+		return false
+	}
+	if p[0] != want {
+		r.err = fmt.Errorf("pulse: expected tag %q, got %q", want, p[0])
+		return false
+	}
+	return true
+}
+
+func (r *tagReader) u32() uint32 {
+	if !r.tag(tagU32) {
+		return 0
+	}
+	if p := r.take(4); p != nil {
+		return binary.BigEndian.Uint32(p)
+	}
+	return 0
+}
+
+func (r *tagReader) u64(tag byte) uint64 {
+	if !r.tag(tag) {
+		return 0
+	}
+	if p := r.take(8); p != nil {
+		return binary.BigEndian.Uint64(p)
+	}
+	return 0
+}
+
+// str reads a string; a null string reads as "".
+func (r *tagReader) str() string {
+	p := r.take(1)
+	if p == nil {
+		return ""
+	}
+	switch p[0] {
+	case tagStringNull:
+		return ""
+	case tagString:
+		for i, c := range r.b {
+			if c == 0 {
+				s := string(r.b[:i])
+				r.b = r.b[i+1:]
+				return s
+			}
+		}
+		r.err = errShort
+	default:
+		r.err = fmt.Errorf("pulse: expected a string, got tag %q", p[0])
+	}
+	return ""
+}
+
+func (r *tagReader) boolean() bool {
+	p := r.take(1)
+	if p == nil {
+		return false
+	}
+	switch p[0] {
+	case tagTrue:
+		return true
+	case tagFalse:
+		return false
+	}
+	r.err = fmt.Errorf("pulse: expected a boolean, got tag %q", p[0])
+	return false
+}
+
+func (r *tagReader) sampleSpec() SampleSpec {
+	if !r.tag(tagSampleSpec) {
+		return SampleSpec{}
+	}
+	p := r.take(6)
+	if p == nil {
+		return SampleSpec{}
+	}
+	return SampleSpec{Format: p[0], Channels: p[1], Rate: binary.BigEndian.Uint32(p[2:])}
+}
+
+func (r *tagReader) channelMap() {
+	if r.tag(tagChannelMap) {
+		if p := r.take(1); p != nil {
+			r.take(int(p[0]))
+		}
+	}
+}
+
+func (r *tagReader) cvolume() {
+	if r.tag(tagCVolume) {
+		if p := r.take(1); p != nil {
+			r.take(4 * int(p[0]))
+		}
+	}
+}
+
+func (r *tagReader) arbitrary() []byte {
+	if !r.tag(tagArbitrary) {
+		return nil
+	}
+	p := r.take(4)
+	if p == nil {
+		return nil
+	}
+	return r.take(int(binary.BigEndian.Uint32(p)))
+}
+
+// proplist reads a property list, keeping the values that are strings.
+func (r *tagReader) proplist() map[string]string {
+	if !r.tag(tagPropList) {
+		return nil
+	}
+	props := make(map[string]string)
+	for r.err == nil {
+		k := r.str()
+		if k == "" {
+			break
+		}
+		r.u32() // length, repeated by the arbitrary
+		v := r.arbitrary()
+		if n := len(v); n > 0 && v[n-1] == 0 {
+			props[k] = string(v[:n-1])
+		}
+	}
+	return props
+}
@@ -0,0 +1,102 @@
+package pulse
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTagRoundTrip(t *testing.T) {
+	var w tagWriter
+	w.u32(0xdeadbeef)
+	w.str("")
+	w.str("alsa_input.pci-0000_00_1f.3.analog-stereo")
+	w.arbitrary([]byte{1, 2, 0, 3})
+	w.proplist(map[string]string{"application.name": "swiftcap", "empty": ""})
+	w.u32(7)
+
+	r := &tagReader{b: w.b}
+	if got := r.u32(); got != 0xdeadbeef {
+		t.Errorf("u32 = %#x, want 0xdeadbeef", got)
+	}
+	if got := r.str(); got != "" {
+		t.Errorf("empty str = %q", got)
+	}
+	if got := r.str(); got != "alsa_input.pci-0000_00_1f.3.analog-stereo" {
+		t.Errorf("str = %q", got)
+	}
+	if got := r.arbitrary(); !reflect.DeepEqual(got, []byte{1, 2, 0, 3}) {
+		t.Errorf("arbitrary = %v", got)
+	}
+	want := map[string]string{"application.name": "swiftcap", "empty": ""}
+	if got := r.proplist(); !reflect.DeepEqual(got, want) {
+		t.Errorf("proplist = %v, want %v", got, want)
+	}
+	if got := r.u32(); got != 7 {
+		t.Errorf("u32 after the proplist = %d, want 7", got)
+	}
+	if r.err != nil || r.more() {
+		t.Errorf("err %v, %d bytes left", r.err, len(r.b))
+	}
+}
+
+func TestTagReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      []byte
+		read    func(r *tagReader) any
+		want    any
+		wantErr bool
+	}{
+		{"u32", []byte{tagU32, 0, 0, 1, 2}, func(r *tagReader) any { return r.u32() }, uint32(258), false},
+		{"short u32", []byte{tagU32, 0, 0}, func(r *tagReader) any { return r.u32() }, uint32(0), true},
+		{"u32 with the wrong tag", []byte{tagString, 0, 0, 0, 1}, func(r *tagReader) any { return r.u32() }, uint32(0), true},
+		{"empty", nil, func(r *tagReader) any { return r.u32() }, uint32(0), true},
+		{"null string", []byte{tagStringNull}, func(r *tagReader) any { return r.str() }, "", false},
+		{"unterminated string", []byte{tagString, 'a', 'b'}, func(r *tagReader) any { return r.str() }, "", true},
+		{"string with the wrong tag", []byte{tagU32}, func(r *tagReader) any { return r.str() }, "", true},
+		{"true", []byte{tagTrue}, func(r *tagReader) any { return r.boolean() }, true, false},
+		{"false", []byte{tagFalse}, func(r *tagReader) any { return r.boolean() }, false, false},
+		{"not a boolean", []byte{'x'}, func(r *tagReader) any { return r.boolean() }, false, true},
+		{"usec", []byte{tagUsec, 0, 0, 0, 0, 0, 0, 1, 0}, func(r *tagReader) any { return r.u64(tagUsec) }, uint64(256), false},
+		{
+			"sample spec",
+			[]byte{tagSampleSpec, 3, 2, 0, 0, 0xbb, 0x80},
+			func(r *tagReader) any { return r.sampleSpec() },
+			SampleSpec{Format: 3, Channels: 2, Rate: 48000},
+			false,
+		},
+		{"short arbitrary", []byte{tagArbitrary, 0, 0, 0, 9, 1}, func(r *tagReader) any { return r.arbitrary() }, []byte(nil), true},
+		{
+			"channel map and volume are skipped",
+			[]byte{tagChannelMap, 2, 1, 2, tagCVolume, 1, 0, 1, 0, 0, tagU32, 0, 0, 0, 5},
+			func(r *tagReader) any { r.channelMap(); r.cvolume(); return r.u32() },
+			uint32(5),
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &tagReader{b: tt.in}
+			got := tt.read(r)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+			if (r.err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want an error: %v", r.err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTagReaderStopsAtFirstError(t *testing.T) {
+	r := &tagReader{b: []byte{tagU32, 0}}
+	r.u32()
+	if !errors.Is(r.err, errShort) {
+		t.Fatalf("err = %v, want errShort", r.err)
+	}
+	r.b = []byte{tagString, 'a', 0}
+	if s := r.str(); s != "" || r.more() {
+		t.Errorf("read %q after an error", s)
+	}
+}
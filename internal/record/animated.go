@@ -0,0 +1,190 @@
+/*
+	animated gif/webp/apng output. these can't be encoded well while
+	capturing (a gif palette wants to see every frame first), so an animated
+	recording is captured as lossless ffv1 in mkv and converted when it
+	stops: palettegen over the whole clip, then paletteuse.
+*/
+
+package record
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	scerr "github.com/almightynan/swiftcap/internal/errors"
+)
+
+// captureContainer is what animated recordings are captured in.
+const captureContainer = "mkv"
+
+// AnimOptions shapes an animated export.
+type AnimOptions struct {
+	Fps    int    `json:"fps"`    // output frame rate, 0 = as captured
+	Width  int    `json:"width"`  // max output width, height follows; 0 = as captured
+	Dither string `json:"dither"` // paletteuse dither, "" = sierra2_4a
+	Loop   int    `json:"loop"`   // plays, 0 = forever
+	Budget int64  `json:"budget"` // bytes; a bigger result gets a warning, 0 = none
+}
+
+// DefaultAnimOptions suits a chat or PR: 15 fps, 960 wide, looping, and a
+// warning past 10 MiB.
+func DefaultAnimOptions() AnimOptions {
+	return AnimOptions{Fps: 15, Width: 960, Dither: "sierra2_4a", Budget: 10 << 20}
+}
+
+// Dithers lists the paletteuse dither modes.
+func Dithers() []string {
+	return []string{"sierra2_4a", "sierra2", "floyd_steinberg", "bayer", "heckbert", "none"}
+}
+
+// IsAnimated reports whether container is one of the animated formats.
+func IsAnimated(container string) bool {
+	switch container {
+	case "gif", "webp", "apng":
+		return true
+	}
+	return false
+}
+
+// Validate checks the export settings.
+func (a AnimOptions) Validate() error {
+	if a.Fps < 0 || a.Width < 0 || a.Loop < 0 || a.Budget < 0 {
+		return fmt.Errorf("animated fps, width, loop and size budget can't be negative")
+	}
+	if a.Dither != "" && !contains(Dithers(), a.Dither) {
+		return fmt.Errorf("unknown dither %q (want %s)", a.Dither, strings.Join(Dithers(), "|"))
+	}
+	return nil
+}
+
+// CaptureOptions returns what to record o's animated output with: lossless
+// ffv1 in mkv and no audio, keeping o.Out. o itself is returned for any
+// other container.
+func (o Options) CaptureOptions() Options {
+	if !IsAnimated(o.Container) {
+		return o
+	}
+	o.Container = captureContainer
+	o.Codec = "ffv1"
+	o.Preset = ""
+	o.CRF = -1
+	o.QP = 0
+	o.Audio = false
+	return o
+}
+
+// ExportAnimated converts the video in into an animated format at out.
+// gif and apng take two passes through a palette built from the whole clip;
+// webp is encoded by libwebp directly.
+func ExportAnimated(in, out, format string, a AnimOptions) error {
+	if !IsAnimated(format) {
+		return fmt.Errorf("%s is not an animated format (want gif|webp|apng)", format)
+	}
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	var filters []string
+	if a.Fps > 0 {
+		filters = append(filters, fmt.Sprintf("fps=%d", a.Fps))
+	}
+	if a.Width > 0 {
+		// only ever shrink; the quotes keep min's comma out of the filter chain
+		filters = append(filters, fmt.Sprintf("scale='min(%d,iw)':-1:flags=lanczos", a.Width))
+	}
+	pre := strings.Join(filters, ",")
+	if pre == "" {
+		pre = "null"
+	}
+
+	var args []string
+	switch format {
+	case "webp":
+		args = []string{"-i", in, "-vf", pre, "-c:v", "libwebp", "-lossless", "0", "-q:v", "75",
+			"-loop", fmt.Sprint(a.Loop), "-an", "-f", "webp", out}
+	default:
+		palette := filepath.Join(filepath.Dir(out), "."+filepath.Base(out)+".palette.png")
+		defer os.Remove(palette)
+		// stats_mode=diff spends the palette on what moves, which is
+		// what screen recordings are about
+		if err := runFFmpeg("-i", in, "-vf", pre+",palettegen=stats_mode=diff", "-update", "1", palette); err != nil {
+			return fmt.Errorf("building the palette failed: %w", err)
+		}
+		dither := a.Dither
+		if dither == "" {
+			dither = "sierra2_4a"
+		}
+		graph := fmt.Sprintf("[0:v]%s[x];[x][1:v]paletteuse=dither=%s:diff_mode=rectangle", pre, dither)
+		args = []string{"-i", in, "-i", palette, "-lavfi", graph, "-an"}
+		if format == "gif" {
+			args = append(args, "-loop", fmt.Sprint(gifLoop(a.Loop)), "-f", "gif", out)
+		} else {
+			args = append(args, "-plays", fmt.Sprint(a.Loop), "-f", "apng", out)
+		}
+	}
+	if err := runFFmpeg(args...); err != nil {
+		os.Remove(out)
+		return fmt.Errorf("%s export failed: %w", format, err)
+	}
+	return nil
+}
+
+// gifLoop turns a play count into the gif muxer's -loop, which counts
+// repeats after the first play and spells "forever" 0 and "once" -1.
+func gifLoop(plays int) int {
+	switch plays {
+	case 0:
+		return 0
+	case 1:
+		return -1
+	}
+	return plays - 1
+}
+
+// OverBudget returns the size of out and whether it is past a.Budget.
+func (a AnimOptions) OverBudget(out string) (int64, bool) {
+	st, err := os.Stat(out)
+	if err != nil {
+		return 0, false
+	}
+	return st.Size(), a.Budget > 0 && st.Size() > a.Budget
+}
+
+// ParseSize reads a byte count like 10M, 500K, 2MB or 1048576; the
+// suffixes are powers of 1024.
+func ParseSize(s string) (int64, error) {
+	t := strings.ToUpper(strings.TrimSpace(s))
+	t = strings.TrimSuffix(strings.TrimSuffix(t, "IB"), "B")
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(t, "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(t, "M"):
+		mult = 1 << 20
+	case strings.HasSuffix(t, "G"):
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		t = t[:len(t)-1]
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+	if err != nil || n < 0 || math.IsNaN(n) {
+		return 0, fmt.Errorf("bad size %q (want e.g. 10M, 500K or a byte count)", s)
+	}
+	if n*float64(mult) >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %q is too big", s)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+func runFFmpeg(args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("ffmpeg", append([]string{"-y", "-hide_banner", "-loglevel", "error"}, args...)...)
+	cmd.Stderr = &stderr
+	return scerr.Classify("ffmpeg", cmd.Run(), stderr.String())
+}
@@ -0,0 +1,41 @@
+package record
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"1048576", 1 << 20, false},
+		{"0", 0, false},
+		{"500K", 500 << 10, false},
+		{"10M", 10 << 20, false},
+		{"2G", 2 << 30, false},
+		{"10m", 10 << 20, false},
+		{"2MB", 2 << 20, false},
+		{"2mb", 2 << 20, false},
+		{"2MiB", 2 << 20, false},
+		{"100B", 100, false},
+		{"1.5M", 3 << 19, false},
+		{" 10M ", 10 << 20, false},
+		{"10 MB", 10 << 20, false},
+		{"8000000000G", 8000000000 << 30, false},
+		{"9000000000G", 0, true}, // past int64
+		{"1e30", 0, true},
+		{"inf", 0, true},
+		{"NaN", 0, true},
+		{"-1M", 0, true},
+		{"10T", 0, true},
+		{"M", 0, true},
+		{"ten", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSize(%q) = %v, %v; want %v, an error: %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
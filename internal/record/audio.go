@@ -0,0 +1,76 @@
+package record
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// AudioSource is one PulseAudio source and the gain to record it at.
+type AudioSource struct {
+	Name string
+	Gain float64 // linear factor, 0 = unchanged
+}
+
+// ParseAudioSource reads NAME[:GAIN]. GAIN is a factor (0.8) or decibels
+// (-6dB); a suffix that is neither stays part of the name.
+func ParseAudioSource(s string) (AudioSource, error) {
+	src := AudioSource{Name: s}
+	if i := strings.LastIndex(s, ":"); i > 0 {
+		g := strings.TrimSpace(s[i+1:])
+		db := strings.HasSuffix(strings.ToLower(g), "db")
+		if db {
+			g = g[:len(g)-2]
+		}
+		if v, err := strconv.ParseFloat(g, 64); err == nil {
+			if db {
+				v = math.Pow(10, v/20)
+			}
+			if v <= 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+				return AudioSource{}, fmt.Errorf("bad gain in audio source %q", s)
+			}
+			src.Name, src.Gain = s[:i], v
+		}
+	}
+	if src.Name == "" {
+		return AudioSource{}, fmt.Errorf("audio source %q has no name", s)
+	}
+	return src, nil
+}
+
+// String is the NAME[:GAIN] form ParseAudioSource reads.
+func (a AudioSource) String() string {
+	if a.unity() {
+		return a.Name
+	}
+	return a.Name + ":" + a.gain()
+}
+
+func (a AudioSource) unity() bool { return a.Gain == 0 || a.Gain == 1 }
+
+// gain formats the factor for ffmpeg's volume filter and gst's volume element.
+func (a AudioSource) gain() string {
+	if a.Gain == 0 {
+		return "1"
+	}
+	return strconv.FormatFloat(math.Round(a.Gain*1e4)/1e4, 'f', -1, 64)
+}
+
+// Audio track layouts for more than one source.
+const (
+	TracksMix      = "mix"      // amix them into one track
+	TracksSeparate = "separate" // one track per source
+)
+
+// audioSources returns the sources to record, "default" if none were given.
+func (o Options) audioSources() []AudioSource {
+	if len(o.ASrc) == 0 {
+		return []AudioSource{{Name: "default"}}
+	}
+	return o.ASrc
+}
+
+func (o Options) separateTracks() bool {
+	return o.AudioTracks == TracksSeparate && len(o.audioSources()) > 1
+}
@@ -0,0 +1,115 @@
+package record
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseAudioSource(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    AudioSource
+		wantErr bool
+	}{
+		{"default", AudioSource{Name: "default"}, false},
+		{"mic:0.8", AudioSource{Name: "mic", Gain: 0.8}, false},
+		{"desktop:-6dB", AudioSource{Name: "desktop", Gain: 0.501187}, false},
+		{"desktop:+0db", AudioSource{Name: "desktop", Gain: 1}, false},
+		// a suffix that isn't a gain stays in the name
+		{"tcp:media-box", AudioSource{Name: "tcp:media-box"}, false},
+		{"tcp:media-box:1.5", AudioSource{Name: "tcp:media-box", Gain: 1.5}, false},
+		{"mic:0", AudioSource{}, true},
+		{"mic:-2", AudioSource{}, true},
+		{"mic:NaN", AudioSource{}, true},
+		{"mic:Inf", AudioSource{}, true},
+		{"", AudioSource{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAudioSource(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAudioSource(%q) error = %v, want an error: %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got.Name != tt.want.Name || math.Abs(got.Gain-tt.want.Gain) > 1e-6 {
+			t.Errorf("ParseAudioSource(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAudioSourceString(t *testing.T) {
+	tests := []struct {
+		src  AudioSource
+		want string
+	}{
+		{AudioSource{Name: "mic"}, "mic"},
+		{AudioSource{Name: "mic", Gain: 1}, "mic"},
+		{AudioSource{Name: "mic", Gain: 0.8}, "mic:0.8"},
+		{AudioSource{Name: "mic", Gain: 0.5011872336272722}, "mic:0.5012"},
+	}
+	for _, tt := range tests {
+		if got := tt.src.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.src, got, tt.want)
+		}
+		if back, err := ParseAudioSource(tt.src.String()); err != nil || back.Name != tt.src.Name {
+			t.Errorf("%q doesn't parse back: %+v, %v", tt.src.String(), back, err)
+		}
+	}
+}
+
+func TestAudioMap(t *testing.T) {
+	mic, desktop := AudioSource{Name: "mic"}, AudioSource{Name: "desktop"}
+	quiet := AudioSource{Name: "desktop", Gain: 0.5}
+	tests := []struct {
+		name   string
+		srcs   []AudioSource
+		tracks string
+		want   []string
+	}{
+		{"default source", nil, "", nil},
+		{"one source at unity", []AudioSource{mic}, TracksSeparate, nil},
+		{
+			"one source with gain",
+			[]AudioSource{quiet},
+			"",
+			[]string{"-filter_complex", "[1:a]volume=0.5[a0]", "-map", "0:v", "-map", "[a0]"},
+		},
+		{
+			"mixed",
+			[]AudioSource{mic, desktop},
+			TracksMix,
+			[]string{"-filter_complex", "[1:a][2:a]amix=inputs=2:duration=longest[aout]", "-map", "0:v", "-map", "[aout]"},
+		},
+		{
+			"mixed with gain",
+			[]AudioSource{mic, quiet},
+			"",
+			[]string{"-filter_complex", "[2:a]volume=0.5[a1];[1:a][a1]amix=inputs=2:duration=longest[aout]", "-map", "0:v", "-map", "[aout]"},
+		},
+		{
+			"separate",
+			[]AudioSource{mic, desktop},
+			TracksSeparate,
+			[]string{"-map", "0:v", "-map", "1:a", "-map", "2:a", "-metadata:s:a:0", "title=mic", "-metadata:s:a:1", "title=desktop"},
+		},
+		{
+			"separate with gain",
+			[]AudioSource{quiet, mic},
+			TracksSeparate,
+			[]string{
+				"-filter_complex", "[1:a]volume=0.5[a0]",
+				"-map", "0:v", "-map", "[a0]", "-map", "2:a",
+				"-metadata:s:a:0", "title=desktop", "-metadata:s:a:1", "title=mic",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := DefaultOptions()
+			o.Audio, o.ASrc, o.AudioTracks = true, tt.srcs, tt.tracks
+			if got := audioMap(o); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+/*
+	control socket for a running `swiftcap record`. the protocol is one JSON
+	object per line over a unix socket: the client sends a ControlRequest,
+	the recorder answers with a ControlReply. a connection may carry any
+	number of requests.
+*/
+
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// Control commands.
+const (
+	CtlStatus = "status"
+	CtlPause  = "pause"
+	CtlResume = "resume"
+	CtlStop   = "stop"
+	CtlMarker = "marker"
+
+	CtlSaveReplay = "save-replay" // replay buffer only
+)
+
+// ControlRequest is one command sent to the recorder.
+type ControlRequest struct {
+	Cmd   string `json:"cmd"`
+	Label string `json:"label,omitempty"` // marker
+	Out   string `json:"out,omitempty"`   // save-replay; empty picks a name from --out
+}
+
+// ControlReply is the recorder's answer. State and the counters are filled
+// in for every command, so each reply doubles as a status.
+type ControlReply struct {
+	OK       bool    `json:"ok"`
+	Error    string  `json:"error,omitempty"`
+	State    string  `json:"state,omitempty"` // recording|paused|buffering|stopping
+	Out      string  `json:"out,omitempty"`   // the replay just saved, for save-replay
+	Elapsed  float64 `json:"elapsed"`         // seconds recorded, pauses excluded
+	Segments int     `json:"segments"`
+	Markers  int     `json:"markers"`
+	Marker   *Marker `json:"marker,omitempty"` // the one just added
+}
+
+// Marker is a labelled point in the recording.
+type Marker struct {
+	At    float64 `json:"at"` // seconds into the output
+	Label string  `json:"label,omitempty"`
+}
+
+// ControlServer accepts control connections on a unix socket.
+type ControlServer struct {
+	ln   net.Listener
+	path string
+}
+
+// ServeControl listens on path and answers every request with handle,
+// which may be called from several goroutines at once. a stale socket left
+// by a crashed recorder is replaced; a live one, or anything that isn't a
+// socket, is an error.
+func ServeControl(path string, handle func(ControlRequest) ControlReply) (*ControlServer, error) {
+	if c, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		c.Close()
+		return nil, fmt.Errorf("control socket %s is in use by another recording", path)
+	}
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	os.Chmod(path, 0o600)
+	s := &ControlServer{ln: ln, path: path}
+	go s.accept(handle)
+	return s, nil
+}
+
+func (s *ControlServer) accept(handle func(ControlRequest) ControlReply) {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return // closed
+		}
+		go func() {
+			defer conn.Close()
+			dec := json.NewDecoder(bufio.NewReader(conn))
+			enc := json.NewEncoder(conn)
+			for {
+				var req ControlRequest
+				if err := dec.Decode(&req); err != nil {
+					var syn *json.SyntaxError
+					if errors.As(err, &syn) {
+						enc.Encode(ControlReply{Error: "malformed request: " + err.Error()})
+					}
+					return
+				}
+				if err := enc.Encode(handle(req)); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// Close stops listening and removes the socket.
+func (s *ControlServer) Close() error {
+	err := s.ln.Close()
+	os.Remove(s.path)
+	return err
+}
+
+// SendControl sends one request to the recorder listening on path.
+func SendControl(path string, req ControlRequest) (ControlReply, error) {
+	conn, err := net.DialTimeout("unix", path, 2*time.Second)
+	if err != nil {
+		return ControlReply{}, err
+	}
+	defer conn.Close()
+	// pause waits for ffmpeg to finish its segment, which can take a moment
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return ControlReply{}, err
+	}
+	var reply ControlReply
+	if err := json.NewDecoder(conn).Decode(&reply); err != nil {
+		return ControlReply{}, fmt.Errorf("no reply from recorder: %w", err)
+	}
+	return reply, nil
+}
@@ -0,0 +1,157 @@
+package record
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recorder answers control requests the way `swiftcap record` does, over
+// a state of its own.
+type recorder struct {
+	mu       sync.Mutex
+	state    string
+	segments int
+}
+
+func (r *recorder) handle(req ControlRequest) ControlReply {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	refuse := func(format string, args ...any) ControlReply {
+		return ControlReply{State: r.state, Segments: r.segments, Error: fmt.Sprintf(format, args...)}
+	}
+	switch req.Cmd {
+	case CtlStatus:
+	case CtlPause:
+		if r.state != "recording" {
+			return refuse("not recording")
+		}
+		r.state = "paused"
+	case CtlResume:
+		if r.state != "paused" {
+			return refuse("not paused")
+		}
+		r.state = "recording"
+		r.segments++
+	case CtlStop:
+		r.state = "stopping"
+	default:
+		return refuse("unknown command %q", req.Cmd)
+	}
+	return ControlReply{OK: true, State: r.state, Segments: r.segments}
+}
+
+func TestControl(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ctl.sock")
+	r := &recorder{state: "recording", segments: 1}
+	srv, err := ServeControl(path, r.handle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("socket mode %v, %v; want 0600", fi.Mode(), err)
+	}
+
+	tests := []struct {
+		cmd      string
+		ok       bool
+		state    string
+		segments int
+		err      string
+	}{
+		{CtlStatus, true, "recording", 1, ""},
+		{CtlResume, false, "recording", 1, "not paused"},
+		{CtlPause, true, "paused", 1, ""},
+		{CtlPause, false, "paused", 1, "not recording"},
+		{CtlStatus, true, "paused", 1, ""},
+		{CtlResume, true, "recording", 2, ""},
+		{"rewind", false, "recording", 2, `unknown command "rewind"`},
+		{CtlStop, true, "stopping", 2, ""},
+	}
+	for _, tt := range tests {
+		reply, err := SendControl(path, ControlRequest{Cmd: tt.cmd})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.cmd, err)
+		}
+		want := ControlReply{OK: tt.ok, State: tt.state, Segments: tt.segments, Error: tt.err}
+		if reply.OK != want.OK || reply.State != want.State || reply.Segments != want.Segments || reply.Error != want.Error {
+			t.Errorf("%s = %+v, want %+v", tt.cmd, reply, want)
+		}
+	}
+
+	if _, err := ServeControl(path, r.handle); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("a second recorder on a live socket: %v", err)
+	}
+	srv.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Close left the socket behind: %v", err)
+	}
+	if _, err := SendControl(path, ControlRequest{Cmd: CtlStatus}); err == nil {
+		t.Error("SendControl reached a closed recorder")
+	}
+}
+
+func TestControlConnection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ctl.sock")
+	srv, err := ServeControl(path, (&recorder{state: "recording"}).handle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// several requests on one connection, then one that isn't JSON
+	fmt.Fprint(conn, `{"cmd":"pause"}`+"\n"+`{"cmd":"status"}`+"\n"+"pause\n")
+	lines := bufio.NewScanner(conn)
+	for _, want := range []string{`"ok":true,"state":"paused"`, `"ok":true,"state":"paused"`, `"error":"malformed request`} {
+		if !lines.Scan() {
+			t.Fatalf("no reply, want %s: %v", want, lines.Err())
+		}
+		if !strings.Contains(lines.Text(), want) {
+			t.Errorf("reply %s, want %s", lines.Text(), want)
+		}
+	}
+	if lines.Scan() {
+		t.Errorf("the connection stayed open after a malformed request: %s", lines.Text())
+	}
+}
+
+func TestServeControlPath(t *testing.T) {
+	dir := t.TempDir()
+	handle := (&recorder{state: "recording"}).handle
+
+	// a crashed recorder's socket is taken over
+	stale := filepath.Join(dir, "stale.sock")
+	ln, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	srv, err := ServeControl(stale, handle)
+	if err != nil {
+		t.Fatalf("stale socket: %v", err)
+	}
+	srv.Close()
+
+	// anything else is left alone
+	file := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(file, []byte("keep me"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ServeControl(file, handle); err == nil || !strings.Contains(err.Error(), "not a socket") {
+		t.Errorf("ServeControl on a file = %v", err)
+	}
+	if b, _ := os.ReadFile(file); string(b) != "keep me" {
+		t.Error("the file was replaced")
+	}
+}
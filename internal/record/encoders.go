@@ -0,0 +1,200 @@
+package record
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Encoder is one video codec backend. Args returns the ffmpeg output
+// arguments for the codec; it can assume Options passed Validate.
+type Encoder struct {
+	Name          string // what --codec takes
+	FFmpeg        string // ffmpeg encoder name
+	Description   string
+	Containers    []string
+	Presets       []string
+	DefaultPreset string
+	MaxCRF        int // 0 = codec has no CRF mode
+	DefaultCRF    int // a visually good CRF, for re-encoding finished files
+	MaxQP         int // 0 = codec has no constant quantizer mode
+	Args          func(o Options, preset string) []string
+}
+
+// AudioCodec is one audio codec backend.
+type AudioCodec struct {
+	Name       string
+	FFmpeg     string
+	Containers []string
+	Lossless   bool // ignores the bitrate
+}
+
+var x264Presets = []string{"ultrafast", "superfast", "veryfast", "faster", "fast", "medium", "slow", "slower", "veryslow", "placebo"}
+
+var encoders = map[string]*Encoder{
+	"x264": {
+		Name:          "x264",
+		FFmpeg:        "libx264",
+		Description:   "H.264, plays everywhere",
+		Containers:    []string{"mp4", "mkv", "mov", "avi"},
+		Presets:       x264Presets,
+		DefaultPreset: "veryfast",
+		MaxCRF:        51,
+		DefaultCRF:    23,
+		MaxQP:         69,
+		Args: func(o Options, preset string) []string {
+			args := []string{"-c:v", "libx264", "-preset", preset, "-tune", "zerolatency", "-profile:v", "baseline"}
+			args = append(args, rateArgs(o)...)
+			if o.CRF < 0 && o.QP <= 0 && o.Bitrate > 0 {
+				args = append(args, "-rc-lookahead", "0")
+			}
+			return append(args, "-pix_fmt", "yuv420p")
+		},
+	},
+	"x265": {
+		Name:          "x265",
+		FFmpeg:        "libx265",
+		Description:   "HEVC, smaller files than H.264 at the same quality",
+		Containers:    []string{"mp4", "mkv", "mov"},
+		Presets:       x264Presets,
+		DefaultPreset: "veryfast",
+		MaxCRF:        51,
+		DefaultCRF:    28,
+		MaxQP:         51,
+		Args: func(o Options, preset string) []string {
+			args := []string{"-c:v", "libx265", "-preset", preset, "-tune", "zerolatency", "-x265-params", "log-level=error"}
+			args = append(args, rateArgs(o)...)
+			if o.Container == "mp4" || o.Container == "mov" {
+				// QuickTime and Safari only play hvc1-tagged HEVC
+				args = append(args, "-tag:v", "hvc1")
+			}
+			return append(args, "-pix_fmt", "yuv420p")
+		},
+	},
+	"vp9": {
+		Name:          "vp9",
+		FFmpeg:        "libvpx-vp9",
+		Description:   "VP9, royalty-free, for the web",
+		Containers:    []string{"webm", "mkv"},
+		Presets:       []string{"realtime", "good", "best"},
+		DefaultPreset: "realtime",
+		MaxCRF:        63,
+		DefaultCRF:    33,
+		Args: func(o Options, preset string) []string {
+			args := []string{"-c:v", "libvpx-vp9", "-deadline", preset, "-row-mt", "1"}
+			if preset == "realtime" {
+				args = append(args, "-cpu-used", "8")
+			}
+			if o.CRF >= 0 {
+				// constant quality mode needs -b:v 0 or it becomes constrained quality
+				args = append(args, "-crf", fmt.Sprintf("%d", o.CRF), "-b:v", "0")
+			} else {
+				args = append(args, rateArgs(o)...)
+			}
+			return append(args, "-pix_fmt", "yuv420p")
+		},
+	},
+	"av1": {
+		Name:          "av1",
+		FFmpeg:        "libsvtav1",
+		Description:   "AV1 via SVT-AV1, best compression that still keeps up in real time",
+		Containers:    []string{"mp4", "mkv", "webm"},
+		Presets:       []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13"},
+		DefaultPreset: "10",
+		MaxCRF:        63,
+		DefaultCRF:    35,
+		MaxQP:         63,
+		Args: func(o Options, preset string) []string {
+			args := []string{"-c:v", "libsvtav1", "-preset", preset}
+			args = append(args, rateArgs(o)...)
+			return append(args, "-pix_fmt", "yuv420p")
+		},
+	},
+	"av1-aom": {
+		Name:          "av1-aom",
+		FFmpeg:        "libaom-av1",
+		Description:   "AV1 via libaom, slow but available everywhere",
+		Containers:    []string{"mp4", "mkv", "webm"},
+		Presets:       []string{"0", "1", "2", "3", "4", "5", "6", "7", "8"},
+		DefaultPreset: "8",
+		MaxCRF:        63,
+		DefaultCRF:    33,
+		Args: func(o Options, preset string) []string {
+			args := []string{"-c:v", "libaom-av1", "-usage", "realtime", "-cpu-used", preset, "-row-mt", "1"}
+			if o.CRF >= 0 {
+				args = append(args, "-crf", fmt.Sprintf("%d", o.CRF), "-b:v", "0")
+			} else {
+				args = append(args, rateArgs(o)...)
+			}
+			return append(args, "-pix_fmt", "yuv420p")
+		},
+	},
+	"ffv1": {
+		Name:        "ffv1",
+		FFmpeg:      "ffv1",
+		Description: "FFV1, lossless archival; big files",
+		Containers:  []string{"mkv", "avi"},
+		Args: func(o Options, preset string) []string {
+			// x11grab hands out bgr0, which ffv1 stores as-is; no chroma loss
+			return []string{"-c:v", "ffv1", "-level", "3", "-g", "1", "-slices", "4", "-slicecrc", "1", "-pix_fmt", "bgr0"}
+		},
+	},
+}
+
+var audioCodecs = map[string]*AudioCodec{
+	"aac":    {Name: "aac", FFmpeg: "aac", Containers: []string{"mp4", "mkv", "mov", "avi"}},
+	"opus":   {Name: "opus", FFmpeg: "libopus", Containers: []string{"webm", "mkv", "mp4"}},
+	"vorbis": {Name: "vorbis", FFmpeg: "libvorbis", Containers: []string{"webm", "mkv"}},
+	"mp3":    {Name: "mp3", FFmpeg: "libmp3lame", Containers: []string{"mp4", "mkv", "mov", "avi"}},
+	"flac":   {Name: "flac", FFmpeg: "flac", Containers: []string{"mkv", "mp4"}, Lossless: true},
+	"pcm":    {Name: "pcm", FFmpeg: "pcm_s16le", Containers: []string{"mkv", "mov", "avi"}, Lossless: true},
+}
+
+// rateArgs picks the rate control: QP beats CRF beats bitrate. Validate
+// keeps QP away from encoders without a -qp.
+func rateArgs(o Options) []string {
+	switch {
+	case o.QP > 0:
+		return []string{"-qp", fmt.Sprintf("%d", o.QP)}
+	case o.CRF >= 0:
+		return []string{"-crf", fmt.Sprintf("%d", o.CRF)}
+	case o.Bitrate > 0:
+		return []string{
+			"-b:v", fmt.Sprintf("%dk", o.Bitrate),
+			"-maxrate", fmt.Sprintf("%dk", o.Bitrate),
+			"-bufsize", fmt.Sprintf("%dk", o.Bitrate*2),
+		}
+	}
+	return nil
+}
+
+// LookupEncoder returns the registered video encoder called name.
+func LookupEncoder(name string) (*Encoder, bool) {
+	e, ok := encoders[name]
+	return e, ok
+}
+
+// EncoderNames lists the registered video encoders, sorted.
+func EncoderNames() []string {
+	names := make([]string, 0, len(encoders))
+	for n := range encoders {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupAudioCodec returns the registered audio codec called name.
+func LookupAudioCodec(name string) (*AudioCodec, bool) {
+	a, ok := audioCodecs[name]
+	return a, ok
+}
+
+// AudioCodecNames lists the registered audio codecs, sorted.
+func AudioCodecNames() []string {
+	names := make([]string, 0, len(audioCodecs))
+	for n := range audioCodecs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
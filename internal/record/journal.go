@@ -0,0 +1,219 @@
+/*
+	crash journal for segmented recordings. while a recording is running its
+	segment list lives in $XDG_STATE_HOME/swiftcap/sessions/<id>.json; a
+	journal whose process is gone belongs to a recording that never got
+	joined, and can be recovered (remux + concat) or discarded.
+*/
+
+package record
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Journal is the on-disk record of one segmented recording.
+type Journal struct {
+	ID        string    `json:"id"`
+	Pid       int       `json:"pid"`
+	Started   time.Time `json:"started"`
+	Prefix    string    `json:"prefix"`
+	Container string    `json:"container"`
+	Out       string    `json:"out,omitempty"` // where it was meant to end up, if known
+	Segments  []string  `json:"segments"`
+
+	Anim *AnimOptions `json:"anim,omitempty"` // gif, webp and apng only
+}
+
+// JournalDir is $XDG_STATE_HOME/swiftcap/sessions, ~/.local/state by default.
+func JournalDir() (string, error) {
+	state := os.Getenv("XDG_STATE_HOME")
+	if state == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		state = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(state, "swiftcap", "sessions"), nil
+}
+
+func (j *Journal) path() (string, error) {
+	dir, err := JournalDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, j.ID+".json"), nil
+}
+
+// save writes the journal atomically, so a crash mid-write leaves the old one.
+func (j *Journal) save() error {
+	p, err := j.path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+func (j *Journal) remove() {
+	if p, err := j.path(); err == nil {
+		os.Remove(p)
+	}
+}
+
+// listPath is where Join writes the concat list, so a crash while joining
+// leaves nothing that recovery can't find.
+func (j *Journal) listPath() string { return j.Prefix + "_concat.txt" }
+
+// Size is the bytes the journal's segments take on disk.
+func (j *Journal) Size() int64 {
+	var n int64
+	for _, seg := range j.Segments {
+		if st, err := os.Stat(seg); err == nil {
+			n += st.Size()
+		}
+	}
+	return n
+}
+
+// Orphans returns the journals of recordings whose process is gone, oldest
+// first.
+func Orphans() ([]*Journal, error) {
+	dir, err := JournalDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []*Journal
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		j := new(Journal)
+		if json.Unmarshal(b, j) != nil || j.ID != strings.TrimSuffix(e.Name(), ".json") {
+			continue
+		}
+		if j.Pid == os.Getpid() || alive(j.Pid) {
+			continue
+		}
+		out = append(out, j)
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].Started.Before(out[b].Started) })
+	return out, nil
+}
+
+// FindOrphan returns the orphaned journal with id.
+func FindOrphan(id string) (*Journal, error) {
+	orphans, err := Orphans()
+	if err != nil {
+		return nil, err
+	}
+	for _, j := range orphans {
+		if j.ID == id {
+			return j, nil
+		}
+	}
+	return nil, fmt.Errorf("no unfinished recording %q", id)
+}
+
+func alive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// DefaultOut is where Recover puts the recording when it isn't told: the
+// original --out if the journal has one, else a recording_<start> file next
+// to the segments.
+func (j *Journal) DefaultOut() string {
+	if j.Out != "" {
+		return j.Out
+	}
+	return filepath.Join(filepath.Dir(j.Prefix), fmt.Sprintf("recording_%s.%s", j.Started.Format("20060102_150405"), j.Container))
+}
+
+// Recover joins whatever segments survived into out ("" for DefaultOut)
+// and drops the journal. an existing out is never overwritten; the name gets
+// a -recovered suffix instead.
+func (j *Journal) Recover(out string) (string, error) {
+	if out == "" {
+		out = j.DefaultOut()
+	}
+	if _, err := os.Stat(out); err == nil {
+		ext := filepath.Ext(out)
+		out = strings.TrimSuffix(out, ext) + "-recovered" + ext
+	}
+	var files []string
+	for _, seg := range j.Segments {
+		// the segment being written when it died may never have got a byte
+		if st, err := os.Stat(seg); err == nil && st.Size() > 0 {
+			files = append(files, seg)
+		}
+	}
+	if len(files) == 0 {
+		j.Discard()
+		return "", errors.New("none of its segments survived")
+	}
+	anim := DefaultAnimOptions()
+	if j.Anim != nil {
+		anim = *j.Anim
+	}
+	if err := join(files, out, j.Container, anim, j.Prefix); err != nil {
+		return "", err
+	}
+	j.Discard()
+	return out, nil
+}
+
+// Discard deletes the journal's segments, its concat list and the journal.
+func (j *Journal) Discard() {
+	for _, seg := range j.Segments {
+		os.Remove(seg)
+	}
+	os.Remove(j.listPath())
+	j.remove()
+}
+
+// newJournal starts a journal for segments named from prefix.
+func newJournal(prefix, container, out string) *Journal {
+	now := time.Now()
+	return &Journal{
+		ID:        strconv.FormatInt(now.UnixNano(), 36),
+		Pid:       os.Getpid(),
+		Started:   now,
+		Prefix:    prefix,
+		Container: container,
+		Out:       out,
+	}
+}
@@ -0,0 +1,232 @@
+package record
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// deadPid is the pid of a process that has exited.
+func deadPid(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skip("can't run true:", err)
+	}
+	return cmd.Process.Pid
+}
+
+// crash makes j look like the journal of a recorder that died.
+func crash(t *testing.T, j *Journal) {
+	t.Helper()
+	j.Pid = deadPid(t)
+	if err := j.save(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJournal(t *testing.T) {
+	state := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", state)
+	dir := t.TempDir()
+
+	s := NewSegments(filepath.Join(dir, "rec.part"), "mkv")
+	if err := s.Journaled(filepath.Join(dir, "rec.mkv")); err != nil {
+		t.Fatal(err)
+	}
+	first, second := s.Next(), s.Next()
+	if want := filepath.Join(dir, "rec.part_segment_2.mkv"); second != want {
+		t.Errorf("second segment %s, want %s", second, want)
+	}
+
+	// the journal is on disk before a byte of the segment is
+	entries, _ := os.ReadDir(filepath.Join(state, "swiftcap", "sessions"))
+	if len(entries) != 1 {
+		t.Fatalf("journal directory holds %v", entries)
+	}
+	b, err := os.ReadFile(filepath.Join(state, "swiftcap", "sessions", entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var j Journal
+	if err := json.Unmarshal(b, &j); err != nil {
+		t.Fatal(err)
+	}
+	if j.ID+".json" != entries[0].Name() || j.Pid != os.Getpid() || j.Container != "mkv" ||
+		j.Out != filepath.Join(dir, "rec.mkv") || strings.Join(j.Segments, " ") != first+" "+second {
+		t.Errorf("journal %+v", j)
+	}
+
+	// a running recording isn't an orphan
+	if orphans, err := Orphans(); err != nil || len(orphans) != 0 {
+		t.Errorf("Orphans = %v, %v while recording", orphans, err)
+	}
+	crash(t, &j)
+	orphans, err := Orphans()
+	if err != nil || len(orphans) != 1 || orphans[0].ID != j.ID {
+		t.Fatalf("Orphans = %v, %v after the crash", orphans, err)
+	}
+	if found, err := FindOrphan(j.ID); err != nil || found.ID != j.ID {
+		t.Errorf("FindOrphan = %v, %v", found, err)
+	}
+	if _, err := FindOrphan("nope"); err == nil {
+		t.Error("FindOrphan found a recording that isn't there")
+	}
+
+	// a Join drops the journal
+	s2 := NewSegments(filepath.Join(dir, "other.part"), "mkv")
+	if err := s2.Journaled(""); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(s2.Next(), []byte("frames"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s2.Join(filepath.Join(dir, "other.mkv")); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(state, "swiftcap", "sessions")); len(entries) != 1 {
+		t.Errorf("Join left its journal: %v", entries)
+	}
+}
+
+func TestOrphans(t *testing.T) {
+	state := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", state)
+	if orphans, err := Orphans(); err != nil || orphans != nil {
+		t.Errorf("Orphans without a journal directory = %v, %v", orphans, err)
+	}
+
+	start := time.Date(2026, 3, 7, 9, 0, 0, 0, time.UTC)
+	for i, id := range []string{"newer", "older"} {
+		j := &Journal{ID: id, Started: start.Add(-time.Duration(i) * time.Hour), Container: "mp4"}
+		crash(t, j)
+	}
+	live := &Journal{ID: "live", Pid: os.Getpid(), Started: start}
+	if err := live.save(); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(state, "swiftcap", "sessions")
+	junk := map[string]string{
+		"broken.json":      "{",
+		"renamed.json":     `{"id":"elsewhere","pid":0}`,
+		"notes.txt":        `{"id":"notes","pid":0}`,
+		"partial.json.tmp": `{"id":"partial","pid":0}`,
+	}
+	for name, body := range junk {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	orphans, err := Orphans()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, j := range orphans {
+		ids = append(ids, j.ID)
+	}
+	if strings.Join(ids, " ") != "older newer" {
+		t.Errorf("Orphans = %v, want older newer", ids)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	started := time.Date(2026, 3, 7, 9, 5, 30, 0, time.Local)
+
+	// orphan writes a crashed recording: seg holds each segment's bytes,
+	// "" for one that never got any
+	orphan := func(id, out string, seg ...string) *Journal {
+		j := &Journal{ID: id, Started: started, Prefix: filepath.Join(dir, id+".part"), Container: "mkv", Out: out}
+		for i, data := range seg {
+			p := filepath.Join(dir, id+".part_segment_"+string(rune('1'+i))+".mkv")
+			if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			j.Segments = append(j.Segments, p)
+		}
+		crash(t, j)
+		return j
+	}
+
+	j := orphan("a", "", "frames", "")
+	want := filepath.Join(dir, "recording_20260307_090530.mkv")
+	if j.DefaultOut() != want {
+		t.Errorf("DefaultOut = %s, want %s", j.DefaultOut(), want)
+	}
+	if n := j.Size(); n != 6 {
+		t.Errorf("Size = %d, want 6", n)
+	}
+	out, err := j.Recover("")
+	if err != nil || out != want {
+		t.Fatalf("Recover = %s, %v; want %s", out, err, want)
+	}
+	if b, _ := os.ReadFile(out); string(b) != "frames" {
+		t.Errorf("recovered %q", b)
+	}
+	for _, seg := range j.Segments {
+		if _, err := os.Stat(seg); !os.IsNotExist(err) {
+			t.Errorf("%s is left over: %v", seg, err)
+		}
+	}
+
+	// never over an existing file
+	taken := filepath.Join(dir, "taken.mkv")
+	if err := os.WriteFile(taken, []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err = orphan("b", taken, "frames").Recover("")
+	if want := filepath.Join(dir, "taken-recovered.mkv"); err != nil || out != want {
+		t.Errorf("Recover over a file = %s, %v; want %s", out, err, want)
+	}
+	if b, _ := os.ReadFile(taken); string(b) != "keep" {
+		t.Error("Recover overwrote a file")
+	}
+
+	// nothing survived
+	if _, err := orphan("c", "", "").Recover(""); err == nil {
+		t.Error("recovered a recording without frames")
+	}
+
+	if orphans, err := Orphans(); err != nil || len(orphans) != 0 {
+		t.Errorf("Orphans = %v, %v after recovering them all", orphans, err)
+	}
+}
+
+func TestRecoverJoins(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg is not installed")
+	}
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	s := NewSegments(filepath.Join(dir, "rec.part"), "mkv")
+	if err := s.Journaled(filepath.Join(dir, "rec.mkv")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		seg := s.Next()
+		gen := exec.Command("ffmpeg", "-v", "error", "-f", "lavfi", "-i", "testsrc=size=64x48:rate=10:duration=1",
+			"-c:v", "ffv1", seg)
+		if out, err := gen.CombinedOutput(); err != nil {
+			t.Fatalf("ffmpeg: %v\n%s", err, out)
+		}
+	}
+	orphans, err := Orphans()
+	if err != nil || len(orphans) != 1 {
+		t.Fatalf("Orphans = %v, %v", orphans, err)
+	}
+	crash(t, orphans[0])
+	out, err := orphans[0].Recover("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(out); err != nil || fi.Size() == 0 {
+		t.Errorf("nothing joined into %s: %v", out, err)
+	}
+}
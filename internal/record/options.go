@@ -0,0 +1,164 @@
+package record
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Options describes one recording: what to grab and how to encode it. The
+// CLI fills it from flags, the UI from its RecordingConfig.
+type Options struct {
+	Display string // X11 display, e.g. ":0"
+	Region  string // WxH+X+Y, empty for whatever the input defaults to
+	Fps     int
+	Cursor  bool
+	Out     string
+	MaxDur  int // seconds, 0 = unlimited
+	Threads int // 0 = let the encoder decide
+	Segment int // seconds; > 0 writes Out as a numbered pattern of segments this long
+	Nice    int // niceness of the capture process, 0 = the recorder's own
+
+	Container string      // mp4, mkv, mov, avi, webm, or gif, webp, apng
+	Anim      AnimOptions // how gif, webp and apng are exported
+
+	Codec   string // key into the encoder registry, e.g. "x264"
+	Preset  string // encoder-specific speed/quality preset, empty for its default
+	CRF     int    // constant rate factor, -1 = unset
+	QP      int    // constant quantizer, 0 = unset
+	Bitrate int    // kbit/s, used when neither CRF nor QP is set
+
+	Audio        bool
+	ASrc         []AudioSource // PulseAudio sources, empty for "default"
+	AudioTracks  string        // TracksMix (default) or TracksSeparate
+	AudioCodec   string        // empty picks the container's default
+	AudioBitrate int           // kbit/s, 0 = 128
+}
+
+// DefaultOptions returns the settings FFmpegCmd used before it took Options:
+// x264 in mp4 with AAC audio.
+func DefaultOptions() Options {
+	return Options{
+		Container: "mp4",
+		Codec:     "x264",
+		CRF:       -1,
+		Anim:      DefaultAnimOptions(),
+	}
+}
+
+// containers maps our container names to ffmpeg muxers.
+var containers = map[string]string{
+	"mp4":  "mp4",
+	"mkv":  "matroska",
+	"mov":  "mov",
+	"avi":  "avi",
+	"webm": "webm",
+	"gif":  "gif",
+	"webp": "webp",
+	"apng": "apng",
+}
+
+// multiTrack are the containers editors read more than one audio track from.
+var multiTrack = []string{"mp4", "mkv", "mov"}
+
+// Containers lists the supported container names.
+func Containers() []string {
+	return []string{"mp4", "mkv", "mov", "avi", "webm", "gif", "webp", "apng"}
+}
+
+// Muxer returns the ffmpeg -f value for container, defaulting to mp4.
+func Muxer(container string) string {
+	if m, ok := containers[container]; ok {
+		return m
+	}
+	return "mp4"
+}
+
+// Fragmented reports whether recordings in container are written as
+// fragmented mp4/mov while capturing. Those always want a Join, which
+// remuxes them into a regular faststart file.
+func Fragmented(container string) bool {
+	m := Muxer(container)
+	return m == "mp4" || m == "mov"
+}
+
+// audioCodec returns the audio codec to use, resolving the default.
+func (o Options) audioCodec() string {
+	if o.AudioCodec != "" {
+		return o.AudioCodec
+	}
+	if o.Container == "webm" {
+		return "opus"
+	}
+	return "aac"
+}
+
+// Validate checks that the codec, preset, CRF, QP and container go together.
+func (o Options) Validate() error {
+	if _, ok := containers[o.Container]; !ok {
+		return fmt.Errorf("unknown container %q (want %s)", o.Container, strings.Join(Containers(), "|"))
+	}
+	if IsAnimated(o.Container) {
+		// captured with CaptureOptions; codec and audio don't apply
+		return o.Anim.Validate()
+	}
+	enc, ok := LookupEncoder(o.Codec)
+	if !ok {
+		return fmt.Errorf("unknown codec %q (want %s)", o.Codec, strings.Join(EncoderNames(), "|"))
+	}
+	if !contains(enc.Containers, o.Container) {
+		return fmt.Errorf("codec %s cannot be stored in %s (use %s)", o.Codec, o.Container, strings.Join(enc.Containers, "|"))
+	}
+	if o.Preset != "" && !contains(enc.Presets, o.Preset) {
+		if len(enc.Presets) == 0 {
+			return fmt.Errorf("codec %s has no presets", o.Codec)
+		}
+		return fmt.Errorf("unknown preset %q for %s (want %s)", o.Preset, o.Codec, strings.Join(enc.Presets, "|"))
+	}
+	if o.CRF >= 0 {
+		if enc.MaxCRF == 0 {
+			return fmt.Errorf("codec %s does not take a CRF", o.Codec)
+		}
+		if o.CRF > enc.MaxCRF {
+			return fmt.Errorf("crf %d out of range for %s (0-%d)", o.CRF, o.Codec, enc.MaxCRF)
+		}
+	}
+	if o.QP > 0 {
+		if enc.MaxQP == 0 {
+			if enc.MaxCRF > 0 {
+				return fmt.Errorf("codec %s does not take a QP (use --crf)", o.Codec)
+			}
+			return fmt.Errorf("codec %s does not take a QP", o.Codec)
+		}
+		if o.QP > enc.MaxQP {
+			return fmt.Errorf("qp %d out of range for %s (1-%d)", o.QP, o.Codec, enc.MaxQP)
+		}
+	}
+	if o.Audio {
+		ac, ok := LookupAudioCodec(o.audioCodec())
+		if !ok {
+			return fmt.Errorf("unknown audio codec %q (want %s)", o.AudioCodec, strings.Join(AudioCodecNames(), "|"))
+		}
+		if !contains(ac.Containers, o.Container) {
+			return fmt.Errorf("audio codec %s cannot be stored in %s (use %s)", ac.Name, o.Container, strings.Join(ac.Containers, "|"))
+		}
+		switch o.AudioTracks {
+		case "", TracksMix:
+		case TracksSeparate:
+			if o.separateTracks() && !contains(multiTrack, o.Container) {
+				return fmt.Errorf("separate audio tracks need %s, not %s", strings.Join(multiTrack, "|"), o.Container)
+			}
+		default:
+			return fmt.Errorf("unknown audio tracks %q (want %s|%s)", o.AudioTracks, TracksMix, TracksSeparate)
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
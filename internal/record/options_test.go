@@ -0,0 +1,68 @@
+package record
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		set     func(o *Options)
+		wantErr string // "" for valid
+	}{
+		{"defaults", func(o *Options) {}, ""},
+		{"unknown container", func(o *Options) { o.Container = "flv" }, `unknown container "flv"`},
+		{"unknown codec", func(o *Options) { o.Codec = "h266" }, `unknown codec "h266"`},
+		{"codec the container can't hold", func(o *Options) { o.Codec = "vp9" }, "codec vp9 cannot be stored in mp4"},
+		{"vp9 in webm", func(o *Options) { o.Codec, o.Container = "vp9", "webm" }, ""},
+		{"preset", func(o *Options) { o.Preset = "slow" }, ""},
+		{"unknown preset", func(o *Options) { o.Preset = "turbo" }, `unknown preset "turbo" for x264`},
+		{"preset for a codec without any", func(o *Options) { o.Codec, o.Container, o.Preset = "ffv1", "mkv", "fast" }, "codec ffv1 has no presets"},
+		{"crf at the limit", func(o *Options) { o.CRF = 51 }, ""},
+		{"crf past the limit", func(o *Options) { o.CRF = 52 }, "crf 52 out of range for x264 (0-51)"},
+		{"vp9 crf past x264's limit", func(o *Options) { o.Codec, o.Container, o.CRF = "vp9", "webm", 60 }, ""},
+		{"crf for ffv1", func(o *Options) { o.Codec, o.Container, o.CRF = "ffv1", "mkv", 10 }, "codec ffv1 does not take a CRF"},
+		{"qp", func(o *Options) { o.QP = 20 }, ""},
+		{"qp past the limit", func(o *Options) { o.Codec, o.QP = "x265", 52 }, "qp 52 out of range for x265 (1-51)"},
+		{"qp for vp9", func(o *Options) { o.Codec, o.Container, o.QP = "vp9", "webm", 30 }, "codec vp9 does not take a QP (use --crf)"},
+		{"qp for libaom", func(o *Options) { o.Codec, o.QP = "av1-aom", 30 }, "codec av1-aom does not take a QP (use --crf)"},
+		{"qp for ffv1", func(o *Options) { o.Codec, o.Container, o.QP = "ffv1", "mkv", 10 }, "codec ffv1 does not take a QP"},
+		{"audio in webm defaults to opus", func(o *Options) { o.Codec, o.Container, o.Audio = "vp9", "webm", true }, ""},
+		{"aac in webm", func(o *Options) { o.Codec, o.Container, o.Audio, o.AudioCodec = "vp9", "webm", true, "aac" }, "audio codec aac cannot be stored in webm"},
+		{"unknown audio codec", func(o *Options) { o.Audio, o.AudioCodec = true, "wma" }, `unknown audio codec "wma"`},
+		{"audio codec without audio", func(o *Options) { o.AudioCodec = "wma" }, ""},
+		{
+			"separate tracks",
+			func(o *Options) {
+				o.Audio, o.AudioTracks, o.ASrc = true, TracksSeparate, []AudioSource{{Name: "mic"}, {Name: "desktop"}}
+			},
+			"",
+		},
+		{
+			"separate tracks in avi",
+			func(o *Options) {
+				o.Container, o.Audio, o.AudioTracks, o.ASrc = "avi", true, TracksSeparate, []AudioSource{{Name: "mic"}, {Name: "desktop"}}
+			},
+			"separate audio tracks need mp4|mkv|mov, not avi",
+		},
+		{"separate tracks with one source", func(o *Options) { o.Container, o.Audio, o.AudioTracks = "avi", true, TracksSeparate }, ""},
+		{"unknown tracks", func(o *Options) { o.Audio, o.AudioTracks = true, "stereo" }, `unknown audio tracks "stereo"`},
+		{"gif skips the codec checks", func(o *Options) { o.Container, o.Codec, o.CRF = "gif", "nope", 99 }, ""},
+		{"gif with a bad dither", func(o *Options) { o.Container, o.Anim.Dither = "gif", "halftone" }, `unknown dither "halftone"`},
+		{"gif with a negative fps", func(o *Options) { o.Container, o.Anim.Fps = "apng", -1 }, "can't be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := DefaultOptions()
+			tt.set(&o)
+			err := o.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Validate() = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Validate() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
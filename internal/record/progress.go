@@ -0,0 +1,117 @@
+/*
+	recording status as events. ffmpeg's -progress stream gets parsed into
+	Progress; the cli turns those (plus start/stop/error) into NDJSON or the
+	terminal view, so both read the same model.
+*/
+
+package record
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event types, in the order a recording produces them.
+const (
+	EventStarted     = "started"
+	EventProgress    = "progress"
+	EventPaused      = "paused"
+	EventResumed     = "resumed"
+	EventMarker      = "marker"
+	EventExporting   = "exporting" // converting to gif, webp or apng
+	EventWarning     = "warning"
+	EventReplaySaved = "replay-saved" // a replay buffer save, Out names the file
+	EventStopped     = "stopped"
+	EventError       = "error"
+)
+
+// Event is one line of `swiftcap record --progress=json`.
+type Event struct {
+	Event string `json:"event"`
+	Time  string `json:"time"` // RFC 3339, milliseconds
+	Out   string `json:"out,omitempty"`
+	*Progress
+	*Marker
+	Reason  string `json:"reason,omitempty"` // stopped: user|ctl|max-dur|done|exited
+	Code    int    `json:"code,omitempty"`   // error: the process exit code; stopped: the --headless command's
+	Name    string `json:"name,omitempty"`   // error: E_NAME
+	Message string `json:"message,omitempty"`
+}
+
+// NewEvent stamps an event of type typ with the current time.
+func NewEvent(typ string) Event {
+	return Event{Event: typ, Time: time.Now().Format("2006-01-02T15:04:05.000Z07:00")}
+}
+
+// Progress is one ffmpeg progress report.
+type Progress struct {
+	Frame   int64   `json:"frame"`
+	FPS     float64 `json:"fps"`
+	Bitrate float64 `json:"bitrate"` // kbit/s
+	Size    int64   `json:"size"`    // bytes written so far
+	Dropped int64   `json:"dropped"`
+	OutTime string  `json:"out_time"` // HH:MM:SS.micro of encoded media
+}
+
+// After adds the totals of earlier segments in prev to p, so a paused and
+// resumed recording keeps one running count.
+func (p Progress) After(prev Progress) Progress {
+	p.Frame += prev.Frame
+	p.Size += prev.Size
+	p.Dropped += prev.Dropped
+	p.OutTime = FormatOutTime(ParseOutTime(p.OutTime) + ParseOutTime(prev.OutTime))
+	return p
+}
+
+// ParseOutTime reads ffmpeg's HH:MM:SS.micro; garbage (or "N/A") is zero.
+func ParseOutTime(s string) time.Duration {
+	var h, m int
+	var sec float64
+	if n, _ := fmt.Sscanf(s, "%d:%d:%f", &h, &m, &sec); n != 3 {
+		return 0
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec*float64(time.Second))
+}
+
+// FormatOutTime writes d the way ffmpeg reports out_time.
+func FormatOutTime(d time.Duration) string {
+	us := d.Microseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%06d", us/3600e6, us/60e6%60, us/1e6%60, us%1e6)
+}
+
+// ReadProgress parses ffmpeg's `-progress` key=value stream from r, calling
+// fn once per report. it returns when r ends.
+func ReadProgress(r io.Reader, fn func(Progress)) error {
+	var p Progress
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		key, val, ok := strings.Cut(sc.Text(), "=")
+		if !ok {
+			continue
+		}
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		switch key {
+		case "frame":
+			p.Frame, _ = strconv.ParseInt(val, 10, 64)
+		case "fps":
+			p.FPS, _ = strconv.ParseFloat(val, 64)
+		case "bitrate":
+			// "1234.5kbits/s", or "N/A" before the first packet
+			p.Bitrate, _ = strconv.ParseFloat(strings.TrimSuffix(val, "kbits/s"), 64)
+		case "total_size":
+			p.Size, _ = strconv.ParseInt(val, 10, 64)
+		case "drop_frames":
+			p.Dropped, _ = strconv.ParseInt(val, 10, 64)
+		case "out_time":
+			p.OutTime = val
+		case "progress":
+			// closes every report: "continue", or "end" for the last one
+			fn(p)
+		}
+	}
+	return sc.Err()
+}
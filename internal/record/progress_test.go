@@ -0,0 +1,86 @@
+package record
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadProgress(t *testing.T) {
+	stream := `frame=0
+fps=0.00
+stream_0_0_q=0.0
+bitrate=N/A
+total_size=N/A
+out_time_us=0
+out_time=00:00:00.000000
+dup_frames=0
+drop_frames=0
+speed=N/A
+progress=continue
+frame=31
+fps=30.12
+bitrate=1843.7kbits/s
+total_size=262192
+out_time=00:00:01.033333
+drop_frames=2
+progress=continue
+garbage without an equals sign
+frame=62
+fps=30.05
+bitrate= 2011.2kbits/s
+total_size=524336
+out_time=00:00:02.066667
+drop_frames=2
+progress=end
+frame=63
+fps=30.00
+`
+	want := []Progress{
+		{OutTime: "00:00:00.000000"}, // N/A before the first packet reads as 0
+		{Frame: 31, FPS: 30.12, Bitrate: 1843.7, Size: 262192, Dropped: 2, OutTime: "00:00:01.033333"},
+		{Frame: 62, FPS: 30.05, Bitrate: 2011.2, Size: 524336, Dropped: 2, OutTime: "00:00:02.066667"},
+	}
+	var got []Progress
+	if err := ReadProgress(strings.NewReader(stream), func(p Progress) { got = append(got, p) }); err != nil {
+		t.Fatal(err)
+	}
+	// the trailing partial block has no progress= line, so it's no report
+	if len(got) != len(want) {
+		t.Fatalf("%d reports, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("report %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestOutTime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"00:00:01.033333", time.Second + 33333*time.Microsecond},
+		{"01:02:03.500000", time.Hour + 2*time.Minute + 3500*time.Millisecond},
+		{"N/A", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := ParseOutTime(tt.in); got != tt.want {
+			t.Errorf("ParseOutTime(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+	if s := FormatOutTime(time.Hour + 2*time.Minute + 3500*time.Millisecond); s != "01:02:03.500000" {
+		t.Errorf("FormatOutTime = %q", s)
+	}
+}
+
+func TestProgressAfter(t *testing.T) {
+	prev := Progress{Frame: 300, Size: 1000, Dropped: 1, OutTime: "00:00:10.000000", FPS: 29.9}
+	p := Progress{Frame: 60, Size: 200, Dropped: 2, OutTime: "00:00:02.500000", FPS: 30, Bitrate: 800}
+	want := Progress{Frame: 360, Size: 1200, Dropped: 3, OutTime: "00:00:12.500000", FPS: 30, Bitrate: 800}
+	if got := p.After(prev); got != want {
+		t.Errorf("After = %+v, want %+v", got, want)
+	}
+}
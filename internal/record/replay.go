@@ -0,0 +1,138 @@
+/*
+	replay buffer: ffmpeg's segment muxer writes the capture as short mkv
+	segments into a temp dir, the recorder prunes all but the last window's
+	worth, and saving joins what's left into a file without re-encoding. the
+	recording never stops for a save.
+*/
+
+package record
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/almightynan/swiftcap/internal/edit"
+)
+
+// ReplaySegment is how long each buffered segment is, in seconds. a saved
+// replay is rounded up to whole segments.
+const ReplaySegment = 2
+
+// replayContainer holds the buffered segments: every codec fits in it and a
+// segment still being written can be read.
+const replayContainer = "mkv"
+
+// ReplayBuffer is the rolling window of segments behind --replay-buffer.
+type ReplayBuffer struct {
+	dir       string
+	window    time.Duration
+	container string // what saved replays are written as
+}
+
+// NewReplayBuffer makes a temp dir for a window-long buffer whose saves are
+// written as container.
+func NewReplayBuffer(window time.Duration, container string) (*ReplayBuffer, error) {
+	dir, err := os.MkdirTemp("", "swiftcap-replay-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the replay buffer: %w", err)
+	}
+	return &ReplayBuffer{dir: dir, window: window, container: container}, nil
+}
+
+// ParseReplayWindow reads a buffer length like 60s, 2m or a bare number of
+// seconds.
+func ParseReplayWindow(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		n, nerr := strconv.Atoi(s)
+		if nerr != nil {
+			return 0, fmt.Errorf("invalid replay buffer %q, want a duration like 60s or 2m", s)
+		}
+		d = time.Duration(n) * time.Second
+	}
+	if d < 2*ReplaySegment*time.Second || d > time.Hour {
+		return 0, fmt.Errorf("replay buffer %s out of range (%ds-1h)", d, 2*ReplaySegment)
+	}
+	return d, nil
+}
+
+// Options returns o set up to write into the buffer.
+func (b *ReplayBuffer) Options(o Options) Options {
+	o.Container = replayContainer
+	o.Segment = ReplaySegment
+	o.MaxDur = 0
+	o.Out = filepath.Join(b.dir, "seg_%06d."+replayContainer)
+	return o
+}
+
+// Window is how much the buffer keeps.
+func (b *ReplayBuffer) Window() time.Duration { return b.window }
+
+// keep is how many segments cover the window. one more, the segment ffmpeg
+// is writing, is always kept on top.
+func (b *ReplayBuffer) keep() int {
+	return int(math.Ceil(b.window.Seconds() / ReplaySegment))
+}
+
+// segments lists the buffered files, oldest first. the names are zero
+// padded, so sorting them sorts by age.
+func (b *ReplayBuffer) segments() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(b.dir, "seg_*."+replayContainer))
+	sort.Strings(files)
+	return files, err
+}
+
+// Prune deletes the segments that fell out of the window, which bounds the
+// buffer's disk use to about one window of video.
+func (b *ReplayBuffer) Prune() error {
+	files, err := b.segments()
+	if err != nil {
+		return err
+	}
+	for len(files) > b.keep()+1 {
+		if err := os.Remove(files[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// Buffered is roughly how much video the buffer holds right now.
+func (b *ReplayBuffer) Buffered() time.Duration {
+	files, _ := b.segments()
+	n := len(files)
+	if n > b.keep() {
+		n = b.keep()
+	}
+	return time.Duration(n*ReplaySegment) * time.Second
+}
+
+// Save joins the last window of segments into out without stopping the
+// capture. the segment still being written goes in as far as it got.
+func (b *ReplayBuffer) Save(out string) error {
+	if err := b.Prune(); err != nil {
+		return err
+	}
+	files, err := b.segments()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("the replay buffer is empty")
+	}
+	if err := edit.ConcatCopy(files, out, Muxer(b.container), ""); err != nil {
+		return fmt.Errorf("failed to save the replay: %w", err)
+	}
+	return nil
+}
+
+// Close deletes the buffer.
+func (b *ReplayBuffer) Close() error {
+	return os.RemoveAll(b.dir)
+}
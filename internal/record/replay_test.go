@@ -0,0 +1,64 @@
+package record
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseReplayWindow(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"60s", time.Minute, false},
+		{"2m", 2 * time.Minute, false},
+		{"1m30s", 90 * time.Second, false},
+		{"45", 45 * time.Second, false},
+		{"4s", 4 * time.Second, false},
+		{"1h", time.Hour, false},
+		{"3s", 0, true},
+		{"0", 0, true},
+		{"-30s", 0, true},
+		{"61m", 0, true},
+		{"3601", 0, true},
+		{"a minute", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseReplayWindow(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseReplayWindow(%q) = %v, %v; want %v, an error: %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestReplayPrune(t *testing.T) {
+	b, err := NewReplayBuffer(7*time.Second, "mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if o := b.Options(DefaultOptions()); o.Container != "mkv" || o.Segment != ReplaySegment || filepath.Dir(o.Out) != b.dir {
+		t.Errorf("Options = %s every %ds to %s", o.Container, o.Segment, o.Out)
+	}
+	for i := 0; i < 10; i++ {
+		if err := os.WriteFile(filepath.Join(b.dir, fmt.Sprintf("seg_%06d.mkv", i)), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	// 7s is four 2s segments, plus the one being written
+	files, _ := b.segments()
+	if len(files) != 5 || filepath.Base(files[0]) != "seg_000005.mkv" {
+		t.Errorf("kept %v, want seg_000005 to seg_000009", files)
+	}
+	if got := b.Buffered(); got != 8*time.Second {
+		t.Errorf("Buffered = %v, want 8s", got)
+	}
+}
@@ -0,0 +1,165 @@
+/*
+	pausing a recording ends one ffmpeg run and resuming starts the next, so
+	a paused recording is a handful of segment files that get joined with
+	ffmpeg's concat demuxer (no re-encode) when it stops. a journaled set
+	survives a crash; see journal.go. animated recordings are segmented as
+	mkv and exported once the segments are joined.
+*/
+
+package record
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/almightynan/swiftcap/internal/edit"
+)
+
+// Segments names and joins the pieces of one recording.
+type Segments struct {
+	prefix    string
+	container string
+	files     []string
+	anim      AnimOptions // for gif, webp and apng
+	journal   *Journal    // nil unless Journaled
+}
+
+// NewSegments returns an empty set whose files are named
+// <prefix>_segment_<n>.<container>.
+func NewSegments(prefix, container string) *Segments {
+	if container == "" {
+		container = "mp4"
+	}
+	if abs, err := filepath.Abs(prefix); err == nil {
+		prefix = abs
+	}
+	return &Segments{prefix: prefix, container: container, anim: DefaultAnimOptions()}
+}
+
+// Animate sets how Join exports an animated container.
+func (s *Segments) Animate(a AnimOptions) {
+	s.anim = a
+	if s.journal != nil {
+		s.journal.Anim = &a
+		s.journal.save()
+	}
+}
+
+// Journaled keeps a crash journal of s from now on, so the segments can be
+// recovered if the process dies before Join. out is where the recording is
+// meant to go; "" lets recovery name it.
+func (s *Segments) Journaled(out string) error {
+	if out != "" {
+		if abs, err := filepath.Abs(out); err == nil {
+			out = abs
+		}
+	}
+	s.journal = newJournal(s.prefix, s.container, out)
+	s.journal.Segments = s.Files()
+	if IsAnimated(s.container) {
+		a := s.anim
+		s.journal.Anim = &a
+	}
+	return s.journal.save()
+}
+
+// Next names a new segment and adds it to the set. the journal, if any, has
+// it before the caller starts writing it.
+func (s *Segments) Next() string {
+	p := fmt.Sprintf("%s_segment_%d.%s", s.prefix, len(s.files)+1, segmentExt(s.container))
+	s.files = append(s.files, p)
+	if s.journal != nil {
+		s.journal.Segments = s.Files()
+		s.journal.save()
+	}
+	return p
+}
+
+// Len is the number of segments so far.
+func (s *Segments) Len() int { return len(s.files) }
+
+// Container is the container the joined file uses; segments of an animated
+// one are mkv.
+func (s *Segments) Container() string { return s.container }
+
+// Files returns the segment paths in recording order.
+func (s *Segments) Files() []string { return append([]string(nil), s.files...) }
+
+// Join writes the recording to out and removes the segments and the
+// journal. a single segment is just renamed, unless it's a fragmented mp4
+// or mov that wants remuxing into a regular one.
+func (s *Segments) Join(out string) error {
+	if err := join(s.files, out, s.container, s.anim, s.prefix); err != nil {
+		return err
+	}
+	s.dropJournal()
+	return nil
+}
+
+// join is Join for any list of segments named from prefix.
+func join(files []string, out, container string, anim AnimOptions, prefix string) error {
+	if IsAnimated(container) {
+		capture := prefix + "_capture." + captureContainer
+		if err := join(files, capture, captureContainer, anim, prefix); err != nil {
+			return err
+		}
+		if err := ExportAnimated(capture, out, container, anim); err != nil {
+			// keep the capture; the recording isn't lost to a bad export
+			return fmt.Errorf("%w (the recording is kept in %s)", err, capture)
+		}
+		os.Remove(capture)
+		return nil
+	}
+	if len(files) == 1 && !Fragmented(container) {
+		if err := os.Rename(files[0], out); err == nil {
+			return nil
+		}
+		// different filesystem; concat copies it instead
+	}
+	return concat(files, out, container, prefix+"_concat.txt")
+}
+
+// segmentExt is the extension segments of container get.
+func segmentExt(container string) string {
+	if IsAnimated(container) {
+		return captureContainer
+	}
+	return container
+}
+
+// Discard deletes the segments (and journal) without joining them.
+func (s *Segments) Discard() {
+	for _, seg := range s.files {
+		os.Remove(seg)
+	}
+	s.dropJournal()
+}
+
+func (s *Segments) dropJournal() {
+	if s.journal != nil {
+		s.journal.remove()
+		s.journal = nil
+	}
+}
+
+// Concat joins files into out without re-encoding, then removes them.
+func Concat(files []string, out, container string) error {
+	return concat(files, out, container, "")
+}
+
+// concat is Concat with the list written to listPath, or a temp file when
+// it's empty.
+func concat(files []string, out, container, listPath string) error {
+	if len(files) == 0 {
+		return errors.New("no recorded segments to merge")
+	}
+	if err := edit.ConcatCopy(files, out, Muxer(container), listPath); err != nil {
+		return err
+	}
+	for _, seg := range files {
+		os.Remove(seg)
+	}
+	return nil
+}
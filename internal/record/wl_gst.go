@@ -1,17 +1,184 @@
+/*
+	gst-launch-1.0 pipelines. on wayland the video comes from a pipewire node
+	handed out by the screencast portal, on x11 from ximagesrc.
+*/
+
 package record
 
 import "fmt"
 
-func GStreamerCmd(videoNode, audioNode, out string, fps, bitrate int, container string) []string {
-	args := []string{"-e", "pipewiresrc", "do-timestamp=true"}
-	args = append(args, "!", "videoconvert", "!", fmt.Sprintf("video/x-raw,format=NV12,framerate=%d/1", fps))
-	args = append(args, "!", "x264enc", "tune=zerolatency", "speed-preset=veryfast", fmt.Sprintf("bitrate=%d", bitrate), fmt.Sprintf("key-int-max=%d", 2*fps))
-	args = append(args, "!", "h264parse")
-	if container == "mp4" {
-		args = append(args, "!", "mp4mux", "faststart=true")
-	} else {
-		args = append(args, "!", "matroskamux")
-	}
-	args = append(args, "!", "filesink", fmt.Sprintf("location=%s", out))
+// PipeWireSource returns the source element for a portal node. fd is the
+// PipeWire remote as seen by the gst-launch child (3 for ExtraFiles[0]).
+func PipeWireSource(fd int, node uint32) []string {
+	return []string{"pipewiresrc", fmt.Sprintf("fd=%d", fd), fmt.Sprintf("path=%d", node), "do-timestamp=true", "keepalive-time=1000"}
+}
+
+// XImageSource returns the source element grabbing region (WxH+X+Y) of
+// display, rounded down to even sizes like FFmpegCmd does.
+func XImageSource(display, region string, cursor bool) []string {
+	args := []string{"ximagesrc", "display-name=" + display, "use-damage=false", fmt.Sprintf("show-pointer=%t", cursor)}
+	var w, h, x, y int
+	if n, _ := fmt.Sscanf(region, "%dx%d+%d+%d", &w, &h, &x, &y); n == 4 {
+		w, h = max(w&^1, 2), max(h&^1, 2)
+		args = append(args, fmt.Sprintf("startx=%d", x), fmt.Sprintf("starty=%d", y),
+			fmt.Sprintf("endx=%d", x+w-1), fmt.Sprintf("endy=%d", y+h-1))
+	}
+	return args
+}
+
+// GStreamerCmd builds gst-launch-1.0 arguments that encode source per o.
+// o.Display and o.Region are not used; they are the source's business.
+func GStreamerCmd(source []string, o Options) []string {
+	enc, ok := LookupEncoder(o.Codec)
+	if !ok {
+		enc = encoders["x264"]
+	}
+	preset := o.Preset
+	if preset == "" {
+		preset = enc.DefaultPreset
+	}
+	format := "I420"
+	if enc.Name == "ffv1" {
+		format = "BGRx"
+	}
+
+	args := []string{"-q", "-e"}
+	args = append(args, source...)
+	args = append(args, "!", "videoconvert", "!", "videorate", "!", fmt.Sprintf("video/x-raw,format=%s,framerate=%d/1", format, o.Fps))
+	args = append(args, "!")
+	args = append(args, gstVideoEncoder(enc.Name, preset, o)...)
+	args = append(args, "!", "queue", "!", "mux.")
+
+	if o.Audio {
+		args = append(args, gstAudio(o)...)
+	}
+
+	switch o.Container {
+	case "mkv":
+		args = append(args, "matroskamux", "name=mux")
+	case "webm":
+		args = append(args, "webmmux", "name=mux")
+	case "mov":
+		args = append(args, "qtmux", "name=mux", "fragment-duration=1000")
+	case "avi":
+		args = append(args, "avimux", "name=mux")
+	default:
+		// fragmented, like the ffmpeg path, so a crash leaves a playable
+		// file; Join makes it a regular faststart one
+		args = append(args, "mp4mux", "name=mux", "fragment-duration=1000")
+	}
+	args = append(args, "!", "filesink", fmt.Sprintf("location=%s", o.Out))
+	return args
+}
+
+// gstVideoEncoder maps a registry encoder onto its GStreamer element, plus
+// the parser the muxers want after it. With no CRF, QP or bitrate the
+// element keeps its own rate control, as ffmpeg's encoders do.
+func gstVideoEncoder(name, preset string, o Options) []string {
+	kbit := o.Bitrate
+	switch name {
+	case "x265":
+		args := []string{"x265enc", "tune=zerolatency", "speed-preset=" + preset, fmt.Sprintf("key-int-max=%d", 2*o.Fps)}
+		if o.CRF >= 0 {
+			args = append(args, fmt.Sprintf("option-string=crf=%d", o.CRF))
+		} else if kbit > 0 {
+			args = append(args, fmt.Sprintf("bitrate=%d", kbit))
+		}
+		return append(args, "!", "h265parse")
+	case "vp9":
+		deadline := "1" // realtime
+		if preset != "realtime" {
+			deadline = "0" // best effort
+		}
+		args := []string{"vp9enc", "deadline=" + deadline, "cpu-used=8", "row-mt=true"}
+		if o.CRF >= 0 {
+			args = append(args, "end-usage=cq", fmt.Sprintf("cq-level=%d", o.CRF))
+		} else if kbit > 0 {
+			args = append(args, fmt.Sprintf("target-bitrate=%d", kbit*1000))
+		}
+		return args
+	case "av1":
+		args := []string{"svtav1enc", "preset=" + preset}
+		if o.CRF >= 0 {
+			args = append(args, fmt.Sprintf("crf=%d", o.CRF))
+		} else if kbit > 0 {
+			args = append(args, fmt.Sprintf("target-bitrate=%d", kbit))
+		}
+		return append(args, "!", "av1parse")
+	case "av1-aom":
+		args := []string{"av1enc", "usage-profile=realtime", "cpu-used=" + preset}
+		if o.CRF >= 0 {
+			args = append(args, "end-usage=q", fmt.Sprintf("cq-level=%d", o.CRF))
+		} else if kbit > 0 {
+			args = append(args, fmt.Sprintf("target-bitrate=%d", kbit))
+		}
+		return append(args, "!", "av1parse")
+	case "ffv1":
+		return []string{"avenc_ffv1"}
+	}
+	args := []string{"x264enc", "tune=zerolatency", "speed-preset=" + preset, fmt.Sprintf("key-int-max=%d", 2*o.Fps)}
+	switch {
+	case o.QP > 0:
+		args = append(args, "pass=quant", fmt.Sprintf("quantizer=%d", o.QP))
+	case o.CRF >= 0:
+		args = append(args, "pass=qual", fmt.Sprintf("quantizer=%d", o.CRF))
+	case kbit > 0:
+		args = append(args, fmt.Sprintf("bitrate=%d", kbit))
+	}
+	return append(args, "!", "h264parse")
+}
+
+// gstAudio builds the audio branches: one per source into the muxer with
+// TracksSeparate, else every source into an audiomixer and that into the
+// muxer.
+func gstAudio(o Options) []string {
+	encode := []string{"audioconvert", "!", "audioresample", "!", "audio/x-raw,channels=2"}
+	if a := gstAudioEncoder(o); len(a) > 0 {
+		encode = append(append(encode, "!"), a...)
+	}
+	encode = append(encode, "!", "queue", "!", "mux.")
+
+	srcs := o.audioSources()
+	mix := len(srcs) > 1 && !o.separateTracks()
+	var args []string
+	for _, src := range srcs {
+		args = append(args, "pulsesrc")
+		if src.Name != "default" {
+			args = append(args, fmt.Sprintf("device=%s", src.Name))
+		}
+		if !src.unity() {
+			args = append(args, "!", "audioconvert", "!", "volume", "volume="+src.gain())
+		}
+		if mix {
+			args = append(args, "!", "audioconvert", "!", "audioresample", "!", "queue", "!", "amix.")
+		} else {
+			args = append(append(args, "!"), encode...)
+		}
+	}
+	if mix {
+		args = append(append(args, "audiomixer", "name=amix", "!"), encode...)
+	}
 	return args
 }
+
+// gstAudioEncoder returns the encoder element for o's audio codec; nil for
+// pcm, which the muxers take raw.
+func gstAudioEncoder(o Options) []string {
+	kbit := o.AudioBitrate
+	if kbit <= 0 {
+		kbit = 128
+	}
+	switch o.audioCodec() {
+	case "opus":
+		return []string{"opusenc", fmt.Sprintf("bitrate=%d", kbit*1000)}
+	case "vorbis":
+		return []string{"vorbisenc", fmt.Sprintf("bitrate=%d", kbit*1000)}
+	case "mp3":
+		return []string{"lamemp3enc", fmt.Sprintf("bitrate=%d", kbit)}
+	case "flac":
+		return []string{"flacenc"}
+	case "pcm":
+		return nil
+	}
+	return []string{"avenc_aac", fmt.Sprintf("bitrate=%d", kbit*1000), "!", "aacparse"}
+}
@@ -0,0 +1,112 @@
+package record
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestXImageSource(t *testing.T) {
+	tests := []struct {
+		region string
+		cursor bool
+		want   []string
+	}{
+		{"", false, []string{"ximagesrc", "display-name=:0", "use-damage=false", "show-pointer=false"}},
+		{
+			"641x481+10+20", true,
+			[]string{"ximagesrc", "display-name=:0", "use-damage=false", "show-pointer=true", "startx=10", "starty=20", "endx=649", "endy=499"},
+		},
+	}
+	for _, tt := range tests {
+		if got := XImageSource(":0", tt.region, tt.cursor); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("XImageSource(%q) = %q, want %q", tt.region, got, tt.want)
+		}
+	}
+}
+
+func TestGStreamerCmd(t *testing.T) {
+	src := PipeWireSource(3, 42)
+	base := func() Options {
+		o := DefaultOptions()
+		o.Fps, o.Out = 30, "out.mp4"
+		return o
+	}
+	t.Run("defaults", func(t *testing.T) {
+		want := []string{
+			"-q", "-e",
+			"pipewiresrc", "fd=3", "path=42", "do-timestamp=true", "keepalive-time=1000",
+			"!", "videoconvert", "!", "videorate", "!", "video/x-raw,format=I420,framerate=30/1",
+			"!", "x264enc", "tune=zerolatency", "speed-preset=veryfast", "key-int-max=60", "!", "h264parse",
+			"!", "queue", "!", "mux.",
+			"mp4mux", "name=mux", "fragment-duration=1000", "!", "filesink", "location=out.mp4",
+		}
+		if got := GStreamerCmd(src, base()); !reflect.DeepEqual(got, want) {
+			t.Errorf("got  %q\nwant %q", got, want)
+		}
+	})
+
+	tests := []struct {
+		name string
+		set  func(o *Options)
+		want [][]string
+	}{
+		{"x264 crf", func(o *Options) { o.CRF = 20 }, [][]string{{"pass=qual", "quantizer=20", "!", "h264parse"}}},
+		{"x264 qp", func(o *Options) { o.QP, o.CRF = 25, 20 }, [][]string{{"pass=quant", "quantizer=25"}}},
+		{"x264 bitrate", func(o *Options) { o.Bitrate = 6000 }, [][]string{{"key-int-max=60", "bitrate=6000"}}},
+		{"x265 without a rate", func(o *Options) { o.Codec = "x265" }, [][]string{{"key-int-max=60", "!", "h265parse"}}},
+		{"x265", func(o *Options) { o.Codec, o.CRF = "x265", 28 }, [][]string{{"x265enc"}, {"option-string=crf=28", "!", "h265parse"}}},
+		{
+			"vp9 in webm",
+			func(o *Options) { o.Codec, o.Container, o.Preset, o.Bitrate = "vp9", "webm", "good", 2000 },
+			[][]string{{"vp9enc", "deadline=0"}, {"target-bitrate=2000000", "!", "queue"}, {"webmmux", "name=mux"}},
+		},
+		{"av1", func(o *Options) { o.Codec, o.Container, o.CRF = "av1", "mkv", 35 }, [][]string{{"svtav1enc", "preset=10", "crf=35", "!", "av1parse"}, {"matroskamux"}}},
+		{"ffv1 keeps bgrx", func(o *Options) { o.Codec, o.Container = "ffv1", "mkv" }, [][]string{{"video/x-raw,format=BGRx,framerate=30/1", "!", "avenc_ffv1"}}},
+		{"mov is fragmented", func(o *Options) { o.Container = "mov" }, [][]string{{"qtmux", "name=mux", "fragment-duration=1000"}}},
+		{
+			"default audio",
+			func(o *Options) { o.Audio = true },
+			[][]string{{"mux.", "pulsesrc", "!", "audioconvert", "!", "audioresample", "!", "audio/x-raw,channels=2",
+				"!", "avenc_aac", "bitrate=128000", "!", "aacparse", "!", "queue", "!", "mux.", "mp4mux"}},
+		},
+		{
+			"mixed sources with gain",
+			func(o *Options) {
+				o.Audio, o.AudioCodec, o.AudioBitrate = true, "opus", 96
+				o.ASrc = []AudioSource{{Name: "mic", Gain: 2}, {Name: "desktop"}}
+			},
+			[][]string{
+				{"pulsesrc", "device=mic", "!", "audioconvert", "!", "volume", "volume=2", "!", "audioconvert", "!", "audioresample", "!", "queue", "!", "amix."},
+				{"pulsesrc", "device=desktop", "!", "audioconvert", "!", "audioresample", "!", "queue", "!", "amix."},
+				{"audiomixer", "name=amix", "!", "audioconvert"},
+				{"opusenc", "bitrate=96000", "!", "queue", "!", "mux."},
+			},
+		},
+		{
+			"separate tracks",
+			func(o *Options) {
+				o.Container, o.Audio, o.AudioCodec, o.AudioTracks = "mkv", true, "pcm", TracksSeparate
+				o.ASrc = []AudioSource{{Name: "mic"}, {Name: "desktop"}}
+			},
+			[][]string{
+				{"pulsesrc", "device=mic", "!", "audioconvert", "!", "audioresample", "!", "audio/x-raw,channels=2", "!", "queue", "!", "mux."},
+				{"pulsesrc", "device=desktop", "!", "audioconvert"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := base()
+			tt.set(&o)
+			if err := o.Validate(); err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			got := GStreamerCmd(src, o)
+			for _, run := range tt.want {
+				if !hasRun(got, run) {
+					t.Errorf("missing %q in\n%q", run, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package record
+
+import "fmt"
+
+// ScreencopyCmd builds ffmpeg arguments that encode raw width x height
+// frames of pixFmt, fed to its stdin at o.Fps, per o. o.Display and
+// o.Region are not used; the frames are already cut to size, and must be
+// even in both directions for yuv420p.
+func ScreencopyCmd(o Options, width, height int, pixFmt string) []string {
+	args := []string{"-y", "-hide_banner", "-loglevel", "error", "-nostats", "-progress", "pipe:1",
+		"-f", "rawvideo", "-pix_fmt", pixFmt, "-video_size", fmt.Sprintf("%dx%d", width, height),
+		"-framerate", fmt.Sprintf("%d", o.Fps), "-thread_queue_size", "512", "-i", "pipe:0"}
+	return append(args, encodeArgs(o)...)
+}
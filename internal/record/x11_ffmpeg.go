@@ -1,17 +1,26 @@
 package record
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
 
-func FFmpegCmd(display string, region string, fps int, out string, audio bool, aSrc string, bitrate int, qp int, container string, maxDur int, threads int, cursor bool) []string {
-	args := []string{"-y"}
+	"github.com/almightynan/swiftcap/internal/edit"
+)
+
+// FFmpegCmd builds ffmpeg arguments that grab o.Region of o.Display with
+// x11grab and encode it per o. Call o.Validate first; unknown codecs fall back
+// to x264. Progress reports go to stdout (see ReadProgress); stderr only
+// carries errors.
+func FFmpegCmd(o Options) []string {
+	args := []string{"-y", "-hide_banner", "-loglevel", "error", "-nostats", "-progress", "pipe:1"}
 
 	// Parse region → video_size and offset.
 	// Width and height must be even for yuv420p / H.264; round down if needed.
 	wxh := ""
 	offset := ""
-	if region != "" {
+	if o.Region != "" {
 		var w, h, x, y int
-		n, err := fmt.Sscanf(region, "%dx%d+%d+%d", &w, &h, &x, &y)
+		n, err := fmt.Sscanf(o.Region, "%dx%d+%d+%d", &w, &h, &x, &y)
 		if n == 4 && err == nil {
 			if w%2 != 0 {
 				w--
@@ -28,7 +37,7 @@ func FFmpegCmd(display string, region string, fps int, out string, audio bool, a
 			wxh = fmt.Sprintf("%dx%d", w, h)
 			offset = fmt.Sprintf("+%d,%d", x, y)
 		} else {
-			wxh = region
+			wxh = o.Region
 		}
 	}
 
@@ -36,73 +45,163 @@ func FFmpegCmd(display string, region string, fps int, out string, audio bool, a
 	if wxh != "" {
 		args = append(args, "-video_size", wxh)
 	}
-	if fps > 0 {
-		args = append(args, "-framerate", fmt.Sprintf("%d", fps))
+	if o.Fps > 0 {
+		args = append(args, "-framerate", fmt.Sprintf("%d", o.Fps))
 	}
 	args = append(args, "-thread_queue_size", "512")
 
-	input := display
+	input := o.Display
 	if offset != "" {
-		input = fmt.Sprintf("%s%s", display, offset)
+		input = fmt.Sprintf("%s%s", o.Display, offset)
 	}
 	// draw_mouse must go after -f x11grab and before -i (device-specific input option)
 	args = append(args, "-f", "x11grab")
-	if cursor {
+	if o.Cursor {
 		args = append(args, "-draw_mouse", "1")
 	} else {
 		args = append(args, "-draw_mouse", "0")
 	}
 	args = append(args, "-i", input)
+	return append(args, encodeArgs(o)...)
+}
+
+// encodeArgs follows the video input (input 0) of an ffmpeg command with
+// the audio inputs, the encoding and the output, all per o.
+func encodeArgs(o Options) []string {
+	var args []string
 
 	// ── PulseAudio input ───────────────────────────────────────────────────────
-	if audio {
-		args = append(args, "-thread_queue_size", "512", "-f", "pulse", "-i", aSrc)
+	// inputs 1..n, in --a-src order
+	if o.Audio {
+		for _, src := range o.audioSources() {
+			args = append(args, "-thread_queue_size", "512", "-f", "pulse", "-i", src.Name)
+		}
 	}
 
 	// ── Video encoding ─────────────────────────────────────────────────────────
-	args = append(args, "-c:v", "libx264")
-	args = append(args, "-preset", "veryfast")
-	args = append(args, "-tune", "zerolatency")
-	args = append(args, "-profile:v", "baseline")
+	args = append(args, VideoArgs(o)...)
 
-	if threads > 0 {
-		args = append(args, "-threads", fmt.Sprintf("%d", threads))
+	// ── Audio encoding ─────────────────────────────────────────────────────────
+	if o.Audio {
+		args = append(args, audioMap(o)...)
+		args = append(args, AudioArgs(o)...)
+	}
+
+	// ── Output container ───────────────────────────────────────────────────────
+	if o.Segment > 0 {
+		// a keyframe at every boundary, so each segment starts cleanly and
+		// any run of them can be joined without re-encoding
+		args = append(args, "-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", o.Segment),
+			"-f", "segment", "-segment_time", fmt.Sprintf("%d", o.Segment),
+			"-segment_format", Muxer(o.Container), "-reset_timestamps", "1")
 	} else {
-		args = append(args, "-threads", "0")
+		args = append(args, "-f", Muxer(o.Container))
+	}
+	if Fragmented(o.Container) && o.Segment == 0 {
+		// a fragmented file stays playable if we die mid-recording; the
+		// capture session records it as a segment, and Join remuxes it into
+		// a regular faststart one
+		args = append(args, "-movflags", "+frag_keyframe+empty_moov+default_base_moof")
 	}
 
-	if qp > 0 {
-		args = append(args, "-qp", fmt.Sprintf("%d", qp))
-	} else if bitrate > 0 {
-		args = append(args, "-b:v", fmt.Sprintf("%dk", bitrate))
-		args = append(args, "-maxrate", fmt.Sprintf("%dk", bitrate))
-		args = append(args, "-bufsize", fmt.Sprintf("%dk", bitrate*2))
-		args = append(args, "-rc-lookahead", "0")
+	if o.MaxDur > 0 {
+		args = append(args, "-t", fmt.Sprintf("%d", o.MaxDur))
 	}
 
-	args = append(args, "-pix_fmt", "yuv420p")
+	args = append(args, o.Out)
+	return args
+}
 
-	// ── Audio encoding ─────────────────────────────────────────────────────────
-	if audio {
-		args = append(args, "-c:a", "aac", "-b:a", "128k", "-ar", "44100", "-ac", "2")
+// audioMap routes the pulse inputs to the output. one source at unity gain
+// needs nothing; otherwise each goes through volume, then amix, or onto its
+// own track with TracksSeparate.
+func audioMap(o Options) []string {
+	srcs := o.audioSources()
+	if len(srcs) == 1 && srcs[0].unity() {
+		return nil
 	}
-
-	// ── Output container ───────────────────────────────────────────────────────
-	switch container {
-	case "mkv":
-		args = append(args, "-f", "matroska")
-	case "mov":
-		args = append(args, "-f", "mov")
-	case "avi":
-		args = append(args, "-f", "avi")
-	default:
-		args = append(args, "-f", "mp4", "-movflags", "+faststart")
+	var graph, outs []string
+	for i, src := range srcs {
+		in := fmt.Sprintf("%d:a", i+1)
+		if src.unity() {
+			outs = append(outs, in)
+			continue
+		}
+		label := fmt.Sprintf("a%d", i)
+		graph = append(graph, fmt.Sprintf("[%s]volume=%s[%s]", in, src.gain(), label))
+		outs = append(outs, "["+label+"]")
 	}
 
-	if maxDur > 0 {
-		args = append(args, "-t", fmt.Sprintf("%d", maxDur))
+	if len(outs) > 1 && !o.separateTracks() {
+		var ins string
+		for _, out := range outs {
+			if !strings.HasPrefix(out, "[") {
+				out = "[" + out + "]"
+			}
+			ins += out
+		}
+		graph = append(graph, fmt.Sprintf("%samix=inputs=%d:duration=longest[aout]", ins, len(outs)))
+		return []string{"-filter_complex", strings.Join(graph, ";"), "-map", "0:v", "-map", "[aout]"}
 	}
 
-	args = append(args, out)
+	var args []string
+	if len(graph) > 0 {
+		args = append(args, "-filter_complex", strings.Join(graph, ";"))
+	}
+	args = append(args, "-map", "0:v")
+	for _, out := range outs {
+		args = append(args, "-map", out)
+	}
+	// name the tracks after their sources so an editor can tell them apart
+	for i, src := range srcs {
+		if len(srcs) > 1 {
+			args = append(args, fmt.Sprintf("-metadata:s:a:%d", i), "title="+src.Name)
+		}
+	}
 	return args
 }
+
+// VideoArgs returns the ffmpeg video encoding arguments for o, threads
+// included.
+func VideoArgs(o Options) []string {
+	enc, ok := LookupEncoder(o.Codec)
+	if !ok {
+		enc = encoders["x264"]
+	}
+	preset := o.Preset
+	if preset == "" {
+		preset = enc.DefaultPreset
+	}
+	args := enc.Args(o, preset)
+	if o.Threads > 0 {
+		return append(args, "-threads", fmt.Sprintf("%d", o.Threads))
+	}
+	return append(args, "-threads", "0")
+}
+
+// Encoding returns the encoder arguments and muxer the recorder would use
+// for o, for re-encoding a finished file the same way.
+func (o Options) Encoding() edit.Encoding {
+	return edit.Encoding{Video: VideoArgs(o), Audio: AudioArgs(o), Format: Muxer(o.Container)}
+}
+
+// AudioArgs returns the ffmpeg audio encoding arguments for o.
+func AudioArgs(o Options) []string {
+	ac, ok := LookupAudioCodec(o.audioCodec())
+	if !ok {
+		ac = audioCodecs["aac"]
+	}
+	args := []string{"-c:a", ac.FFmpeg}
+	if !ac.Lossless {
+		kbit := o.AudioBitrate
+		if kbit <= 0 {
+			kbit = 128
+		}
+		args = append(args, "-b:a", fmt.Sprintf("%dk", kbit))
+	}
+	rate := "44100"
+	if ac.Name == "opus" {
+		rate = "48000" // libopus refuses 44.1k
+	}
+	return append(args, "-ar", rate, "-ac", "2")
+}
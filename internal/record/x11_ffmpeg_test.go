@@ -0,0 +1,124 @@
+package record
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// hasRun reports whether want appears in args as a contiguous run.
+func hasRun(args, want []string) bool {
+	for i := 0; i+len(want) <= len(args); i++ {
+		if reflect.DeepEqual(args[i:i+len(want)], want) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFFmpegCmd(t *testing.T) {
+	base := func() Options {
+		o := DefaultOptions()
+		o.Display, o.Fps, o.Out = ":0", 30, "out.mp4"
+		return o
+	}
+	t.Run("defaults", func(t *testing.T) {
+		want := []string{
+			"-y", "-hide_banner", "-loglevel", "error", "-nostats", "-progress", "pipe:1",
+			"-framerate", "30", "-thread_queue_size", "512", "-f", "x11grab", "-draw_mouse", "0", "-i", ":0",
+			"-c:v", "libx264", "-preset", "veryfast", "-tune", "zerolatency", "-profile:v", "baseline", "-pix_fmt", "yuv420p", "-threads", "0",
+			"-f", "mp4", "-movflags", "+frag_keyframe+empty_moov+default_base_moof",
+			"out.mp4",
+		}
+		if got := FFmpegCmd(base()); !reflect.DeepEqual(got, want) {
+			t.Errorf("got  %q\nwant %q", got, want)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		set     func(o *Options)
+		want    [][]string // runs that must be there
+		notWant []string   // arguments that mustn't
+	}{
+		{
+			"odd region rounds down to even",
+			func(o *Options) { o.Region = "641x481+10+20" },
+			[][]string{{"-video_size", "640x480"}, {"-i", ":0+10,20"}},
+			nil,
+		},
+		{
+			"tiny region grows to 2x2",
+			func(o *Options) { o.Region = "1x1+0+0" },
+			[][]string{{"-video_size", "2x2"}},
+			nil,
+		},
+		{"cursor", func(o *Options) { o.Cursor = true }, [][]string{{"-f", "x11grab", "-draw_mouse", "1"}}, nil},
+		{"threads", func(o *Options) { o.Threads = 4 }, [][]string{{"-threads", "4"}}, nil},
+		{"max duration", func(o *Options) { o.MaxDur = 90 }, [][]string{{"-t", "90", "out.mp4"}}, nil},
+		{"crf", func(o *Options) { o.CRF = 18 }, [][]string{{"-crf", "18", "-pix_fmt"}}, nil},
+		{"qp beats crf", func(o *Options) { o.CRF, o.QP = 18, 20 }, [][]string{{"-qp", "20"}}, []string{"-crf"}},
+		{
+			"bitrate",
+			func(o *Options) { o.Bitrate = 4000 },
+			[][]string{{"-b:v", "4000k", "-maxrate", "4000k", "-bufsize", "8000k", "-rc-lookahead", "0"}},
+			nil,
+		},
+		{
+			"mkv isn't fragmented",
+			func(o *Options) { o.Container, o.Out = "mkv", "out.mkv" },
+			[][]string{{"-f", "matroska", "out.mkv"}},
+			[]string{"-movflags"},
+		},
+		{
+			"segments",
+			func(o *Options) { o.Segment, o.Out = 5, "seg_%03d.mp4" },
+			[][]string{{"-force_key_frames", "expr:gte(t,n_forced*5)", "-f", "segment", "-segment_time", "5", "-segment_format", "mp4"}},
+			[]string{"-movflags"},
+		},
+		{
+			"hevc in mp4 is tagged hvc1",
+			func(o *Options) { o.Codec = "x265" },
+			[][]string{{"-c:v", "libx265"}, {"-tag:v", "hvc1"}},
+			nil,
+		},
+		{
+			"vp9 crf is constant quality",
+			func(o *Options) { o.Codec, o.Container, o.CRF, o.Out = "vp9", "webm", 30, "out.webm" },
+			[][]string{{"-crf", "30", "-b:v", "0"}, {"-f", "webm", "out.webm"}},
+			nil,
+		},
+		{
+			"audio",
+			func(o *Options) { o.Audio = true },
+			[][]string{{"-i", ":0", "-thread_queue_size", "512", "-f", "pulse", "-i", "default"}, {"-c:a", "aac", "-b:a", "128k"}},
+			[]string{"-filter_complex"},
+		},
+		{
+			"lossless audio has no bitrate",
+			func(o *Options) { o.Container, o.Audio, o.AudioCodec, o.AudioBitrate = "mkv", true, "flac", 320 },
+			[][]string{{"-c:a", "flac"}},
+			[]string{"-b:a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := base()
+			tt.set(&o)
+			if err := o.Validate(); err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			got := FFmpegCmd(o)
+			for _, run := range tt.want {
+				if !hasRun(got, run) {
+					t.Errorf("missing %q in\n%q", run, got)
+				}
+			}
+			for _, a := range tt.notWant {
+				if hasRun(got, []string{a}) {
+					t.Errorf("unexpected %s in\n%q", a, strings.Join(got, " "))
+				}
+			}
+		})
+	}
+}
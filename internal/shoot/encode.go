@@ -0,0 +1,120 @@
+/*
+	screenshot encoding. everything happens in-process: png and jpeg come
+	from the standard library, bmp from x/image, webp from our own encoder.
+*/
+
+package shoot
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/image/bmp"
+
+	"github.com/almightynan/swiftcap/internal/webp"
+)
+
+// Options says how a screenshot is written.
+type Options struct {
+	Format      string // png|jpg|webp|webp-lossless|bmp
+	Quality     int    // 1-100, jpg and lossy webp
+	Compression string // png: default|none|fast|best
+}
+
+// Formats lists the screenshot formats Encode accepts.
+func Formats() []string {
+	return []string{"png", "jpg", "webp", "webp-lossless", "bmp"}
+}
+
+// Validate checks o before anything is captured, so a typo doesn't cost a
+// portal round trip.
+func (o Options) Validate() error {
+	switch normFormat(o.Format) {
+	case "png":
+		if _, ok := pngLevels[o.Compression]; !ok {
+			return fmt.Errorf("unknown --compression %q, want default|none|fast|best", o.Compression)
+		}
+	case "jpg", "webp":
+		if o.Quality < 1 || o.Quality > 100 {
+			return fmt.Errorf("--quality %d out of range 1-100", o.Quality)
+		}
+	case "webp-lossless", "bmp":
+	default:
+		return fmt.Errorf("unknown screenshot format %q, want %s", o.Format, strings.Join(Formats(), "|"))
+	}
+	return nil
+}
+
+var pngLevels = map[string]png.CompressionLevel{
+	"":        png.DefaultCompression,
+	"default": png.DefaultCompression,
+	"none":    png.NoCompression,
+	"fast":    png.BestSpeed,
+	"best":    png.BestCompression,
+}
+
+func normFormat(f string) string {
+	switch strings.ToLower(f) {
+	case "", "png":
+		return "png"
+	case "jpg", "jpeg":
+		return "jpg"
+	case "webp":
+		return "webp"
+	case "webp-lossless":
+		return "webp-lossless"
+	case "bmp":
+		return "bmp"
+	}
+	return f
+}
+
+// Ext returns the file extension (without the dot) for format.
+func Ext(format string) string {
+	if f := normFormat(format); f != "webp-lossless" {
+		return f
+	}
+	return "webp"
+}
+
+// Encode writes img to w in the format o asks for.
+func Encode(w io.Writer, img image.Image, o Options) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	switch normFormat(o.Format) {
+	case "jpg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: o.Quality})
+	case "webp":
+		return webp.Encode(w, img, &webp.Options{Quality: o.Quality})
+	case "webp-lossless":
+		return webp.Encode(w, img, &webp.Options{Lossless: true})
+	case "bmp":
+		return bmp.Encode(w, img)
+	}
+	enc := png.Encoder{CompressionLevel: pngLevels[o.Compression]}
+	return enc.Encode(w, img)
+}
+
+// Save encodes img to the file out; "-" means stdout. a failed encode
+// doesn't leave half a file behind.
+func Save(out string, img image.Image, o Options) error {
+	if out == "-" {
+		return Encode(os.Stdout, img, o)
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := Encode(f, img, o); err != nil {
+		f.Close()
+		os.Remove(out)
+		return err
+	}
+	return f.Close()
+}
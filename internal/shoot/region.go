@@ -0,0 +1,34 @@
+package shoot
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// ParseRegion turns WxH+X+Y into a rectangle; "" is the empty rectangle,
+// which the capture functions read as "everything".
+func ParseRegion(region string) (image.Rectangle, error) {
+	if region == "" {
+		return image.Rectangle{}, nil
+	}
+	var w, h, x, y int
+	if n, _ := fmt.Sscanf(region, "%dx%d+%d+%d", &w, &h, &x, &y); n != 4 || w <= 0 || h <= 0 {
+		return image.Rectangle{}, fmt.Errorf("invalid region %q, want WxH+X+Y", region)
+	}
+	return image.Rect(x, y, x+w, y+h), nil
+}
+
+// crop cuts r (relative to the image's origin) out of img.
+func crop(img image.Image, r image.Rectangle) (image.Image, error) {
+	if r.Empty() {
+		return img, nil
+	}
+	b := r.Add(img.Bounds().Min).Intersect(img.Bounds())
+	if b.Empty() {
+		return nil, fmt.Errorf("region %v lies outside the %dx%d screenshot", r, img.Bounds().Dx(), img.Bounds().Dy())
+	}
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(out, out.Bounds(), img, b.Min, draw.Src)
+	return out, nil
+}
@@ -0,0 +1,52 @@
+package shoot
+
+import (
+	"image"
+	"testing"
+)
+
+func TestToPixels(t *testing.T) {
+	r := image.Rect(100, 50, 740, 530) // 640x480+100+50
+	tests := []struct {
+		name   string
+		layout image.Rectangle
+		size   image.Point
+		want   image.Rectangle
+	}{
+		{"no layout", image.Rectangle{}, image.Pt(3840, 2160), r},
+		{"scale 1", image.Rect(0, 0, 1920, 1080), image.Pt(1920, 1080), r},
+		{"scale 2", image.Rect(0, 0, 1920, 1080), image.Pt(3840, 2160), image.Rect(200, 100, 1480, 1060)},
+		{"scale 1.5", image.Rect(0, 0, 1920, 1080), image.Pt(2880, 1620), image.Rect(150, 75, 1110, 795)},
+		{"scale 1.25 rounds outwards", image.Rect(0, 0, 1536, 864), image.Pt(1920, 1080), image.Rect(125, 62, 925, 663)},
+		{"layout left of zero", image.Rect(-1920, 0, 1920, 1080), image.Pt(3840, 1080), image.Rect(2020, 50, 2660, 530)},
+	}
+	for _, tt := range tests {
+		if got := toPixels(r, tt.layout, tt.size); got != tt.want {
+			t.Errorf("%s: toPixels = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCrop(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 80))
+	tests := []struct {
+		r       image.Rectangle
+		want    image.Point
+		wantErr bool
+	}{
+		{image.Rectangle{}, image.Pt(100, 80), false},
+		{image.Rect(10, 10, 30, 20), image.Pt(20, 10), false},
+		{image.Rect(90, 70, 130, 100), image.Pt(10, 10), false},
+		{image.Rect(100, 0, 120, 10), image.Point{}, true},
+	}
+	for _, tt := range tests {
+		got, err := crop(img, tt.r)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("crop(%v) = %v, want an error: %v", tt.r, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.Bounds().Size() != tt.want {
+			t.Errorf("crop(%v) is %v, want %v", tt.r, got.Bounds().Size(), tt.want)
+		}
+	}
+}
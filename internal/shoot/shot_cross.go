@@ -1,27 +1,59 @@
 package shoot
 
 import (
+	"bytes"
 	"fmt"
+	"image"
+	_ "image/png"
+	"os"
 	"os/exec"
 	"runtime"
+
+	scerr "github.com/almightynan/swiftcap/internal/errors"
 )
 
-// ScreenshotCross handles screenshots for all major platforms
-func ScreenshotCross(region, out, format string) error {
-	osys := runtime.GOOS
-	switch osys {
-	case "linux":
-		// X11 or wayland handled in main.go via session type
-		return fmt.Errorf("ScreenshotCross should not be called for Linux; use ScreenshotX11 or ScreenshotWayland")
+// CaptureCross grabs the screen on windows and macOS. ffmpeg does the grab
+// into a temporary png; encoding is ours, like everywhere else.
+func CaptureCross(region string) (image.Image, error) {
+	r, err := ParseRegion(region)
+	if err != nil {
+		return nil, err
+	}
+	var input []string
+	switch runtime.GOOS {
 	case "windows":
 		// use ffmpeg gdigrab
-		cmd := exec.Command("ffmpeg", "-f", "gdigrab", "-framerate", "1", "-i", "desktop", "-vframes", "1", out)
-		return cmd.Run()
+		input = []string{"-f", "gdigrab", "-framerate", "1", "-i", "desktop"}
 	case "darwin":
 		// use ffmpeg avfoundation
-		cmd := exec.Command("ffmpeg", "-f", "avfoundation", "-framerate", "1", "-i", "1:none", "-vframes", "1", out)
-		return cmd.Run()
+		input = []string{"-f", "avfoundation", "-framerate", "1", "-i", "1:none"}
 	default:
-		return fmt.Errorf("unsupported OS for screenshot")
+		return nil, fmt.Errorf("unsupported OS for screenshot")
+	}
+
+	tmp, err := os.CreateTemp("", "swiftcap-shot-*.png")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	args := append([]string{"-y"}, input...)
+	args = append(args, "-vframes", "1", tmp.Name())
+	var stderr bytes.Buffer
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, scerr.Classify("ffmpeg", err, stderr.String())
+	}
+
+	f, err := os.Open(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
 	}
+	return crop(img, r)
 }
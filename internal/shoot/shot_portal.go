@@ -1,7 +1,54 @@
 package shoot
 
-import "swiftcap/internal/portal"
+import (
+	"image"
+	"math"
 
-func ScreenshotWayland(region, out string) error {
-	return portal.TakeScreenshot(region, out)
+	"github.com/almightynan/swiftcap/internal/portal"
+	"github.com/almightynan/swiftcap/internal/wayland"
+)
+
+// CaptureWayland asks the Screenshot portal for the screen and crops it to
+// region. region is in the compositor's logical pixels and the portal's
+// screenshot in the outputs' own, so on HiDPI the region is scaled up to
+// it first.
+func CaptureWayland(region string) (image.Image, error) {
+	r, err := ParseRegion(region)
+	if err != nil {
+		return nil, err
+	}
+	img, err := portal.Screenshot()
+	if err != nil {
+		return nil, err
+	}
+	if !r.Empty() {
+		// without the layout there's nothing to scale by; take the region
+		// as pixels
+		var layout image.Rectangle
+		if outs, err := wayland.Outputs(); err == nil {
+			for _, o := range outs {
+				layout = layout.Union(o.Rect())
+			}
+		}
+		r = toPixels(r, layout, img.Bounds().Size())
+	}
+	return crop(img, r)
+}
+
+// toPixels maps r, in layout's logical pixels, onto a screenshot of size
+// that shows all of layout. An empty layout leaves r as it is.
+func toPixels(r, layout image.Rectangle, size image.Point) image.Rectangle {
+	if layout.Empty() {
+		return r
+	}
+	r = r.Sub(layout.Min)
+	if size == layout.Size() {
+		return r
+	}
+	fx := float64(size.X) / float64(layout.Dx())
+	fy := float64(size.Y) / float64(layout.Dy())
+	return image.Rect(
+		int(math.Floor(float64(r.Min.X)*fx)), int(math.Floor(float64(r.Min.Y)*fy)),
+		int(math.Ceil(float64(r.Max.X)*fx)), int(math.Ceil(float64(r.Max.Y)*fy)),
+	)
 }
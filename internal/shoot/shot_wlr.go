@@ -0,0 +1,69 @@
+package shoot
+
+import (
+	"fmt"
+	"image"
+
+	xdraw "golang.org/x/image/draw"
+
+	"github.com/almightynan/swiftcap/internal/wayland"
+)
+
+// CaptureWlr copies region straight off the outputs with wlr-screencopy,
+// with the pointer painted in when cursor is set. A region on one output
+// comes back in that output's pixels, so HiDPI stays sharp; one spanning
+// outputs is put together in logical pixels.
+func CaptureWlr(region string, cursor bool) (image.Image, error) {
+	r, err := ParseRegion(region)
+	if err != nil {
+		return nil, err
+	}
+	cl, err := wayland.Connect()
+	if err != nil {
+		return nil, err
+	}
+	defer cl.Close()
+	outs := cl.Outputs()
+	if r.Empty() {
+		for _, o := range outs {
+			r = r.Union(o.Rect())
+		}
+	}
+
+	type piece struct {
+		at  image.Rectangle
+		img *image.RGBA
+	}
+	var pieces []piece
+	for _, o := range outs {
+		part := r.Intersect(o.Rect())
+		if part.Empty() {
+			continue
+		}
+		local := part.Sub(o.Rect().Min)
+		if part == o.Rect() {
+			local = image.Rectangle{} // the whole output
+		}
+		f, err := cl.Capture(o, local, cursor)
+		if err != nil {
+			return nil, err
+		}
+		pieces = append(pieces, piece{part, f.Image()})
+	}
+	switch {
+	case len(pieces) == 0:
+		return nil, fmt.Errorf("region %v is on no output", r)
+	case len(pieces) == 1 && pieces[0].at == r:
+		return pieces[0].img, nil
+	}
+	out := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	for _, p := range pieces {
+		dst := p.at.Sub(r.Min)
+		if dst.Size() == p.img.Bounds().Size() {
+			xdraw.Draw(out, dst, p.img, image.Point{}, xdraw.Src)
+			continue
+		}
+		xdraw.ApproxBiLinear.Scale(out, dst, p.img, p.img.Bounds(), xdraw.Src, nil)
+	}
+	return out, nil
+}
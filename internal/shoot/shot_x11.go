@@ -1,10 +1,20 @@
 package shoot
 
 import (
-	"os/exec"
+	"image"
+
+	"github.com/almightynan/swiftcap/internal/x11"
 )
 
-func ScreenshotX11(region, out, format string) error {
-	cmd := exec.Command("ffmpeg", "-f", "x11grab", "-video_size", region, "-i", "$DISPLAY+0,0", "-vframes", "1", out)
-	return cmd.Run()
+// CaptureX11 grabs region straight from the X server, with the pointer
+// painted in when cursor is set.
+func CaptureX11(region string, cursor bool) (image.Image, error) {
+	r, err := ParseRegion(region)
+	if err != nil {
+		return nil, err
+	}
+	if cursor {
+		return x11.CaptureWithCursor(r)
+	}
+	return x11.Capture(r)
 }
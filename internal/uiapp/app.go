@@ -1,20 +1,17 @@
 package uiapp
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"image/color"
+	"image/png"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strconv"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -25,33 +22,31 @@ import (
 	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
-)
 
-const (
-	countdownSeconds = 3
+	"github.com/almightynan/swiftcap/capture"
+	"github.com/almightynan/swiftcap/internal/config"
+	"github.com/almightynan/swiftcap/internal/record"
+	"github.com/almightynan/swiftcap/internal/shoot"
+	"github.com/almightynan/swiftcap/internal/x11"
 )
 
-type exitIntent int
-
 const (
-	exitIntentNone exitIntent = iota
-	exitIntentPause
-	exitIntentStop
+	countdownSeconds = 3
 )
 
 type RecordingUI struct {
-	app        fyne.App
-	mainWin    fyne.Window
-	startBtn   *hoverButton
-	stopBtn    *hoverButton
-	statusText *widget.Label
-	statusDot  *canvas.Circle
-	configSummary *widget.Label
-	audioToggle   *widget.Check
-	cursorToggle  *widget.Check
+	app             fyne.App
+	mainWin         fyne.Window
+	startBtn        *hoverButton
+	stopBtn         *hoverButton
+	statusText      *widget.Label
+	statusDot       *canvas.Circle
+	configSummary   *widget.Label
+	audioPicker     *widget.Button
+	cursorToggle    *widget.Check
 	containerSelect *widget.Select
 
-	captureMode   int  // 0 = screenshot (capture), 1 = recording
+	captureMode   int // 0 = screenshot (capture), 1 = recording, 2 = replay buffer
 	recordPanel   *fyne.Container
 	shotPanel     *fyne.Container
 	shotActionBtn *hoverButton
@@ -64,41 +59,35 @@ type RecordingUI struct {
 	sidebarExpanded bool
 	split           *splitView
 	actionBtn       *hoverButton
+	replayStopBtn   *hoverButton
 	settingsCard    fyne.CanvasObject
 	statusCard      fyne.CanvasObject
 	sidebarAnim     *fyne.Animation
 
-	desktopApp    desktop.App
-	toast         *toastHandle
-	countdown     *countdownBanner
-	settingsWin   *settingsWindow
+	desktopApp     desktop.App
+	toast          *toastHandle
+	countdown      *countdownBanner
+	hotkeys        *x11.Hotkeys
+	profiles       *config.File
+	profileSelect  *widget.Select
+	settingsWin    *settingsWindow
 	recordingsList *recordingsList
-	config        *RecordingConfig
-	cliPath       string
-	videosDir     string
-	activeRegion  string
-
-	mu             sync.Mutex
-	recorderCmd    *exec.Cmd
-	recorderCancel context.CancelFunc
-	recorderDone   chan struct{}
-	exitIntent     exitIntent
-	isPaused       bool
-	finalizing     bool
-	windowVisible  bool
-
-	segmentIndex   int
-	segmentFiles   []string
-	concatListPath string
+	config         *RecordingConfig
+	videosDir      string
+
+	mu            sync.Mutex
+	session       *capture.Session // nil unless a recording is going
+	isPaused      bool
+	finalizing    bool
+	windowVisible bool
+
+	replay *capture.Replay // nil unless the replay buffer is running
 
 	elapsedSeconds int
 	elapsedTicker  *time.Ticker
 	elapsedQuit    chan struct{}
 	flashState     bool
 	playIconTimer  int
-
-	lastRecorderErr    error
-	recorderStderr     *bytes.Buffer
 }
 
 var (
@@ -111,9 +100,21 @@ func Run() error {
 	application := app.NewWithID("swiftcap-ui")
 	application.Settings().SetTheme(theme.DarkTheme())
 	ui := newRecordingUI(application)
+	profileErr := ui.loadProfiles()
+	if profileErr == nil {
+		profileErr = ui.applyProfile()
+	}
 	ui.buildMainWindow()
 	ui.refreshUI()
 	ui.updateTray()
+	ui.offerRecovery()
+	if profileErr != nil {
+		ui.showError("config.toml", profileErr.Error())
+	}
+	ui.watchConfig()
+	if err := ui.registerHotkeys(); err != nil {
+		ui.showInfo("Global Hotkeys", fmt.Sprintf("Some hotkeys could not be registered: %v\n\nRebind them in Settings.", err))
+	}
 	application.Run()
 	return nil
 }
@@ -141,17 +142,52 @@ func (ui *RecordingUI) loadPrefs() {
 	c.SetCursor(p.BoolWithFallback("cursor", c.GetCursor()))
 	c.SetContainer(p.StringWithFallback("container", c.GetContainer()))
 	c.SetMaxDur(p.IntWithFallback("max_dur", c.GetMaxDur()))
+	c.SetReplaySecs(p.IntWithFallback("replay_secs", c.GetReplaySecs()))
+	keys := c.GetHotkeys()
+	for id, b := range keys {
+		keys[id] = p.StringWithFallback("hotkey_"+id, b)
+	}
+	if checkHotkeys(keys) == nil {
+		c.SetHotkeys(keys)
+	}
 	c.SetThreads(p.IntWithFallback("threads", c.GetThreads()))
 	c.SetQP(p.IntWithFallback("qp", c.GetQP()))
 	c.SetNice(p.IntWithFallback("nice", c.GetNice()))
+	c.SetBackend(p.StringWithFallback("backend", c.GetBackend()))
+	c.SetCodec(p.StringWithFallback("codec", c.GetCodec()))
+	c.SetPreset(p.StringWithFallback("preset", c.GetPreset()))
+	c.SetCRF(p.IntWithFallback("crf", c.GetCRF()))
+	c.SetAudioCodec(p.StringWithFallback("audio_codec", c.GetAudioCodec()))
+	c.SetAudioSources(p.StringListWithFallback("audio_sources", c.GetAudioSources()))
+	c.SetAudioTracks(p.StringWithFallback("audio_tracks", c.GetAudioTracks()))
+	a := c.GetAnim()
+	a.Fps = p.IntWithFallback("anim_fps", a.Fps)
+	a.Width = p.IntWithFallback("anim_width", a.Width)
+	a.Dither = p.StringWithFallback("anim_dither", a.Dither)
+	a.Loop = p.IntWithFallback("anim_loop", a.Loop)
+	a.Budget = int64(p.IntWithFallback("anim_budget_kb", int(a.Budget>>10))) << 10
+	if a.Validate() == nil {
+		c.SetAnim(a)
+	}
 	c.SetShotFormat(p.StringWithFallback("shot_format", c.GetShotFormat()))
 	c.SetShotCursor(p.BoolWithFallback("shot_cursor", c.GetShotCursor()))
+	if q := p.IntWithFallback("shot_quality", -1); q >= 1 && q <= 100 {
+		c.SetShotQuality(q)
+	}
 	if d := p.IntWithFallback("record_delay", -1); d >= 0 && d <= 10 {
 		c.SetRecordDelay(d)
 	}
 	if d := p.IntWithFallback("shot_delay", -1); d >= 0 && d <= 60 {
 		c.SetShotDelay(d)
 	}
+	vd, sd := p.StringWithFallback("video_dir", c.GetVideoDir()), p.StringWithFallback("shot_dir", c.GetShotDir())
+	vt, st := p.StringWithFallback("video_template", c.GetVideoTemplate()), p.StringWithFallback("shot_template", c.GetShotTemplate())
+	if checkOutput(vd, sd, vt, st) == nil {
+		c.SetVideoDir(vd)
+		c.SetShotDir(sd)
+		c.SetVideoTemplate(vt)
+		c.SetShotTemplate(st)
+	}
 }
 
 // persistConfig writes all remembered settings to app preferences.
@@ -164,11 +200,33 @@ func (ui *RecordingUI) persistConfig() {
 	p.SetBool("cursor", c.GetCursor())
 	p.SetString("container", c.GetContainer())
 	p.SetInt("max_dur", c.GetMaxDur())
+	p.SetInt("replay_secs", c.GetReplaySecs())
+	for id, b := range c.GetHotkeys() {
+		p.SetString("hotkey_"+id, b)
+	}
 	p.SetInt("threads", c.GetThreads())
 	p.SetInt("qp", c.GetQP())
 	p.SetInt("nice", c.GetNice())
+	p.SetString("backend", c.GetBackend())
+	p.SetString("codec", c.GetCodec())
+	p.SetString("preset", c.GetPreset())
+	p.SetInt("crf", c.GetCRF())
+	p.SetString("audio_codec", c.GetAudioCodec())
+	p.SetStringList("audio_sources", c.GetAudioSources())
+	p.SetString("audio_tracks", c.GetAudioTracks())
+	a := c.GetAnim()
+	p.SetInt("anim_fps", a.Fps)
+	p.SetInt("anim_width", a.Width)
+	p.SetString("anim_dither", a.Dither)
+	p.SetInt("anim_loop", a.Loop)
+	p.SetInt("anim_budget_kb", int(a.Budget>>10))
 	p.SetString("shot_format", c.GetShotFormat())
 	p.SetBool("shot_cursor", c.GetShotCursor())
+	p.SetInt("shot_quality", c.GetShotQuality())
+	p.SetString("video_dir", c.GetVideoDir())
+	p.SetString("shot_dir", c.GetShotDir())
+	p.SetString("video_template", c.GetVideoTemplate())
+	p.SetString("shot_template", c.GetShotTemplate())
 }
 
 func (ui *RecordingUI) buildMainWindow() {
@@ -218,7 +276,8 @@ func (ui *RecordingUI) buildMainWindow() {
 	ui.pauseBtn.Disable()
 
 	// Recording settings panel
-	ui.audioToggle = widget.NewCheck("Audio", func(b bool) { ui.config.SetAudio(b); ui.persistConfig() })
+	ui.audioPicker = ui.newAudioPicker()
+	ui.audioPicker.SetText(ui.audioSummary())
 	ui.cursorToggle = widget.NewCheck("Show cursor", func(b bool) { ui.config.SetCursor(b); ui.persistConfig() })
 
 	if ui.config.GetRecordDelay() > 10 {
@@ -229,7 +288,7 @@ func (ui *RecordingUI) buildMainWindow() {
 		ui.app.Preferences().SetInt("record_delay", n)
 	})
 
-	ui.containerSelect = widget.NewSelect([]string{"mp4", "mkv", "mov", "avi"}, func(s string) {
+	ui.containerSelect = widget.NewSelect(record.Containers(), func(s string) {
 		ui.config.SetContainer(s)
 		ui.persistConfig()
 		ui.refreshConfigSummary()
@@ -245,7 +304,7 @@ func (ui *RecordingUI) buildMainWindow() {
 		widget.NewLabelWithStyle("Recording", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
 		widget.NewSeparator(),
 		ui.cursorToggle,
-		ui.audioToggle,
+		ui.audioPicker,
 		container.NewBorder(nil, nil, widget.NewLabel("Delay"), nil, recDelayStepper),
 		container.NewBorder(nil, nil, widget.NewLabel("Format"), nil, ui.containerSelect),
 	)
@@ -259,15 +318,21 @@ func (ui *RecordingUI) buildMainWindow() {
 		ui.app.Preferences().SetInt("shot_delay", n)
 	})
 
-	shotFmtSelect := widget.NewSelect([]string{"png", "jpg", "webp", "bmp"}, func(s string) { ui.config.SetShotFormat(s); ui.persistConfig() })
+	shotFmtSelect := widget.NewSelect(shoot.Formats(), func(s string) { ui.config.SetShotFormat(s); ui.persistConfig() })
 	shotFmtSelect.SetSelected(ui.config.GetShotFormat())
 
+	shotQualityStepper := newNumericStepper(ui.config.GetShotQuality(), 1, 100, func(n int) {
+		ui.config.SetShotQuality(n)
+		ui.persistConfig()
+	})
+
 	ui.shotPanel = container.NewVBox(
 		widget.NewLabelWithStyle("Screenshot", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
 		widget.NewSeparator(),
 		shotCursorCheck,
 		container.NewBorder(nil, nil, widget.NewLabel("Delay"), nil, shotDelayStepper),
 		container.NewBorder(nil, nil, widget.NewLabel("Format"), nil, shotFmtSelect),
+		container.NewBorder(nil, nil, widget.NewLabel("Quality"), nil, shotQualityStepper),
 	)
 	ui.shotPanel.Hide()
 
@@ -287,9 +352,20 @@ func (ui *RecordingUI) buildMainWindow() {
 
 	// Top spacer reserves room for the toggle icon (top-right, ~12+28px tall)
 	// so it has its own row and the cards start clear below it.
+	// Profiles come from config.toml, shared with `swiftcap record --profile`.
+	ui.profileSelect = widget.NewSelect(nil, func(s string) {
+		if s == noProfile {
+			s = ""
+		}
+		ui.switchProfile(s)
+	})
+	ui.syncProfileSelect()
+	profileCard := sidebarCard(container.NewBorder(nil, nil, widget.NewLabel("Profile"), nil, ui.profileSelect))
+
 	sidebarInner := container.NewVBox(
 		newHeightSpacer(40),
 		ui.statusCard,
+		profileCard,
 		ui.settingsCard,
 	)
 
@@ -323,15 +399,18 @@ func (ui *RecordingUI) buildMainWindow() {
 	openFolderBtn.Importance = widget.LowImportance
 	settingsBtn := newButtonWithIcon("", theme.SettingsIcon(), func() { ui.showSettings() })
 	settingsBtn.Importance = widget.LowImportance
+	aboutBtn := newButtonWithIcon("", theme.InfoIcon(), func() { ui.showDiagnostics() })
+	aboutBtn.Importance = widget.LowImportance
 
 	mainHeader := container.NewBorder(nil, nil,
 		appTitle,
-		container.NewHBox(openFolderBtn, settingsBtn),
+		container.NewHBox(openFolderBtn, aboutBtn, settingsBtn),
 	)
 
 	seg := NewSegControl([]SegItem{
 		{Icon: theme.FileImageIcon(), Label: "Capture"},
 		{Icon: theme.MediaRecordIcon(), Label: "Record"},
+		{Icon: theme.HistoryIcon(), Label: "Replay"},
 	}, func(idx int) {
 		ui.setMode(idx)
 	})
@@ -341,24 +420,31 @@ func (ui *RecordingUI) buildMainWindow() {
 	ui.actionBtn = newButtonWithIcon("  Take Screenshot", theme.FileImageIcon(), func() {
 		if ui.captureMode == 1 {
 			go ui.handleStart()
+		} else if ui.captureMode == replayMode {
+			if ui.replayRunning() {
+				go ui.handleSaveReplay()
+			} else {
+				go ui.handleStartReplay()
+			}
 		} else {
 			go ui.handleScreenshot()
 		}
 	})
 	ui.actionBtn.Importance = widget.HighImportance
+	ui.replayStopBtn = newButtonWithIcon("  Stop", theme.MediaStopIcon(), func() { go ui.handleStopReplay() })
+	ui.replayStopBtn.Hide()
 
-	videosDir, _ := ui.ensureVideosDir()
-	screenshotsDir, _ := ui.ensureScreenshotsDir()
-	ui.recordingsList = newRecordingsList(videosDir, screenshotsDir, func(path string) {
+	ui.recordingsList = newRecordingsList(ui.captureDirs(), func(path string) {
 		ui.showCaptureViewer(path)
 	})
+	ui.recordingsList.onExport = ui.exportGIF
 
 	// Use Border layout so the recordings list stretches to fill remaining height.
 	mainTop := container.NewVBox(
 		mainHeader,
 		widget.NewSeparator(),
 		container.NewPadded(container.NewPadded(seg)),
-		container.NewPadded(ui.actionBtn),
+		container.NewPadded(container.NewBorder(nil, nil, nil, ui.replayStopBtn, ui.actionBtn)),
 		widget.NewSeparator(),
 		container.NewPadded(
 			widget.NewLabelWithStyle("Recent Captures", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
@@ -399,6 +485,12 @@ func (ui *RecordingUI) setMode(mode int) {
 			ui.actionBtn.SetIcon(theme.MediaRecordIcon())
 			ui.actionBtn.SetText("  Start Recording")
 		}
+	} else if mode == replayMode { // Replay buffer: records with the Record settings
+		ui.recordPanel.Show()
+		ui.shotPanel.Hide()
+		if ui.statusCard != nil {
+			ui.statusCard.Hide()
+		}
 	} else { // Capture (screenshot)
 		ui.recordPanel.Hide()
 		ui.shotPanel.Show()
@@ -410,6 +502,7 @@ func (ui *RecordingUI) setMode(mode int) {
 			ui.actionBtn.SetText("  Take Screenshot")
 		}
 	}
+	ui.syncReplayControls()
 }
 
 func (ui *RecordingUI) toggleSidebar() {
@@ -742,8 +835,8 @@ func (ui *RecordingUI) updateStatusIndicator(recording, paused, flash bool) {
 
 func (ui *RecordingUI) syncQuickControls() {
 	ui.runOnMain(func() {
-		if ui.audioToggle != nil {
-			ui.audioToggle.SetChecked(ui.config.GetAudio())
+		if ui.audioPicker != nil {
+			ui.audioPicker.SetText(ui.audioSummary())
 		}
 		if ui.cursorToggle != nil {
 			ui.cursorToggle.SetChecked(ui.config.GetCursor())
@@ -783,18 +876,11 @@ func (ui *RecordingUI) refreshConfigSummary() {
 }
 
 func (ui *RecordingUI) refreshRecordingsList() {
-	ui.mu.Lock()
-	videosDir := ui.videosDir
-	ui.mu.Unlock()
-
-	if videosDir == "" {
-		videosDir, _ = ui.ensureVideosDir()
-	}
-	screenshotsDir, _ := ui.ensureScreenshotsDir()
+	dirs := ui.captureDirs()
 
 	ui.runOnMain(func() {
 		if ui.recordingsList != nil {
-			ui.recordingsList.refresh(videosDir, screenshotsDir)
+			ui.recordingsList.refresh(dirs...)
 		}
 	})
 }
@@ -814,11 +900,16 @@ func (ui *RecordingUI) restoreMainWindow() {
 
 func (ui *RecordingUI) handleStart() {
 	ui.mu.Lock()
-	if ui.recorderCmd != nil || ui.finalizing {
+	if ui.capturingLocked() || ui.finalizing {
 		ui.mu.Unlock()
 		ui.showInfo("SwiftCap", "Recording already in progress.")
 		return
 	}
+	if ui.replay != nil {
+		ui.mu.Unlock()
+		ui.showInfo("SwiftCap", "Stop the replay buffer before starting a recording.")
+		return
+	}
 	if ui.isPaused {
 		ui.mu.Unlock()
 		ui.showInfo("SwiftCap", "Recording is paused. Use Resume instead.")
@@ -848,9 +939,9 @@ func (ui *RecordingUI) handleStart() {
 	_ = takeScreenshot(screenW, screenH, tmpBg)
 
 	// Show the interactive region selector (blocks until user confirms or cancels).
-	// Recording: only Rectangle (selected area) + Full Screen — no freeform/markup.
+	// Recording: Rectangle, Window and Full Screen — no freeform/markup.
 	region := showSnipOverlay(ui.app, screenW, screenH, tmpBg,
-		[]snipMode{snipRect, snipFullscreen})
+		[]snipMode{snipRect, snipWindow, snipFullscreen})
 	os.Remove(tmpBg)
 
 	if region == "" {
@@ -907,116 +998,42 @@ func (ui *RecordingUI) handleStart() {
 
 func (ui *RecordingUI) handleStop() {
 	ui.mu.Lock()
-	if ui.recorderCmd == nil && !ui.isPaused {
-		if len(ui.segmentFiles) == 0 {
-			ui.mu.Unlock()
-			ui.showInfo("SwiftCap", "No recording in progress.")
-			return
-		}
+	s := ui.session
+	if s == nil {
+		ui.mu.Unlock()
+		ui.showInfo("SwiftCap", "No recording in progress.")
+		return
 	}
 	if ui.finalizing {
 		ui.mu.Unlock()
 		return
 	}
 	ui.finalizing = true
-	done := ui.recorderDone
-	cmd := ui.recorderCmd
-	ui.exitIntent = exitIntentStop
-	ui.mu.Unlock()
-
-	if cmd != nil {
-		sendInterrupt(cmd.Process)
-		if done != nil {
-			select {
-			case <-done:
-			case <-time.After(5 * time.Second):
-				// Timeout - force kill
-				if cmd.Process != nil {
-					cmd.Process.Kill()
-				}
-			}
-		}
-	}
-
-	ui.mu.Lock()
 	ui.stopElapsedTickerLocked()
-	files := append([]string(nil), ui.segmentFiles...)
-	listPath := ui.concatListPath
-	ui.segmentFiles = nil
-	ui.concatListPath = ""
-	ui.recorderCmd = nil
-	ui.recorderCancel = nil
-	ui.recorderDone = nil
-	ui.isPaused = false
-	ui.playIconTimer = 0
-	ui.exitIntent = exitIntentNone
 	ui.mu.Unlock()
 
-	if len(files) == 0 {
-		ui.mu.Lock()
-		ui.finalizing = false
-		ui.mu.Unlock()
-		ui.setStatus("Ready")
-		ui.refreshUI()
-		return
-	}
-
 	ui.setStatus("Finalizing recording...")
 	ui.refreshUI()
-	
-	finalPath, err := ui.concatSegments(files, listPath)
-	ui.mu.Lock()
-	ui.finalizing = false
-	ui.mu.Unlock()
-
-	if err != nil {
-		ui.showError("SwiftCap", fmt.Sprintf("Failed to finalize recording: %v", err))
-		ui.setStatus("Ready")
-		ui.refreshUI()
-		return
-	}
-	
-	ui.setStatus("Recording saved")
-	
-	// Refresh recordings list
-	ui.refreshRecordingsList()
-	
-	// Show window after recording finishes
-	ui.runOnMain(func() {
-		if ui.mainWin != nil {
-			ui.mu.Lock()
-			ui.windowVisible = true
-			ui.mu.Unlock()
-			ui.mainWin.Show()
-			ui.mainWin.RequestFocus()
-			ui.updateTray()
-		}
-	})
-	
-	ui.showPreviewModal(finalPath, false)
-	ui.refreshUI()
+	ui.finishRecording(s, s.Stop())
 }
 
 func (ui *RecordingUI) handlePause() {
 	ui.mu.Lock()
-	if ui.recorderCmd == nil || ui.isPaused {
+	s := ui.session
+	if !ui.capturingLocked() {
 		ui.mu.Unlock()
 		return
 	}
-	ui.exitIntent = exitIntentPause
-	done := ui.recorderDone
-	cmd := ui.recorderCmd
 	ui.mu.Unlock()
 
-	sendInterrupt(cmd.Process)
-	if done != nil {
-		<-done
+	if err := s.Pause(); err != nil {
+		ui.showError("SwiftCap", fmt.Sprintf("Failed to pause recording: %v", err))
+		return
 	}
 
 	ui.mu.Lock()
 	ui.isPaused = true
 	ui.stopElapsedTickerLocked()
-	ui.exitIntent = exitIntentNone
 	ui.mu.Unlock()
 	ui.setStatus("Recording paused")
 	ui.refreshUI()
@@ -1024,41 +1041,17 @@ func (ui *RecordingUI) handlePause() {
 
 func (ui *RecordingUI) handleResume() {
 	ui.mu.Lock()
-	if !ui.isPaused {
+	s := ui.session
+	if !ui.isPaused || ui.finalizing {
 		ui.mu.Unlock()
 		return
 	}
-	nextIndex := ui.segmentIndex + 1
-	ui.mu.Unlock()
-
-	dir, err := ui.ensureVideosDir()
-	if err != nil {
-		ui.showError("SwiftCap", fmt.Sprintf("Failed to access videos directory: %v", err))
-		return
-	}
-
-	segmentPath := filepath.Join(dir, fmt.Sprintf("swiftcap_segment_%d.mp4", nextIndex))
-	absSeg, err := filepath.Abs(segmentPath)
-	if err != nil {
-		absSeg = segmentPath
-	}
-
-	ui.mu.Lock()
-	if !ui.isPaused {
-		ui.mu.Unlock()
-		return
-	}
-	ui.segmentIndex = nextIndex
-	ui.segmentFiles = append(ui.segmentFiles, absSeg)
 	ui.mu.Unlock()
 
 	ui.setStatus("Resuming recording...")
 	ui.refreshUI()
-	
-	if err := ui.launchRecorder(absSeg); err != nil {
-		ui.mu.Lock()
-		ui.isPaused = true
-		ui.mu.Unlock()
+
+	if err := s.Resume(); err != nil {
 		ui.showError("SwiftCap", fmt.Sprintf("Failed to resume recording: %v", err))
 		ui.setStatus("Paused")
 		ui.refreshUI()
@@ -1068,160 +1061,48 @@ func (ui *RecordingUI) handleResume() {
 	ui.mu.Lock()
 	ui.isPaused = false
 	ui.playIconTimer = 5
+	ui.startElapsedTickerLocked()
 	ui.mu.Unlock()
 	ui.setStatus("Recording...")
 	ui.refreshUI()
 }
 
+// capturingLocked is whether a capture run is going: a recording that's
+// neither paused nor finishing. ui.mu must be held.
+func (ui *RecordingUI) capturingLocked() bool {
+	return ui.session != nil && !ui.isPaused && !ui.finalizing
+}
+
 func (ui *RecordingUI) cancelPendingRecording() {
 	ui.setStatus("Ready")
 	ui.refreshUI()
 }
 
-
+// startRecording records with the current settings into a file named by
+// the recordings template. Recordings are always pausable, so they go into
+// segments that are joined when they stop.
 func (ui *RecordingUI) startRecording() {
 	ui.setStatus("Starting recording...")
 	ui.refreshUI()
-	
-	segmentPath, err := ui.initialSegmentPath()
+
+	out, err := ui.outputPath("recording", ui.config.GetRegion(), ui.config.GetContainer())
 	if err != nil {
 		ui.showError("SwiftCap", fmt.Sprintf("Failed to prepare recording: %v", err))
 		ui.setStatus("Ready")
 		ui.refreshUI()
 		return
 	}
-	
-	if err := ui.launchRecorder(segmentPath); err != nil {
+
+	s, err := capture.StartRecording(context.Background(), ui.recordOptions(out))
+	if err != nil {
 		ui.showError("SwiftCap", fmt.Sprintf("Failed to start recording: %v", err))
-		ui.mu.Lock()
-		ui.recorderCmd = nil
-		ui.recorderCancel = nil
-		ui.recorderDone = nil
-		ui.mu.Unlock()
 		ui.setStatus("Ready")
 		ui.refreshUI()
 		return
 	}
-	
-	ui.setStatus("Recording...")
-	ui.refreshUI()
-}
-
-func (ui *RecordingUI) initialSegmentPath() (string, error) {
-	dir, err := ui.ensureVideosDir()
-	if err != nil {
-		return "", err
-	}
-	now := time.Now().UnixNano()
-	seg := filepath.Join(dir, fmt.Sprintf("swiftcap_%d_segment_1.mp4", now))
-	absSeg, err := filepath.Abs(seg)
-	if err != nil {
-		absSeg = seg
-	}
-	ui.mu.Lock()
-	ui.segmentIndex = 1
-	ui.segmentFiles = []string{absSeg}
-	ui.concatListPath = filepath.Join(dir, fmt.Sprintf("swiftcap_concat_%d.txt", now))
-	ui.mu.Unlock()
-	return absSeg, nil
-}
-
-func (ui *RecordingUI) launchRecorder(outPath string) error {
-	cli, err := ui.resolveCLIBinary()
-	if err != nil {
-		return err
-	}
-
-	args := []string{"record", "--out", outPath}
-
-	// Audio
-	if ui.config.GetAudio() {
-		args = append(args, "--audio", "on")
-	} else {
-		args = append(args, "--audio", "off")
-	}
-
-	// Cursor
-	if ui.config.GetCursor() {
-		args = append(args, "--cursor", "on")
-	} else {
-		args = append(args, "--cursor", "off")
-	}
-
-	// FPS
-	if fps := ui.config.GetFPS(); fps > 0 {
-		args = append(args, "--fps", fmt.Sprintf("%d", fps))
-	}
-
-	// Bitrate
-	if bitrate := ui.config.GetBitrate(); bitrate > 0 {
-		args = append(args, "--bitrate", fmt.Sprintf("%d", bitrate))
-	}
-
-	// Container
-	if container := ui.config.GetContainer(); container != "" {
-		args = append(args, "--container", container)
-	}
-
-	// Region
-	region := ui.config.GetRegion()
-	if region == "" {
-		region = ui.detectRegion()
-	}
-	if region != "" {
-		args = append(args, "--region", region)
-		ui.mu.Lock()
-		ui.activeRegion = region
-		ui.mu.Unlock()
-	}
-
-	// Max Duration
-	if maxDur := ui.config.GetMaxDur(); maxDur > 0 {
-		args = append(args, "--max-dur", fmt.Sprintf("%d", maxDur))
-	}
-
-	// Threads
-	if threads := ui.config.GetThreads(); threads > 0 {
-		args = append(args, "--threads", fmt.Sprintf("%d", threads))
-	}
-
-	// QP
-	if qp := ui.config.GetQP(); qp > 0 {
-		args = append(args, "--qp", fmt.Sprintf("%d", qp))
-	}
-
-	// Nice
-	if nice := ui.config.GetNice(); nice != 0 {
-		args = append(args, "--nice", fmt.Sprintf("%d", nice))
-	}
-
-	// Ensure we have an absolute path
-	absCli, err := filepath.Abs(cli)
-	if err != nil {
-		absCli = cli
-	}
-	
-	// Verify the file exists and is executable before trying to run it
-	if _, err := os.Stat(absCli); err != nil {
-		return fmt.Errorf("swiftcap CLI binary not found at: %s", absCli)
-	}
-	
-	ctx, cancel := context.WithCancel(context.Background())
-	cmd := exec.CommandContext(ctx, absCli, args...)
-	var stderrBuf bytes.Buffer
-	cmd.Stderr = &stderrBuf
-
-	if err := cmd.Start(); err != nil {
-		cancel()
-		return fmt.Errorf("failed to start swiftcap CLI: %w", err)
-	}
 
 	ui.mu.Lock()
-	ui.recorderCmd = cmd
-	ui.recorderCancel = cancel
-	ui.recorderDone = make(chan struct{})
-	ui.recorderStderr = &stderrBuf
-	ui.exitIntent = exitIntentNone
+	ui.session = s
 	ui.elapsedSeconds = 0
 	ui.startElapsedTickerLocked()
 	ui.mu.Unlock()
@@ -1229,153 +1110,110 @@ func (ui *RecordingUI) launchRecorder(outPath string) error {
 	// Update tray immediately so it shows recording state without waiting for first tick
 	ui.updateTray()
 
-	go ui.monitorRecorder(cmd)
-	return nil
+	go ui.watchRecording(s)
+	ui.setStatus("Recording...")
+	ui.refreshUI()
 }
 
-func (ui *RecordingUI) monitorRecorder(cmd *exec.Cmd) {
-	err := cmd.Wait()
-	ui.mu.Lock()
-	done := ui.recorderDone
-	intent := ui.exitIntent
-	ui.exitIntent = exitIntentNone
-	ui.recorderCmd = nil
-	ui.recorderCancel = nil
-	ui.recorderDone = nil
-	ui.lastRecorderErr = err
-	cliStderr := ""
-	if ui.recorderStderr != nil {
-		cliStderr = stripANSI(strings.TrimSpace(ui.recorderStderr.String()))
-		ui.recorderStderr = nil
-	}
-	ui.mu.Unlock()
-	if done != nil {
-		close(done)
+// recordOptions is a recording to out with the current settings. With no
+// region set it covers the monitor under the pointer.
+func (ui *RecordingUI) recordOptions(out string) capture.RecordOptions {
+	c := ui.config
+	region := c.GetRegion()
+	if region == "" {
+		region = ui.detectRegion()
 	}
-	if intent == exitIntentNone {
-		ui.mu.Lock()
-		ui.stopElapsedTickerLocked()
-		ui.isPaused = false
-		ui.mu.Unlock()
-
-		// Always show the main window so the user sees any error dialog
-		ui.runOnMain(func() {
-			if ui.mainWin != nil {
-				ui.mu.Lock()
-				ui.windowVisible = true
-				ui.mu.Unlock()
-				ui.mainWin.Show()
-				ui.mainWin.RequestFocus()
-				ui.updateTray()
-			}
-		})
-
-		if err != nil {
-			if err.Error() != "signal: interrupt" && err.Error() != "exit status 1" {
-				msg := fmt.Sprintf("Recording ended unexpectedly: %v", err)
-				if cliStderr != "" {
-					// Surface the first meaningful line from the CLI/ffmpeg output
-					for _, line := range strings.Split(cliStderr, "\n") {
-						line = strings.TrimSpace(line)
-						if line != "" && !strings.HasPrefix(line, "frame=") {
-							msg += "\n\n" + line
-							break
-						}
-					}
-				}
-				ui.showError("SwiftCap", msg)
+	target := capture.FullScreen()
+	if r, err := capture.ParseRegion(region); err == nil {
+		target = capture.Region(r)
+	}
+	a := c.GetAnim()
+	o := capture.RecordOptions{
+		Target:       target,
+		Out:          out,
+		Backend:      c.GetBackend(),
+		Pausable:     true,
+		FPS:          c.GetFPS(),
+		Cursor:       c.GetCursor(),
+		MaxDuration:  time.Duration(c.GetMaxDur()) * time.Second,
+		Container:    c.GetContainer(),
+		Codec:        c.GetCodec(),
+		Preset:       c.GetPreset(),
+		QP:           c.GetQP(),
+		Bitrate:      c.GetBitrate(),
+		Threads:      c.GetThreads(),
+		Nice:         c.GetNice(),
+		Audio:        c.GetAudio(),
+		AudioSources: c.GetAudioSources(),
+		AudioTracks:  c.GetAudioTracks(),
+		AudioCodec:   c.GetAudioCodec(),
+		Animation:    &capture.Animation{FPS: a.Fps, Width: a.Width, Dither: a.Dither, Loop: a.Loop, SizeBudget: a.Budget},
+	}
+	if crf := c.GetCRF(); crf >= 0 {
+		o.CRF = &crf
+	}
+	return o
+}
+
+// watchRecording follows s until it stops. Stops the user didn't ask for,
+// like reaching the maximum duration or the capture failing, are finished
+// here; handleStop finishes the rest.
+func (ui *RecordingUI) watchRecording(s *capture.Session) {
+	for e := range s.Events() {
+		switch e.Kind {
+		case capture.EventExporting:
+			ui.setStatus(fmt.Sprintf("Exporting %s...", e.Message))
+		case capture.EventWarning:
+			ui.showInfo("SwiftCap", e.Message)
+		case capture.EventStopped:
+			if e.Reason != capture.StopRequested {
+				ui.finishRecording(s, e.Err)
 			}
 		}
-		ui.setStatus("Ready")
-		ui.refreshUI()
 	}
 }
 
-func (ui *RecordingUI) concatSegments(files []string, listPath string) (string, error) {
-	if len(files) == 0 {
-		return "", errors.New("no recorded segments to merge")
+// finishRecording clears s once it has stopped and shows the file, or why
+// there isn't one.
+func (ui *RecordingUI) finishRecording(s *capture.Session, err error) {
+	ui.mu.Lock()
+	if ui.session != s {
+		ui.mu.Unlock()
+		return
 	}
-	
-	// Verify all segments exist before proceeding
-	missing := []string{}
-	for _, seg := range files {
-		if _, err := os.Stat(seg); err != nil {
-			missing = append(missing, seg)
+	ui.session = nil
+	ui.isPaused = false
+	ui.finalizing = false
+	ui.playIconTimer = 0
+	ui.stopElapsedTickerLocked()
+	ui.mu.Unlock()
+
+	// Always show the main window so the user sees the file or the error
+	ui.runOnMain(func() {
+		if ui.mainWin != nil {
+			ui.mu.Lock()
+			ui.windowVisible = true
+			ui.mu.Unlock()
+			ui.mainWin.Show()
+			ui.mainWin.RequestFocus()
+			ui.updateTray()
 		}
-	}
-	if len(missing) > 0 {
-		return "", fmt.Errorf("missing segment files: %v", missing)
-	}
-	
-	dir, err := ui.ensureVideosDir()
-	if err != nil {
-		return "", fmt.Errorf("failed to access videos directory: %w", err)
-	}
-	
-	if listPath == "" {
-		listPath = filepath.Join(dir, fmt.Sprintf("swiftcap_concat_%d.txt", time.Now().UnixNano()))
-	}
-	
-	f, err := os.Create(listPath)
+	})
+
 	if err != nil {
-		return "", fmt.Errorf("failed to create concat list: %w", err)
-	}
-	defer f.Close()
-	
-	for _, seg := range files {
-		absPath, err := filepath.Abs(seg)
-		if err != nil {
-			absPath = seg
-		}
-		if _, writeErr := fmt.Fprintf(f, "file '%s'\n", absPath); writeErr != nil {
-			return "", fmt.Errorf("failed to write concat list: %w", writeErr)
-		}
-	}
-	
-	if err := f.Close(); err != nil {
-		return "", fmt.Errorf("failed to close concat list: %w", err)
+		ui.showError("SwiftCap", fmt.Sprintf("Recording failed: %v", err))
+		ui.setStatus("Ready")
+		ui.refreshUI()
+		return
 	}
 
-	cont := ui.config.GetContainer()
-	if cont == "" {
-		cont = "mp4"
-	}
-	var fmtFlag string
-	switch cont {
-	case "mkv":
-		fmtFlag = "matroska"
-	case "mov":
-		fmtFlag = "mov"
-	case "avi":
-		fmtFlag = "avi"
-	default:
-		fmtFlag = "mp4"
-	}
-	out := filepath.Join(dir, fmt.Sprintf("recording_%s.%s", time.Now().Format("20060102_150405"), cont))
-	var ffmpegOut bytes.Buffer
-	cmd := exec.Command("ffmpeg", "-y", "-loglevel", "error", "-f", "concat", "-safe", "0", "-i", listPath, "-c", "copy", "-f", fmtFlag, out)
-	cmd.Stdout = &ffmpegOut
-	cmd.Stderr = &ffmpegOut
-	
-	if err := cmd.Run(); err != nil {
-		// Clean up list file on error
-		os.Remove(listPath)
-		return "", fmt.Errorf("ffmpeg failed to merge segments: %w\nOutput: %s", err, ffmpegOut.String())
-	}
-	
-	// Verify output file was created
-	if _, err := os.Stat(out); err != nil {
-		os.Remove(listPath)
-		return "", fmt.Errorf("output file was not created: %w", err)
-	}
-	
-	// Clean up segment files and list file
-	for _, seg := range files {
-		os.Remove(seg)
-	}
-	os.Remove(listPath)
-	
-	return out, nil
+	ui.setStatus("Recording saved")
+
+	// Refresh recordings list
+	ui.refreshRecordingsList()
+
+	ui.showPreviewModal(s.Out(), false)
+	ui.refreshUI()
 }
 
 func (ui *RecordingUI) ensureVideosDir() (string, error) {
@@ -1387,7 +1225,10 @@ func (ui *RecordingUI) ensureVideosDir() (string, error) {
 	}
 	ui.mu.Unlock()
 
-	dir := os.Getenv("SWIFTCAP_VIDEOS_DIR")
+	dir := expandHome(ui.config.GetVideoDir())
+	if dir == "" {
+		dir = os.Getenv("SWIFTCAP_VIDEOS_DIR")
+	}
 	if dir == "" {
 		if xdg := lookupXDGVideos(); xdg != "" {
 			dir = xdg
@@ -1436,7 +1277,10 @@ func lookupXDGPictures() string {
 }
 
 func (ui *RecordingUI) ensureScreenshotsDir() (string, error) {
-	dir := os.Getenv("SWIFTCAP_SCREENSHOTS_DIR")
+	dir := expandHome(ui.config.GetShotDir())
+	if dir == "" {
+		dir = os.Getenv("SWIFTCAP_SCREENSHOTS_DIR")
+	}
 	if dir == "" {
 		if xdg := lookupXDGPictures(); xdg != "" {
 			dir = xdg
@@ -1455,137 +1299,6 @@ func (ui *RecordingUI) ensureScreenshotsDir() (string, error) {
 	return dir, nil
 }
 
-func (ui *RecordingUI) resolveCLIBinary() (string, error) {
-	ui.mu.Lock()
-	if ui.cliPath != "" {
-		path := ui.cliPath
-		ui.mu.Unlock()
-		if fileExists(path) && isExecutable(path) {
-			absPath, _ := filepath.Abs(path)
-			return absPath, nil
-		}
-		// Cached path no longer exists, clear it
-		ui.mu.Lock()
-		ui.cliPath = ""
-		ui.mu.Unlock()
-	}
-	ui.mu.Unlock()
-
-	// Helper to check and return absolute path
-	checkAndReturn := func(candidate string) (string, bool) {
-		if runtime.GOOS == "windows" && !strings.HasSuffix(candidate, ".exe") {
-			candidate += ".exe"
-		}
-		if !fileExists(candidate) {
-			return "", false
-		}
-		if !isExecutable(candidate) {
-			return "", false
-		}
-		absPath, err := filepath.Abs(candidate)
-		if err != nil {
-			return candidate, true
-		}
-		return absPath, true
-	}
-
-	// 1. Check environment variable
-	if env := os.Getenv("SWIFTCAP_CLI_PATH"); env != "" {
-		if path, ok := checkAndReturn(env); ok {
-			ui.mu.Lock()
-			ui.cliPath = path
-			ui.mu.Unlock()
-			return path, nil
-		}
-	}
-
-	// 2. Check same directory as UI executable
-	if exe, err := os.Executable(); err == nil {
-		exeDir := filepath.Dir(exe)
-		if path, ok := checkAndReturn(filepath.Join(exeDir, "swiftcap")); ok {
-			ui.mu.Lock()
-			ui.cliPath = path
-			ui.mu.Unlock()
-			return path, nil
-		}
-	}
-
-	// 3. Check current working directory
-	if wd, err := os.Getwd(); err == nil {
-		if path, ok := checkAndReturn(filepath.Join(wd, "swiftcap")); ok {
-			ui.mu.Lock()
-			ui.cliPath = path
-			ui.mu.Unlock()
-			return path, nil
-		}
-	}
-
-	// 4. Check cmd/swiftcap directory (common build location)
-	if exe, err := os.Executable(); err == nil {
-		exeDir := filepath.Dir(exe)
-		// Try cmd/swiftcap/swiftcap relative to executable
-		candidate := filepath.Join(exeDir, "cmd", "swiftcap", "swiftcap")
-		if path, ok := checkAndReturn(candidate); ok {
-			ui.mu.Lock()
-			ui.cliPath = path
-			ui.mu.Unlock()
-			return path, nil
-		}
-		// Try parent/cmd/swiftcap/swiftcap
-		parent := filepath.Dir(exeDir)
-		candidate = filepath.Join(parent, "cmd", "swiftcap", "swiftcap")
-		if path, ok := checkAndReturn(candidate); ok {
-			ui.mu.Lock()
-			ui.cliPath = path
-			ui.mu.Unlock()
-			return path, nil
-		}
-		// Try parent/swiftcap
-		candidate = filepath.Join(parent, "swiftcap")
-		if path, ok := checkAndReturn(candidate); ok {
-			ui.mu.Lock()
-			ui.cliPath = path
-			ui.mu.Unlock()
-			return path, nil
-		}
-	}
-
-	// 5. Check $PATH
-	if inPath, err := exec.LookPath("swiftcap"); err == nil {
-		if path, ok := checkAndReturn(inPath); ok {
-			ui.mu.Lock()
-			ui.cliPath = path
-			ui.mu.Unlock()
-			return path, nil
-		}
-	}
-
-	// Build helpful error message
-	var suggestions []string
-	if exe, err := os.Executable(); err == nil {
-		dir := filepath.Dir(exe)
-		suggestions = append(suggestions, fmt.Sprintf("  - Place 'swiftcap' in: %s", dir))
-	}
-	if wd, err := os.Getwd(); err == nil {
-		suggestions = append(suggestions, fmt.Sprintf("  - Place 'swiftcap' in: %s", wd))
-	}
-	suggestions = append(suggestions, "  - Set SWIFTCAP_CLI_PATH environment variable")
-	suggestions = append(suggestions, "  - Build CLI: go build ./cmd/swiftcap")
-	
-	msg := "swiftcap CLI binary not found.\n\nTry:\n" + strings.Join(suggestions, "\n")
-	return "", errors.New(msg)
-}
-
-func isExecutable(path string) bool {
-	info, err := os.Stat(path)
-	if err != nil {
-		return false
-	}
-	// Check if it's a regular file and has execute permissions
-	mode := info.Mode()
-	return mode.IsRegular() && (mode&0111 != 0)
-}
-
 func fileExists(path string) bool {
 	if path == "" {
 		return false
@@ -1594,20 +1307,15 @@ func fileExists(path string) bool {
 	return err == nil
 }
 
+// detectRegion returns the geometry of the monitor under the pointer, so a
+// "full screen" recording covers one display rather than the whole
+// multi-monitor canvas.
 func (ui *RecordingUI) detectRegion() string {
-	out, err := exec.Command("xdpyinfo").Output()
+	m, err := x11.ResolveMonitor("focused")
 	if err != nil {
 		return ""
 	}
-	scanner := bufio.NewScanner(bytes.NewReader(out))
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		var w, h int
-		if _, scanErr := fmt.Sscanf(line, "dimensions: %dx%d pixels", &w, &h); scanErr == nil {
-			return fmt.Sprintf("%dx%d+0+0", w, h)
-		}
-	}
-	return ""
+	return m.Region()
 }
 
 func (ui *RecordingUI) startElapsedTickerLocked() {
@@ -1640,7 +1348,7 @@ func (ui *RecordingUI) stopElapsedTickerLocked() {
 
 func (ui *RecordingUI) incrementElapsed() {
 	ui.mu.Lock()
-	if ui.recorderCmd != nil && !ui.isPaused {
+	if ui.capturingLocked() {
 		ui.elapsedSeconds++
 		if ui.playIconTimer > 0 {
 			ui.playIconTimer--
@@ -1649,7 +1357,7 @@ func (ui *RecordingUI) incrementElapsed() {
 		}
 	}
 	elapsed := ui.elapsedSeconds
-	recording := ui.recorderCmd != nil
+	recording := ui.capturingLocked()
 	paused := ui.isPaused
 	flash := ui.flashState
 	playTimer := ui.playIconTimer > 0
@@ -1692,7 +1400,7 @@ func formatElapsed(seconds int) string {
 
 func (ui *RecordingUI) refreshUI() {
 	ui.mu.Lock()
-	recording := ui.recorderCmd != nil
+	recording := ui.capturingLocked()
 	paused := ui.isPaused
 	finalizing := ui.finalizing
 	flash := ui.flashState
@@ -1761,7 +1469,7 @@ func (ui *RecordingUI) refreshUI() {
 
 func (ui *RecordingUI) updateTray() {
 	ui.mu.Lock()
-	recording := ui.recorderCmd != nil
+	recording := ui.capturingLocked()
 	paused := ui.isPaused
 	elapsed := ui.elapsedSeconds
 	flash := ui.flashState
@@ -1843,7 +1551,24 @@ func (ui *RecordingUI) buildTrayMenu(recording, paused bool, elapsed int, finali
 		shot.Icon = theme.MediaPhotoIcon()
 		rec := fyne.NewMenuItem("Start Recording", func() { go ui.handleStart() })
 		rec.Icon = theme.MediaRecordIcon()
-		items = []*fyne.MenuItem{shot, rec, sep, showHide, quit}
+		items = []*fyne.MenuItem{shot, rec}
+		if ui.replayRunning() {
+			save := fyne.NewMenuItem("Save Replay", func() { go ui.handleSaveReplay() })
+			save.Icon = theme.DocumentSaveIcon()
+			stop := fyne.NewMenuItem("Stop Replay Buffer", func() { go ui.handleStopReplay() })
+			stop.Icon = theme.MediaStopIcon()
+			items = append(items, sep, save, stop)
+		} else {
+			replay := fyne.NewMenuItem("Start Replay Buffer", func() { go ui.handleStartReplay() })
+			replay.Icon = theme.HistoryIcon()
+			items = append(items, replay)
+		}
+		if profiles := ui.profileMenu(); profiles != nil {
+			items = append(items, sep, profiles)
+		}
+		diag := fyne.NewMenuItem("Diagnostics…", ui.diagnosticsFromTray)
+		diag.Icon = theme.InfoIcon()
+		items = append(items, sep, diag, showHide, quit)
 	}
 
 	return fyne.NewMenu("SwiftCap", items...)
@@ -1922,7 +1647,7 @@ func (ui *RecordingUI) handleScreenshot() {
 	// Show the snipping overlay (blocks until user selects or cancels).
 	// Screenshot: full mode set including the beta Markup annotation tool.
 	region := showSnipOverlay(ui.app, screenW, screenH, tmpFile,
-		[]snipMode{snipRect, snipFreeform, snipFullscreen, snipMarkup})
+		[]snipMode{snipRect, snipFreeform, snipWindow, snipFullscreen, snipMarkup})
 	os.Remove(tmpFile)
 
 	if region == "" {
@@ -1987,7 +1712,8 @@ func (ui *RecordingUI) handleScreenshot() {
 func (ui *RecordingUI) saveMarkupCapture(tmpPath string) {
 	defer os.Remove(tmpPath)
 
-	dir, err := ui.ensureScreenshotsDir()
+	opts := ui.shotOptions()
+	outPath, err := ui.outputPath("markup", "", shoot.Ext(opts.Format))
 	if err != nil {
 		ui.runOnMain(func() {
 			if ui.mainWin != nil {
@@ -2001,27 +1727,19 @@ func (ui *RecordingUI) saveMarkupCapture(tmpPath string) {
 		return
 	}
 
-	ext := ui.config.GetShotFormat()
-	if ext == "" {
-		ext = "png"
+	f, err := os.Open(tmpPath)
+	if err != nil {
+		ui.showError("Screenshot", fmt.Sprintf("Failed to save markup: %v", err))
+		return
 	}
-	outPath := filepath.Join(dir, fmt.Sprintf("swiftcap_markup_%d.%s", time.Now().UnixNano(), ext))
-
-	if ext == "png" {
-		if err := os.Rename(tmpPath, outPath); err != nil {
-			// Cross-device rename — fall through to ffmpeg copy
-			cmd := exec.Command("ffmpeg", "-y", "-i", tmpPath, outPath)
-			if err2 := cmd.Run(); err2 != nil {
-				ui.showError("Screenshot", fmt.Sprintf("Failed to save markup: %v", err2))
-				return
-			}
-		}
-	} else {
-		cmd := exec.Command("ffmpeg", "-y", "-i", tmpPath, outPath)
-		if err := cmd.Run(); err != nil {
-			ui.showError("Screenshot", fmt.Sprintf("Failed to convert markup: %v", err))
-			return
-		}
+	img, err := png.Decode(f)
+	f.Close()
+	if err == nil {
+		err = shoot.Save(outPath, img, opts)
+	}
+	if err != nil {
+		ui.showError("Screenshot", fmt.Sprintf("Failed to save markup: %v", err))
+		return
 	}
 
 	ui.runOnMain(func() {
@@ -2038,8 +1756,18 @@ func (ui *RecordingUI) saveMarkupCapture(tmpPath string) {
 	notifyScreenshotSaved(outPath)
 }
 
+// shotOptions reads the screenshot encoding settings.
+func (ui *RecordingUI) shotOptions() shoot.Options {
+	f := ui.config.GetShotFormat()
+	if f == "" {
+		f = "png"
+	}
+	return shoot.Options{Format: f, Quality: ui.config.GetShotQuality()}
+}
+
 func (ui *RecordingUI) captureScreenshotWithRegion(region string) {
-	dir, err := ui.ensureScreenshotsDir()
+	opts := ui.shotOptions()
+	path, err := ui.outputPath("screenshot", region, shoot.Ext(opts.Format))
 	if err != nil {
 		ui.runOnMain(func() {
 			if ui.mainWin != nil {
@@ -2053,52 +1781,15 @@ func (ui *RecordingUI) captureScreenshotWithRegion(region string) {
 		return
 	}
 
-	ext := ui.config.GetShotFormat()
-	if ext == "" {
-		ext = "png"
+	target := capture.FullScreen()
+	if r, err := capture.ParseRegion(region); err == nil {
+		target = capture.Region(r)
 	}
-	path := filepath.Join(dir, fmt.Sprintf("swiftcap_%d.%s", time.Now().UnixNano(), ext))
-
-	display := os.Getenv("DISPLAY")
-	if display == "" {
-		display = ":0.0"
+	img, err := capture.Screenshot(context.Background(), capture.ScreenshotOptions{Target: target, Cursor: ui.config.GetShotCursor()})
+	if err == nil {
+		err = shoot.Save(path, img, opts)
 	}
-
-	cursor := ui.config.GetShotCursor()
-
-	var args []string
-	args = append(args, "-y")
-
-	var vidW, vidH, vidX, vidY int
-	hasRegion := false
-	if region != "" {
-		if n, _ := fmt.Sscanf(region, "%dx%d+%d+%d", &vidW, &vidH, &vidX, &vidY); n == 4 {
-			hasRegion = true
-			if vidW%2 != 0 {
-				vidW--
-			}
-			if vidH%2 != 0 {
-				vidH--
-			}
-			args = append(args, "-video_size", fmt.Sprintf("%dx%d", vidW, vidH))
-		}
-	}
-
-	args = append(args, "-f", "x11grab")
-	if cursor {
-		args = append(args, "-draw_mouse", "1")
-	} else {
-		args = append(args, "-draw_mouse", "0")
-	}
-
-	input := display
-	if hasRegion {
-		input = fmt.Sprintf("%s+%d,%d", display, vidX, vidY)
-	}
-	args = append(args, "-i", input, "-vframes", "1", path)
-
-	cmd := exec.Command("ffmpeg", args...)
-	if err := cmd.Run(); err != nil {
+	if err != nil {
 		ui.runOnMain(func() {
 			if ui.mainWin != nil {
 				ui.mu.Lock()
@@ -2139,34 +1830,3 @@ func notifyScreenshotSaved(path string) {
 }
 
 var sidebarBgColor = color.NRGBA{0x24, 0x24, 0x24, 0xff}
-
-// stripANSI removes ANSI terminal escape sequences from s.
-func stripANSI(s string) string {
-	var out strings.Builder
-	i := 0
-	for i < len(s) {
-		if s[i] == '\033' && i+1 < len(s) && s[i+1] == '[' {
-			// Skip until we hit the final byte of the escape sequence (a letter)
-			i += 2
-			for i < len(s) && (s[i] < 'A' || s[i] > 'Z') && (s[i] < 'a' || s[i] > 'z') {
-				i++
-			}
-			i++ // skip the final letter
-		} else {
-			out.WriteByte(s[i])
-			i++
-		}
-	}
-	return out.String()
-}
-
-func sendInterrupt(proc *os.Process) {
-	if proc == nil {
-		return
-	}
-	if runtime.GOOS == "windows" {
-		_ = proc.Signal(os.Kill)
-		return
-	}
-	_ = proc.Signal(syscall.SIGINT)
-}
@@ -0,0 +1,174 @@
+package uiapp
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/almightynan/swiftcap/internal/pulse"
+)
+
+// audioChoice is one source the audio picker offers.
+type audioChoice struct {
+	name  string // PulseAudio source name
+	label string
+}
+
+// audioChoices lists the desktop and mic aliases, which follow whatever the
+// system's default devices are, followed by every source the sound server
+// reports.
+func audioChoices() []audioChoice {
+	out := []audioChoice{
+		{name: pulse.AliasDesktop, label: audioLabel(pulse.AliasDesktop)},
+		{name: pulse.AliasMic, label: audioLabel(pulse.AliasMic)},
+	}
+	srcs, _, err := pulse.ListSources()
+	if err != nil {
+		return out
+	}
+	for _, s := range srcs {
+		label := s.Description
+		if label == "" {
+			label = s.Name
+		}
+		out = append(out, audioChoice{name: s.Name, label: label})
+	}
+	return out
+}
+
+// audioLabel names a picked source for people.
+func audioLabel(name string) string {
+	switch name {
+	case pulse.AliasDesktop:
+		return "Desktop audio"
+	case pulse.AliasMic:
+		return "Microphone"
+	case "default":
+		return "Default input"
+	}
+	return name
+}
+
+// newAudioPicker builds the button that replaces the old Audio checkbox: it
+// shows what will be recorded and opens a menu to pick any number of
+// sources, or none.
+func (ui *RecordingUI) newAudioPicker() *widget.Button {
+	btn := widget.NewButtonWithIcon("", theme.VolumeUpIcon(), nil)
+	btn.Alignment = widget.ButtonAlignLeading
+	btn.Importance = widget.LowImportance
+	btn.OnTapped = func() {
+		if ui.mainWin == nil {
+			return
+		}
+		menu := fyne.NewMenu("", ui.audioMenuItems()...)
+		c := ui.mainWin.Canvas()
+		pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(btn)
+		widget.ShowPopUpMenuAtPosition(menu, c, pos.Add(fyne.NewPos(0, btn.Size().Height)))
+	}
+	return btn
+}
+
+func (ui *RecordingUI) audioMenuItems() []*fyne.MenuItem {
+	c := ui.config
+	on := c.GetAudio()
+	picked := make(map[string]bool)
+	for _, s := range c.GetAudioSources() {
+		picked[s] = true
+	}
+	changed := func() {
+		ui.persistConfig()
+		ui.syncQuickControls()
+	}
+
+	off := fyne.NewMenuItem("No audio", func() {
+		c.SetAudio(false)
+		changed()
+	})
+	off.Checked = !on
+	items := []*fyne.MenuItem{off, fyne.NewMenuItemSeparator()}
+
+	choices := audioChoices()
+	// the aliases come first; a rule sets them apart from the devices
+	aliases := 2
+	known := make(map[string]bool)
+	for _, ch := range choices {
+		known[ch.name] = true
+	}
+	// keep a picked source that's unplugged right now, so it can be unpicked
+	for _, s := range c.GetAudioSources() {
+		switch {
+		case s == "default":
+			choices = append(choices, audioChoice{name: s, label: audioLabel(s)})
+		case !known[s]:
+			choices = append(choices, audioChoice{name: s, label: s + " (not available)"})
+		}
+	}
+	for i, ch := range choices {
+		if i == aliases && len(choices) > aliases {
+			items = append(items, fyne.NewMenuItemSeparator())
+		}
+		name := ch.name
+		it := fyne.NewMenuItem(ch.label, func() {
+			srcs := c.GetAudioSources()
+			switch {
+			case !c.GetAudio():
+				srcs = []string{name}
+			case picked[name] && len(srcs) == 1:
+				// unpicking the last source turns audio off
+				c.SetAudio(false)
+				changed()
+				return
+			case picked[name]:
+				srcs = removeString(srcs, name)
+			default:
+				srcs = append(srcs, name)
+			}
+			c.SetAudioSources(srcs)
+			c.SetAudio(true)
+			changed()
+		})
+		it.Checked = on && picked[name]
+		items = append(items, it)
+	}
+
+	sep := fyne.NewMenuItem("Separate tracks", func() {
+		if c.GetAudioTracks() == "separate" {
+			c.SetAudioTracks("mix")
+		} else {
+			c.SetAudioTracks("separate")
+		}
+		changed()
+	})
+	sep.Checked = c.GetAudioTracks() == "separate"
+	sep.Disabled = !on || len(picked) < 2
+	return append(items, fyne.NewMenuItemSeparator(), sep)
+}
+
+// audioSummary is the picker's label.
+func (ui *RecordingUI) audioSummary() string {
+	c := ui.config
+	if !c.GetAudio() {
+		return "Audio: off"
+	}
+	srcs := c.GetAudioSources()
+	if len(srcs) == 1 {
+		return "Audio: " + truncateText(audioLabel(srcs[0]), 180, theme.TextSize())
+	}
+	how := "mixed"
+	if c.GetAudioTracks() == "separate" {
+		how = "separate tracks"
+	}
+	return fmt.Sprintf("Audio: %d sources, %s", len(srcs), how)
+}
+
+func removeString(list []string, s string) []string {
+	out := list[:0]
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
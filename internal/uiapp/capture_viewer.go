@@ -19,7 +19,8 @@ import (
 
 // showCaptureViewer opens an in-app modal previewing a capture (screenshot or
 // recording): the image with a fullscreen button pinned to its top-right, the
-// file path beneath it, and Open File / Open Folder actions below that.
+// file path beneath it, and Open File / Open Folder actions below that. Videos
+// also get in/out trim handles on the player's seek bar.
 func (ui *RecordingUI) showCaptureViewer(path string) {
 	if ui.mainWin == nil {
 		return
@@ -62,7 +63,19 @@ func (ui *RecordingUI) showCaptureViewer(path string) {
 	var previewArea fyne.CanvasObject
 	if isVideo {
 		player = newVideoPlayer(ui, path, 640, 400)
+		// Videos get trim handles on the seek bar and a trim row under the
+		// player; animated exports are left to their own settings.
+		var trim fyne.CanvasObject
+		if !isAnimatedExt(strings.ToLower(filepath.Ext(path))) {
+			trim = player.trimControls(func(out string) {
+				closeViewer()
+				ui.showCaptureViewer(out)
+			})
+		}
 		previewArea = player.object()
+		if trim != nil {
+			previewArea = container.NewVBox(previewArea, trim)
+		}
 	} else {
 		paths, startIdx := ui.imageCapturePaths(path)
 		iv = newImageViewer(ui, paths, startIdx)
@@ -275,9 +288,7 @@ func (ui *RecordingUI) showCaptureViewer(path string) {
 // imageCapturePaths returns the ordered list of screenshot (non-video) capture
 // paths and the index of current within it, for prev/next navigation.
 func (ui *RecordingUI) imageCapturePaths(current string) ([]string, int) {
-	videosDir, _ := ui.ensureVideosDir()
-	screenshotsDir, _ := ui.ensureScreenshotsDir()
-	items := loadItems(videosDir, screenshotsDir)
+	items := loadItems(ui.captureDirs()...)
 
 	var paths []string
 	idx := 0
@@ -1,6 +1,11 @@
 package uiapp
 
-import "sync"
+import (
+	"sync"
+
+	"github.com/almightynan/swiftcap/internal/backend"
+	"github.com/almightynan/swiftcap/internal/record"
+)
 
 type RecordingConfig struct {
 	mu sync.RWMutex
@@ -15,29 +20,64 @@ type RecordingConfig struct {
 	Threads   int
 	QP        int
 	Nice      int
+	Backend   string // capture backend, or backend.Auto
+
+	Codec      string // video encoder, see record.EncoderNames
+	Preset     string // encoder preset, empty for the codec default
+	CRF        int    // constant rate factor, -1 = use bitrate
+	AudioCodec string // empty picks the container default
+
+	AudioSources []string // PulseAudio sources to record while Audio is on
+	AudioTracks  string   // mix|separate, for more than one source
+
+	Anim record.AnimOptions // gif/webp/apng recordings and Export as GIF
+
+	ReplaySecs int // how much the replay buffer keeps
+
+	Hotkeys map[string]string // hotkey action id -> binding, "" = unbound
 
 	RecordDelay int    // seconds to wait before recording starts, 0 = no delay
 	ShotDelay   int    // seconds to wait before screenshot, 0 = no delay
-	ShotFormat  string // "png" or "jpg"
+	ShotFormat  string // png|jpg|webp|webp-lossless|bmp
+	ShotQuality int    // 1-100 for jpg and webp
 	ShotCursor  bool   // show cursor in screenshots
+
+	VideoDir      string // empty: $SWIFTCAP_VIDEOS_DIR, then the XDG videos folder
+	ShotDir       string // empty: $SWIFTCAP_SCREENSHOTS_DIR, then the XDG pictures folder
+	VideoTemplate string // naming template for recordings, under VideoDir
+	ShotTemplate  string // naming template for screenshots, under ShotDir
 }
 
 func NewRecordingConfig() *RecordingConfig {
 	return &RecordingConfig{
-		FPS:         30,
-		Bitrate:     4000,
-		Audio:       true,
-		Cursor:      true,
-		Container:   "mp4",
-		Region:      "",
-		MaxDur:      0,
-		Threads:     0,
-		QP:          0,
-		Nice:        0,
-		RecordDelay: 0,
-		ShotDelay:   0,
-		ShotFormat:  "png",
-		ShotCursor:  true,
+		FPS:          30,
+		Bitrate:      4000,
+		Audio:        true,
+		Cursor:       true,
+		Container:    "mp4",
+		Region:       "",
+		MaxDur:       0,
+		Threads:      0,
+		QP:           0,
+		Nice:         0,
+		Backend:      backend.Auto,
+		Codec:        "x264",
+		Preset:       "",
+		CRF:          -1,
+		AudioCodec:   "",
+		AudioSources: []string{"desktop"},
+		AudioTracks:  "mix",
+		Anim:         record.DefaultAnimOptions(),
+		ReplaySecs:   60,
+		Hotkeys:      defaultHotkeys(),
+		RecordDelay:  0,
+		ShotDelay:    0,
+		ShotFormat:   "png",
+		ShotQuality:  90,
+		ShotCursor:   true,
+
+		VideoTemplate: defaultVideoTemplate,
+		ShotTemplate:  defaultShotTemplate,
 	}
 }
 
@@ -77,6 +117,46 @@ func (c *RecordingConfig) SetAudio(v bool) {
 	c.Audio = v
 }
 
+// GetAudioSources never returns an empty list: no sources means "default".
+func (c *RecordingConfig) GetAudioSources() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.AudioSources) == 0 {
+		return []string{"default"}
+	}
+	return append([]string(nil), c.AudioSources...)
+}
+
+func (c *RecordingConfig) SetAudioSources(v []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.AudioSources = append([]string(nil), v...)
+}
+
+func (c *RecordingConfig) GetAudioTracks() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.AudioTracks
+}
+
+func (c *RecordingConfig) SetAudioTracks(v string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.AudioTracks = v
+}
+
+func (c *RecordingConfig) GetAnim() record.AnimOptions {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Anim
+}
+
+func (c *RecordingConfig) SetAnim(v record.AnimOptions) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Anim = v
+}
+
 func (c *RecordingConfig) GetCursor() bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -125,6 +205,38 @@ func (c *RecordingConfig) SetMaxDur(v int) {
 	c.MaxDur = v
 }
 
+func (c *RecordingConfig) GetReplaySecs() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ReplaySecs
+}
+
+func (c *RecordingConfig) SetReplaySecs(v int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ReplaySecs = v
+}
+
+// GetHotkeys returns a copy of the bindings.
+func (c *RecordingConfig) GetHotkeys() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	m := make(map[string]string, len(c.Hotkeys))
+	for k, v := range c.Hotkeys {
+		m[k] = v
+	}
+	return m
+}
+
+func (c *RecordingConfig) SetHotkeys(v map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Hotkeys = make(map[string]string, len(v))
+	for k, b := range v {
+		c.Hotkeys[k] = b
+	}
+}
+
 func (c *RecordingConfig) GetThreads() int {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -161,6 +273,66 @@ func (c *RecordingConfig) SetNice(v int) {
 	c.Nice = v
 }
 
+func (c *RecordingConfig) GetBackend() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Backend
+}
+
+func (c *RecordingConfig) SetBackend(v string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Backend = v
+}
+
+func (c *RecordingConfig) GetCodec() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Codec
+}
+
+func (c *RecordingConfig) SetCodec(v string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Codec = v
+}
+
+func (c *RecordingConfig) GetPreset() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Preset
+}
+
+func (c *RecordingConfig) SetPreset(v string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Preset = v
+}
+
+func (c *RecordingConfig) GetCRF() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.CRF
+}
+
+func (c *RecordingConfig) SetCRF(v int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.CRF = v
+}
+
+func (c *RecordingConfig) GetAudioCodec() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.AudioCodec
+}
+
+func (c *RecordingConfig) SetAudioCodec(v string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.AudioCodec = v
+}
+
 func (c *RecordingConfig) GetRecordDelay() int {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -197,6 +369,18 @@ func (c *RecordingConfig) SetShotFormat(v string) {
 	c.ShotFormat = v
 }
 
+func (c *RecordingConfig) GetShotQuality() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ShotQuality
+}
+
+func (c *RecordingConfig) SetShotQuality(v int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ShotQuality = v
+}
+
 func (c *RecordingConfig) GetShotCursor() bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -208,3 +392,51 @@ func (c *RecordingConfig) SetShotCursor(v bool) {
 	defer c.mu.Unlock()
 	c.ShotCursor = v
 }
+
+func (c *RecordingConfig) GetVideoDir() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.VideoDir
+}
+
+func (c *RecordingConfig) SetVideoDir(v string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.VideoDir = v
+}
+
+func (c *RecordingConfig) GetShotDir() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ShotDir
+}
+
+func (c *RecordingConfig) SetShotDir(v string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ShotDir = v
+}
+
+func (c *RecordingConfig) GetVideoTemplate() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.VideoTemplate
+}
+
+func (c *RecordingConfig) SetVideoTemplate(v string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.VideoTemplate = v
+}
+
+func (c *RecordingConfig) GetShotTemplate() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ShotTemplate
+}
+
+func (c *RecordingConfig) SetShotTemplate(v string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ShotTemplate = v
+}
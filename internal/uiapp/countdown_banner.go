@@ -138,6 +138,9 @@ func (b *countdownBanner) finish(cb func()) {
 
 func (b *countdownBanner) close() { b.finish(nil) }
 
+// cancel stops the countdown as if the user had pressed Escape.
+func (b *countdownBanner) cancel() { b.bw.onCancel() }
+
 // ─── widget ──────────────────────────────────────────────────────────────────
 
 type countdownBannerWidget struct {
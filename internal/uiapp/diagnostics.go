@@ -0,0 +1,114 @@
+package uiapp
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/almightynan/swiftcap/internal/doctor"
+)
+
+// showDiagnostics runs the same checks as swiftcap doctor and shows them in
+// the About/Diagnostics panel.
+func (ui *RecordingUI) showDiagnostics() {
+	ui.setStatus("Checking the system...")
+	go func() {
+		r := doctor.Run(doctor.Options{Dirs: ui.diagnosticDirs()})
+		ui.setStatus("Ready")
+		ui.runOnMain(func() {
+			if ui.mainWin == nil {
+				return
+			}
+			ui.showReport(r)
+		})
+	}()
+}
+
+// diagnosticDirs are the folders the app saves into.
+func (ui *RecordingUI) diagnosticDirs() []doctor.Dir {
+	var dirs []doctor.Dir
+	if d, err := ui.ensureVideosDir(); err == nil {
+		dirs = append(dirs, doctor.Dir{Name: "videos folder", Path: d})
+	}
+	if d, err := ui.ensureScreenshotsDir(); err == nil {
+		dirs = append(dirs, doctor.Dir{Name: "screenshots folder", Path: d})
+	}
+	return dirs
+}
+
+var statusIcons = map[doctor.Status]fyne.Resource{
+	doctor.Pass: theme.ConfirmIcon(),
+	doctor.Warn: theme.WarningIcon(),
+	doctor.Fail: theme.ErrorIcon(),
+}
+
+func (ui *RecordingUI) showReport(r doctor.Report) {
+	summary := "Everything SwiftCap needs is here."
+	switch r.Worst() {
+	case doctor.Warn:
+		summary = "SwiftCap works here; some extras are missing."
+	case doctor.Fail:
+		summary = "Something SwiftCap needs is missing. See the hints below."
+	}
+	about := widget.NewLabel(fmt.Sprintf("SwiftCap - fast, low-resource screen recorder\nSession: %s\n\n%s", r.Session, summary))
+
+	rows := container.NewVBox()
+	for _, c := range r.Checks {
+		name := widget.NewLabelWithStyle(c.Name, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+		detail := widget.NewLabel(c.Detail)
+		detail.Wrapping = fyne.TextWrapWord
+		text := container.NewVBox(name, detail)
+		if c.Hint != "" && c.Status != doctor.Pass {
+			hint := widget.NewLabelWithStyle(c.Hint, fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+			hint.Wrapping = fyne.TextWrapWord
+			text.Add(hint)
+		}
+		rows.Add(container.NewBorder(nil, nil, widget.NewIcon(statusIcons[c.Status]), nil, text))
+	}
+	scroll := container.NewVScroll(rows)
+	scroll.SetMinSize(fyne.NewSize(520, 360))
+
+	d := dialog.NewCustomWithoutButtons("About / Diagnostics", container.NewBorder(about, nil, nil, nil, scroll), ui.mainWin)
+	copyBtn := widget.NewButtonWithIcon("Copy Report", theme.ContentCopyIcon(), func() {
+		ui.mainWin.Clipboard().SetContent(reportText(r))
+		ui.setStatus("Diagnostics report copied to the clipboard")
+	})
+	closeBtn := widget.NewButton("Close", d.Hide)
+	closeBtn.Importance = widget.HighImportance
+	d.SetButtons([]fyne.CanvasObject{copyBtn, closeBtn})
+	d.Show()
+}
+
+// reportText is the report as swiftcap doctor prints it, without colour,
+// for pasting into a bug report.
+func reportText(r doctor.Report) string {
+	var b strings.Builder
+	for _, c := range r.Checks {
+		fmt.Fprintf(&b, "%-4s  %-18s %s\n", c.Status, c.Name, c.Detail)
+		if c.Hint != "" && c.Status != doctor.Pass {
+			fmt.Fprintf(&b, "      %-18s -> %s\n", "", c.Hint)
+		}
+	}
+	return b.String()
+}
+
+// diagnosticsFromTray brings the window up for the panel.
+func (ui *RecordingUI) diagnosticsFromTray() {
+	ui.runOnMain(func() {
+		if ui.mainWin == nil {
+			return
+		}
+		ui.mu.Lock()
+		ui.mainWin.Show()
+		ui.mainWin.RequestFocus()
+		ui.windowVisible = true
+		ui.mu.Unlock()
+		ui.updateTray()
+		ui.showDiagnostics()
+	})
+}
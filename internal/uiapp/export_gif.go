@@ -0,0 +1,55 @@
+package uiapp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/almightynan/swiftcap/internal/record"
+)
+
+// exportGIF converts a recording into a GIF next to it, using the animated
+// settings, and shows it once done. It runs in the background; the status
+// line says what's happening.
+func (ui *RecordingUI) exportGIF(path string) {
+	out := uniqueSibling(path, ".gif")
+	go func() {
+		ui.setStatus("Exporting GIF...")
+		if err := record.ExportAnimated(path, out, "gif", ui.config.GetAnim()); err != nil {
+			ui.showError("SwiftCap", fmt.Sprintf("Failed to export GIF: %v", err))
+			ui.setStatus("Ready")
+			return
+		}
+		ui.setStatus("GIF saved")
+		ui.refreshRecordingsList()
+		ui.warnOverBudget(out)
+		ui.showPreviewModal(out, false)
+	}()
+}
+
+// warnOverBudget tells the user when an animated file came out bigger than
+// the size budget; other files are left alone.
+func (ui *RecordingUI) warnOverBudget(out string) {
+	if !record.IsAnimated(strings.TrimPrefix(strings.ToLower(filepath.Ext(out)), ".")) {
+		return
+	}
+	a := ui.config.GetAnim()
+	if size, over := a.OverBudget(out); over {
+		ui.showInfo("SwiftCap", fmt.Sprintf("%s is %s, over the %s size budget.\n\nLower the animated FPS or width in Settings, or record a shorter clip.",
+			filepath.Base(out), formatSize(size), formatSize(a.Budget)))
+	}
+}
+
+// uniqueSibling is path with its extension swapped for ext, numbered if a
+// file by that name already exists.
+func uniqueSibling(path, ext string) string {
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	out := base + ext
+	for i := 2; ; i++ {
+		if _, err := os.Stat(out); os.IsNotExist(err) {
+			return out
+		}
+		out = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+}
@@ -0,0 +1,332 @@
+package uiapp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/almightynan/swiftcap/internal/detect"
+	"github.com/almightynan/swiftcap/internal/x11"
+)
+
+// hotkeyAction is something a global hotkey can do.
+type hotkeyAction struct {
+	id    string // preferences key suffix
+	label string
+	def   string // default binding
+}
+
+var hotkeyActions = []hotkeyAction{
+	{"region_shot", "Region Screenshot", "Ctrl+Alt+A"},
+	{"full_shot", "Full Screenshot", "Ctrl+Alt+F"},
+	{"record", "Start / Stop Recording", "Ctrl+Alt+R"},
+	{"pause", "Pause / Resume", "Ctrl+Alt+P"},
+	{"cancel", "Cancel Countdown", "Ctrl+Alt+X"},
+}
+
+// runHotkey does what the hotkey action id is bound to.
+func (ui *RecordingUI) runHotkey(id string) {
+	switch id {
+	case "region_shot":
+		ui.handleScreenshot()
+	case "full_shot":
+		ui.handleFullScreenshot()
+	case "record":
+		ui.toggleRecording()
+	case "pause":
+		ui.togglePause()
+	case "cancel":
+		ui.cancelCountdown()
+	}
+}
+
+func defaultHotkeys() map[string]string {
+	m := map[string]string{}
+	for _, a := range hotkeyActions {
+		m[a.id] = a.def
+	}
+	return m
+}
+
+// registerHotkeys (re)grabs the configured hotkeys. They only exist on X11;
+// Wayland leaves global shortcuts to the compositor. An empty binding is
+// unbound. Bindings another app holds are returned as the error, the rest
+// are live either way.
+func (ui *RecordingUI) registerHotkeys() error {
+	ui.mu.Lock()
+	old := ui.hotkeys
+	ui.hotkeys = nil
+	ui.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	if s, _ := detect.Session(); s != detect.SessionX11 {
+		return nil
+	}
+
+	bindings := ui.config.GetHotkeys()
+	var keys []x11.Hotkey
+	var actions []hotkeyAction
+	for _, a := range hotkeyActions {
+		if bindings[a.id] == "" {
+			continue
+		}
+		k, err := x11.ParseHotkey(bindings[a.id])
+		if err != nil {
+			continue // checkHotkeys keeps these out of the settings
+		}
+		keys = append(keys, k)
+		actions = append(actions, a)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	hk, err := x11.GrabHotkeys(keys, func(i int) { go ui.runHotkey(actions[i].id) })
+	if hk == nil {
+		return err
+	}
+	ui.mu.Lock()
+	ui.hotkeys = hk
+	ui.mu.Unlock()
+	return err
+}
+
+// checkHotkeys reports bindings that don't parse or that two actions share.
+func checkHotkeys(bindings map[string]string) error {
+	seen := map[x11.Hotkey]string{}
+	for _, a := range hotkeyActions {
+		if bindings[a.id] == "" {
+			continue
+		}
+		k, err := x11.ParseHotkey(bindings[a.id])
+		if err != nil {
+			return fmt.Errorf("%s: %v", a.label, err)
+		}
+		if other, ok := seen[k]; ok {
+			return fmt.Errorf("%s is bound to both %s and %s", k, other, a.label)
+		}
+		seen[k] = a.label
+	}
+	return nil
+}
+
+// handleFullScreenshot captures every monitor straight away, no overlay.
+func (ui *RecordingUI) handleFullScreenshot() {
+	ui.mu.Lock()
+	busy := ui.countdown != nil
+	ui.mu.Unlock()
+	if busy {
+		return
+	}
+	ui.runOnMain(func() {
+		if ui.mainWin != nil {
+			ui.mu.Lock()
+			ui.windowVisible = false
+			ui.mu.Unlock()
+			ui.mainWin.Hide()
+		}
+	})
+	time.Sleep(180 * time.Millisecond)
+	ui.captureScreenshotWithRegion("")
+}
+
+func (ui *RecordingUI) toggleRecording() {
+	ui.mu.Lock()
+	active := ui.session != nil
+	ui.mu.Unlock()
+	if active {
+		ui.handleStop()
+	} else {
+		ui.handleStart()
+	}
+}
+
+func (ui *RecordingUI) togglePause() {
+	ui.mu.Lock()
+	paused := ui.isPaused
+	ui.mu.Unlock()
+	if paused {
+		ui.handleResume()
+	} else {
+		ui.handlePause()
+	}
+}
+
+func (ui *RecordingUI) cancelCountdown() {
+	ui.mu.Lock()
+	b := ui.countdown
+	ui.mu.Unlock()
+	if b != nil {
+		b.cancel()
+	}
+}
+
+// hotkeyButton shows a binding; tapping it records the next key combination
+// pressed. Escape gives up, Backspace unbinds.
+type hotkeyButton struct {
+	widget.Button
+	value     string
+	recording bool
+	held      map[string]bool // x11 modifier names
+	onChanged func(string)
+}
+
+func newHotkeyButton(value string, onChanged func(string)) *hotkeyButton {
+	b := &hotkeyButton{value: value, held: map[string]bool{}, onChanged: onChanged}
+	b.ExtendBaseWidget(b)
+	b.OnTapped = func() {
+		b.recording = true
+		b.held = map[string]bool{}
+		b.refreshText()
+		if c := fyne.CurrentApp().Driver().CanvasForObject(b); c != nil {
+			c.Focus(b)
+		}
+	}
+	b.refreshText()
+	return b
+}
+
+// SetValue shows v without calling onChanged.
+func (b *hotkeyButton) SetValue(v string) {
+	b.value = v
+	b.recording = false
+	b.refreshText()
+}
+
+func (b *hotkeyButton) refreshText() {
+	switch {
+	case b.recording:
+		b.SetText("Press keys... (Esc cancels, Backspace unbinds)")
+	case b.value == "":
+		b.SetText("Unbound")
+	default:
+		b.SetText(b.value)
+	}
+}
+
+var hotkeyModKeys = map[fyne.KeyName]string{
+	desktop.KeyControlLeft: "Ctrl", desktop.KeyControlRight: "Ctrl",
+	desktop.KeyAltLeft: "Alt", desktop.KeyAltRight: "Alt",
+	desktop.KeyShiftLeft: "Shift", desktop.KeyShiftRight: "Shift",
+	desktop.KeySuperLeft: "Super", desktop.KeySuperRight: "Super",
+}
+
+// hotkeyKeyNames maps fyne's key names to ParseHotkey's where they differ.
+var hotkeyKeyNames = map[fyne.KeyName]string{
+	fyne.KeyPageUp: "PageUp", fyne.KeyPageDown: "PageDown",
+	desktop.KeyPrintScreen: "Print",
+}
+
+func (b *hotkeyButton) KeyDown(ev *fyne.KeyEvent) {
+	if !b.recording {
+		return
+	}
+	if m, ok := hotkeyModKeys[ev.Name]; ok {
+		b.held[m] = true
+		return
+	}
+	switch ev.Name {
+	case fyne.KeyEscape:
+		b.recording = false
+		b.refreshText()
+		return
+	case fyne.KeyBackspace:
+		b.finish("")
+		return
+	}
+	key := string(ev.Name)
+	if n, ok := hotkeyKeyNames[ev.Name]; ok {
+		key = n
+	}
+	var parts []string
+	for _, m := range []string{"Ctrl", "Alt", "Shift", "Super"} {
+		if b.held[m] {
+			parts = append(parts, m)
+		}
+	}
+	k, err := x11.ParseHotkey(strings.Join(append(parts, key), "+"))
+	if err != nil {
+		return // not a key a hotkey can use; keep listening
+	}
+	b.finish(k.String())
+}
+
+func (b *hotkeyButton) KeyUp(ev *fyne.KeyEvent) {
+	if m, ok := hotkeyModKeys[ev.Name]; ok {
+		delete(b.held, m)
+	}
+}
+
+func (b *hotkeyButton) finish(v string) {
+	b.value = v
+	b.recording = false
+	b.refreshText()
+	if b.onChanged != nil {
+		b.onChanged(v)
+	}
+}
+
+func (b *hotkeyButton) FocusLost() {
+	if b.recording {
+		b.recording = false
+		b.refreshText()
+	}
+	b.Button.FocusLost()
+}
+
+func (b *hotkeyButton) TypedKey(ev *fyne.KeyEvent) {
+	if !b.recording {
+		b.Button.TypedKey(ev)
+	}
+}
+
+func (b *hotkeyButton) TypedRune(rune) {}
+
+// applyHotkeys regrabs the hotkeys after the settings changed them and says
+// which ones another app holds.
+func (ui *RecordingUI) applyHotkeys() {
+	go func() {
+		if err := ui.registerHotkeys(); err != nil {
+			ui.showInfo("Global Hotkeys", fmt.Sprintf("Some hotkeys could not be registered: %v", err))
+		}
+	}()
+}
+
+// hotkeyRows is the Global Hotkeys section of the settings.
+func (sw *settingsWindow) hotkeyRows() fyne.CanvasObject {
+	rows := container.NewVBox(container.NewHBox(
+		widget.NewLabelWithStyle("Global Hotkeys", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		sw.helpTip(tipHotkeys)))
+	if s, _ := detect.Session(); s != detect.SessionX11 {
+		note := widget.NewLabel("Global hotkeys need an X11 session. On Wayland, set up shortcuts in your desktop's keyboard settings instead.")
+		note.Wrapping = fyne.TextWrapWord
+		rows.Add(note)
+	}
+	for _, a := range hotkeyActions {
+		rows.Add(container.NewBorder(nil, nil, widget.NewLabel(a.label), nil, sw.hotkeyBtns[a.id]))
+	}
+	return rows
+}
+
+func (sw *settingsWindow) stagedHotkeys() map[string]string {
+	m := map[string]string{}
+	for id, b := range sw.hotkeyBtns {
+		m[id] = b.value
+	}
+	return m
+}
+
+func (sw *settingsWindow) hotkeysDirty() bool {
+	saved := sw.config.GetHotkeys()
+	for id, b := range sw.hotkeyBtns {
+		if b.value != saved[id] {
+			return true
+		}
+	}
+	return false
+}
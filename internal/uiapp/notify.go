@@ -6,7 +6,7 @@ import (
 
 	"github.com/godbus/dbus/v5"
 
-	"swiftcap/internal/detect"
+	"github.com/almightynan/swiftcap/internal/detect"
 )
 
 // copyImageToClipboard copies the image at path onto the system clipboard as
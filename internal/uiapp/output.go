@@ -0,0 +1,106 @@
+package uiapp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/almightynan/swiftcap/internal/detect"
+	"github.com/almightynan/swiftcap/internal/naming"
+	"github.com/almightynan/swiftcap/internal/x11"
+)
+
+// The built-in templates give the names SwiftCap has always used.
+const (
+	defaultVideoTemplate = "recording_{date:20060102}_{time:150405}.{ext}"
+	defaultShotTemplate  = "swiftcap_{date:20060102}_{time:150405}.{ext}"
+)
+
+// captureDir is a folder captures are saved under and the template naming
+// them, so the recent list can find them again.
+type captureDir struct {
+	path string
+	tmpl naming.Template
+}
+
+// videoTemplate and shotTemplate are the configured templates, falling back
+// to the built-in ones if a saved template no longer parses.
+func (ui *RecordingUI) videoTemplate() naming.Template {
+	return parseTemplate(ui.config.GetVideoTemplate(), defaultVideoTemplate)
+}
+
+func (ui *RecordingUI) shotTemplate() naming.Template {
+	return parseTemplate(ui.config.GetShotTemplate(), defaultShotTemplate)
+}
+
+func parseTemplate(s, def string) naming.Template {
+	t, err := naming.Parse(s)
+	if err != nil || s == "" {
+		t, _ = naming.Parse(def)
+	}
+	return t
+}
+
+// outputPath names a new capture of region: mode is one of naming.Modes and
+// ext the file extension. Recordings and replays go in the videos folder,
+// screenshots and markups in the screenshots folder.
+func (ui *RecordingUI) outputPath(mode, region, ext string) (string, error) {
+	dir, err := ui.ensureScreenshotsDir()
+	t := ui.shotTemplate()
+	if mode == "recording" || mode == "replay" {
+		dir, err = ui.ensureVideosDir()
+		t = ui.videoTemplate()
+	}
+	if err != nil {
+		return "", err
+	}
+	f := naming.Fields{Time: time.Now(), Mode: mode, Ext: ext}
+	if s, _ := detect.Session(); s == detect.SessionX11 {
+		f.Monitor, f.WindowClass = x11.RegionSource(region)
+	}
+	return t.Create(dir, f)
+}
+
+// captureDirs are the folders the recent list looks through.
+func (ui *RecordingUI) captureDirs() []captureDir {
+	videosDir, _ := ui.ensureVideosDir()
+	screenshotsDir, _ := ui.ensureScreenshotsDir()
+	return []captureDir{
+		{path: videosDir, tmpl: ui.videoTemplate()},
+		{path: screenshotsDir, tmpl: ui.shotTemplate()},
+	}
+}
+
+// checkOutput vets the Output settings before they're saved.
+func checkOutput(videoDir, shotDir, videoTmpl, shotTmpl string) error {
+	for _, d := range []string{videoDir, shotDir} {
+		if d != "" && !filepath.IsAbs(expandHome(d)) {
+			return fmt.Errorf("%s: folders must be absolute paths", d)
+		}
+	}
+	for _, s := range []string{videoTmpl, shotTmpl} {
+		if strings.TrimSpace(s) == "" {
+			return fmt.Errorf("a name template can't be empty")
+		}
+		t, err := naming.Parse(s)
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(s, "{ext}") {
+			return fmt.Errorf("template %q: needs {ext}", t)
+		}
+	}
+	return nil
+}
+
+// expandHome turns a leading ~ into the home folder.
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[1:])
+		}
+	}
+	return p
+}
@@ -0,0 +1,272 @@
+package uiapp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/almightynan/swiftcap/internal/config"
+	"github.com/almightynan/swiftcap/internal/record"
+)
+
+// noProfile is the switcher entry for "just my saved settings".
+const noProfile = "None"
+
+// loadProfiles rereads config.toml. A broken file keeps the profiles from
+// the last good read and is reported.
+func (ui *RecordingUI) loadProfiles() error {
+	f, err := config.Load(config.Path())
+	if err != nil {
+		return err
+	}
+	ui.mu.Lock()
+	ui.profiles = f
+	ui.mu.Unlock()
+	return nil
+}
+
+// profileNames lists the profiles in config.toml.
+func (ui *RecordingUI) profileNames() []string {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	if ui.profiles == nil {
+		return nil
+	}
+	return ui.profiles.Names()
+}
+
+// applyProfile puts the saved settings back and lays the active profile
+// over them, so the profile wins for whatever it sets. A profile that's gone
+// or doesn't make a valid recording is dropped and reported.
+func (ui *RecordingUI) applyProfile() error {
+	ui.loadPrefs()
+	name := ui.app.Preferences().String("profile")
+	if name == "" {
+		return nil
+	}
+	ui.mu.Lock()
+	f := ui.profiles
+	ui.mu.Unlock()
+	if f == nil {
+		return nil
+	}
+	p, err := f.Profile(name)
+	if err == nil {
+		err = setProfile(ui.config, p)
+	}
+	if err == nil {
+		err = ui.recordOptions(filepath.Join(os.TempDir(), "check")).Validate()
+	}
+	if err != nil {
+		ui.app.Preferences().SetString("profile", "")
+		ui.loadPrefs()
+		return fmt.Errorf("profile %s: %w", name, err)
+	}
+	return nil
+}
+
+// setProfile copies what p sets into c. a-bitrate and compression have no
+// app setting and are left to the CLI.
+func setProfile(c *RecordingConfig, p config.Profile) error {
+	if p.Fps != nil {
+		c.SetFPS(*p.Fps)
+	}
+	if p.Bitrate != nil {
+		c.SetBitrate(*p.Bitrate)
+	}
+	if p.Container != nil {
+		c.SetContainer(*p.Container)
+	}
+	if p.Codec != nil {
+		c.SetCodec(*p.Codec)
+	}
+	if p.Preset != nil {
+		c.SetPreset(*p.Preset)
+	}
+	if p.Crf != nil {
+		c.SetCRF(*p.Crf)
+	}
+	if p.Audio != nil {
+		c.SetAudio(*p.Audio)
+	}
+	if len(p.ASrc) > 0 {
+		c.SetAudioSources(p.ASrc)
+	}
+	if p.AudioTracks != nil {
+		c.SetAudioTracks(*p.AudioTracks)
+	}
+	if p.ACodec != nil {
+		c.SetAudioCodec(*p.ACodec)
+	}
+	if p.Cursor != nil {
+		c.SetCursor(*p.Cursor)
+	}
+	if p.MaxDur != nil {
+		c.SetMaxDur(*p.MaxDur)
+	}
+	if p.Threads != nil {
+		c.SetThreads(*p.Threads)
+	}
+	if p.Qp != nil {
+		c.SetQP(*p.Qp)
+	}
+	if p.Nice != nil {
+		c.SetNice(*p.Nice)
+	}
+	if p.Backend != nil {
+		c.SetBackend(*p.Backend)
+	}
+	a := c.GetAnim()
+	if p.AnimFps != nil {
+		a.Fps = *p.AnimFps
+	}
+	if p.AnimWidth != nil {
+		a.Width = *p.AnimWidth
+	}
+	if p.Dither != nil {
+		a.Dither = *p.Dither
+	}
+	if p.Loop != nil {
+		a.Loop = *p.Loop
+	}
+	if p.SizeBudget != nil {
+		b, err := record.ParseSize(*p.SizeBudget)
+		if err != nil {
+			return fmt.Errorf("size-budget: %w", err)
+		}
+		a.Budget = b
+	}
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	c.SetAnim(a)
+	if p.Format != nil {
+		c.SetShotFormat(*p.Format)
+	}
+	if p.Quality != nil {
+		c.SetShotQuality(*p.Quality)
+	}
+	return nil
+}
+
+// switchProfile makes name ("" for none) the active profile.
+func (ui *RecordingUI) switchProfile(name string) {
+	if name == ui.app.Preferences().String("profile") {
+		return
+	}
+	ui.app.Preferences().SetString("profile", name)
+	if err := ui.applyProfile(); err != nil {
+		ui.showError("Profile", err.Error())
+	}
+	ui.profileChanged()
+}
+
+// profileChanged brings everything showing a setting up to date.
+func (ui *RecordingUI) profileChanged() {
+	ui.syncQuickControls()
+	ui.runOnMain(func() {
+		ui.refreshConfigSummary()
+		ui.syncProfileSelect()
+	})
+	ui.updateTray()
+}
+
+// syncProfileSelect refills the sidebar switcher without firing it.
+func (ui *RecordingUI) syncProfileSelect() {
+	if ui.profileSelect == nil {
+		return
+	}
+	ui.profileSelect.Options = append([]string{noProfile}, ui.profileNames()...)
+	sel := ui.app.Preferences().String("profile")
+	if sel == "" {
+		sel = noProfile
+	}
+	ui.profileSelect.Selected = sel
+	ui.profileSelect.Refresh()
+}
+
+// profileMenu is the tray's profile switcher, nil without any profiles.
+func (ui *RecordingUI) profileMenu() *fyne.MenuItem {
+	names := ui.profileNames()
+	if len(names) == 0 {
+		return nil
+	}
+	active := ui.app.Preferences().String("profile")
+	none := fyne.NewMenuItem(noProfile, func() { ui.switchProfile("") })
+	none.Checked = active == ""
+	items := []*fyne.MenuItem{none}
+	for _, n := range names {
+		n := n
+		it := fyne.NewMenuItem(n, func() { ui.switchProfile(n) })
+		it.Checked = n == active
+		items = append(items, it)
+	}
+	label := "Profile"
+	if active != "" {
+		label += ": " + active
+	}
+	m := fyne.NewMenuItem(label, nil)
+	m.Icon = theme.SettingsIcon()
+	m.ChildMenu = fyne.NewMenu("", items...)
+	return m
+}
+
+// watchConfig reloads config.toml whenever it changes. The directory is
+// watched rather than the file, since editors save by replacing it.
+func (ui *RecordingUI) watchConfig() {
+	path := config.Path()
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return
+	}
+	if err := w.Add(dir); err != nil {
+		w.Close()
+		return
+	}
+	go func() {
+		defer w.Close()
+		var settle <-chan time.Time
+		lastErr := ""
+		for {
+			select {
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(ev.Name) == filepath.Clean(path) {
+					// editors write in bursts; reload once it's quiet
+					settle = time.After(250 * time.Millisecond)
+				}
+			case _, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+			case <-settle:
+				settle = nil
+				err := ui.loadProfiles()
+				if err == nil {
+					err = ui.applyProfile()
+				}
+				if err != nil {
+					if err.Error() != lastErr {
+						ui.showError("config.toml", err.Error())
+					}
+					lastErr = err.Error()
+				} else {
+					lastErr = ""
+					ui.setStatus("Reloaded " + filepath.Base(path))
+				}
+				ui.profileChanged()
+			}
+		}
+	}()
+}
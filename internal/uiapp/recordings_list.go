@@ -41,18 +41,19 @@ type recordingsList struct {
 	box      *fyne.Container
 	scroll   *container.Scroll
 	onSelect func(string)
+	onExport func(string) // "Export as GIF" on a video's context menu
 }
 
-func newRecordingsList(videosDir, screenshotsDir string, onSelect func(string)) *recordingsList {
+func newRecordingsList(dirs []captureDir, onSelect func(string)) *recordingsList {
 	rl := &recordingsList{onSelect: onSelect}
 	rl.box = container.NewGridWithColumns(3)
 	rl.scroll = container.NewVScroll(rl.box)
 	rl.scroll.SetMinSize(fyne.NewSize(0, 220))
-	rl.refresh(videosDir, screenshotsDir)
+	rl.refresh(dirs...)
 	return rl
 }
 
-func (rl *recordingsList) refresh(dirs ...string) {
+func (rl *recordingsList) refresh(dirs ...captureDir) {
 	items := loadItems(dirs...)
 	rl.box.Objects = nil
 	if len(items) == 0 {
@@ -68,6 +69,9 @@ func (rl *recordingsList) refresh(dirs ...string) {
 					rl.onSelect(item.path)
 				}
 			})
+			if item.isVideo && !isAnimatedExt(filepath.Ext(item.path)) && rl.onExport != nil {
+				card.onExport = func() { rl.onExport(item.path) }
+			}
 			rl.box.Add(card)
 		}
 	}
@@ -85,37 +89,30 @@ func (rl *recordingsList) getContainer() fyne.CanvasObject { return rl.scroll }
 // panel, not a gallery — the most recent handful is all it needs to show.
 const maxRecentItems = 60
 
-func loadItems(dirs ...string) []recordingItem {
+func loadItems(dirs ...captureDir) []recordingItem {
 	seen := make(map[string]bool)
 	var items []recordingItem
 	for _, dir := range dirs {
-		if dir == "" {
+		if dir.path == "" {
 			continue
 		}
-		entries, err := os.ReadDir(dir)
-		if err != nil {
-			continue
-		}
-		for _, e := range entries {
-			if e.IsDir() {
-				continue
-			}
-			name := e.Name()
+		match := dir.tmpl.Matcher()
+		for _, e := range templateFiles(dir.path, dir.tmpl.Depth()) {
+			rel, name := e.rel, filepath.Base(e.rel)
 			ext := strings.ToLower(filepath.Ext(name))
 			if !isCaptureFile(ext) {
 				continue
 			}
-			// Only list SwiftCap's own captures. Otherwise a shared folder like
+			// Only list SwiftCap's own captures: what the naming template makes,
+			// or the names it used before templates. Otherwise a shared folder like
 			// ~/Pictures (which can hold thousands of unrelated images) would all
 			// get loaded here — the source of the app's startup/screenshot lag.
-			if !isSwiftCapCapture(name) {
+			if strings.HasSuffix(name, ".thumb.jpg") ||
+				!match.MatchString(filepath.ToSlash(rel)) && !(rel == name && isSwiftCapCapture(name)) {
 				continue
 			}
-			info, err := e.Info()
-			if err != nil {
-				continue
-			}
-			path := filepath.Join(dir, name)
+			info := e.info
+			path := filepath.Join(dir.path, rel)
 			if seen[path] {
 				continue
 			}
@@ -142,6 +139,41 @@ func loadItems(dirs ...string) []recordingItem {
 	return items
 }
 
+// templateFile is a file found under a capture folder, relative to it.
+type templateFile struct {
+	rel  string
+	info os.FileInfo
+}
+
+// templateFiles lists the files under dir down to depth folders deep, as
+// deep as a naming template puts them.
+func templateFiles(dir string, depth int) []templateFile {
+	var files []templateFile
+	var walk func(rel string, depth int)
+	walk = func(rel string, depth int) {
+		entries, err := os.ReadDir(filepath.Join(dir, rel))
+		if err != nil {
+			return
+		}
+		for _, e := range entries {
+			name := filepath.Join(rel, e.Name())
+			if e.IsDir() {
+				if depth > 0 {
+					walk(name, depth-1)
+				}
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			files = append(files, templateFile{rel: name, info: info})
+		}
+	}
+	walk("", depth)
+	return files
+}
+
 // isSwiftCapCapture reports whether a filename is one of SwiftCap's own final
 // captures: screenshots (swiftcap_*, swiftcap_markup_*) or recordings
 // (recording_*). Recording intermediates (segments, concat lists, temp
@@ -160,7 +192,7 @@ func isSwiftCapCapture(name string) bool {
 
 func isCaptureFile(ext string) bool {
 	switch ext {
-	case ".mp4", ".mkv", ".avi", ".mov", ".webm", ".flv",
+	case ".mp4", ".mkv", ".avi", ".mov", ".webm", ".flv", ".gif", ".apng",
 		".png", ".jpg", ".jpeg", ".webp", ".bmp":
 		return true
 	}
@@ -169,7 +201,17 @@ func isCaptureFile(ext string) bool {
 
 func isVideoExt(ext string) bool {
 	switch ext {
-	case ".mp4", ".mkv", ".avi", ".mov", ".webm", ".flv":
+	case ".mp4", ".mkv", ".avi", ".mov", ".webm", ".flv", ".gif", ".apng":
+		return true
+	}
+	return false
+}
+
+// isAnimatedExt reports whether ext is an animated-image recording, which
+// has nothing to export a GIF from.
+func isAnimatedExt(ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".gif", ".apng":
 		return true
 	}
 	return false
@@ -187,8 +229,9 @@ const (
 
 type captureCard struct {
 	widget.BaseWidget
-	item  recordingItem
-	onTap func()
+	item     recordingItem
+	onTap    func()
+	onExport func() // nil hides the context menu
 
 	mu      sync.Mutex
 	hovered bool
@@ -340,7 +383,18 @@ func (c *captureCard) Tapped(*fyne.PointEvent) {
 		c.onTap()
 	}
 }
-func (c *captureCard) TappedSecondary(*fyne.PointEvent) {}
+// TappedSecondary opens the card's context menu, which videos have.
+func (c *captureCard) TappedSecondary(ev *fyne.PointEvent) {
+	if c.onExport == nil {
+		return
+	}
+	cv := fyne.CurrentApp().Driver().CanvasForObject(c)
+	if cv == nil {
+		return
+	}
+	menu := fyne.NewMenu("", fyne.NewMenuItem("Export as GIF", c.onExport))
+	widget.ShowPopUpMenuAtPosition(menu, cv, ev.AbsolutePosition)
+}
 
 // Cursor makes the capture tiles show a pointer, signalling they're clickable.
 func (c *captureCard) Cursor() desktop.Cursor { return desktop.PointerCursor }
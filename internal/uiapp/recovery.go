@@ -0,0 +1,71 @@
+package uiapp
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/almightynan/swiftcap/internal/record"
+)
+
+// offerRecovery looks for recordings a previous run left unjoined (it
+// crashed or was killed mid-recording) and asks whether to recover or
+// discard them.
+func (ui *RecordingUI) offerRecovery() {
+	orphans, err := record.Orphans()
+	if err != nil || len(orphans) == 0 {
+		return
+	}
+	var size int64
+	for _, j := range orphans {
+		size += j.Size()
+	}
+	what := "a recording"
+	if len(orphans) > 1 {
+		what = fmt.Sprintf("%d recordings", len(orphans))
+	}
+	msg := widget.NewLabel(fmt.Sprintf("SwiftCap closed before it could finish %s (%s).\n\n"+
+		"Recover joins what was recorded into your videos folder.\nDiscard deletes it.", what, formatSize(size)))
+
+	ui.runOnMain(func() {
+		if ui.mainWin == nil {
+			return
+		}
+		dialog.ShowCustomConfirm("Unfinished recording", "Recover", "Discard", msg, func(keep bool) {
+			go ui.finishOrphans(orphans, keep)
+		}, ui.mainWin)
+	})
+}
+
+func (ui *RecordingUI) finishOrphans(orphans []*record.Journal, keep bool) {
+	if !keep {
+		for _, j := range orphans {
+			j.Discard()
+		}
+		return
+	}
+	ui.setStatus("Recovering recordings...")
+	var saved []string
+	var failed []string
+	for _, j := range orphans {
+		// the ui's segments sit in the videos dir, so the default name lands
+		// next to the other recordings
+		p, err := j.Recover("")
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", j.Started.Format("Jan 2 15:04"), err))
+			continue
+		}
+		saved = append(saved, p)
+	}
+	ui.setStatus("Ready")
+	ui.refreshRecordingsList()
+	if len(failed) > 0 {
+		ui.showError("SwiftCap", "Some recordings could not be recovered:\n\n"+strings.Join(failed, "\n"))
+	} else if len(saved) == 1 {
+		ui.showPreviewModal(saved[0], false)
+	} else {
+		ui.showInfo("SwiftCap", fmt.Sprintf("Recovered %d recordings.", len(saved)))
+	}
+}
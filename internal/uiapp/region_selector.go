@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	_ "image/png"
+	"image/png"
 	"math"
 	"os"
 	"os/exec"
@@ -18,6 +18,8 @@ import (
 	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+
+	"github.com/almightynan/swiftcap/internal/x11"
 )
 
 // ─── snipMode ────────────────────────────────────────────────────────────────
@@ -29,9 +31,10 @@ const (
 	snipFreeform   snipMode = 1
 	snipFullscreen snipMode = 2
 	snipMarkup     snipMode = 3
+	snipWindow     snipMode = 4
 )
 
-var snipLabels = [4]string{"Rectangle", "Freeform", "Full Screen", "Markup"}
+var snipLabels = [5]string{"Rectangle", "Freeform", "Full Screen", "Markup", "Window"}
 
 func snipIcon(m snipMode) fyne.Resource {
 	switch m {
@@ -41,6 +44,8 @@ func snipIcon(m snipMode) fyne.Resource {
 		return theme.DocumentCreateIcon()
 	case snipFullscreen:
 		return theme.ViewFullScreenIcon()
+	case snipWindow:
+		return theme.ViewRestoreIcon()
 	default:
 		return theme.DocumentIcon()
 	}
@@ -115,10 +120,10 @@ func (rs *regionSelector) showBuiltinSelector() {
 	resultCh := make(chan string, 1)
 	win := newFullscreenOverlayWindow(rs.app, screenW, screenH)
 
-	// Recording: only Rectangle (selected area) + Full Screen. Freeform and the
+	// Recording: Rectangle (selected area), Window and Full Screen. Freeform and the
 	// beta Markup tool are screenshot-only and don't apply to video capture.
 	overlay := newRegionOverlayWidget(tmpFile, screenW, screenH,
-		[]snipMode{snipRect, snipFullscreen}, func(region string) {
+		[]snipMode{snipRect, snipWindow, snipFullscreen}, func(region string) {
 			win.Close()
 			os.Remove(tmpFile)
 			resultCh <- region
@@ -189,7 +194,7 @@ type regionOverlayWidget struct {
 	onDone  func(string)
 
 	// modes is the ordered set of selection modes shown in the top toolbar.
-	// Recording uses only {Rectangle, Full Screen}; screenshots use all four
+	// Recording uses {Rectangle, Window, Full Screen}; screenshots use all five
 	// (markup is a screenshot-only, beta annotation feature).
 	modes []snipMode
 
@@ -239,6 +244,11 @@ type regionOverlayWidget struct {
 	confirmAnimT float32
 	confirmAnim  *fyne.Animation
 
+	// Window mode: top-level windows, listed before the overlay maps so it
+	// isn't one of them, and the one under the cursor (or clicked).
+	windows  []x11.Window
+	hoverWin int // index into windows, -1 = none
+
 	// Rect-mode handle-drag state (guarded by mu)
 	handleDrag   int          // -1 = none, 0-7 = index of handle being dragged
 	handleOffset fyne.Position // click-point offset from handle centre (prevents jump)
@@ -266,7 +276,9 @@ func newRegionOverlayWidget(bgFile string, screenW, screenH int, modes []snipMod
 		markBlurType: 0,
 		mkHoverCode: mkHitNone,
 		handleDrag:  -1,
+		hoverWin:    -1,
 	}
+	w.initWindowMode()
 	if f, err := os.Open(bgFile); err == nil {
 		img, _, _ := image.Decode(f)
 		f.Close()
@@ -353,6 +365,7 @@ func (w *regionOverlayWidget) selectMode(newMode snipMode) {
 	// Fullscreen has no drag step — mark selection complete immediately.
 	w.selComplete = newMode == snipFullscreen
 	w.freePoints = w.freePoints[:0]
+	w.hoverWin = -1
 	w.mu.Unlock()
 }
 
@@ -398,6 +411,14 @@ func (w *regionOverlayWidget) MouseDown(ev *desktop.MouseEvent) {
 		return
 	}
 
+	if w.mode == snipWindow {
+		w.hoverWin = w.windowAt(ev.Position)
+		w.selComplete = w.hoverWin >= 0
+		w.mu.Unlock()
+		w.Refresh()
+		return
+	}
+
 	// In Rect mode with a finished selection, check whether the click lands on a
 	// resize handle before deciding to start a new selection.
 	if w.mode == snipRect && w.selComplete && w.started {
@@ -564,6 +585,10 @@ func (w *regionOverlayWidget) MouseMoved(ev *desktop.MouseEvent) {
 		w.hoverBtn = -1
 	}
 
+	if w.mode == snipWindow && !w.selComplete && hit == -1 {
+		w.hoverWin = w.windowAt(ev.Position)
+	}
+
 	if w.started && w.mouseDown && !w.done {
 		w.curX = ev.Position.X
 		w.curY = ev.Position.Y
@@ -611,6 +636,10 @@ func (w *regionOverlayWidget) TypedKey(ev *fyne.KeyEvent) {
 		cx, cy := w.curX, w.curY
 		freePoints := append([]fyne.Position(nil), w.freePoints...)
 		size := w.Size()
+		var win x11.Window
+		if mode == snipWindow {
+			win = w.windows[w.hoverWin]
+		}
 		w.done = true
 		w.mu.Unlock()
 
@@ -623,6 +652,10 @@ func (w *regionOverlayWidget) TypedKey(ev *fyne.KeyEvent) {
 		switch {
 		case mode == snipFullscreen:
 			region = fmt.Sprintf("%dx%d+0+0", w.screenW, w.screenH)
+		case mode == snipWindow:
+			// frames can hang off the screen edge; grab only what's visible
+			r := image.Rect(win.X, win.Y, win.X+win.Width, win.Y+win.Height).Intersect(image.Rect(0, 0, w.screenW, w.screenH))
+			region = fmt.Sprintf("%dx%d+%d+%d", r.Dx(), r.Dy(), r.Min.X, r.Min.Y)
 		case mode == snipFreeform && len(freePoints) >= 3:
 			// Polygon-mask the bgImage directly; no second x11grab needed.
 			if tmpPath, err := saveFreeformTmpFile(w.bgImage, freePoints, scale); err == nil {
@@ -764,9 +797,9 @@ type regionOverlayRenderer struct {
 	// toolbar
 	toolbarBg *canvas.Rectangle
 	indicator *canvas.Rectangle // animated selection indicator
-	btnBg     [4]*canvas.Rectangle
-	btnIcon   [4]*widget.Icon
-	btnLabel  [4]*canvas.Text
+	btnBg     [5]*canvas.Rectangle
+	btnIcon   [5]*widget.Icon
+	btnLabel  [5]*canvas.Text
 
 	// ── Markup mode objects ──────────────────────────────────────────────
 	mkBufRaster    *canvas.Raster
@@ -962,10 +995,10 @@ func (w *regionOverlayWidget) CreateRenderer() fyne.WidgetRenderer {
 	r.indicator = canvas.NewRectangle(color.NRGBA{0x2a, 0x5e, 0xc8, 0xff})
 	r.indicator.CornerRadius = 7
 
-	// Button slots are a fixed [4] array, but content maps to this overlay's
+	// Button slots are a fixed [5] array, but content maps to this overlay's
 	// active mode set; slots past len(modes) are built with placeholder content
 	// and kept hidden by the toolbar layout.
-	for i := 0; i < 4; i++ {
+	for i := 0; i < len(r.btnBg); i++ {
 		bg := canvas.NewRectangle(color.Transparent)
 		bg.CornerRadius = 7
 		r.btnBg[i] = bg
@@ -1202,6 +1235,11 @@ func (r *regionOverlayRenderer) paint(size fyne.Size) {
 	mx, my := w.mouseX, w.mouseY
 	hoverBtn := w.hoverBtn
 	freePoints := append([]fyne.Position(nil), w.freePoints...)
+	hoverWin := x11.Window{}
+	hasWin := mode == snipWindow && w.hoverWin >= 0
+	if hasWin {
+		hoverWin = w.windows[w.hoverWin]
+	}
 	w.mu.Unlock()
 
 	indicX := w.indicX // safe: only written on main thread by animation
@@ -1220,6 +1258,13 @@ func (r *regionOverlayRenderer) paint(size fyne.Size) {
 		scale = float32(w.screenW) / size.Width
 	}
 
+	// Window mode highlights the window through the rect path below.
+	if hasWin {
+		started = true
+		sx, sy = float32(hoverWin.X)/scale, float32(hoverWin.Y)/scale
+		cx, cy = float32(hoverWin.X+hoverWin.Width)/scale, float32(hoverWin.Y+hoverWin.Height)/scale
+	}
+
 	// Screen crosshair
 	r.crossH.Position1 = fyne.NewPos(0, my)
 	r.crossH.Position2 = fyne.NewPos(size.Width, my)
@@ -1243,6 +1288,26 @@ func (r *regionOverlayRenderer) paint(size fyne.Size) {
 		r.helpMain.Show()
 		r.helpSub.Show()
 		r.instrText.Text = "Enter to capture  ·  Esc"
+	case mode == snipWindow:
+		if hasWin {
+			r.helpMain.Hide()
+			r.helpSub.Hide()
+		} else {
+			r.helpMain.Text = "Hover a window and click to select it"
+			r.helpMain.Move(fyne.NewPos(0, size.Height/2-36))
+			r.helpMain.Resize(fyne.NewSize(size.Width, 28))
+			r.helpSub.Move(fyne.NewPos(0, size.Height/2))
+			r.helpSub.Resize(fyne.NewSize(size.Width, 20))
+			canvas.Refresh(r.helpMain)
+			canvas.Refresh(r.helpSub)
+			r.helpMain.Show()
+			r.helpSub.Show()
+		}
+		if selComplete {
+			r.instrText.Text = "Enter to capture  ·  Click another window  ·  Esc"
+		} else {
+			r.instrText.Text = "Click a window  ·  Esc"
+		}
 	case !started:
 		// Pre-selection: show large centered instructions
 		switch mode {
@@ -1295,7 +1360,7 @@ func (r *regionOverlayRenderer) paint(size fyne.Size) {
 	// ── Rectangular selection ─────────────────────────────────────────────────
 	r.freeformRaster.Hide()
 
-	if mode == snipRect {
+	if mode == snipRect || mode == snipWindow {
 		minX := float32(math.Min(float64(sx), float64(cx)))
 		minY := float32(math.Min(float64(sy), float64(cy)))
 		maxX := float32(math.Max(float64(sx), float64(cx)))
@@ -1360,7 +1425,7 @@ func (r *regionOverlayRenderer) paint(size fyne.Size) {
 
 			// Handle dots — only shown once the selection is complete so they
 			// clearly signal "drag me to adjust". TL TC TR  ML MR  BL BC BR.
-			if selComplete {
+			if selComplete && mode == snipRect {
 				const hR = float32(6)
 				const hD = hR * 2
 				midX := minX + selW/2
@@ -1551,8 +1616,8 @@ func (r *regionOverlayRenderer) paintTopToolbar(size fyne.Size, mode snipMode, h
 
 	n := w.numModes()
 	zero := fyne.NewSize(0, 0)
-	for i := 0; i < 4; i++ {
-		// Slots past the active mode count are hidden (fixed [4] array).
+	for i := 0; i < len(r.btnBg); i++ {
+		// Slots past the active mode count are hidden (fixed [5] array).
 		if i >= n {
 			r.btnBg[i].Resize(zero)
 			r.btnIcon[i].Hide()
@@ -1601,6 +1666,49 @@ func (r *regionOverlayRenderer) paintTopToolbar(size fyne.Size, mode snipMode, h
 	}
 }
 
+// ─── window mode ─────────────────────────────────────────────────────────────
+
+// initWindowMode lists the windows for snipWindow, dropping the mode when
+// there is no X window tree to pick from.
+func (w *regionOverlayWidget) initWindowMode() {
+	wanted := false
+	for _, m := range w.modes {
+		wanted = wanted || m == snipWindow
+	}
+	if !wanted {
+		return
+	}
+	wins, err := x11.Windows()
+	if err == nil && len(wins) > 0 {
+		w.windows = wins
+		return
+	}
+	modes := make([]snipMode, 0, len(w.modes))
+	for _, m := range w.modes {
+		if m != snipWindow {
+			modes = append(modes, m)
+		}
+	}
+	w.modes = modes
+	w.mode = modes[0]
+}
+
+// windowAt returns the index of the topmost window under pos, or -1.
+// caller holds mu.
+func (w *regionOverlayWidget) windowAt(pos fyne.Position) int {
+	scale := float32(1.0)
+	if sz := w.Size(); sz.Width > 0 {
+		scale = float32(w.screenW) / sz.Width
+	}
+	x, y := int(pos.X*scale), int(pos.Y*scale)
+	for i := len(w.windows) - 1; i >= 0; i-- {
+		if w.windows[i].Contains(x, y) {
+			return i
+		}
+	}
+	return -1
+}
+
 // ─── image helpers ───────────────────────────────────────────────────────────
 
 func clearRGBA(img *image.RGBA) {
@@ -1697,43 +1805,26 @@ func runSlop(slopPath string) (string, error) {
 }
 
 func takeScreenshot(w, h int, outFile string) error {
-	display := os.Getenv("DISPLAY")
-	if display == "" {
-		display = ":0"
-	}
-	if !strings.Contains(display, ".") {
-		display += ".0"
-	}
-	cmd := exec.Command("ffmpeg", "-y",
-		"-f", "x11grab",
-		"-video_size", fmt.Sprintf("%dx%d", w, h),
-		"-i", display+"+0,0",
-		"-vframes", "1",
-		outFile,
-	)
-	return cmd.Run()
+	img, err := x11.Capture(image.Rect(0, 0, w, h))
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(outFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return (&png.Encoder{CompressionLevel: png.BestSpeed}).Encode(f, img)
 }
 
+// getScreenSize returns the size of the whole X screen (every monitor), which
+// the snip overlay covers.
 func getScreenSize() (int, int) {
 	if runtime.GOOS == "linux" {
-		if out, err := exec.Command("xdpyinfo").Output(); err == nil {
-			for _, line := range strings.Split(string(out), "\n") {
-				var w, h int
-				if n, _ := fmt.Sscanf(strings.TrimSpace(line), "dimensions: %dx%d pixels", &w, &h); n == 2 && w > 0 {
-					return w, h
-				}
-			}
-		}
-		if out, err := exec.Command("xrandr").Output(); err == nil {
-			for _, line := range strings.Split(string(out), "\n") {
-				if strings.Contains(line, " connected ") {
-					var w, h int
-					if n, _ := fmt.Sscanf(line, " %dx%d", &w, &h); n == 2 && w > 0 {
-						return w, h
-					}
-				}
-			}
+		if w, h, err := x11.ScreenSize(); err == nil && w > 0 {
+			return w, h
 		}
 	}
+	// no X server at all (pure wayland without xwayland); nothing to measure
 	return 1920, 1080
 }
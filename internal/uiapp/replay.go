@@ -0,0 +1,129 @@
+package uiapp
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"fyne.io/fyne/v2/theme"
+
+	"github.com/almightynan/swiftcap/capture"
+	"github.com/almightynan/swiftcap/internal/record"
+)
+
+// replayMode is the third home-screen mode, beside Capture and Record.
+const replayMode = 2
+
+func (ui *RecordingUI) replayRunning() bool {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	return ui.replay != nil
+}
+
+// handleStartReplay starts buffering the screen with the record settings.
+// Nothing is written to the videos folder until Save Replay.
+func (ui *RecordingUI) handleStartReplay() {
+	ui.mu.Lock()
+	busy := ui.replay != nil || ui.session != nil
+	ui.mu.Unlock()
+	if busy {
+		ui.showInfo("Replay Buffer", "Stop the current recording before starting the replay buffer.")
+		return
+	}
+
+	opts := ui.recordOptions("")
+	if record.IsAnimated(opts.Container) {
+		// replays are saved as video; Export as GIF works on them afterwards
+		opts.Container = "mp4"
+	}
+	window := time.Duration(ui.config.GetReplaySecs()) * time.Second
+	r, err := capture.StartReplay(context.Background(), opts, window)
+	if err != nil {
+		ui.showError("Replay Buffer", err.Error())
+		return
+	}
+	ui.mu.Lock()
+	ui.replay = r
+	ui.mu.Unlock()
+	ui.setStatus(fmt.Sprintf("Replay buffer: keeping the last %ds", ui.config.GetReplaySecs()))
+	ui.syncReplayControls()
+	go ui.monitorReplay(r)
+}
+
+// monitorReplay waits for the buffer to stop and reports it if it failed.
+func (ui *RecordingUI) monitorReplay(r *capture.Replay) {
+	err := r.Wait()
+	ui.mu.Lock()
+	ui.replay = nil
+	ui.mu.Unlock()
+	if err != nil {
+		ui.showError("Replay Buffer", fmt.Sprintf("The replay buffer stopped: %v", err))
+	}
+	ui.setStatus("Ready")
+	ui.syncReplayControls()
+}
+
+// handleStopReplay stops buffering; what's in the buffer is dropped.
+func (ui *RecordingUI) handleStopReplay() {
+	ui.mu.Lock()
+	r := ui.replay
+	ui.mu.Unlock()
+	if r != nil {
+		r.Stop()
+	}
+}
+
+// handleSaveReplay writes the buffer to a new recording without stopping it.
+func (ui *RecordingUI) handleSaveReplay() {
+	ui.mu.Lock()
+	r := ui.replay
+	ui.mu.Unlock()
+	if r == nil {
+		return
+	}
+	ext := ui.config.GetContainer()
+	if record.IsAnimated(ext) {
+		ext = "mp4"
+	}
+	out, err := ui.outputPath("replay", ui.config.GetRegion(), ext)
+	if err != nil {
+		ui.showError("Save Replay", fmt.Sprintf("Failed to access videos directory: %v", err))
+		return
+	}
+	ui.setStatus("Saving replay...")
+	out, err = r.Save(out)
+	if err != nil {
+		ui.showError("Save Replay", fmt.Sprintf("Failed to save the replay: %v", err))
+		ui.setStatus("Replay buffer running")
+		return
+	}
+	ui.setStatus("Replay saved")
+	ui.refreshRecordingsList()
+	sendNotification("Replay saved", filepath.Base(out))
+}
+
+// syncReplayControls matches the action button, the stop button and the tray
+// to whether the buffer is running.
+func (ui *RecordingUI) syncReplayControls() {
+	running := ui.replayRunning()
+	ui.runOnMain(func() {
+		if ui.captureMode == replayMode && ui.actionBtn != nil {
+			if running {
+				ui.actionBtn.SetIcon(theme.DocumentSaveIcon())
+				ui.actionBtn.SetText(fmt.Sprintf("  Save Last %ds", ui.config.GetReplaySecs()))
+			} else {
+				ui.actionBtn.SetIcon(theme.HistoryIcon())
+				ui.actionBtn.SetText("  Start Replay Buffer")
+			}
+		}
+		if ui.replayStopBtn != nil {
+			if running && ui.captureMode == replayMode {
+				ui.replayStopBtn.Show()
+			} else {
+				ui.replayStopBtn.Hide()
+			}
+		}
+	})
+	ui.updateTray()
+}
@@ -1,28 +1,47 @@
 package uiapp
 
 import (
-	"image/color"
-	"strconv"
-	"time"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"image/color"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/almightynan/swiftcap/internal/backend"
+	"github.com/almightynan/swiftcap/internal/record"
 )
 
 // Plain-language explanations shown by the "?" tips next to each setting.
 const (
 	tipFPS       = "Frames captured per second. Higher looks smoother but uses more CPU and disk. 30 is fine for most recordings; use 60 for fast motion or gaming."
 	tipBitrate   = "Target video quality, in kbit/s. Higher means better quality and larger files. Around 4000-8000 suits 1080p."
-	tipAudio     = "Record audio alongside the video."
+	tipAudio     = "Record audio alongside the video. Pick the sources from the Audio button on the main window."
 	tipCursor    = "Show the mouse cursor in the recording."
-	tipContainer = "Output file format. MP4 is the most widely compatible. MKV is more robust: the file stays playable even if the recording is interrupted."
+	tipContainer = "Output file format. MP4 is the most widely compatible. MKV is more robust: the file stays playable even if the recording is interrupted. GIF, WebP and APNG make short silent clips for chat and PRs, using the settings at the bottom."
 	tipMaxDur    = "Automatically stop recording after this many seconds. 0 means record until you press stop."
+	tipReplay    = "How many seconds the replay buffer keeps. Save Replay writes about this much, up to the moment you press it."
 	tipThreads   = "How many CPU threads the encoder may use. 0 lets ffmpeg pick the best value (recommended)."
 	tipQP        = "Constant Quantizer: fixes quality instead of bitrate. Lower values mean better quality and bigger files. 0 disables it and uses the bitrate above."
 	tipNice      = "Process priority (the Linux 'nice' value). Higher numbers give other apps more CPU. 0 is normal; raise it if recording slows your system."
+	tipBackend   = "What captures the screen. auto picks the best one that works here; `swiftcap backends` lists them and what each can do."
+	tipCodec     = "Video encoder. x264 plays everywhere. x265 and AV1 make smaller files but use more CPU. VP9 suits the web (WebM). FFV1 is lossless and very large."
+	tipPreset    = "Encoder speed/quality trade-off. Faster presets use less CPU; slower ones compress better. Leave on default unless you know you need it."
+	tipCRF       = "Constant Rate Factor: keeps quality constant and lets the file size vary. Lower is better quality. -1 turns it off and uses the bitrate above."
+	tipACodec    = "Audio encoder. Default picks AAC, or Opus for WebM."
+	tipAnimFPS   = "Frame rate of GIF, WebP and APNG files, including Export as GIF. 10-15 keeps them small; 0 keeps the recorded rate."
+	tipAnimWidth = "Largest width of GIF, WebP and APNG files; the height follows. Smaller recordings are never enlarged. 0 keeps the recorded size."
+	tipDither    = "How GIF and APNG fake colours their 256-colour palette lacks. sierra2_4a looks best for most screens; bayer compresses better; none is sharpest on flat UI."
+	tipLoop      = "How many times GIF, WebP and APNG files play. 0 loops forever."
+	tipHotkeys   = "Keys that work from any app. Click a binding and press the new combination; Esc keeps the old one, Backspace unbinds it. A combination another app already uses is reported when you save."
+	tipVideoDir  = "Folder recordings and replays are saved in. Leave empty for $SWIFTCAP_VIDEOS_DIR or your Videos folder."
+	tipShotDir   = "Folder screenshots and markups are saved in. Leave empty for $SWIFTCAP_SCREENSHOTS_DIR or your Pictures folder."
+	tipTemplate  = "File name, with optional subfolders, filled in per capture: {date} and {time} (with an optional Go layout, e.g. {date:2006-01-02}), {mode}, {monitor}, {window_class} and {ext}. A name that's taken gets -2, -3, ... added."
+	tipBudget    = "Warn when a GIF, WebP or APNG file comes out bigger than this many MB. Many chat apps and PR hosts cap uploads around 10 MB. 0 never warns."
 )
 
 type settingsWindow struct {
@@ -35,10 +54,29 @@ type settingsWindow struct {
 	audioCheck   *widget.Check
 	cursorCheck  *widget.Check
 	containerSel *widget.Select
+	codecSel     *widget.Select
+	presetSel    *widget.Select
+	crfEntry     *widget.Entry
+	aCodecSel    *widget.Select
 	maxDurEntry  *widget.Entry
+	replayEntry  *widget.Entry
 	threadsEntry *widget.Entry
 	qpEntry      *widget.Entry
 	niceEntry    *widget.Entry
+	backendSel   *widget.Select
+
+	animFpsEntry   *widget.Entry
+	animWidthEntry *widget.Entry
+	ditherSel      *widget.Select
+	loopEntry      *widget.Entry
+	budgetEntry    *widget.Entry // MB
+
+	hotkeyBtns map[string]*hotkeyButton // by hotkey action id
+
+	videoDirEntry  *widget.Entry
+	shotDirEntry   *widget.Entry
+	videoTmplEntry *widget.Entry
+	shotTmplEntry  *widget.Entry
 
 	saveBtn     *hoverButton
 	dirtyBox    *fyne.Container
@@ -73,13 +111,34 @@ func (ui *RecordingUI) showSettings() {
 	sw.cursorCheck = widget.NewCheck("Show Cursor", func(bool) { sw.refreshDirty() })
 	sw.cursorCheck.SetChecked(sw.config.GetCursor())
 
-	sw.containerSel = widget.NewSelect([]string{"mp4", "mkv", "mov", "avi"}, func(string) { sw.refreshDirty() })
+	sw.containerSel = widget.NewSelect(record.Containers(), func(string) { sw.refreshDirty() })
 	sw.containerSel.SetSelected(sw.config.GetContainer())
 
+	// The preset list depends on the codec, so changing codec repopulates it.
+	sw.presetSel = widget.NewSelect(nil, func(string) { sw.refreshDirty() })
+	sw.codecSel = widget.NewSelect(record.EncoderNames(), func(codec string) {
+		sw.fillPresets(codec)
+		sw.refreshDirty()
+	})
+	sw.codecSel.SetSelected(sw.config.GetCodec())
+	sw.fillPresets(sw.config.GetCodec())
+	sw.presetSel.SetSelected(presetLabel(sw.config.GetPreset()))
+
+	sw.crfEntry = widget.NewEntry()
+	sw.crfEntry.SetText(strconv.Itoa(sw.config.GetCRF()))
+	sw.crfEntry.SetPlaceHolder("-1")
+
+	sw.aCodecSel = widget.NewSelect(append([]string{defaultChoice}, record.AudioCodecNames()...), func(string) { sw.refreshDirty() })
+	sw.aCodecSel.SetSelected(presetLabel(sw.config.GetAudioCodec()))
+
 	sw.maxDurEntry = widget.NewEntry()
 	sw.maxDurEntry.SetText(strconv.Itoa(sw.config.GetMaxDur()))
 	sw.maxDurEntry.SetPlaceHolder("0")
 
+	sw.replayEntry = widget.NewEntry()
+	sw.replayEntry.SetText(strconv.Itoa(sw.config.GetReplaySecs()))
+	sw.replayEntry.SetPlaceHolder("60")
+
 	sw.threadsEntry = widget.NewEntry()
 	sw.threadsEntry.SetText(strconv.Itoa(sw.config.GetThreads()))
 	sw.threadsEntry.SetPlaceHolder("0")
@@ -92,10 +151,57 @@ func (ui *RecordingUI) showSettings() {
 	sw.niceEntry.SetText(strconv.Itoa(sw.config.GetNice()))
 	sw.niceEntry.SetPlaceHolder("0")
 
+	sw.backendSel = widget.NewSelect(append([]string{backend.Auto}, backend.RecorderNames()...), func(string) { sw.refreshDirty() })
+	sw.backendSel.SetSelected(sw.config.GetBackend())
+
+	anim := sw.config.GetAnim()
+	sw.animFpsEntry = widget.NewEntry()
+	sw.animFpsEntry.SetText(strconv.Itoa(anim.Fps))
+	sw.animFpsEntry.SetPlaceHolder("15")
+
+	sw.animWidthEntry = widget.NewEntry()
+	sw.animWidthEntry.SetText(strconv.Itoa(anim.Width))
+	sw.animWidthEntry.SetPlaceHolder("960")
+
+	sw.ditherSel = widget.NewSelect(record.Dithers(), func(string) { sw.refreshDirty() })
+	sw.ditherSel.SetSelected(anim.Dither)
+
+	sw.loopEntry = widget.NewEntry()
+	sw.loopEntry.SetText(strconv.Itoa(anim.Loop))
+	sw.loopEntry.SetPlaceHolder("0")
+
+	sw.budgetEntry = widget.NewEntry()
+	sw.budgetEntry.SetText(budgetMB(anim.Budget))
+	sw.budgetEntry.SetPlaceHolder("10")
+
+	sw.videoDirEntry = widget.NewEntry()
+	sw.videoDirEntry.SetText(sw.config.GetVideoDir())
+	sw.videoDirEntry.SetPlaceHolder("Videos folder")
+
+	sw.shotDirEntry = widget.NewEntry()
+	sw.shotDirEntry.SetText(sw.config.GetShotDir())
+	sw.shotDirEntry.SetPlaceHolder("Pictures folder")
+
+	sw.videoTmplEntry = widget.NewEntry()
+	sw.videoTmplEntry.SetText(sw.config.GetVideoTemplate())
+	sw.videoTmplEntry.SetPlaceHolder(defaultVideoTemplate)
+
+	sw.shotTmplEntry = widget.NewEntry()
+	sw.shotTmplEntry.SetText(sw.config.GetShotTemplate())
+	sw.shotTmplEntry.SetPlaceHolder(defaultShotTemplate)
+
+	sw.hotkeyBtns = map[string]*hotkeyButton{}
+	keys := sw.config.GetHotkeys()
+	for _, a := range hotkeyActions {
+		sw.hotkeyBtns[a.id] = newHotkeyButton(keys[a.id], func(string) { sw.refreshDirty() })
+	}
+
 	// Wire entry edits to dirty tracking (assigned after SetText so the initial
 	// values don't count as changes).
 	for _, e := range []*widget.Entry{
-		sw.fpsEntry, sw.bitrateEntry, sw.maxDurEntry, sw.threadsEntry, sw.qpEntry, sw.niceEntry,
+		sw.fpsEntry, sw.bitrateEntry, sw.crfEntry, sw.maxDurEntry, sw.replayEntry, sw.threadsEntry, sw.qpEntry, sw.niceEntry,
+		sw.animFpsEntry, sw.animWidthEntry, sw.loopEntry, sw.budgetEntry,
+		sw.videoDirEntry, sw.shotDirEntry, sw.videoTmplEntry, sw.shotTmplEntry,
 	} {
 		e.OnChanged = func(string) { sw.refreshDirty() }
 	}
@@ -151,10 +257,32 @@ func (ui *RecordingUI) showSettings() {
 		container.NewHBox(sw.cursorCheck, sw.helpTip(tipCursor)),
 		sw.tipRow("Container", tipContainer, sw.containerSel),
 		widget.NewSeparator(),
+		sw.tipRow("Codec", tipCodec, sw.codecSel),
+		sw.tipRow("Preset", tipPreset, sw.presetSel),
+		sw.tipRow("CRF (-1 = use bitrate)", tipCRF, sw.crfEntry),
+		sw.tipRow("Audio Codec", tipACodec, sw.aCodecSel),
+		widget.NewSeparator(),
 		sw.tipRow("Max Duration (s, 0 = unlimited)", tipMaxDur, sw.maxDurEntry),
+		sw.tipRow("Replay Buffer (s)", tipReplay, sw.replayEntry),
 		sw.tipRow("Threads (0 = auto)", tipThreads, sw.threadsEntry),
 		sw.tipRow("QP (0 = use bitrate)", tipQP, sw.qpEntry),
 		sw.tipRow("Nice Priority (0 = default)", tipNice, sw.niceEntry),
+		sw.tipRow("Capture Backend", tipBackend, sw.backendSel),
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("GIF / WebP / APNG", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		sw.tipRow("Animated FPS (0 = as recorded)", tipAnimFPS, sw.animFpsEntry),
+		sw.tipRow("Max Width (0 = as recorded)", tipAnimWidth, sw.animWidthEntry),
+		sw.tipRow("Dithering", tipDither, sw.ditherSel),
+		sw.tipRow("Plays (0 = loop forever)", tipLoop, sw.loopEntry),
+		sw.tipRow("Size Budget (MB, 0 = none)", tipBudget, sw.budgetEntry),
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("Output", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		sw.tipRow("Recordings Folder", tipVideoDir, sw.videoDirEntry),
+		sw.tipRow("Recording Names", tipTemplate, sw.videoTmplEntry),
+		sw.tipRow("Screenshots Folder", tipShotDir, sw.shotDirEntry),
+		sw.tipRow("Screenshot Names", tipTemplate, sw.shotTmplEntry),
+		widget.NewSeparator(),
+		sw.hotkeyRows(),
 	)
 
 	scroll := container.NewVScroll(fields)
@@ -300,12 +428,69 @@ func (sw *settingsWindow) isDirty() bool {
 	return sw.fpsEntry.Text != strconv.Itoa(c.GetFPS()) ||
 		sw.bitrateEntry.Text != strconv.Itoa(c.GetBitrate()) ||
 		sw.maxDurEntry.Text != strconv.Itoa(c.GetMaxDur()) ||
+		sw.replayEntry.Text != strconv.Itoa(c.GetReplaySecs()) ||
 		sw.threadsEntry.Text != strconv.Itoa(c.GetThreads()) ||
 		sw.qpEntry.Text != strconv.Itoa(c.GetQP()) ||
 		sw.niceEntry.Text != strconv.Itoa(c.GetNice()) ||
+		sw.backendSel.Selected != c.GetBackend() ||
 		sw.audioCheck.Checked != c.GetAudio() ||
 		sw.cursorCheck.Checked != c.GetCursor() ||
-		sw.containerSel.Selected != c.GetContainer()
+		sw.containerSel.Selected != c.GetContainer() ||
+		sw.codecSel.Selected != c.GetCodec() ||
+		sw.presetSel.Selected != presetLabel(c.GetPreset()) ||
+		sw.crfEntry.Text != strconv.Itoa(c.GetCRF()) ||
+		sw.aCodecSel.Selected != presetLabel(c.GetAudioCodec()) ||
+		sw.animFpsEntry.Text != strconv.Itoa(c.GetAnim().Fps) ||
+		sw.animWidthEntry.Text != strconv.Itoa(c.GetAnim().Width) ||
+		sw.ditherSel.Selected != c.GetAnim().Dither ||
+		sw.loopEntry.Text != strconv.Itoa(c.GetAnim().Loop) ||
+		sw.budgetEntry.Text != budgetMB(c.GetAnim().Budget) ||
+		sw.outputDirty() ||
+		sw.hotkeysDirty()
+}
+
+// outputDirty reports whether the Output folders or templates were edited.
+func (sw *settingsWindow) outputDirty() bool {
+	c := sw.config
+	return sw.videoDirEntry.Text != c.GetVideoDir() ||
+		sw.shotDirEntry.Text != c.GetShotDir() ||
+		sw.videoTmplEntry.Text != c.GetVideoTemplate() ||
+		sw.shotTmplEntry.Text != c.GetShotTemplate()
+}
+
+// budgetMB shows a size budget in the MB the settings use.
+func budgetMB(b int64) string {
+	return strconv.FormatFloat(float64(b)/(1<<20), 'f', -1, 64)
+}
+
+// defaultChoice is the select entry standing for "let the encoder decide".
+const defaultChoice = "default"
+
+// presetLabel maps an empty (default) config value onto its select entry.
+func presetLabel(v string) string {
+	if v == "" {
+		return defaultChoice
+	}
+	return v
+}
+
+// fillPresets repopulates the preset select for codec, keeping the current
+// choice when the new codec also has it.
+func (sw *settingsWindow) fillPresets(codec string) {
+	opts := []string{defaultChoice}
+	if enc, ok := record.LookupEncoder(codec); ok {
+		opts = append(opts, enc.Presets...)
+	}
+	cur := sw.presetSel.Selected
+	sw.presetSel.Options = opts
+	keep := false
+	for _, o := range opts {
+		keep = keep || o == cur
+	}
+	if !keep {
+		sw.presetSel.Selected = defaultChoice
+	}
+	sw.presetSel.Refresh()
 }
 
 // refreshDirty updates the Save button and the "unsaved changes" indicator. It's
@@ -336,9 +521,38 @@ func (sw *settingsWindow) refreshDirty() {
 // onSave applies + persists the settings, then flashes "Saved!" on the button
 // (without closing the modal) and reverts it back to a disabled "Save".
 func (sw *settingsWindow) onSave() {
+	// Refuse combinations the recorder would reject (e.g. VP9 in MP4) here,
+	// rather than when the next recording fails to start.
+	err := sw.stagedOptions().Validate()
+	if err == nil {
+		err = sw.stagedAnim().Validate()
+	}
+	if err == nil {
+		err = checkHotkeys(sw.stagedHotkeys())
+	}
+	if err == nil {
+		err = checkOutput(sw.videoDirEntry.Text, sw.shotDirEntry.Text, sw.videoTmplEntry.Text, sw.shotTmplEntry.Text)
+	}
+	if err != nil {
+		if sw.ui != nil && sw.ui.mainWin != nil {
+			dialog.ShowError(err, sw.ui.mainWin)
+		}
+		return
+	}
+	rebind := sw.hotkeysDirty()
+	moved := sw.outputDirty()
 	sw.save()
 	if sw.ui != nil {
 		sw.ui.persistConfig()
+		if rebind {
+			sw.ui.applyHotkeys()
+		}
+		if moved {
+			sw.ui.mu.Lock()
+			sw.ui.videosDir = ""
+			sw.ui.mu.Unlock()
+			go sw.ui.refreshRecordingsList()
+		}
 	}
 
 	sw.dirtyBox.Hide()
@@ -363,6 +577,52 @@ func (sw *settingsWindow) onSave() {
 	})
 }
 
+// stagedOptions returns the encode settings as currently shown in the window.
+func (sw *settingsWindow) stagedOptions() record.Options {
+	o := record.DefaultOptions()
+	o.Container = sw.containerSel.Selected
+	o.Codec = sw.codecSel.Selected
+	if p := sw.presetSel.Selected; p != defaultChoice {
+		o.Preset = p
+	}
+	if crf, err := strconv.Atoi(sw.crfEntry.Text); err == nil {
+		o.CRF = crf
+	}
+	o.Audio = sw.audioCheck.Checked
+	if a := sw.aCodecSel.Selected; a != defaultChoice {
+		o.AudioCodec = a
+	}
+	// sources and track layout come from the audio picker
+	for _, name := range sw.config.GetAudioSources() {
+		o.ASrc = append(o.ASrc, record.AudioSource{Name: name})
+	}
+	o.AudioTracks = sw.config.GetAudioTracks()
+	o.Anim = sw.stagedAnim()
+	return o
+}
+
+// stagedAnim returns the animated export settings as shown, falling back to
+// the saved ones for fields that don't parse.
+func (sw *settingsWindow) stagedAnim() record.AnimOptions {
+	a := sw.config.GetAnim()
+	if v, err := strconv.Atoi(sw.animFpsEntry.Text); err == nil {
+		a.Fps = v
+	}
+	if v, err := strconv.Atoi(sw.animWidthEntry.Text); err == nil {
+		a.Width = v
+	}
+	if sw.ditherSel.Selected != "" {
+		a.Dither = sw.ditherSel.Selected
+	}
+	if v, err := strconv.Atoi(sw.loopEntry.Text); err == nil {
+		a.Loop = v
+	}
+	if v, err := strconv.ParseFloat(sw.budgetEntry.Text, 64); err == nil {
+		a.Budget = int64(v * (1 << 20))
+	}
+	return a
+}
+
 // save applies the staged widget values to the config and normalizes the entry
 // text back to the validated values (so invalid input snaps back and the fields
 // read as clean afterwards).
@@ -377,6 +637,9 @@ func (sw *settingsWindow) save() {
 	if maxDur, err := strconv.Atoi(sw.maxDurEntry.Text); err == nil && maxDur >= 0 {
 		c.SetMaxDur(maxDur)
 	}
+	if secs, err := strconv.Atoi(sw.replayEntry.Text); err == nil && secs >= 2*record.ReplaySegment && secs <= 3600 {
+		c.SetReplaySecs(secs)
+	}
 	if threads, err := strconv.Atoi(sw.threadsEntry.Text); err == nil && threads >= 0 {
 		c.SetThreads(threads)
 	}
@@ -386,11 +649,44 @@ func (sw *settingsWindow) save() {
 	if nice, err := strconv.Atoi(sw.niceEntry.Text); err == nil {
 		c.SetNice(nice)
 	}
+	if sw.backendSel.Selected != "" {
+		c.SetBackend(sw.backendSel.Selected)
+	}
 	c.SetAudio(sw.audioCheck.Checked)
 	c.SetCursor(sw.cursorCheck.Checked)
 	if sw.containerSel.Selected != "" {
 		c.SetContainer(sw.containerSel.Selected)
 	}
+	if sw.codecSel.Selected != "" {
+		c.SetCodec(sw.codecSel.Selected)
+	}
+	if p := sw.presetSel.Selected; p != "" && p != defaultChoice {
+		c.SetPreset(p)
+	} else {
+		c.SetPreset("")
+	}
+	if crf, err := strconv.Atoi(sw.crfEntry.Text); err == nil && crf >= -1 {
+		c.SetCRF(crf)
+	}
+	if a := sw.aCodecSel.Selected; a != "" && a != defaultChoice {
+		c.SetAudioCodec(a)
+	} else {
+		c.SetAudioCodec("")
+	}
+	if a := sw.stagedAnim(); a.Validate() == nil {
+		c.SetAnim(a)
+	}
+	if keys := sw.stagedHotkeys(); checkHotkeys(keys) == nil {
+		c.SetHotkeys(keys)
+	}
+	vd, sd := strings.TrimSpace(sw.videoDirEntry.Text), strings.TrimSpace(sw.shotDirEntry.Text)
+	vt, st := strings.TrimSpace(sw.videoTmplEntry.Text), strings.TrimSpace(sw.shotTmplEntry.Text)
+	if checkOutput(vd, sd, vt, st) == nil {
+		c.SetVideoDir(vd)
+		c.SetShotDir(sd)
+		c.SetVideoTemplate(vt)
+		c.SetShotTemplate(st)
+	}
 
 	// Snap entries back to the validated config values (also clears dirtiness
 	// from any rejected input). OnChanged handlers are inert here since the text
@@ -398,9 +694,19 @@ func (sw *settingsWindow) save() {
 	sw.fpsEntry.SetText(strconv.Itoa(c.GetFPS()))
 	sw.bitrateEntry.SetText(strconv.Itoa(c.GetBitrate()))
 	sw.maxDurEntry.SetText(strconv.Itoa(c.GetMaxDur()))
+	sw.replayEntry.SetText(strconv.Itoa(c.GetReplaySecs()))
 	sw.threadsEntry.SetText(strconv.Itoa(c.GetThreads()))
 	sw.qpEntry.SetText(strconv.Itoa(c.GetQP()))
 	sw.niceEntry.SetText(strconv.Itoa(c.GetNice()))
+	sw.crfEntry.SetText(strconv.Itoa(c.GetCRF()))
+	sw.videoDirEntry.SetText(c.GetVideoDir())
+	sw.shotDirEntry.SetText(c.GetShotDir())
+	sw.videoTmplEntry.SetText(c.GetVideoTemplate())
+	sw.shotTmplEntry.SetText(c.GetShotTemplate())
+	sw.animFpsEntry.SetText(strconv.Itoa(c.GetAnim().Fps))
+	sw.animWidthEntry.SetText(strconv.Itoa(c.GetAnim().Width))
+	sw.loopEntry.SetText(strconv.Itoa(c.GetAnim().Loop))
+	sw.budgetEntry.SetText(budgetMB(c.GetAnim().Budget))
 
 	if sw.ui != nil {
 		sw.ui.syncQuickControls()
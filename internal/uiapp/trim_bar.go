@@ -0,0 +1,402 @@
+package uiapp
+
+import (
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/almightynan/swiftcap/internal/edit"
+	"github.com/almightynan/swiftcap/internal/record"
+)
+
+// ── trim bar ────────────────────────────────────────────────────────────────
+//
+// trimBar lays draggable in/out handles over the player's seek bar in the
+// capture viewer. The part that will be kept is tinted with the accent colour
+// along the track; dragging a handle calls onScrub so the player can preview
+// the frame under it, and onChange with where it was dropped. Clicks and drags
+// away from the handles fall through to the seek bar underneath.
+
+const (
+	trimHandleW = 4  // the visible handle
+	trimGrabW   = 14 // the area that picks it up
+	trimHandleH = 22
+	trimKeptH   = 6
+	trimMinGap  = 0.1 // seconds between the handles
+)
+
+const (
+	trimIn = iota + 1
+	trimOut
+)
+
+type trimBar struct {
+	widget.BaseWidget
+	duration float64
+	in, out  float64
+
+	onScrub  func(handle int, pos float64)
+	onChange func(handle int, pos float64)
+
+	kept      *canvas.Rectangle
+	inH, outH *trimHandle
+}
+
+func newTrimBar(duration float64) *trimBar {
+	b := &trimBar{duration: duration, out: duration}
+	accent := toNRGBA(theme.PrimaryColor())
+	b.kept = canvas.NewRectangle(color.NRGBA{accent.R, accent.G, accent.B, 0x66})
+	b.kept.CornerRadius = trimKeptH / 2
+	b.inH = newTrimHandle(b, trimIn, accent)
+	b.outH = newTrimHandle(b, trimOut, accent)
+	b.ExtendBaseWidget(b)
+	return b
+}
+
+// changed reports whether the handles have been moved off the ends.
+func (b *trimBar) changed() bool {
+	return b.in > 0 || b.out < b.duration
+}
+
+// seekEndPad is how far in from each end widget.Slider starts its track, so
+// the handles line up with the seek bar's own positions.
+func seekEndPad() float32 {
+	return (theme.IconInlineSize()-4)/2 + theme.InnerPadding() - 1.5
+}
+
+// posAt maps an x coordinate on the bar to a time in the video.
+func (b *trimBar) posAt(x float32) float64 {
+	pad := seekEndPad()
+	w := b.Size().Width - 2*pad
+	if w <= 0 || b.duration <= 0 {
+		return 0
+	}
+	return clampF(float64((x-pad)/w)*b.duration, 0, b.duration)
+}
+
+// xAt maps a time to its x coordinate on the bar.
+func (b *trimBar) xAt(pos float64) float32 {
+	pad := seekEndPad()
+	if b.duration <= 0 {
+		return pad
+	}
+	return pad + float32(pos/b.duration)*(b.Size().Width-2*pad)
+}
+
+// drag moves handle to x and reports the time it landed on.
+func (b *trimBar) drag(handle int, x float32) float64 {
+	t := b.posAt(x)
+	if handle == trimIn {
+		b.in = clampF(t, 0, b.out-trimMinGap)
+		t = b.in
+	} else {
+		b.out = clampF(t, b.in+trimMinGap, b.duration)
+		t = b.out
+	}
+	b.Refresh()
+	return t
+}
+
+func (b *trimBar) MinSize() fyne.Size {
+	b.ExtendBaseWidget(b)
+	return fyne.NewSize(2*trimGrabW, trimHandleH)
+}
+
+func (b *trimBar) CreateRenderer() fyne.WidgetRenderer {
+	return &trimBarRenderer{b: b, objs: []fyne.CanvasObject{b.kept, b.inH, b.outH}}
+}
+
+type trimBarRenderer struct {
+	b    *trimBar
+	objs []fyne.CanvasObject
+}
+
+func (r *trimBarRenderer) Layout(sz fyne.Size) {
+	b := r.b
+	x0, x1 := b.xAt(b.in), b.xAt(b.out)
+	b.kept.Resize(fyne.NewSize(x1-x0, trimKeptH))
+	b.kept.Move(fyne.NewPos(x0, (sz.Height-trimKeptH)/2))
+	for _, h := range []struct {
+		w *trimHandle
+		x float32
+	}{{b.inH, x0}, {b.outH, x1}} {
+		h.w.Resize(fyne.NewSize(trimGrabW, trimHandleH))
+		h.w.Move(fyne.NewPos(h.x-trimGrabW/2, (sz.Height-trimHandleH)/2))
+	}
+}
+
+func (r *trimBarRenderer) MinSize() fyne.Size { return r.b.MinSize() }
+func (r *trimBarRenderer) Refresh() {
+	r.Layout(r.b.Size())
+	canvas.Refresh(r.b)
+}
+func (r *trimBarRenderer) Objects() []fyne.CanvasObject { return r.objs }
+func (r *trimBarRenderer) Destroy()                     {}
+
+// trimHandle is one of the bar's two handles: a thin accent bar in a wider
+// grab area.
+type trimHandle struct {
+	widget.BaseWidget
+	bar      *trimBar
+	which    int
+	rect     *canvas.Rectangle
+	dragging bool
+}
+
+func newTrimHandle(b *trimBar, which int, c color.Color) *trimHandle {
+	h := &trimHandle{bar: b, which: which, rect: canvas.NewRectangle(c)}
+	h.rect.CornerRadius = trimHandleW / 2
+	h.rect.SetMinSize(fyne.NewSize(trimHandleW, trimHandleH))
+	h.ExtendBaseWidget(h)
+	return h
+}
+
+func (h *trimHandle) Dragged(ev *fyne.DragEvent) {
+	h.dragging = true
+	t := h.bar.drag(h.which, h.Position().X+ev.Position.X)
+	if h.bar.onScrub != nil {
+		h.bar.onScrub(h.which, t)
+	}
+}
+
+func (h *trimHandle) DragEnd() {
+	if !h.dragging {
+		return
+	}
+	h.dragging = false
+	pos := h.bar.out
+	if h.which == trimIn {
+		pos = h.bar.in
+	}
+	if h.bar.onChange != nil {
+		h.bar.onChange(h.which, pos)
+	}
+}
+
+func (h *trimHandle) Cursor() desktop.Cursor { return desktop.HResizeCursor }
+
+func (h *trimHandle) MinSize() fyne.Size {
+	h.ExtendBaseWidget(h)
+	return fyne.NewSize(trimGrabW, trimHandleH)
+}
+
+func (h *trimHandle) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(container.NewCenter(h.rect))
+}
+
+func clampF(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// ── trim controls ───────────────────────────────────────────────────────────
+
+const trimThumbW, trimThumbH = 160, 90
+
+// trimControls puts trim handles on the player's seek bar and builds the trim
+// row for the capture viewer: the kept range, a "Precise (re-encode)" option
+// and the two save buttons. It has to be called before object. done is called
+// on the UI thread with the file that was written.
+func (p *videoPlayer) trimControls(done func(out string)) fyne.CanvasObject {
+	bar := newTrimBar(p.duration)
+	p.trim = bar
+	rangeLbl := widget.NewLabel("")
+	rangeLbl.TextStyle = fyne.TextStyle{Monospace: true}
+	showRange := func() {
+		rangeLbl.SetText(fmt.Sprintf("%s – %s  (%s)", fmtDur(bar.in), fmtDur(bar.out), fmtDur(bar.out-bar.in)))
+	}
+	showRange()
+
+	// Dragging a handle floats a small frameAt thumbnail above it. Frames are
+	// grabbed one at a time; the latest position wins.
+	thumb := canvas.NewImageFromImage(nil)
+	thumb.FillMode = canvas.ImageFillContain
+	thumb.SetMinSize(fyne.NewSize(trimThumbW, trimThumbH))
+	thumbLbl := canvas.NewText("", color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	thumbLbl.TextSize = 12
+	thumbLbl.Alignment = fyne.TextAlignCenter
+	var popup *widget.PopUp
+	var thumbMu sync.Mutex
+	var want float64
+	busy := false
+	var grab func()
+	grab = func() {
+		thumbMu.Lock()
+		pos := want
+		thumbMu.Unlock()
+		im := frameAt(p.path, pos, trimThumbW, trimThumbH)
+		p.ui.runOnMain(func() {
+			if im != nil {
+				thumb.Image = im
+				thumb.Refresh()
+			}
+			thumbMu.Lock()
+			again := want != pos
+			busy = again
+			thumbMu.Unlock()
+			if again {
+				go grab()
+			}
+		})
+	}
+	bar.onScrub = func(handle int, pos float64) {
+		showRange()
+		if popup == nil {
+			bg := canvas.NewRectangle(color.NRGBA{0x12, 0x12, 0x12, 0xff})
+			popup = widget.NewPopUp(container.NewStack(bg, container.NewVBox(thumb, thumbLbl)), p.cv)
+		}
+		thumbLbl.Text = fmtDur(pos)
+		thumbLbl.Refresh()
+		at := fyne.CurrentApp().Driver().AbsolutePositionForObject(bar)
+		sz := popup.MinSize()
+		popup.ShowAtPosition(fyne.NewPos(at.X+bar.xAt(pos)-sz.Width/2, at.Y-sz.Height-6))
+		thumbMu.Lock()
+		want = pos
+		start := !busy
+		busy = true
+		thumbMu.Unlock()
+		if start {
+			go grab()
+		}
+	}
+	bar.onChange = func(_ int, pos float64) {
+		if popup != nil {
+			popup.Hide()
+		}
+		showRange()
+		// park the player, paused, on the dropped handle so the frame shown is
+		// where the cut will be
+		p.pause()
+		p.seekTo(pos)
+	}
+
+	precise := widget.NewCheck("Precise (re-encode)", nil)
+
+	ghost := color.NRGBA{0xff, 0xff, 0xff, 0x12}
+	ghostHover := color.NRGBA{0xff, 0xff, 0xff, 0x26}
+	dim := color.NRGBA{0xcf, 0xcf, 0xd8, 0xff}
+	cut := func(replace bool) {
+		if !bar.changed() {
+			p.ui.showInfo("Trim", "Drag the handles on the seek bar to choose the part to keep.")
+			return
+		}
+		e := edit.Edit{Precise: precise.Checked}
+		e.Trim.Start = time.Duration(bar.in * float64(time.Second))
+		if bar.out < p.duration {
+			e.Trim.End = time.Duration(bar.out * float64(time.Second))
+		}
+		if !replace {
+			p.pause()
+			go p.ui.saveTrim(p.path, e, false, done)
+			return
+		}
+		dialog.ShowConfirm("Replace original?",
+			fmt.Sprintf("Keep only %s of %s and overwrite the original file?", fmtDur(bar.out-bar.in), filepath.Base(p.path)),
+			func(ok bool) {
+				if ok {
+					p.pause()
+					go p.ui.saveTrim(p.path, e, true, done)
+				}
+			}, p.ui.mainWin)
+	}
+	saveBtn := newCleanButton(theme.DocumentSaveIcon(), "Save trimmed copy", ghost, ghostHover, dim, func() { cut(false) })
+	replaceBtn := newCleanButton(theme.ContentCutIcon(), "Replace original", ghost, ghostHover, dim, func() { cut(true) })
+
+	return container.NewCenter(container.NewHBox(rangeLbl, precise, saveBtn, replaceBtn))
+}
+
+// saveTrim cuts path per e, to a "_trimmed" sibling or over the original, and
+// hands the result to done. Without Precise the cut is a stream copy starting
+// at the keyframe before the in point.
+func (ui *RecordingUI) saveTrim(path string, e edit.Edit, replace bool, done func(out string)) {
+	ext := filepath.Ext(path)
+	out := uniqueSibling(strings.TrimSuffix(path, ext)+"_trimmed"+ext, ext)
+	if replace {
+		out = filepath.Join(filepath.Dir(path), ".swiftcap_trim_"+filepath.Base(path))
+	}
+	opts := ui.trimOptions(path)
+	if e.Precise {
+		ui.setStatus(fmt.Sprintf("Trimming with %s...", opts.Codec))
+	} else {
+		ui.setStatus("Trimming...")
+	}
+	res, err := edit.Apply(path, out, e, opts.Encoding())
+	if err == nil && replace {
+		// out stays the temp file until the rename lands, so a failure
+		// below removes that and never the original
+		if err = os.Rename(out, path); err == nil {
+			os.Remove(path + ".thumb.jpg") // regenerated from the new first frame
+			out = path
+		}
+	}
+	if err != nil {
+		os.Remove(out)
+		ui.showError("Trim", fmt.Sprintf("Failed to trim %s: %v", filepath.Base(path), err))
+		ui.setStatus("Ready")
+		return
+	}
+	ui.setStatus("Trimmed")
+	ui.refreshRecordingsList()
+	ui.runOnMain(func() {
+		done(out)
+		if res.Copied && res.Start != e.Trim.Start {
+			ui.showInfo("Trim", fmt.Sprintf("Cut at the keyframe at %s, %.1fs before the in point. Tick \"Precise (re-encode)\" for an exact cut.",
+				fmtDur(res.Start.Seconds()), (e.Trim.Start-res.Start).Seconds()))
+		}
+	})
+}
+
+// trimOptions is what a precise trim re-encodes with: the recorder's settings,
+// in the container the file already uses. A codec that can't go in that
+// container falls back to one that can.
+func (ui *RecordingUI) trimOptions(path string) record.Options {
+	c := ui.config
+	o := record.DefaultOptions()
+	o.Container = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if o.Container == "m4v" {
+		o.Container = "mp4"
+	}
+	if codec := c.GetCodec(); codec != "" {
+		o.Codec = codec
+	}
+	o.Preset = c.GetPreset()
+	o.CRF = c.GetCRF()
+	o.QP = c.GetQP()
+	o.Bitrate = c.GetBitrate()
+	o.Threads = c.GetThreads()
+	o.Audio = true // whatever audio the file has is carried over
+	o.AudioCodec = c.GetAudioCodec()
+	if o.Validate() == nil {
+		return o
+	}
+	o.Codec, o.Preset, o.AudioCodec = "x264", "", ""
+	if o.Container == "webm" {
+		o.Codec = "vp9"
+	}
+	if enc, ok := record.LookupEncoder(o.Codec); ok {
+		if o.CRF > enc.MaxCRF {
+			o.CRF = enc.DefaultCRF
+		}
+		if o.QP > enc.MaxQP {
+			o.QP = 0
+		}
+	}
+	return o
+}
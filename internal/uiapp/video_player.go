@@ -44,6 +44,9 @@ type videoPlayer struct {
 	volBtn   *hoverButton
 	fsBtn    *hoverButton
 
+	// trim, if set, is laid over the seek bar (see trimControls).
+	trim *trimBar
+
 	// Fullscreen: set on the fullscreen instance; onExitFS closes its window.
 	fullscreen bool
 	onExitFS   func()
@@ -204,7 +207,11 @@ func (p *videoPlayer) object() fyne.CanvasObject {
 	barBg := canvas.NewRectangle(color.NRGBA{0x00, 0x00, 0x00, 0xb0})
 	left := container.NewHBox(p.playBtn, p.back10, p.fwd10)
 	right := container.NewHBox(p.timeLbl, p.volBtn, p.fsBtn)
-	bar := container.NewBorder(nil, nil, left, right, p.seek)
+	var seek fyne.CanvasObject = p.seek
+	if p.trim != nil {
+		seek = container.NewStack(p.seek, p.trim)
+	}
+	bar := container.NewBorder(nil, nil, left, right, seek)
 	barStack := container.NewStack(barBg, container.NewPadded(bar))
 
 	// Pin the bar to the bottom of the frame via a top spacer, stacked over it.
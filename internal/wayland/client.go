@@ -0,0 +1,273 @@
+package wayland
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+)
+
+// Output is one wl_output, placed in the compositor's layout in logical
+// (scaled) pixels, the coordinates regions are given in.
+type Output struct {
+	Index       int     `json:"index"`
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	X           int     `json:"x"`
+	Y           int     `json:"y"`
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+	Scale       float64 `json:"scale"`
+}
+
+// Rect is the output's part of the layout.
+func (o Output) Rect() image.Rectangle {
+	return image.Rect(o.X, o.Y, o.X+o.Width, o.Y+o.Height)
+}
+
+// Region returns the output as a WxH+X+Y region string.
+func (o Output) Region() string {
+	return fmt.Sprintf("%dx%d+%d+%d", o.Width, o.Height, o.X, o.Y)
+}
+
+// Client is a connection with the globals we use bound.
+type Client struct {
+	c          *conn
+	globals    []global
+	shm        uint32
+	screencopy uint32
+	scVersion  uint32
+	xdgOutputs uint32
+	outputs    []*output
+	buf        *shmBuffer // kept between frames of the same size
+}
+
+type global struct {
+	name    uint32
+	iface   string
+	version uint32
+}
+
+// output is a bound wl_output and what it and its xdg_output said.
+type output struct {
+	id        uint32
+	Output    Output
+	x, y      int // wl_output.geometry, for compositors without xdg-output
+	modeW     int
+	modeH     int
+	transform int32
+	scale     int32
+	logical   bool // xdg_output gave the layout position and size
+}
+
+// Connect connects to $WAYLAND_DISPLAY and binds wl_shm, the outputs and,
+// where the compositor has them, xdg-output and wlr-screencopy.
+func Connect() (*Client, error) {
+	c, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	cl := &Client{c: c}
+	if err := cl.setup(); err != nil {
+		c.close()
+		return nil, err
+	}
+	return cl, nil
+}
+
+// Advertises reports whether the compositor on $WAYLAND_DISPLAY offers
+// the global interface iface, without binding anything.
+func Advertises(iface string) bool {
+	c, err := dial()
+	if err != nil {
+		return false
+	}
+	defer c.close()
+	cl := &Client{c: c}
+	if _, err := cl.registry(); err != nil {
+		return false
+	}
+	for _, g := range cl.globals {
+		if g.iface == iface {
+			return true
+		}
+	}
+	return false
+}
+
+// Close hangs up.
+func (cl *Client) Close() error {
+	cl.releaseBuffer()
+	return cl.c.close()
+}
+
+// registry lists the globals into cl.globals and returns the registry.
+func (cl *Client) registry() (uint32, error) {
+	registry := cl.c.newID(func(opcode uint16, m *message) {
+		if opcode == 0 { // global
+			cl.globals = append(cl.globals, global{name: m.u32(), iface: m.str(), version: m.u32()})
+		}
+	})
+	if err := cl.c.send(displayID, 1, registry); err != nil { // wl_display.get_registry
+		return 0, err
+	}
+	return registry, cl.c.roundtrip()
+}
+
+func (cl *Client) setup() error {
+	registry, err := cl.registry()
+	if err != nil {
+		return err
+	}
+	bind := func(g global, version uint32, h handler) uint32 {
+		id := cl.c.newID(h)
+		cl.c.send(registry, 0, g.name, g.iface, min(g.version, version), id)
+		return id
+	}
+	for _, g := range cl.globals {
+		switch g.iface {
+		case "wl_shm":
+			cl.shm = bind(g, 1, nil)
+		case "wl_output":
+			o := &output{scale: 1}
+			o.id = bind(g, 4, o.event)
+			cl.outputs = append(cl.outputs, o)
+		case "zxdg_output_manager_v1":
+			cl.xdgOutputs = bind(g, 3, nil)
+		case "zwlr_screencopy_manager_v1":
+			cl.scVersion = min(g.version, 3)
+			cl.screencopy = bind(g, 3, nil)
+		}
+	}
+	if cl.shm == 0 {
+		return fmt.Errorf("wayland: the compositor has no wl_shm")
+	}
+	if err := cl.c.roundtrip(); err != nil {
+		return err
+	}
+	if cl.xdgOutputs != 0 {
+		for _, o := range cl.outputs {
+			// zxdg_output_manager_v1.get_xdg_output
+			cl.c.send(cl.xdgOutputs, 1, cl.c.newID(o.xdgEvent), o.id)
+		}
+		if err := cl.c.roundtrip(); err != nil {
+			return err
+		}
+	}
+	for i, o := range cl.outputs {
+		o.finish(i)
+	}
+	return nil
+}
+
+// event takes wl_output's events.
+func (o *output) event(opcode uint16, m *message) {
+	switch opcode {
+	case 0: // geometry
+		o.x, o.y = int(m.i32()), int(m.i32())
+		m.i32() // physical width and height in mm, subpixel
+		m.i32()
+		m.i32()
+		m.str() // make and model
+		m.str()
+		o.transform = m.i32()
+	case 1: // mode
+		if m.u32()&1 != 0 { // the current one
+			o.modeW, o.modeH = int(m.i32()), int(m.i32())
+		}
+	case 3: // scale
+		o.scale = max(m.i32(), 1)
+	case 4: // name
+		o.Output.Name = m.str()
+	case 5: // description
+		o.Output.Description = m.str()
+	}
+}
+
+// xdgEvent takes zxdg_output_v1's events.
+func (o *output) xdgEvent(opcode uint16, m *message) {
+	switch opcode {
+	case 0: // logical_position
+		o.Output.X, o.Output.Y = int(m.i32()), int(m.i32())
+	case 1: // logical_size
+		o.Output.Width, o.Output.Height = int(m.i32()), int(m.i32())
+		o.logical = true
+	case 3: // name, before wl_output had one
+		if o.Output.Name == "" {
+			o.Output.Name = m.str()
+		}
+	case 4: // description
+		if o.Output.Description == "" {
+			o.Output.Description = m.str()
+		}
+	}
+}
+
+// finish fills in the layout from wl_output alone where xdg-output didn't,
+// and works out the scale, fractional ones included.
+func (o *output) finish(index int) {
+	o.Output.Index = index
+	if o.Output.Name == "" {
+		o.Output.Name = fmt.Sprintf("output-%d", index)
+	}
+	w, h := o.modeW, o.modeH
+	if o.transform%2 == 1 { // 90 and 270 degrees, flipped or not
+		w, h = h, w
+	}
+	if !o.logical {
+		o.Output.X, o.Output.Y = o.x, o.y
+		o.Output.Width, o.Output.Height = w/int(o.scale), h/int(o.scale)
+	}
+	o.Output.Scale = float64(o.scale)
+	if o.Output.Width > 0 && w > 0 {
+		o.Output.Scale = float64(w) / float64(o.Output.Width)
+	}
+}
+
+// Outputs lists the outputs in the order the compositor announced them.
+func (cl *Client) Outputs() []Output {
+	out := make([]Output, len(cl.outputs))
+	for i, o := range cl.outputs {
+		out[i] = o.Output
+	}
+	return out
+}
+
+// Outputs lists the outputs of $WAYLAND_DISPLAY.
+func Outputs() ([]Output, error) {
+	cl, err := Connect()
+	if err != nil {
+		return nil, err
+	}
+	defer cl.Close()
+	return cl.Outputs(), nil
+}
+
+// ResolveOutput finds the output named by spec: an output name, an index
+// from Outputs, or "primary" or "focused". Wayland has neither a primary
+// output nor a pointer position for clients to see, so those two are the
+// first output.
+func ResolveOutput(spec string) (Output, error) {
+	outs, err := Outputs()
+	if err != nil {
+		return Output{}, err
+	}
+	if len(outs) == 0 {
+		return Output{}, fmt.Errorf("the compositor has no outputs")
+	}
+	switch spec {
+	case "", "primary", "focused":
+		return outs[0], nil
+	}
+	if idx, err := strconv.Atoi(spec); err == nil {
+		if idx < 0 || idx >= len(outs) {
+			return Output{}, fmt.Errorf("monitor index %d out of range (0-%d)", idx, len(outs)-1)
+		}
+		return outs[idx], nil
+	}
+	for _, o := range outs {
+		if o.Name == spec {
+			return o, nil
+		}
+	}
+	return Output{}, fmt.Errorf("no output named %q", spec)
+}
@@ -0,0 +1,71 @@
+package wayland
+
+import "testing"
+
+func TestOutputFinish(t *testing.T) {
+	tests := []struct {
+		name string
+		o    output
+		want Output
+	}{
+		{
+			"wl_output only",
+			output{x: 1920, modeW: 2560, modeH: 1440, scale: 2},
+			Output{Name: "output-0", X: 1920, Width: 1280, Height: 720, Scale: 2},
+		},
+		{
+			"rotated",
+			output{modeW: 1920, modeH: 1080, transform: 1, scale: 1},
+			Output{Name: "output-0", Width: 1080, Height: 1920, Scale: 1},
+		},
+		{
+			"flipped and rotated 270",
+			output{modeW: 1920, modeH: 1080, transform: 7, scale: 1},
+			Output{Name: "output-0", Width: 1080, Height: 1920, Scale: 1},
+		},
+		{
+			"fractional scale from xdg-output",
+			output{modeW: 2880, modeH: 1800, scale: 2, logical: true, Output: Output{Name: "eDP-1", X: 0, Y: 0, Width: 1920, Height: 1200}},
+			Output{Name: "eDP-1", Width: 1920, Height: 1200, Scale: 1.5},
+		},
+		{
+			"no mode yet",
+			output{scale: 1, logical: true, Output: Output{Name: "HEADLESS-1", Width: 800, Height: 600}},
+			Output{Name: "HEADLESS-1", Width: 800, Height: 600, Scale: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.o.finish(0)
+			if tt.o.Output != tt.want {
+				t.Errorf("got %+v, want %+v", tt.o.Output, tt.want)
+			}
+		})
+	}
+}
+
+func TestFrameRow(t *testing.T) {
+	f := &Frame{Width: 1, Height: 3, Stride: 8, Format: formatXBGR8888, Pix: []byte{
+		1, 2, 3, 0, 9, 9, 9, 9,
+		4, 5, 6, 0, 9, 9, 9, 9,
+		7, 8, 9, 0, 9, 9, 9, 9,
+	}}
+	if got := f.Row(0)[0]; got != 1 {
+		t.Errorf("row 0 starts with %d, want 1", got)
+	}
+	f.YInvert = true
+	if got := f.Row(0)[0]; got != 7 {
+		t.Errorf("inverted row 0 starts with %d, want 7", got)
+	}
+	img := f.Image()
+	if c := img.RGBAAt(0, 0); c.R != 7 || c.G != 8 || c.B != 9 || c.A != 0xff {
+		t.Errorf("xbgr pixel = %v, want {7 8 9 255}", c)
+	}
+	f.Format = formatXRGB8888
+	if c := f.Image().RGBAAt(0, 2); c.R != 3 || c.B != 1 {
+		t.Errorf("xrgb pixel = %v, want red 3 and blue 1", c)
+	}
+	if f.PixFmt() != "bgr0" {
+		t.Errorf("xrgb PixFmt = %s, want bgr0", f.PixFmt())
+	}
+}
@@ -0,0 +1,200 @@
+package wayland
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+// ErrNoScreencopy means the compositor doesn't offer
+// zwlr_screencopy_manager_v1, as GNOME and KDE don't.
+var ErrNoScreencopy = errors.New("the compositor has no wlr-screencopy")
+
+// the wl_shm formats we can read: 32 bits a pixel, in memory order B G R X
+// for the first two and R G B X for the others
+const (
+	formatARGB8888 = 0
+	formatXRGB8888 = 1
+	formatABGR8888 = 0x34324241
+	formatXBGR8888 = 0x34324258
+)
+
+// Frame is one screencopy capture in the buffer's own pixels, so an output
+// at scale 2 gives twice the logical size.
+type Frame struct {
+	Width, Height, Stride int
+	Format                uint32
+	YInvert               bool // rows run bottom to top
+	// Pix is shared with the compositor and only good until the next
+	// Capture on the same Client.
+	Pix []byte
+}
+
+// Screencopy reports whether the compositor lets us copy outputs.
+func (cl *Client) Screencopy() bool { return cl.screencopy != 0 }
+
+// Capture copies rect of o, in o's logical coordinates; an empty rect is
+// all of it. cursor paints the pointer in.
+func (cl *Client) Capture(o Output, rect image.Rectangle, cursor bool) (*Frame, error) {
+	if cl.screencopy == 0 {
+		return nil, ErrNoScreencopy
+	}
+	if o.Index < 0 || o.Index >= len(cl.outputs) {
+		return nil, fmt.Errorf("wayland: no output %d", o.Index)
+	}
+	var (
+		f          Frame
+		offers     []Frame
+		bufferDone bool
+		ready      bool
+		failed     bool
+	)
+	frame := cl.c.newID(func(opcode uint16, m *message) {
+		switch opcode {
+		case 0: // buffer, one per wl_shm format it can copy into
+			offers = append(offers, Frame{Format: m.u32(), Width: int(m.u32()), Height: int(m.u32()), Stride: int(m.u32())})
+		case 1: // flags
+			f.YInvert = m.u32()&1 != 0
+		case 2: // ready
+			ready = true
+		case 3: // failed
+			failed = true
+		case 6: // buffer_done
+			bufferDone = true
+		}
+	})
+	overlay := int32(0)
+	if cursor {
+		overlay = 1
+	}
+	id := cl.outputs[o.Index].id
+	var err error
+	if rect.Empty() {
+		err = cl.c.send(cl.screencopy, 0, frame, overlay, id) // capture_output
+	} else {
+		err = cl.c.send(cl.screencopy, 1, frame, overlay, id, rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy()) // capture_output_region
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer cl.c.send(frame, 1) // destroy
+
+	// before version 3 there is one buffer event and nothing after it
+	if err := cl.c.wait(func() bool { return failed || bufferDone || (cl.scVersion < 3 && len(offers) > 0) }); err != nil {
+		return nil, err
+	}
+	if failed {
+		return nil, fmt.Errorf("wayland: the compositor couldn't copy %s", o.Name)
+	}
+	ok := false
+	for _, b := range offers {
+		if ok = readable(b.Format); ok {
+			f.Width, f.Height, f.Stride, f.Format = b.Width, b.Height, b.Stride, b.Format
+			break
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("wayland: %s offers no 8-bit RGB buffer to copy into", o.Name)
+	}
+	buf, err := cl.buffer(f)
+	if err != nil {
+		return nil, err
+	}
+	if err := cl.c.send(frame, 0, buf.id); err != nil { // copy
+		return nil, err
+	}
+	if err := cl.c.wait(func() bool { return ready || failed }); err != nil {
+		return nil, err
+	}
+	if failed {
+		return nil, fmt.Errorf("wayland: the compositor couldn't copy %s", o.Name)
+	}
+	f.Pix = buf.data[:f.Stride*f.Height]
+	return &f, nil
+}
+
+func readable(format uint32) bool {
+	switch format {
+	case formatARGB8888, formatXRGB8888, formatABGR8888, formatXBGR8888:
+		return true
+	}
+	return false
+}
+
+// Row returns row y of the picture, top first, flipped or not.
+func (f *Frame) Row(y int) []byte {
+	if f.YInvert {
+		y = f.Height - 1 - y
+	}
+	return f.Pix[y*f.Stride:][:f.Width*4]
+}
+
+// PixFmt is the frame's layout as ffmpeg's rawvideo demuxer names it. The
+// alpha of a screen means nothing, so it's skipped.
+func (f *Frame) PixFmt() string {
+	if f.Format == formatABGR8888 || f.Format == formatXBGR8888 {
+		return "rgb0"
+	}
+	return "bgr0"
+}
+
+// Image converts the frame to an opaque RGBA image, right way up.
+func (f *Frame) Image() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, f.Width, f.Height))
+	swap := f.PixFmt() == "bgr0"
+	for y := 0; y < f.Height; y++ {
+		src, dst := f.Row(y), img.Pix[y*img.Stride:][:f.Width*4]
+		for x := 0; x < len(src); x += 4 {
+			if swap {
+				dst[x], dst[x+1], dst[x+2] = src[x+2], src[x+1], src[x]
+			} else {
+				copy(dst[x:x+3], src[x:x+3])
+			}
+			dst[x+3] = 0xff
+		}
+	}
+	return img
+}
+
+// shmBuffer is a wl_buffer over memory we share with the compositor.
+type shmBuffer struct {
+	id, pool              uint32
+	width, height, stride int
+	format                uint32
+	data                  []byte
+}
+
+// buffer returns a buffer shaped like f, reusing the last one if it fits.
+func (cl *Client) buffer(f Frame) (*shmBuffer, error) {
+	if b := cl.buf; b != nil && b.width == f.Width && b.height == f.Height && b.stride == f.Stride && b.format == f.Format {
+		return b, nil
+	}
+	cl.releaseBuffer()
+	size := f.Stride * f.Height
+	file, data, err := mapShm(size)
+	if err != nil {
+		return nil, fmt.Errorf("wayland: shared memory: %w", err)
+	}
+	b := &shmBuffer{pool: cl.c.newID(nil), width: f.Width, height: f.Height, stride: f.Stride, format: f.Format, data: data}
+	err = cl.c.send(cl.shm, 0, b.pool, fd(file), size) // wl_shm.create_pool
+	// the compositor has its own copy of the fd now
+	closeFd(file)
+	if err != nil {
+		unmapShm(data)
+		return nil, err
+	}
+	b.id = cl.c.newID(nil)
+	cl.c.send(b.pool, 0, b.id, 0, f.Width, f.Height, f.Stride, f.Format) // wl_shm_pool.create_buffer
+	cl.buf = b
+	return b, nil
+}
+
+func (cl *Client) releaseBuffer() {
+	if cl.buf == nil {
+		return
+	}
+	cl.c.send(cl.buf.id, 0)   // wl_buffer.destroy
+	cl.c.send(cl.buf.pool, 1) // wl_shm_pool.destroy
+	unmapShm(cl.buf.data)
+	cl.buf = nil
+}
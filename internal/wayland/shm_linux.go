@@ -0,0 +1,34 @@
+package wayland
+
+import "golang.org/x/sys/unix"
+
+// mapShm makes an anonymous file of size bytes and maps it. The fd goes to
+// the compositor, which copies frames into the mapping.
+func mapShm(size int) (int, []byte, error) {
+	file, err := unix.MemfdCreate("swiftcap", unix.MFD_CLOEXEC)
+	if err != nil {
+		return -1, nil, err
+	}
+	if err := unix.Ftruncate(file, int64(size)); err != nil {
+		unix.Close(file)
+		return -1, nil, err
+	}
+	data, err := unix.Mmap(file, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		unix.Close(file)
+		return -1, nil, err
+	}
+	return file, data, nil
+}
+
+func unmapShm(data []byte) { unix.Munmap(data) }
+
+func closeFd(file int) { unix.Close(file) }
+
+// rights is the control message that passes fds over the socket.
+func rights(fds []int) []byte {
+	if len(fds) == 0 {
+		return nil
+	}
+	return unix.UnixRights(fds...)
+}
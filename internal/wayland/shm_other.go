@@ -0,0 +1,17 @@
+//go:build !linux
+
+package wayland
+
+import "errors"
+
+// off linux there are no memfds to hand the compositor, so nothing can be
+// copied; listing outputs still works.
+func mapShm(size int) (int, []byte, error) {
+	return -1, nil, errors.New("not supported on this OS")
+}
+
+func unmapShm(data []byte) {}
+
+func closeFd(file int) {}
+
+func rights(fds []int) []byte { return nil }
@@ -0,0 +1,162 @@
+package wayland
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sway starts a headless sway with one 640x480 output for the test and
+// points WAYLAND_DISPLAY at it. The output is painted red when swaybg is
+// installed, which bg reports. It skips the test when sway isn't installed.
+func sway(t *testing.T) (bg bool) {
+	t.Helper()
+	if _, err := exec.LookPath("sway"); err != nil {
+		t.Skip("sway is not installed")
+	}
+	_, err := exec.LookPath("swaybg")
+	bg = err == nil
+
+	runtime := t.TempDir()
+	config := "output * resolution 640x480 position 0 0\n"
+	if bg {
+		config += "output * bg #ff0000 solid_color\n"
+	}
+	configPath := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(configPath, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("sway", "--config", configPath)
+	cmd.Env = append(os.Environ(),
+		"XDG_RUNTIME_DIR="+runtime,
+		"WLR_BACKENDS=headless",
+		"WLR_RENDERER=pixman",
+		"WLR_LIBINPUT_NO_DEVICES=1",
+		"WAYLAND_DISPLAY=",
+		"DISPLAY=",
+	)
+	var log strings.Builder
+	cmd.Stdout, cmd.Stderr = &log, &log
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	// sway names its socket wayland-N once it's listening
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		socks, _ := filepath.Glob(filepath.Join(runtime, "wayland-[0-9]"))
+		if len(socks) > 0 {
+			t.Setenv("XDG_RUNTIME_DIR", runtime)
+			t.Setenv("WAYLAND_DISPLAY", filepath.Base(socks[0]))
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("sway didn't start:\n%s", log.String())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	// its outputs come up after the socket
+	for {
+		if outs, err := Outputs(); err == nil && len(outs) > 0 && outs[0].Width == 640 {
+			return bg
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("sway has no 640x480 output:\n%s", log.String())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestSwayOutputs(t *testing.T) {
+	sway(t)
+	outs, err := Outputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outs) != 1 {
+		t.Fatalf("Outputs = %v, want one", outs)
+	}
+	o := outs[0]
+	if o.Index != 0 || o.Name != "HEADLESS-1" || o.Rect() != image.Rect(0, 0, 640, 480) || o.Scale != 1 {
+		t.Errorf("output %+v, want HEADLESS-1 at 640x480+0+0", o)
+	}
+	if !Advertises("zwlr_screencopy_manager_v1") {
+		t.Error("sway doesn't advertise wlr-screencopy")
+	}
+	for _, spec := range []string{"", "primary", "0", "HEADLESS-1"} {
+		if got, err := ResolveOutput(spec); err != nil || got.Name != o.Name {
+			t.Errorf("ResolveOutput(%q) = %v, %v", spec, got.Name, err)
+		}
+	}
+	if _, err := ResolveOutput("1"); err == nil {
+		t.Error("ResolveOutput(\"1\") found a second output")
+	}
+}
+
+func TestSwayScreencopy(t *testing.T) {
+	bg := sway(t)
+	cl, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+	if !cl.Screencopy() {
+		t.Fatal("no wlr-screencopy")
+	}
+	o := cl.Outputs()[0]
+	if bg {
+		// swaybg paints a moment after the output comes up
+		for deadline := time.Now().Add(5 * time.Second); ; {
+			f, err := cl.Capture(o, image.Rectangle{}, false)
+			if err == nil && f.Image().RGBAAt(320, 240) == (color.RGBA{0xff, 0, 0, 0xff}) {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("the background never turned red: %v", err)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+
+	tests := []struct {
+		name string
+		rect image.Rectangle
+		w, h int
+	}{
+		{"whole output", image.Rectangle{}, 640, 480},
+		{"region", image.Rect(10, 20, 110, 70), 100, 50},
+		{"again, reusing the buffer", image.Rect(10, 20, 110, 70), 100, 50},
+		{"corner", image.Rect(600, 440, 640, 480), 40, 40},
+	}
+	for _, tt := range tests {
+		f, err := cl.Capture(o, tt.rect, false)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if f.Width != tt.w || f.Height != tt.h || f.Stride < 4*f.Width || len(f.Pix) < f.Stride*f.Height {
+			t.Errorf("%s: frame %dx%d stride %d, want %dx%d", tt.name, f.Width, f.Height, f.Stride, tt.w, tt.h)
+			continue
+		}
+		img := f.Image()
+		if img.Bounds() != image.Rect(0, 0, tt.w, tt.h) {
+			t.Errorf("%s: image %v", tt.name, img.Bounds())
+		}
+		if bg {
+			if c := img.RGBAAt(tt.w/2, tt.h/2); c != (color.RGBA{0xff, 0, 0, 0xff}) {
+				t.Errorf("%s: centre is %v, want the red background", tt.name, c)
+			}
+		}
+	}
+	if _, err := cl.Capture(Output{Index: 1}, image.Rectangle{}, false); err == nil {
+		t.Error("captured an output that isn't there")
+	}
+}
@@ -0,0 +1,208 @@
+/*
+	a tiny wayland client. all we need is a handful of interfaces to list
+	the outputs and copy their pixels, so rather than cgo into libwayland we
+	speak the wire protocol ourselves: a unix socket, 32-bit words in host
+	byte order, and fds passed alongside.
+*/
+
+package wayland
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrNoDisplay means there is no Wayland compositor to talk to.
+var ErrNoDisplay = errors.New("no Wayland display")
+
+// replyTimeout bounds every wait on the compositor, so a wedged one fails
+// the capture instead of hanging it.
+const replyTimeout = 5 * time.Second
+
+// displayID is wl_display, the one object that exists from the start.
+const displayID = 1
+
+var ne = binary.NativeEndian
+
+// handler gets the events sent to one object.
+type handler func(opcode uint16, m *message)
+
+// fd is a file descriptor request argument; it travels out of band.
+type fd int
+
+// conn is the socket plus the objects we made on it.
+type conn struct {
+	sock     *net.UnixConn
+	next     uint32
+	handlers map[uint32]handler
+	in       []byte // read but not yet dispatched
+	err      error  // the protocol error the compositor sent, which ends the connection
+}
+
+// dial connects to $WAYLAND_DISPLAY, a socket name in $XDG_RUNTIME_DIR or
+// an absolute path.
+func dial() (*conn, error) {
+	name := os.Getenv("WAYLAND_DISPLAY")
+	if name == "" {
+		return nil, ErrNoDisplay
+	}
+	if !filepath.IsAbs(name) {
+		dir := os.Getenv("XDG_RUNTIME_DIR")
+		if dir == "" {
+			return nil, fmt.Errorf("%w: XDG_RUNTIME_DIR is not set", ErrNoDisplay)
+		}
+		name = filepath.Join(dir, name)
+	}
+	sock, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: name, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoDisplay, err)
+	}
+	c := &conn{sock: sock, next: displayID + 1, handlers: map[uint32]handler{}}
+	c.handlers[displayID] = c.displayEvent
+	return c, nil
+}
+
+func (c *conn) close() error { return c.sock.Close() }
+
+func (c *conn) displayEvent(opcode uint16, m *message) {
+	switch opcode {
+	case 0: // error
+		obj, code, msg := m.u32(), m.u32(), m.str()
+		c.err = fmt.Errorf("wayland: error %d on object %d: %s", code, obj, msg)
+	case 1: // delete_id
+		delete(c.handlers, m.u32())
+	}
+}
+
+// newID allocates an object whose events go to h, if it has any we want.
+func (c *conn) newID(h handler) uint32 {
+	id := c.next
+	c.next++
+	if h != nil {
+		c.handlers[id] = h
+	}
+	return id
+}
+
+// send makes request opcode on object id. args are uint32 (uint, object
+// and new_id), int32 or int (int), string or fd.
+func (c *conn) send(id uint32, opcode uint16, args ...any) error {
+	if c.err != nil {
+		return c.err
+	}
+	b := ne.AppendUint32(nil, id)
+	b = append(b, 0, 0, 0, 0) // size and opcode, once the size is known
+	var fds []int
+	for _, a := range args {
+		switch v := a.(type) {
+		case uint32:
+			b = ne.AppendUint32(b, v)
+		case int32:
+			b = ne.AppendUint32(b, uint32(v))
+		case int:
+			b = ne.AppendUint32(b, uint32(int32(v)))
+		case string:
+			// length with the NUL, then the bytes padded to a word
+			b = ne.AppendUint32(b, uint32(len(v)+1))
+			b = append(b, v...)
+			b = append(b, make([]byte, (len(v)+4)&^3-len(v))...)
+		case fd:
+			fds = append(fds, int(v))
+		default:
+			panic(fmt.Sprintf("wayland: can't send a %T", a))
+		}
+	}
+	ne.PutUint32(b[4:], uint32(len(b))<<16|uint32(opcode))
+	if _, _, err := c.sock.WriteMsgUnix(b, rights(fds), nil); err != nil {
+		return fmt.Errorf("wayland: %w", err)
+	}
+	return nil
+}
+
+// dispatch reads what the compositor has sent and hands each whole event
+// to its object's handler. None of the events we take carry fds, so any
+// that come are left for the kernel to close.
+func (c *conn) dispatch() error {
+	buf := make([]byte, 4096)
+	n, err := c.sock.Read(buf)
+	if err == io.EOF {
+		return errors.New("wayland: the compositor hung up")
+	}
+	if err != nil {
+		return err
+	}
+	c.in = append(c.in, buf[:n]...)
+	for len(c.in) >= 8 {
+		id, word := ne.Uint32(c.in), ne.Uint32(c.in[4:])
+		size := int(word >> 16)
+		if size < 8 {
+			return errors.New("wayland: malformed event")
+		}
+		if len(c.in) < size {
+			break
+		}
+		if h := c.handlers[id]; h != nil {
+			h(uint16(word), &message{data: c.in[8:size]})
+		}
+		c.in = c.in[size:]
+	}
+	return c.err
+}
+
+// wait dispatches events until cond holds.
+func (c *conn) wait(cond func() bool) error {
+	c.sock.SetReadDeadline(time.Now().Add(replyTimeout))
+	defer c.sock.SetReadDeadline(time.Time{})
+	for !cond() {
+		if err := c.dispatch(); err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return errors.New("wayland: the compositor stopped answering")
+			}
+			return err
+		}
+	}
+	return c.err
+}
+
+// roundtrip waits until the compositor has handled every request so far,
+// and so sent every event they cause.
+func (c *conn) roundtrip() error {
+	done := false
+	callback := c.newID(func(uint16, *message) { done = true })
+	if err := c.send(displayID, 0, callback); err != nil { // wl_display.sync
+		return err
+	}
+	return c.wait(func() bool { return done })
+}
+
+// message is an event's arguments, read off in order.
+type message struct {
+	data []byte
+}
+
+func (m *message) u32() uint32 {
+	if len(m.data) < 4 {
+		return 0
+	}
+	v := ne.Uint32(m.data)
+	m.data = m.data[4:]
+	return v
+}
+
+func (m *message) i32() int32 { return int32(m.u32()) }
+
+func (m *message) str() string {
+	n := int(m.u32())
+	if n == 0 || n > len(m.data) {
+		return ""
+	}
+	s := string(m.data[:n-1])
+	m.data = m.data[min((n+3)&^3, len(m.data)):]
+	return s
+}
@@ -0,0 +1,213 @@
+package wayland
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pipe returns a conn and the compositor's end of its socket.
+func pipe(t *testing.T) (*conn, *net.UnixConn) {
+	t.Helper()
+	addr := &net.UnixAddr{Name: filepath.Join(t.TempDir(), "wayland-test"), Net: "unix"}
+	l, err := net.ListenUnix("unix", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	sock, err := net.DialUnix("unix", nil, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer, err := l.AcceptUnix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sock.Close(); peer.Close() })
+	c := &conn{sock: sock, next: displayID + 1, handlers: map[uint32]handler{}}
+	c.handlers[displayID] = c.displayEvent
+	return c, peer
+}
+
+// words lays out a message the way the wire does.
+func words(vs ...uint32) []byte {
+	var b []byte
+	for _, v := range vs {
+		b = ne.AppendUint32(b, v)
+	}
+	return b
+}
+
+// event is a whole event: header, then args.
+func event(id uint32, opcode uint16, args []byte) []byte {
+	return append(words(id, uint32(8+len(args))<<16|uint32(opcode)), args...)
+}
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name string
+		args []any
+		want []byte // the arguments, after the header
+	}{
+		{"none", nil, nil},
+		{"uint and int", []any{uint32(7), int32(-1), -2}, words(7, 0xffffffff, 0xfffffffe)},
+		{"empty string", []any{""}, append(words(1), 0, 0, 0, 0)},
+		{"string filling its last word", []any{"abc"}, append(words(4), 'a', 'b', 'c', 0)},
+		{"string spilling into a word", []any{"wl_shm"}, append(words(7), 'w', 'l', '_', 's', 'h', 'm', 0, 0)},
+		{"string of whole words", []any{"abcd"}, append(words(5), 'a', 'b', 'c', 'd', 0, 0, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, peer := pipe(t)
+			if err := c.send(5, 2, tt.args...); err != nil {
+				t.Fatal(err)
+			}
+			want := event(5, 2, tt.want)
+			got := make([]byte, len(want)+8)
+			peer.SetReadDeadline(time.Now().Add(time.Second))
+			n, err := peer.Read(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got[:n], want) {
+				t.Errorf("sent % x\nwant % x", got[:n], want)
+			}
+		})
+	}
+}
+
+func TestSendFd(t *testing.T) {
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	c, peer := pipe(t)
+	if err := c.send(5, 0, uint32(3), fd(f.Fd()), uint32(4)); err != nil {
+		t.Fatal(err)
+	}
+	b, oob := make([]byte, 64), make([]byte, 64)
+	n, oobn, _, _, err := peer.ReadMsgUnix(b, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the fd adds nothing to the message itself
+	if want := event(5, 0, words(3, 4)); !bytes.Equal(b[:n], want) {
+		t.Errorf("sent % x\nwant % x", b[:n], want)
+	}
+	if runtime.GOOS == "linux" && oobn == 0 {
+		t.Error("the fd wasn't passed")
+	}
+}
+
+func TestSendAfterProtocolError(t *testing.T) {
+	c, _ := pipe(t)
+	c.err = errors.New("wayland: error 1 on object 1: gone")
+	if err := c.send(1, 0); err != c.err {
+		t.Errorf("send = %v, want the protocol error", err)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		read func(m *message) any
+		want any
+		left int // bytes left after the read
+	}{
+		{"u32", words(9, 1), func(m *message) any { return m.u32() }, uint32(9), 4},
+		{"short u32", []byte{1, 2}, func(m *message) any { return m.u32() }, uint32(0), 2},
+		{"i32", words(0xfffffffb), func(m *message) any { return m.i32() }, int32(-5), 0},
+		{"str", append(words(6), "HDMI-\x00\x00\x00"...), func(m *message) any { return m.str() }, "HDMI-", 0},
+		{"str then more", append(words(4), "DP1\x00\x00\x00\x00\x07"...), func(m *message) any { return m.str() }, "DP1", 4},
+		{"null str", words(0, 3), func(m *message) any { return m.str() }, "", 4},
+		{"str longer than the message", append(words(40), "abc\x00"...), func(m *message) any { return m.str() }, "", 4},
+		{"str without its padding", append(words(2), 'a', 0), func(m *message) any { return m.str() }, "a", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &message{data: tt.data}
+			if got := tt.read(m); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+			if len(m.data) != tt.left {
+				t.Errorf("%d bytes left, want %d", len(m.data), tt.left)
+			}
+		})
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	c, peer := pipe(t)
+	type got struct {
+		opcode uint16
+		name   string
+		n      uint32
+	}
+	var events []got
+	id := c.newID(func(opcode uint16, m *message) {
+		events = append(events, got{opcode, m.str(), m.u32()})
+	})
+	first := event(id, 1, append(append(words(6), "eDP-1\x00\x00\x00"...), words(60)...))
+	second := event(id, 3, append(append(words(3), "ok\x00\x00"...), words(2)...))
+	other := event(99, 0, words(1)) // nobody's listening
+
+	// the second event arrives in two pieces
+	all := append(append(append([]byte{}, first...), other...), second...)
+	cut := len(first) + len(other) + 6
+	peer.Write(all[:cut])
+	if err := c.wait(func() bool { return len(events) == 1 }); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.in) != 6 {
+		t.Errorf("%d bytes held back, want 6", len(c.in))
+	}
+	peer.Write(all[cut:])
+	if err := c.wait(func() bool { return len(events) == 2 }); err != nil {
+		t.Fatal(err)
+	}
+	want := []got{{1, "eDP-1", 60}, {3, "ok", 2}}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %+v, want %+v", events, want)
+	}
+	if len(c.in) != 0 {
+		t.Errorf("%d bytes left over", len(c.in))
+	}
+}
+
+func TestDisplayEvents(t *testing.T) {
+	c, peer := pipe(t)
+	id := c.newID(func(uint16, *message) {})
+	peer.Write(event(displayID, 1, words(id))) // delete_id
+	if err := c.dispatch(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.handlers[id]; ok {
+		t.Error("delete_id kept the handler")
+	}
+
+	peer.Write(event(displayID, 0, append(words(id, 3, 12), "bad request\x00"...)))
+	err := c.dispatch()
+	if err == nil || !strings.Contains(err.Error(), "error 3 on object") || !strings.Contains(err.Error(), "bad request") {
+		t.Fatalf("err = %v, want the protocol error", err)
+	}
+	if c.send(id, 0) == nil {
+		t.Error("send worked after a protocol error")
+	}
+}
+
+func TestMalformedEvent(t *testing.T) {
+	c, peer := pipe(t)
+	peer.Write(words(displayID, 4<<16))
+	if err := c.dispatch(); err == nil {
+		t.Error("an event shorter than its header was taken")
+	}
+}
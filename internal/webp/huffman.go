@@ -0,0 +1,250 @@
+/*
+	canonical prefix codes and the lsb-first bit writer used by VP8L.
+*/
+
+package webp
+
+import "container/heap"
+
+// bitWriter packs bits least significant first, as VP8L reads them.
+type bitWriter struct {
+	buf []byte
+	acc uint64
+	n   uint
+}
+
+func (w *bitWriter) write(v uint32, n uint) {
+	w.acc |= uint64(v) << w.n
+	w.n += n
+	for w.n >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.n -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.n > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.n = 0, 0
+	}
+	return w.buf
+}
+
+// prefixCode is a canonical code for one alphabet. codes are stored bit
+// reversed so they can go straight into the lsb-first writer.
+type prefixCode struct {
+	lengths []uint8
+	codes   []uint16
+	single  bool // one used symbol: the decoder reads no bits for it
+}
+
+func (c *prefixCode) writeSymbol(w *bitWriter, sym int) {
+	if c.single {
+		return
+	}
+	w.write(uint32(c.codes[sym]), uint(c.lengths[sym]))
+}
+
+// newPrefixCode builds a code from symbol frequencies, limited to maxLen bits.
+func newPrefixCode(freq []uint32, maxLen int) *prefixCode {
+	c := &prefixCode{lengths: make([]uint8, len(freq)), codes: make([]uint16, len(freq))}
+	used := 0
+	for _, f := range freq {
+		if f > 0 {
+			used++
+		}
+	}
+	if used <= 1 {
+		for s, f := range freq {
+			if f > 0 {
+				c.lengths[s] = 1
+			}
+		}
+		c.single = true
+		return c
+	}
+	f := append([]uint32(nil), freq...)
+	for !huffmanLengths(f, c.lengths, maxLen) {
+		// too deep; flatten the distribution and try again
+		for i := range f {
+			if f[i] > 0 {
+				f[i] = f[i]/2 + 1
+			}
+		}
+	}
+	c.assignCodes()
+	return c
+}
+
+// assignCodes turns lengths into canonical, bit-reversed codes.
+func (c *prefixCode) assignCodes() {
+	var count [16]uint16
+	for _, l := range c.lengths {
+		count[l]++
+	}
+	count[0] = 0
+	var next [16]uint16
+	code := uint16(0)
+	for l := 1; l < 16; l++ {
+		code = (code + count[l-1]) << 1
+		next[l] = code
+	}
+	for s, l := range c.lengths {
+		if l == 0 {
+			continue
+		}
+		c.codes[s] = reverse(next[l], l)
+		next[l]++
+	}
+}
+
+func reverse(v uint16, n uint8) uint16 {
+	r := uint16(0)
+	for i := uint8(0); i < n; i++ {
+		r = r<<1 | v&1
+		v >>= 1
+	}
+	return r
+}
+
+type hnode struct {
+	freq        uint64
+	sym         int // -1 for internal nodes
+	left, right *hnode
+}
+
+type hheap []*hnode
+
+func (h hheap) Len() int { return len(h) }
+func (h hheap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].sym < h[j].sym
+}
+func (h hheap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hheap) Push(x interface{}) { *h = append(*h, x.(*hnode)) }
+func (h *hheap) Pop() interface{} {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+// huffmanLengths fills lengths with plain Huffman code lengths and reports
+// whether they fit in maxLen.
+func huffmanLengths(freq []uint32, lengths []uint8, maxLen int) bool {
+	h := &hheap{}
+	for s, f := range freq {
+		if f > 0 {
+			*h = append(*h, &hnode{freq: uint64(f), sym: s})
+		}
+	}
+	heap.Init(h)
+	for h.Len() > 1 {
+		a := heap.Pop(h).(*hnode)
+		b := heap.Pop(h).(*hnode)
+		heap.Push(h, &hnode{freq: a.freq + b.freq, sym: -1, left: a, right: b})
+	}
+	for i := range lengths {
+		lengths[i] = 0
+	}
+	ok := true
+	var walk func(n *hnode, depth int)
+	walk = func(n *hnode, depth int) {
+		if n.sym >= 0 {
+			if depth > maxLen {
+				ok = false
+			}
+			lengths[n.sym] = uint8(depth)
+			return
+		}
+		walk(n.left, depth+1)
+		walk(n.right, depth+1)
+	}
+	walk((*h)[0], 0)
+	return ok
+}
+
+// codeLengthOrder is the order code length code lengths are stored in.
+var codeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// writeCode stores c in the VP8L header format.
+func writeCode(w *bitWriter, c *prefixCode) {
+	var used []int
+	for s, l := range c.lengths {
+		if l > 0 {
+			used = append(used, s)
+		}
+	}
+	if len(used) == 0 {
+		used = []int{0}
+	}
+	if len(used) == 1 && used[0] < 256 {
+		// simple code with one symbol
+		w.write(1, 1)
+		w.write(0, 1)
+		if used[0] < 2 {
+			w.write(0, 1)
+			w.write(uint32(used[0]), 1)
+		} else {
+			w.write(1, 1)
+			w.write(uint32(used[0]), 8)
+		}
+		return
+	}
+
+	// the lengths are themselves sent with a prefix code, with runs of
+	// zeros folded into symbols 17 and 18
+	type token struct{ sym, extra, bits int }
+	var toks []token
+	for i := 0; i < len(c.lengths); {
+		if c.lengths[i] != 0 {
+			toks = append(toks, token{sym: int(c.lengths[i])})
+			i++
+			continue
+		}
+		run := 0
+		for i+run < len(c.lengths) && c.lengths[i+run] == 0 {
+			run++
+		}
+		i += run
+		for run > 0 {
+			switch {
+			case run >= 11:
+				n := min(run, 138)
+				toks = append(toks, token{18, n - 11, 7})
+				run -= n
+			case run >= 3:
+				toks = append(toks, token{17, run - 3, 3})
+				run = 0
+			default:
+				toks = append(toks, token{sym: 0})
+				run--
+			}
+		}
+	}
+	freq := make([]uint32, 19)
+	for _, t := range toks {
+		freq[t.sym]++
+	}
+	lc := newPrefixCode(freq, 7)
+
+	n := 19
+	for n > 4 && lc.lengths[codeLengthOrder[n-1]] == 0 {
+		n--
+	}
+	w.write(0, 1)
+	w.write(uint32(n-4), 4)
+	for i := 0; i < n; i++ {
+		w.write(uint32(lc.lengths[codeLengthOrder[i]]), 3)
+	}
+	w.write(0, 1) // lengths for the whole alphabet follow
+	for _, t := range toks {
+		lc.writeSymbol(w, t.sym)
+		if t.bits > 0 {
+			w.write(uint32(t.extra), uint(t.bits))
+		}
+	}
+}
@@ -0,0 +1,414 @@
+/*
+	VP8L (lossless) encoder. subtract-green and a per-block predictor
+	transform, then greedy LZ77 against the previous pixel, the pixel above
+	and a hash of recent pixels. screenshots are mostly flat colour and
+	repeated rows, which this handles well.
+*/
+
+package webp
+
+import "image"
+
+const (
+	predictorBits = 4 // 16x16 predictor blocks
+	maxMatch      = 4096
+	minMatch      = 3
+	hashBits      = 16
+	maxDistance   = 1<<20 - 120 // the largest distance code the format has
+)
+
+// encodeLossless returns a VP8L bitstream for img.
+func encodeLossless(img image.Image) []byte {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	argb := toARGB(img)
+	opaque := true
+	for _, p := range argb {
+		if p>>24 != 0xff {
+			opaque = false
+			break
+		}
+	}
+
+	bw := &bitWriter{}
+	bw.write(0x2f, 8)
+	bw.write(uint32(w-1), 14)
+	bw.write(uint32(h-1), 14)
+	if opaque {
+		bw.write(0, 1)
+	} else {
+		bw.write(1, 1)
+	}
+	bw.write(0, 3) // version
+
+	// transforms, in the order we apply them; the decoder undoes them backwards
+	bw.write(1, 1)
+	bw.write(2, 2) // subtract green
+	subtractGreen(argb)
+
+	bw.write(1, 1)
+	bw.write(0, 2) // predictor
+	bw.write(predictorBits-2, 3)
+	modes, mw, mh := choosePredictors(argb, w, h)
+	writeImage(bw, modes, mw, mh, false)
+	applyPredictors(argb, w, h, modes, mw)
+
+	bw.write(0, 1) // no more transforms
+
+	writeImage(bw, argb, w, h, true)
+	return bw.bytes()
+}
+
+func toARGB(img image.Image) []uint32 {
+	b := img.Bounds()
+	out := make([]uint32, 0, b.Dx()*b.Dy())
+	switch m := img.(type) {
+	case *image.NRGBA:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			row := m.Pix[m.PixOffset(b.Min.X, y):m.PixOffset(b.Max.X, y)]
+			for i := 0; i < len(row); i += 4 {
+				out = append(out, uint32(row[i+3])<<24|uint32(row[i])<<16|uint32(row[i+1])<<8|uint32(row[i+2]))
+			}
+		}
+	case *image.RGBA:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			row := m.Pix[m.PixOffset(b.Min.X, y):m.PixOffset(b.Max.X, y)]
+			for i := 0; i < len(row); i += 4 {
+				a := uint32(row[i+3])
+				out = append(out, argb(uint32(row[i])*0x101, uint32(row[i+1])*0x101, uint32(row[i+2])*0x101, a*0x101))
+			}
+		}
+	default:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				out = append(out, argb(img.At(x, y).RGBA()))
+			}
+		}
+	}
+	return out
+}
+
+// argb packs a premultiplied 16-bit colour as VP8L stores it:
+// unpremultiplied, 8 bits a channel.
+func argb(r, g, b, a uint32) uint32 {
+	if a != 0 && a != 0xffff {
+		r, g, b = r*0xffff/a, g*0xffff/a, b*0xffff/a
+	}
+	return (a>>8)<<24 | (r>>8)<<16 | (g>>8)<<8 | b>>8
+}
+
+func subtractGreen(argb []uint32) {
+	for i, p := range argb {
+		g := (p >> 8) & 0xff
+		r := ((p >> 16) - g) & 0xff
+		b := (p - g) & 0xff
+		argb[i] = p&0xff00ff00 | r<<16 | b
+	}
+}
+
+// predict returns the prediction for pixel i (x > 0, y > 0) under mode.
+func predict(argb []uint32, i, w, mode int) uint32 {
+	l := argb[i-1]
+	t := argb[i-w]
+	tl := argb[i-w-1]
+	tr := argb[i-w+1] // on the last column this wraps to the row's first pixel, as the spec says
+	switch mode {
+	case 0:
+		return 0xff000000
+	case 1:
+		return l
+	case 2:
+		return t
+	case 3:
+		return tr
+	case 4:
+		return tl
+	case 5:
+		return average2(average2(l, tr), t)
+	case 6:
+		return average2(l, tl)
+	case 7:
+		return average2(l, t)
+	case 8:
+		return average2(tl, t)
+	case 9:
+		return average2(t, tr)
+	case 10:
+		return average2(average2(l, tl), average2(t, tr))
+	case 11:
+		return selectPred(l, t, tl)
+	case 12:
+		return clampAddSubtractFull(l, t, tl)
+	}
+	return clampAddSubtractHalf(average2(l, t), tl)
+}
+
+func average2(a, b uint32) uint32 {
+	return (((a ^ b) & 0xfefefefe) >> 1) + (a & b)
+}
+
+func channel(p uint32, shift uint) int32 {
+	return int32((p >> shift) & 0xff)
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func selectPred(l, t, tl uint32) uint32 {
+	var pl, pt int32
+	for _, s := range [4]uint{24, 16, 8, 0} {
+		p := channel(l, s) + channel(t, s) - channel(tl, s)
+		pl += abs32(p - channel(l, s))
+		pt += abs32(p - channel(t, s))
+	}
+	if pl < pt {
+		return l
+	}
+	return t
+}
+
+func clamp255(v int32) uint32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint32(v)
+}
+
+func clampAddSubtractFull(a, b, c uint32) uint32 {
+	var out uint32
+	for _, s := range [4]uint{24, 16, 8, 0} {
+		out |= clamp255(channel(a, s)+channel(b, s)-channel(c, s)) << s
+	}
+	return out
+}
+
+func clampAddSubtractHalf(a, b uint32) uint32 {
+	var out uint32
+	for _, s := range [4]uint{24, 16, 8, 0} {
+		ca := channel(a, s)
+		out |= clamp255(ca+(ca-channel(b, s))/2) << s
+	}
+	return out
+}
+
+func subPixels(a, b uint32) uint32 {
+	return (((a | 0x00ff00ff) - (b & 0xff00ff00)) & 0xff00ff00) |
+		(((a | 0xff00ff00) - (b & 0x00ff00ff)) & 0x00ff00ff)
+}
+
+// residualCost is a cheap stand-in for entropy: small residuals code well.
+func residualCost(r uint32) int {
+	cost := 0
+	for _, s := range [4]uint{24, 16, 8, 0} {
+		v := int8(r >> s)
+		if v < 0 {
+			v = -v
+		}
+		cost += int(v)
+	}
+	return cost
+}
+
+// choosePredictors picks the cheapest predictor mode for every block and
+// returns them as the green channel of a sub-image.
+func choosePredictors(argb []uint32, w, h int) ([]uint32, int, int) {
+	size := 1 << predictorBits
+	mw, mh := (w+size-1)/size, (h+size-1)/size
+	modes := make([]uint32, mw*mh)
+	for by := 0; by < mh; by++ {
+		for bx := 0; bx < mw; bx++ {
+			best, bestCost := 11, -1
+			for mode := 0; mode < 14; mode++ {
+				cost := 0
+				for y := by * size; y < min((by+1)*size, h); y++ {
+					if y == 0 {
+						continue
+					}
+					for x := max(bx*size, 1); x < min((bx+1)*size, w); x++ {
+						i := y*w + x
+						cost += residualCost(subPixels(argb[i], predict(argb, i, w, mode)))
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = mode, cost
+				}
+			}
+			modes[by*mw+bx] = 0xff000000 | uint32(best)<<8
+		}
+	}
+	return modes, mw, mh
+}
+
+// applyPredictors replaces argb with prediction residuals. it works from the
+// bottom right so every prediction still sees original pixels.
+func applyPredictors(argb []uint32, w, h int, modes []uint32, mw int) {
+	for y := h - 1; y >= 0; y-- {
+		for x := w - 1; x >= 0; x-- {
+			i := y*w + x
+			var pred uint32
+			switch {
+			case x == 0 && y == 0:
+				pred = 0xff000000
+			case y == 0:
+				pred = argb[i-1]
+			case x == 0:
+				pred = argb[i-w]
+			default:
+				mode := int(modes[(y>>predictorBits)*mw+(x>>predictorBits)]>>8) & 0xf
+				pred = predict(argb, i, w, mode)
+			}
+			argb[i] = subPixels(argb[i], pred)
+		}
+	}
+}
+
+// symbol is one literal pixel or one backward reference.
+type symbol struct {
+	argb   uint32
+	length int // 0 for a literal
+	dist   int // distance code, already mapped
+}
+
+// writeImage entropy codes pix. the main image (main == true) has a meta
+// prefix code flag; sub-images such as the predictor modes do not.
+func writeImage(bw *bitWriter, pix []uint32, w, h int, main bool) {
+	syms := backwardRefs(pix, w)
+
+	green := make([]uint32, 256+24)
+	red := make([]uint32, 256)
+	blue := make([]uint32, 256)
+	alpha := make([]uint32, 256)
+	dist := make([]uint32, 40)
+	for _, s := range syms {
+		if s.length == 0 {
+			green[(s.argb>>8)&0xff]++
+			red[(s.argb>>16)&0xff]++
+			blue[s.argb&0xff]++
+			alpha[s.argb>>24]++
+			continue
+		}
+		lp, _, _ := prefixEncode(s.length)
+		green[256+lp]++
+		dp, _, _ := prefixEncode(s.dist)
+		dist[dp]++
+	}
+	codes := [5]*prefixCode{
+		newPrefixCode(green, 15),
+		newPrefixCode(red, 15),
+		newPrefixCode(blue, 15),
+		newPrefixCode(alpha, 15),
+		newPrefixCode(dist, 15),
+	}
+
+	bw.write(0, 1) // no colour cache
+	if main {
+		bw.write(0, 1) // one prefix code group for the whole image
+	}
+	for _, c := range codes {
+		writeCode(bw, c)
+	}
+	for _, s := range syms {
+		if s.length == 0 {
+			codes[0].writeSymbol(bw, int((s.argb>>8)&0xff))
+			codes[1].writeSymbol(bw, int((s.argb>>16)&0xff))
+			codes[2].writeSymbol(bw, int(s.argb&0xff))
+			codes[3].writeSymbol(bw, int(s.argb>>24))
+			continue
+		}
+		lp, lbits, lextra := prefixEncode(s.length)
+		codes[0].writeSymbol(bw, 256+lp)
+		bw.write(uint32(lextra), uint(lbits))
+		dp, dbits, dextra := prefixEncode(s.dist)
+		codes[4].writeSymbol(bw, dp)
+		bw.write(uint32(dextra), uint(dbits))
+	}
+}
+
+// prefixEncode splits a length or distance code into its prefix symbol and
+// extra bits.
+func prefixEncode(v int) (prefix, bits, extra int) {
+	d := v - 1
+	if d < 4 {
+		return d, 0, 0
+	}
+	hi := 0
+	for t := d; t > 1; t >>= 1 {
+		hi++
+	}
+	second := (d >> (hi - 1)) & 1
+	bits = hi - 1
+	return 2*hi + second, bits, d & (1<<bits - 1)
+}
+
+// backwardRefs turns pix into literals and copies. candidates are the
+// previous pixel, the pixel above and the last position with the same hash.
+func backwardRefs(pix []uint32, w int) []symbol {
+	n := len(pix)
+	var table [1 << hashBits]int32
+	for i := range table {
+		table[i] = -1
+	}
+	hash := func(i int) uint32 {
+		v := pix[i]*0x9e3779b1 ^ pix[i+1]*0x85ebca6b ^ pix[i+2]*0xc2b2ae35
+		return v >> (32 - hashBits)
+	}
+	matchLen := func(i, j int) int {
+		l := 0
+		for i+l < n && l < maxMatch && pix[i+l] == pix[j+l] {
+			l++
+		}
+		return l
+	}
+
+	syms := make([]symbol, 0, n/4)
+	for i := 0; i < n; {
+		bestLen, bestDist := 0, 0
+		try := func(j int) {
+			if j < 0 || j >= i || i-j > maxDistance {
+				return
+			}
+			if l := matchLen(i, j); l > bestLen {
+				bestLen, bestDist = l, i-j
+			}
+		}
+		try(i - 1)
+		try(i - w)
+		if i+2 < n {
+			h := hash(i)
+			try(int(table[h]))
+			table[h] = int32(i)
+		}
+		if bestLen < minMatch {
+			syms = append(syms, symbol{argb: pix[i]})
+			i++
+			continue
+		}
+		syms = append(syms, symbol{length: bestLen, dist: distanceCode(bestDist, w)})
+		// keep the hash fresh inside long copies, but not for every pixel
+		for k := i + 1; k < i+bestLen && k+2 < n; k += 8 {
+			table[hash(k)] = int32(k)
+		}
+		i += bestLen
+	}
+	return syms
+}
+
+// distanceCode maps a pixel distance onto a VP8L distance code. the short
+// codes cover the pixel to the left and the one above; everything else is
+// stored plainly, offset by the 120 neighbourhood codes.
+func distanceCode(d, w int) int {
+	switch d {
+	case w:
+		return 1
+	case 1:
+		return 2
+	}
+	return d + 120
+}
@@ -0,0 +1,721 @@
+/*
+	VP8 (lossy) key frame encoder. every macroblock uses whole-block 16x16
+	luma and 8x8 chroma prediction (DC, V, H or TM, whichever is closest),
+	a single quantizer and default token probabilities. that is a small
+	subset of what libwebp does, but it decodes everywhere and is plenty for
+	screenshots.
+*/
+
+package webp
+
+import "image"
+
+// quantizer holds the step sizes for one quality setting: [0] for DC, [1]
+// for AC.
+type quantizer struct {
+	y1, y2, uv [2]int32
+}
+
+func newQuantizer(q int) quantizer {
+	var z quantizer
+	z.y1 = [2]int32{int32(dequantTableDC[q]), int32(dequantTableAC[q])}
+	z.y2 = [2]int32{int32(dequantTableDC[q]) * 2, int32(dequantTableAC[q]) * 155 / 100}
+	if z.y2[1] < 8 {
+		z.y2[1] = 8
+	}
+	z.uv = [2]int32{int32(dequantTableDC[min(q, 117)]), int32(dequantTableAC[q])}
+	return z
+}
+
+// quantIndex maps quality 1-100 onto the 0-127 quantizer index.
+func quantIndex(quality int) int {
+	quality = max(1, min(quality, 100))
+	return (100 - quality) * 127 / 99
+}
+
+// Prediction modes, numbered as in the bitstream.
+const (
+	predDC = iota
+	predTM
+	predV
+	predH
+)
+
+// macroblock is one encoded 16x16 block: its modes and quantized levels in
+// zigzag order.
+type macroblock struct {
+	ymode, uvmode int
+	y2            [16]int32
+	y             [16][16]int32
+	uv            [8][16]int32 // 4 u blocks then 4 v blocks
+	skip          bool
+}
+
+type lossyEncoder struct {
+	mbw, mbh int
+	quant    quantizer
+
+	// source and reconstruction planes, padded to whole macroblocks
+	srcY, srcU, srcV []uint8
+	recY, recU, recV []uint8
+	strideY, strideC int
+}
+
+// encodeLossy returns a VP8 key frame for img.
+func encodeLossy(img image.Image, quality int) []byte {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	q := quantIndex(quality)
+	e := &lossyEncoder{mbw: (w + 15) / 16, mbh: (h + 15) / 16, quant: newQuantizer(q)}
+	e.strideY, e.strideC = e.mbw*16, e.mbw*8
+	e.toYUV(img)
+	e.recY = make([]uint8, len(e.srcY))
+	e.recU = make([]uint8, len(e.srcU))
+	e.recV = make([]uint8, len(e.srcV))
+
+	mbs := make([]macroblock, 0, e.mbw*e.mbh)
+	nonSkip := 0
+	for mby := 0; mby < e.mbh; mby++ {
+		for mbx := 0; mbx < e.mbw; mbx++ {
+			mb := e.encodeMacroblock(mbx, mby)
+			if !mb.skip {
+				nonSkip++
+			}
+			mbs = append(mbs, mb)
+		}
+	}
+	probSkipFalse := uint8(max(1, min(255, nonSkip*255/len(mbs))))
+
+	// first partition: frame header and per-macroblock modes
+	fp := newBoolEncoder()
+	fp.literal(0, 1) // colour space
+	fp.literal(0, 1) // clamping required
+	fp.literal(0, 1) // no segmentation
+	fp.literal(0, 1) // normal loop filter
+	fp.literal(uint32(filterLevel(q)), 6)
+	fp.literal(0, 3) // sharpness
+	fp.literal(0, 1) // no loop filter deltas
+	fp.literal(0, 2) // one token partition
+	fp.literal(uint32(q), 7)
+	for i := 0; i < 5; i++ {
+		fp.literal(0, 1) // no quantizer deltas
+	}
+	fp.literal(0, 1) // refresh entropy probs
+	for i := range tokenProbUpdateProb {
+		for j := range tokenProbUpdateProb[i] {
+			for k := range tokenProbUpdateProb[i][j] {
+				for l := range tokenProbUpdateProb[i][j][k] {
+					fp.put(tokenProbUpdateProb[i][j][k][l], false)
+				}
+			}
+		}
+	}
+	fp.literal(1, 1) // macroblocks may be skipped
+	fp.literal(uint32(probSkipFalse), 8)
+	for i := range mbs {
+		mb := &mbs[i]
+		fp.put(probSkipFalse, mb.skip)
+		fp.put(145, true) // 16x16 luma prediction
+		switch mb.ymode {
+		case predDC:
+			fp.put(156, false)
+			fp.put(163, false)
+		case predV:
+			fp.put(156, false)
+			fp.put(163, true)
+		case predH:
+			fp.put(156, true)
+			fp.put(128, false)
+		case predTM:
+			fp.put(156, true)
+			fp.put(128, true)
+		}
+		switch mb.uvmode {
+		case predDC:
+			fp.put(142, false)
+		case predV:
+			fp.put(142, true)
+			fp.put(114, false)
+		case predH:
+			fp.put(142, true)
+			fp.put(114, true)
+			fp.put(183, false)
+		case predTM:
+			fp.put(142, true)
+			fp.put(114, true)
+			fp.put(183, true)
+		}
+	}
+	first := fp.flush()
+
+	// token partition
+	tp := newBoolEncoder()
+	var left nzState
+	up := make([]nzState, e.mbw)
+	for mby := 0; mby < e.mbh; mby++ {
+		left = nzState{}
+		for mbx := 0; mbx < e.mbw; mbx++ {
+			mb := &mbs[mby*e.mbw+mbx]
+			if mb.skip {
+				left, up[mbx] = nzState{}, nzState{}
+				continue
+			}
+			writeResiduals(tp, mb, &left, &up[mbx])
+		}
+	}
+	tokens := tp.flush()
+
+	out := make([]byte, 10, 10+len(first)+len(tokens))
+	size := uint32(len(first))
+	out[0] = byte(1<<4 | (size&7)<<5) // key frame, version 0, shown
+	out[1] = byte(size >> 3)
+	out[2] = byte(size >> 11)
+	out[3], out[4], out[5] = 0x9d, 0x01, 0x2a
+	out[6], out[7] = byte(w), byte(w>>8)
+	out[8], out[9] = byte(h), byte(h>>8)
+	out = append(out, first...)
+	return append(out, tokens...)
+}
+
+// filterLevel picks a loop filter strength that grows with the quantizer.
+func filterLevel(q int) int {
+	return min(63, q/2)
+}
+
+// toYUV converts img to BT.601 studio-range planes, replicating the right
+// and bottom edges into the macroblock padding.
+func (e *lossyEncoder) toYUV(img image.Image) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	pw, ph := e.mbw*16, e.mbh*16
+	rgb := make([]int32, pw*ph*3)
+	rgba, _ := img.(*image.RGBA)
+	for y := 0; y < ph; y++ {
+		sy := min(y, h-1)
+		for x := 0; x < pw; x++ {
+			sx := min(x, w-1)
+			i := (y*pw + x) * 3
+			if rgba != nil {
+				o := rgba.PixOffset(b.Min.X+sx, b.Min.Y+sy)
+				rgb[i], rgb[i+1], rgb[i+2] = int32(rgba.Pix[o]), int32(rgba.Pix[o+1]), int32(rgba.Pix[o+2])
+				continue
+			}
+			r, g, bl, _ := img.At(b.Min.X+sx, b.Min.Y+sy).RGBA()
+			rgb[i], rgb[i+1], rgb[i+2] = int32(r>>8), int32(g>>8), int32(bl>>8)
+		}
+	}
+	e.srcY = make([]uint8, pw*ph)
+	for i := range e.srcY {
+		r, g, bl := rgb[3*i], rgb[3*i+1], rgb[3*i+2]
+		e.srcY[i] = uint8((16839*r + 33059*g + 6420*bl + (16 << 16) + 1<<15) >> 16)
+	}
+	cw, ch := pw/2, ph/2
+	e.srcU = make([]uint8, cw*ch)
+	e.srcV = make([]uint8, cw*ch)
+	for y := 0; y < ch; y++ {
+		for x := 0; x < cw; x++ {
+			var r, g, bl int32
+			for _, d := range [4][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+				i := ((2*y+d[1])*pw + 2*x + d[0]) * 3
+				r, g, bl = r+rgb[i], g+rgb[i+1], bl+rgb[i+2]
+			}
+			// r, g and b are sums of four pixels, hence the extra >> 2
+			e.srcU[y*cw+x] = clip8((-9719*r - 19081*g + 28800*bl + (128 << 18) + 1<<17) >> 18)
+			e.srcV[y*cw+x] = clip8((28800*r - 24116*g - 4684*bl + (128 << 18) + 1<<17) >> 18)
+		}
+	}
+}
+
+func clip8(v int32) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
+
+// edges gathers the reconstructed pixels above and left of an n x n block at
+// x, y of a plane, with the substitutes VP8 uses off the frame.
+func edges(rec []uint8, stride, x, y, n int) (top, left []uint8, corner uint8) {
+	top = make([]uint8, n)
+	left = make([]uint8, n)
+	for i := 0; i < n; i++ {
+		if y == 0 {
+			top[i] = 127
+		} else {
+			top[i] = rec[(y-1)*stride+x+i]
+		}
+		if x == 0 {
+			left[i] = 129
+		} else {
+			left[i] = rec[(y+i)*stride+x-1]
+		}
+	}
+	switch {
+	case y == 0:
+		corner = 127
+	case x == 0:
+		corner = 129
+	default:
+		corner = rec[(y-1)*stride+x-1]
+	}
+	return top, left, corner
+}
+
+// predictBlock fills an n x n prediction for mode.
+func predictBlock(mode, n int, top, left []uint8, corner uint8, hasTop, hasLeft bool) []uint8 {
+	p := make([]uint8, n*n)
+	switch mode {
+	case predDC:
+		shift := 3
+		if n == 16 {
+			shift = 4
+		}
+		var sum int
+		dc := 128
+		switch {
+		case hasTop && hasLeft:
+			for i := 0; i < n; i++ {
+				sum += int(top[i]) + int(left[i])
+			}
+			dc = (sum + n) >> (shift + 1)
+		case hasTop:
+			for i := 0; i < n; i++ {
+				sum += int(top[i])
+			}
+			dc = (sum + n/2) >> shift
+		case hasLeft:
+			for i := 0; i < n; i++ {
+				sum += int(left[i])
+			}
+			dc = (sum + n/2) >> shift
+		}
+		for i := range p {
+			p[i] = uint8(dc)
+		}
+	case predV:
+		for y := 0; y < n; y++ {
+			copy(p[y*n:], top)
+		}
+	case predH:
+		for y := 0; y < n; y++ {
+			for x := 0; x < n; x++ {
+				p[y*n+x] = left[y]
+			}
+		}
+	case predTM:
+		for y := 0; y < n; y++ {
+			for x := 0; x < n; x++ {
+				p[y*n+x] = clip8(int32(left[y]) + int32(top[x]) - int32(corner))
+			}
+		}
+	}
+	return p
+}
+
+// bestPrediction tries every mode for the n x n block at x, y and returns
+// the one closest to the source.
+func bestPrediction(src, rec []uint8, stride, x, y, n int) (int, []uint8) {
+	top, left, corner := edges(rec, stride, x, y, n)
+	best, bestSAD := predDC, -1
+	var bestPred []uint8
+	for _, mode := range []int{predDC, predV, predH, predTM} {
+		p := predictBlock(mode, n, top, left, corner, y > 0, x > 0)
+		sad := 0
+		for j := 0; j < n; j++ {
+			for i := 0; i < n; i++ {
+				d := int(src[(y+j)*stride+x+i]) - int(p[j*n+i])
+				if d < 0 {
+					d = -d
+				}
+				sad += d
+			}
+		}
+		if bestSAD < 0 || sad < bestSAD {
+			best, bestSAD, bestPred = mode, sad, p
+		}
+	}
+	return best, bestPred
+}
+
+// encodeMacroblock picks modes, quantizes the residuals and writes the
+// reconstruction back so later macroblocks predict from what the decoder
+// will see.
+func (e *lossyEncoder) encodeMacroblock(mbx, mby int) macroblock {
+	var mb macroblock
+	z := e.quant
+
+	// luma: 16 4x4 DCTs whose DCs go through the Y2 Walsh-Hadamard transform
+	x0, y0 := mbx*16, mby*16
+	var pred []uint8
+	mb.ymode, pred = bestPrediction(e.srcY, e.recY, e.strideY, x0, y0, 16)
+	var coeffs [16][16]int32
+	var dcs [16]int32
+	for n := 0; n < 16; n++ {
+		bx, by := (n%4)*4, (n/4)*4
+		var res [16]int32
+		for j := 0; j < 4; j++ {
+			for i := 0; i < 4; i++ {
+				res[j*4+i] = int32(e.srcY[(y0+by+j)*e.strideY+x0+bx+i]) - int32(pred[(by+j)*16+bx+i])
+			}
+		}
+		coeffs[n] = fdct(res)
+		dcs[n] = coeffs[n][0]
+	}
+	y2 := fwht(dcs)
+	var y2deq [16]int32
+	for k := 0; k < 16; k++ {
+		raster := zigzag[k]
+		step := z.y2[btoi(raster > 0)]
+		mb.y2[k] = quantize(y2[raster], step)
+		y2deq[raster] = mb.y2[k] * step
+	}
+	dcRec := iwht(y2deq)
+	coded := false
+	for n := 0; n < 16; n++ {
+		var deq [16]int32
+		deq[0] = dcRec[n]
+		for k := 1; k < 16; k++ {
+			raster := zigzag[k]
+			mb.y[n][k] = quantize(coeffs[n][raster], z.y1[1])
+			deq[raster] = mb.y[n][k] * z.y1[1]
+			coded = coded || mb.y[n][k] != 0
+		}
+		bx, by := (n%4)*4, (n/4)*4
+		block := idct(deq)
+		for j := 0; j < 4; j++ {
+			for i := 0; i < 4; i++ {
+				e.recY[(y0+by+j)*e.strideY+x0+bx+i] = clip8(int32(pred[(by+j)*16+bx+i]) + block[j*4+i])
+			}
+		}
+	}
+	for _, v := range mb.y2 {
+		coded = coded || v != 0
+	}
+
+	// chroma: both planes share one mode, chosen on u+v together
+	cx, cy := mbx*8, mby*8
+	mb.uvmode = bestChromaMode(e, cx, cy)
+	for plane, pl := range [2]struct{ src, rec []uint8 }{{e.srcU, e.recU}, {e.srcV, e.recV}} {
+		top, left, corner := edges(pl.rec, e.strideC, cx, cy, 8)
+		pred := predictBlock(mb.uvmode, 8, top, left, corner, cy > 0, cx > 0)
+		for n := 0; n < 4; n++ {
+			bx, by := (n%2)*4, (n/2)*4
+			var res [16]int32
+			for j := 0; j < 4; j++ {
+				for i := 0; i < 4; i++ {
+					res[j*4+i] = int32(pl.src[(cy+by+j)*e.strideC+cx+bx+i]) - int32(pred[(by+j)*8+bx+i])
+				}
+			}
+			c := fdct(res)
+			var deq [16]int32
+			lv := &mb.uv[plane*4+n]
+			for k := 0; k < 16; k++ {
+				raster := zigzag[k]
+				step := z.uv[btoi(raster > 0)]
+				lv[k] = quantize(c[raster], step)
+				deq[raster] = lv[k] * step
+				coded = coded || lv[k] != 0
+			}
+			block := idct(deq)
+			for j := 0; j < 4; j++ {
+				for i := 0; i < 4; i++ {
+					pl.rec[(cy+by+j)*e.strideC+cx+bx+i] = clip8(int32(pred[(by+j)*8+bx+i]) + block[j*4+i])
+				}
+			}
+		}
+	}
+	mb.skip = !coded
+	return mb
+}
+
+func bestChromaMode(e *lossyEncoder, cx, cy int) int {
+	tu, lu, cu := edges(e.recU, e.strideC, cx, cy, 8)
+	tv, lv, cv := edges(e.recV, e.strideC, cx, cy, 8)
+	best, bestSAD := predDC, -1
+	for _, mode := range []int{predDC, predV, predH, predTM} {
+		pu := predictBlock(mode, 8, tu, lu, cu, cy > 0, cx > 0)
+		pv := predictBlock(mode, 8, tv, lv, cv, cy > 0, cx > 0)
+		sad := 0
+		for j := 0; j < 8; j++ {
+			for i := 0; i < 8; i++ {
+				o := (cy+j)*e.strideC + cx + i
+				sad += absInt(int(e.srcU[o])-int(pu[j*8+i])) + absInt(int(e.srcV[o])-int(pv[j*8+i]))
+			}
+		}
+		if bestSAD < 0 || sad < bestSAD {
+			best, bestSAD = mode, sad
+		}
+	}
+	return best
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// quantize rounds c to the nearest multiple of step, with a slight dead
+// zone so noise doesn't cost bits.
+func quantize(c, step int32) int32 {
+	neg := c < 0
+	if neg {
+		c = -c
+	}
+	v := (c + step*3/8) / step
+	if v > 2048 {
+		v = 2048
+	}
+	if neg {
+		return -v
+	}
+	return v
+}
+
+// fdct is the forward 4x4 transform from libvpx/libwebp; out is in raster
+// order.
+func fdct(in [16]int32) [16]int32 {
+	var tmp, out [16]int32
+	for i := 0; i < 4; i++ {
+		d0, d1, d2, d3 := in[i*4], in[i*4+1], in[i*4+2], in[i*4+3]
+		a0, a1, a2, a3 := d0+d3, d1+d2, d1-d2, d0-d3
+		tmp[0+i*4] = (a0 + a1) * 8
+		tmp[1+i*4] = (a2*2217 + a3*5352 + 1812) >> 9
+		tmp[2+i*4] = (a0 - a1) * 8
+		tmp[3+i*4] = (a3*2217 - a2*5352 + 937) >> 9
+	}
+	for i := 0; i < 4; i++ {
+		a0 := tmp[0+i] + tmp[12+i]
+		a1 := tmp[4+i] + tmp[8+i]
+		a2 := tmp[4+i] - tmp[8+i]
+		a3 := tmp[0+i] - tmp[12+i]
+		out[0+i] = (a0 + a1 + 7) >> 4
+		out[4+i] = (a2*2217+a3*5352+12000)>>16 + int32(btoi(a3 != 0))
+		out[8+i] = (a0 - a1 + 7) >> 4
+		out[12+i] = (a3*2217 - a2*5352 + 51000) >> 16
+	}
+	return out
+}
+
+// idct is the decoder's inverse 4x4 transform, returning the residual.
+func idct(in [16]int32) [16]int32 {
+	const c1, c2 = 85627, 35468
+	var m [4][4]int32
+	for i := 0; i < 4; i++ {
+		a := in[i] + in[8+i]
+		b := in[i] - in[8+i]
+		c := (in[4+i]*c2)>>16 - (in[12+i]*c1)>>16
+		d := (in[4+i]*c1)>>16 + (in[12+i]*c2)>>16
+		m[i][0] = a + d
+		m[i][1] = b + c
+		m[i][2] = b - c
+		m[i][3] = a - d
+	}
+	var out [16]int32
+	for j := 0; j < 4; j++ {
+		dc := m[0][j] + 4
+		a := dc + m[2][j]
+		b := dc - m[2][j]
+		c := (m[1][j]*c2)>>16 - (m[3][j]*c1)>>16
+		d := (m[1][j]*c1)>>16 + (m[3][j]*c2)>>16
+		out[j*4+0] = (a + d) >> 3
+		out[j*4+1] = (b + c) >> 3
+		out[j*4+2] = (b - c) >> 3
+		out[j*4+3] = (a - d) >> 3
+	}
+	return out
+}
+
+// fwht transforms the 16 luma DCs (block raster order) into Y2
+// coefficients.
+func fwht(in [16]int32) [16]int32 {
+	var tmp, out [16]int32
+	for i := 0; i < 4; i++ {
+		a0 := in[i*4+0] + in[i*4+2]
+		a1 := in[i*4+1] + in[i*4+3]
+		a2 := in[i*4+1] - in[i*4+3]
+		a3 := in[i*4+0] - in[i*4+2]
+		tmp[0+i*4] = a0 + a1
+		tmp[1+i*4] = a3 + a2
+		tmp[2+i*4] = a3 - a2
+		tmp[3+i*4] = a0 - a1
+	}
+	for i := 0; i < 4; i++ {
+		a0 := tmp[0+i] + tmp[8+i]
+		a1 := tmp[4+i] + tmp[12+i]
+		a2 := tmp[4+i] - tmp[12+i]
+		a3 := tmp[0+i] - tmp[8+i]
+		out[0+i] = (a0 + a1) >> 1
+		out[4+i] = (a3 + a2) >> 1
+		out[8+i] = (a3 - a2) >> 1
+		out[12+i] = (a0 - a1) >> 1
+	}
+	return out
+}
+
+// iwht is the decoder's inverse Walsh-Hadamard transform, returning the DC
+// of each luma block in raster order.
+func iwht(in [16]int32) [16]int32 {
+	var m, out [16]int32
+	for i := 0; i < 4; i++ {
+		a0 := in[0+i] + in[12+i]
+		a1 := in[4+i] + in[8+i]
+		a2 := in[4+i] - in[8+i]
+		a3 := in[0+i] - in[12+i]
+		m[0+i] = a0 + a1
+		m[8+i] = a0 - a1
+		m[4+i] = a3 + a2
+		m[12+i] = a3 - a2
+	}
+	for i := 0; i < 4; i++ {
+		dc := m[0+i*4] + 3
+		a0 := dc + m[3+i*4]
+		a1 := m[1+i*4] + m[2+i*4]
+		a2 := m[1+i*4] - m[2+i*4]
+		a3 := dc - m[3+i*4]
+		out[i*4+0] = (a0 + a1) >> 3
+		out[i*4+1] = (a3 + a2) >> 3
+		out[i*4+2] = (a0 - a1) >> 3
+		out[i*4+3] = (a3 - a2) >> 3
+	}
+	return out
+}
+
+// nzState remembers which blocks along a macroblock edge had coefficients;
+// it is the context for the neighbouring macroblock's tokens.
+type nzState struct {
+	y2 int
+	y  [4]int
+	uv [4]int // u0 u1 v0 v1
+}
+
+func writeResiduals(tp *boolEncoder, mb *macroblock, left, up *nzState) {
+	nz := writeBlock(tp, planeY2, left.y2+up.y2, &mb.y2, 0)
+	left.y2, up.y2 = nz, nz
+	for y := 0; y < 4; y++ {
+		nz := left.y[y]
+		for x := 0; x < 4; x++ {
+			nz = writeBlock(tp, planeY1WithY2, nz+up.y[x], &mb.y[y*4+x], 1)
+			up.y[x] = nz
+		}
+		left.y[y] = nz
+	}
+	for c := 0; c < 4; c += 2 {
+		for y := 0; y < 2; y++ {
+			nz := left.uv[y+c]
+			for x := 0; x < 2; x++ {
+				nz = writeBlock(tp, planeUV, nz+up.uv[x+c], &mb.uv[c*2+y*2+x], 0)
+				up.uv[x+c] = nz
+			}
+			left.uv[y+c] = nz
+		}
+	}
+}
+
+// writeBlock codes one block's levels (zigzag order) from position first and
+// reports whether it had any.
+func writeBlock(tp *boolEncoder, plane, ctx int, lv *[16]int32, first int) int {
+	probs := &defaultTokenProb[plane]
+	last := -1
+	for k := 15; k >= first; k-- {
+		if lv[k] != 0 {
+			last = k
+			break
+		}
+	}
+	p := &probs[bands[first]][ctx]
+	if last < 0 {
+		tp.put(p[0], false)
+		return 0
+	}
+	tp.put(p[0], true)
+	for n := first; n < 16; {
+		v := lv[n]
+		n++
+		if v == 0 {
+			tp.put(p[1], false)
+			p = &probs[bands[n]][0]
+			continue
+		}
+		tp.put(p[1], true)
+		a := v
+		if a < 0 {
+			a = -a
+		}
+		if a == 1 {
+			tp.put(p[2], false)
+			p2 := &probs[bands[n]][1]
+			tp.put(128, v < 0)
+			p = p2
+		} else {
+			tp.put(p[2], true)
+			writeLarge(tp, p, a)
+			tp.put(128, v < 0)
+			p = &probs[bands[n]][2]
+		}
+		if n == 16 {
+			break
+		}
+		if n > last {
+			tp.put(p[0], false) // end of block
+			break
+		}
+		tp.put(p[0], true)
+	}
+	return 1
+}
+
+// writeLarge codes a level of 2 or more with the token tree and extra bits.
+func writeLarge(tp *boolEncoder, p *[nProb]uint8, a int32) {
+	switch {
+	case a <= 4:
+		tp.put(p[3], false)
+		if a == 2 {
+			tp.put(p[4], false)
+			return
+		}
+		tp.put(p[4], true)
+		tp.put(p[5], a == 4)
+	case a <= 10:
+		tp.put(p[3], true)
+		tp.put(p[6], false)
+		if a <= 6 {
+			tp.put(p[7], false)
+			tp.put(159, a == 6)
+			return
+		}
+		tp.put(p[7], true)
+		tp.put(165, (a-7)>>1 != 0)
+		tp.put(145, (a-7)&1 != 0)
+	default:
+		tp.put(p[3], true)
+		tp.put(p[6], true)
+		cat := 0
+		switch {
+		case a >= 67:
+			cat = 3
+		case a >= 35:
+			cat = 2
+		case a >= 19:
+			cat = 1
+		}
+		tp.put(p[8], cat >= 2)
+		tp.put(p[9+cat>>1], cat&1 != 0)
+		extra := a - (3 + 8<<cat)
+		tab := cat3456[cat]
+		for i := range tab {
+			tp.put(tab[i], extra>>(len(tab)-1-i)&1 != 0)
+		}
+	}
+}
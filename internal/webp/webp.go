@@ -0,0 +1,57 @@
+/*
+	a small pure-go WebP encoder for screenshots. golang.org/x/image only
+	decodes webp, and we don't want cgo or cwebp just to save a screenshot.
+	lossless output is VP8L; lossy output is a plain VP8 key frame.
+*/
+
+package webp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+)
+
+// Options controls Encode.
+type Options struct {
+	Lossless bool
+	Quality  int // 1-100 for lossy output, ignored when Lossless
+}
+
+// Encode writes img to w as a WebP file. Lossy output drops alpha.
+func Encode(w io.Writer, img image.Image, o *Options) error {
+	b := img.Bounds()
+	if b.Dx() < 1 || b.Dy() < 1 || b.Dx() > 16383 || b.Dy() > 16383 {
+		return fmt.Errorf("webp: cannot encode a %dx%d image", b.Dx(), b.Dy())
+	}
+	if o == nil {
+		o = &Options{Quality: 75}
+	}
+	var chunk string
+	var data []byte
+	if o.Lossless {
+		chunk, data = "VP8L", encodeLossless(img)
+	} else {
+		chunk, data = "VP8 ", encodeLossy(img, o.Quality)
+	}
+
+	pad := len(data) & 1
+	hdr := make([]byte, 20)
+	copy(hdr[0:], "RIFF")
+	binary.LittleEndian.PutUint32(hdr[4:], uint32(4+8+len(data)+pad))
+	copy(hdr[8:], "WEBP")
+	copy(hdr[12:], chunk)
+	binary.LittleEndian.PutUint32(hdr[16:], uint32(len(data)))
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if pad != 0 {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+	return nil
+}
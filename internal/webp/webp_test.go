@@ -0,0 +1,154 @@
+package webp
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	xwebp "golang.org/x/image/webp"
+)
+
+// testImage is w x h of gradients, flat runs and repeated rows, so the
+// predictors and LZ77 both get used. alpha picks each
+// pixel's alpha.
+func testImage(w, h int, alpha func(x, y int) uint8) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{uint8(x * 7), uint8(y * 5), uint8(x*y + 3), alpha(x, y)}
+			switch {
+			case y%9 == 4:
+				c = img.NRGBAAt(x, y-1)
+			case x > w/2 && y > h/2:
+				c.R, c.G, c.B = 0x20, 0x40, 0x60
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func opaque(x, y int) uint8      { return 0xff }
+func translucent(x, y int) uint8 { return uint8(x*y) | 1 }
+func holes(x, y int) uint8 {
+	if (x+y)%5 == 0 {
+		return 0
+	}
+	return 0xff
+}
+
+func encodeDecode(t *testing.T, img image.Image, o *Options) image.Image {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Encode(&buf, img, o); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	out, err := xwebp.Decode(&buf)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.Bounds().Size() != img.Bounds().Size() {
+		t.Fatalf("decoded %v, want %v", out.Bounds().Size(), img.Bounds().Size())
+	}
+	return out
+}
+
+func TestLosslessRoundTrip(t *testing.T) {
+	premul := func(src *image.NRGBA) image.Image {
+		dst := image.NewRGBA(src.Bounds())
+		for y := 0; y < src.Bounds().Dy(); y++ {
+			for x := 0; x < src.Bounds().Dx(); x++ {
+				dst.Set(x, y, src.At(x, y))
+			}
+		}
+		return dst
+	}
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{"nrgba opaque", testImage(70, 45, opaque)},
+		{"nrgba translucent", testImage(70, 45, translucent)},
+		{"nrgba transparent holes", testImage(33, 17, holes)},
+		{"rgba opaque", premul(testImage(70, 45, opaque))},
+		{"rgba translucent", premul(testImage(70, 45, translucent))},
+		{"nrgba sub-image", testImage(40, 40, translucent).SubImage(image.Rect(5, 7, 37, 30))},
+		{"gray16", image.NewGray16(image.Rect(0, 0, 20, 20))},
+		{"one pixel", testImage(1, 1, translucent)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := encodeDecode(t, tt.img, &Options{Lossless: true})
+			b, ob := tt.img.Bounds(), out.Bounds()
+			for y := 0; y < b.Dy(); y++ {
+				for x := 0; x < b.Dx(); x++ {
+					want := color.NRGBAModel.Convert(tt.img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+					got := color.NRGBAModel.Convert(out.At(ob.Min.X+x, ob.Min.Y+y)).(color.NRGBA)
+					if want.A == 0 {
+						// the colour under a clear pixel is the decoder's to pick
+						want.R, want.G, want.B, got.R, got.G, got.B = 0, 0, 0, 0, 0, 0
+					}
+					if got != want {
+						t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestLossy(t *testing.T) {
+	tests := []struct {
+		quality int
+		maxErr  float64 // mean absolute error a channel
+	}{
+		{10, 8},
+		{75, 4},
+		{100, 3},
+	}
+	// smooth, so what's lost is the quantizer's doing and not the chroma
+	// subsampling's
+	img := image.NewNRGBA(image.Rect(0, 0, 70, 45))
+	for y := 0; y < 45; y++ {
+		for x := 0; x < 70; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x * 3), uint8(y * 5), uint8(200 - x - y), 0xff})
+		}
+	}
+	for _, tt := range tests {
+		out, ok := encodeDecode(t, img, &Options{Quality: tt.quality}).(*image.YCbCr)
+		if !ok {
+			t.Fatalf("quality %d: decoded a %T, want *image.YCbCr", tt.quality, out)
+		}
+		var sum float64
+		for y := 0; y < 45; y++ {
+			for x := 0; x < 70; x++ {
+				want := img.NRGBAAt(x, y)
+				r, g, b := studioRGB(out.Y[out.YOffset(x, y)], out.Cb[out.COffset(x, y)], out.Cr[out.COffset(x, y)])
+				sum += math.Abs(r-float64(want.R)) + math.Abs(g-float64(want.G)) + math.Abs(b-float64(want.B))
+			}
+		}
+		if e := sum / (70 * 45 * 3); e > tt.maxErr {
+			t.Errorf("quality %d: mean error %.1f, want at most %v", tt.quality, e, tt.maxErr)
+		}
+	}
+}
+
+// studioRGB converts BT.601 studio-range YCbCr, which is what VP8 holds,
+// to RGB. x/image's decoder leaves the planes as they are and its At
+// reads them as full range, so the test can't use that.
+func studioRGB(y, cb, cr uint8) (r, g, b float64) {
+	l := 1.164 * (float64(y) - 16)
+	u, v := float64(cb)-128, float64(cr)-128
+	clamp := func(c float64) float64 { return max(0, min(255, c)) }
+	return clamp(l + 1.596*v), clamp(l - 0.391*u - 0.813*v), clamp(l + 2.018*u)
+}
+
+func TestEncodeBadSize(t *testing.T) {
+	for _, r := range []image.Rectangle{image.Rect(0, 0, 0, 5), image.Rect(0, 0, 16384, 1)} {
+		if err := Encode(&bytes.Buffer{}, image.NewGray(r), nil); err == nil {
+			t.Errorf("Encode of %v: no error", r.Size())
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package x11
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMaskByte(t *testing.T) {
+	for mask, want := range map[uint32]uint{0xff: 0, 0xff00: 1, 0xff0000: 2, 0xff000000: 3, 0x3ff00000: 3} {
+		if got := maskByte(mask); got != want {
+			t.Errorf("maskByte(%#x) = %d, want %d", mask, got, want)
+		}
+	}
+}
+
+func TestToRGBA(t *testing.T) {
+	// two pixels, red then blue-green, as the server sends them
+	tests := []struct {
+		name string
+		l    pixelLayout
+		src  []byte
+	}{
+		{"BGRX, the usual little-endian server", pixelLayout{r: 2, g: 1, b: 0}, []byte{0x10, 0x20, 0xf0, 0x00, 0xc0, 0xb0, 0x00, 0x00}},
+		{"XRGB from a big-endian server", pixelLayout{r: 3 - 2, g: 3 - 1, b: 3 - 0, bigEndian: true}, []byte{0x00, 0xf0, 0x20, 0x10, 0x00, 0x00, 0xb0, 0xc0}},
+		{"RGBX", pixelLayout{r: 0, g: 1, b: 2}, []byte{0xf0, 0x20, 0x10, 0xff, 0x00, 0xb0, 0xc0, 0xff}},
+	}
+	want := []byte{0xf0, 0x20, 0x10, 0xff, 0x00, 0xb0, 0xc0, 0xff}
+	for _, tt := range tests {
+		dst := make([]byte, len(tt.src))
+		tt.l.toRGBA(dst, tt.src)
+		if !bytes.Equal(dst, want) {
+			t.Errorf("%s: got % x, want % x", tt.name, dst, want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+/*
+	a tiny X client. we used to scrape xdpyinfo, xrandr and xdotool for
+	everything; now we speak the protocol ourselves (through xgb) so none of
+	those need to be installed.
+*/
+
+package x11
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// ErrNoDisplay means there is no X server to talk to.
+var ErrNoDisplay = errors.New("no X display")
+
+// Conn is a connection to the X server plus the bits of its setup we use.
+type Conn struct {
+	X      *xgb.Conn
+	Root   xproto.Window
+	Screen *xproto.ScreenInfo
+
+	atoms map[string]xproto.Atom
+}
+
+// Open connects to $DISPLAY.
+func Open() (*Conn, error) {
+	return OpenDisplay(os.Getenv("DISPLAY"))
+}
+
+// OpenDisplay connects to the given display, e.g. ":0".
+func OpenDisplay(display string) (*Conn, error) {
+	if display == "" {
+		return nil, ErrNoDisplay
+	}
+	x, err := xgb.NewConnDisplay(display)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrNoDisplay, display, err)
+	}
+	setup := xproto.Setup(x)
+	screen := setup.DefaultScreen(x)
+	return &Conn{X: x, Root: screen.Root, Screen: screen, atoms: map[string]xproto.Atom{}}, nil
+}
+
+// Close hangs up.
+func (c *Conn) Close() {
+	c.X.Close()
+}
+
+// ScreenSize returns the size of the root window, which spans every monitor.
+func (c *Conn) ScreenSize() (int, int, error) {
+	g, err := xproto.GetGeometry(c.X, xproto.Drawable(c.Root)).Reply()
+	if err != nil {
+		return 0, 0, fmt.Errorf("x11: root geometry: %w", err)
+	}
+	return int(g.Width), int(g.Height), nil
+}
+
+// PointerPosition returns the pointer's root window coordinates.
+func (c *Conn) PointerPosition() (int, int, error) {
+	p, err := xproto.QueryPointer(c.X, c.Root).Reply()
+	if err != nil {
+		return 0, 0, fmt.Errorf("x11: query pointer: %w", err)
+	}
+	if !p.SameScreen {
+		return 0, 0, fmt.Errorf("x11: pointer is on another screen")
+	}
+	return int(p.RootX), int(p.RootY), nil
+}
+
+// atom interns name, caching the result for the life of the connection.
+func (c *Conn) atom(name string) (xproto.Atom, error) {
+	if a, ok := c.atoms[name]; ok {
+		return a, nil
+	}
+	r, err := xproto.InternAtom(c.X, false, uint16(len(name)), name).Reply()
+	if err != nil {
+		return 0, fmt.Errorf("x11: intern %s: %w", name, err)
+	}
+	c.atoms[name] = r.Atom
+	return r.Atom, nil
+}
+
+// atomName looks an atom up by value.
+func (c *Conn) atomName(a xproto.Atom) string {
+	r, err := xproto.GetAtomName(c.X, a).Reply()
+	if err != nil {
+		return ""
+	}
+	return r.Name
+}
+
+// ScreenSize returns the size of the whole X screen, i.e. the bounding box of
+// all monitors.
+func ScreenSize() (int, int, error) {
+	c, err := Open()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer c.Close()
+	return c.ScreenSize()
+}
+
+// PointerPosition returns the pointer's root window coordinates.
+func PointerPosition() (int, int, error) {
+	c, err := Open()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer c.Close()
+	return c.PointerPosition()
+}
@@ -0,0 +1,81 @@
+/*
+	the pointer isn't part of the root window's pixels, so grabs come back
+	without it. XFixes hands us the current cursor image to paint on top.
+*/
+
+package x11
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+
+	"github.com/jezek/xgb/xfixes"
+)
+
+// Cursor returns the current cursor image, positioned in screen
+// coordinates (hotspot already subtracted).
+func (c *Conn) Cursor() (*image.RGBA, error) {
+	if err := xfixes.Init(c.X); err != nil {
+		return nil, fmt.Errorf("x11: XFixes unavailable: %w", err)
+	}
+	// the server refuses XFixes requests until we've said which version we speak
+	if _, err := xfixes.QueryVersion(c.X, 4, 0).Reply(); err != nil {
+		return nil, err
+	}
+	r, err := xfixes.GetCursorImage(c.X).Reply()
+	if err != nil {
+		return nil, err
+	}
+	w, h := int(r.Width), int(r.Height)
+	x, y := int(r.X)-int(r.Xhot), int(r.Y)-int(r.Yhot)
+	img := image.NewRGBA(image.Rect(x, y, x+w, y+h))
+	for i, p := range r.CursorImage[:w*h] {
+		// premultiplied ARGB, which is what image.RGBA wants too
+		img.Pix[i*4+0] = byte(p >> 16)
+		img.Pix[i*4+1] = byte(p >> 8)
+		img.Pix[i*4+2] = byte(p)
+		img.Pix[i*4+3] = byte(p >> 24)
+	}
+	return img, nil
+}
+
+// DrawCursor paints the cursor over dst, a grab of the screen rectangle
+// whose top-left corner is at origin.
+func (c *Conn) DrawCursor(dst *image.RGBA, origin image.Point) error {
+	cur, err := c.Cursor()
+	if err != nil {
+		return err
+	}
+	r := cur.Bounds().Sub(origin).Add(dst.Bounds().Min)
+	draw.Draw(dst, r, cur, cur.Bounds().Min, draw.Over)
+	return nil
+}
+
+// CaptureWithCursor is Capture with the pointer painted in. a cursor we
+// can't fetch is not worth failing the screenshot over.
+func CaptureWithCursor(rect image.Rectangle) (*image.RGBA, error) {
+	c, err := Open()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	if rect.Empty() {
+		w, h, err := c.ScreenSize()
+		if err != nil {
+			return nil, err
+		}
+		rect = image.Rect(0, 0, w, h)
+	}
+	g, err := c.NewGrabber(rect)
+	if err != nil {
+		return nil, err
+	}
+	defer g.Close()
+	img, err := g.Grab(nil)
+	if err != nil {
+		return nil, err
+	}
+	c.DrawCursor(img, g.Rect().Min)
+	return img, nil
+}
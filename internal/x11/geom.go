@@ -4,14 +4,13 @@
 
 package x11
 
-import (
-	"os/exec"
-)
+import "fmt"
 
+// GetGeometry returns the whole X screen as a WxH+0+0 region string.
 func GetGeometry() (string, error) {
-	out, err := exec.Command("xdpyinfo").Output()
+	w, h, err := ScreenSize()
 	if err != nil {
 		return "", err
 	}
-	return string(out), nil
+	return fmt.Sprintf("%dx%d+0+0", w, h), nil
 }
@@ -0,0 +1,201 @@
+/*
+	global hotkeys: keys grabbed on the root window with XGrabKey, so they
+	reach us whichever window has focus. a key another client already grabbed
+	can't be had (BadAccess); those come back as a HotkeyConflict.
+*/
+
+package x11
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jezek/xgb/xproto"
+)
+
+// Hotkey is a key plus the modifiers held with it.
+type Hotkey struct {
+	Mods uint16 // xproto.ModMask* bits
+	Sym  uint32 // X keysym
+}
+
+var modNames = []struct {
+	name string
+	mask uint16
+}{
+	{"Ctrl", xproto.ModMaskControl},
+	{"Alt", xproto.ModMask1},
+	{"Shift", xproto.ModMaskShift},
+	{"Super", xproto.ModMask4},
+}
+
+// keysyms are the keys a hotkey can end in, besides letters and digits.
+var keysyms = map[string]uint32{
+	"Space": 0x0020, "Print": 0xff61, "Pause": 0xff13, "ScrollLock": 0xff14,
+	"Insert": 0xff63, "Delete": 0xffff, "Home": 0xff50, "End": 0xff57,
+	"PageUp": 0xff55, "PageDown": 0xff56,
+	"F1": 0xffbe, "F2": 0xffbf, "F3": 0xffc0, "F4": 0xffc1, "F5": 0xffc2, "F6": 0xffc3,
+	"F7": 0xffc4, "F8": 0xffc5, "F9": 0xffc6, "F10": 0xffc7, "F11": 0xffc8, "F12": 0xffc9,
+}
+
+// ParseHotkey reads a binding like "Ctrl+Alt+R", "Super+Shift+4" or
+// "Print". modifier names are Ctrl, Alt, Shift and Super.
+func ParseHotkey(s string) (Hotkey, error) {
+	parts := strings.Split(s, "+")
+	var h Hotkey
+	for _, p := range parts[:len(parts)-1] {
+		found := false
+		for _, m := range modNames {
+			if strings.EqualFold(strings.TrimSpace(p), m.name) {
+				h.Mods |= m.mask
+				found = true
+			}
+		}
+		if !found {
+			return Hotkey{}, fmt.Errorf("invalid hotkey %q: unknown modifier %q (want Ctrl, Alt, Shift or Super)", s, p)
+		}
+	}
+	key := strings.TrimSpace(parts[len(parts)-1])
+	switch {
+	case len(key) == 1 && key[0] >= 'a' && key[0] <= 'z':
+		h.Sym = uint32(key[0])
+	case len(key) == 1 && key[0] >= 'A' && key[0] <= 'Z':
+		h.Sym = uint32(key[0] - 'A' + 'a') // keysyms for letters are lower case
+	case len(key) == 1 && key[0] >= '0' && key[0] <= '9':
+		h.Sym = uint32(key[0])
+	default:
+		for name, sym := range keysyms {
+			if strings.EqualFold(key, name) {
+				h.Sym = sym
+			}
+		}
+	}
+	if h.Sym == 0 {
+		return Hotkey{}, fmt.Errorf("invalid hotkey %q: unknown key %q", s, key)
+	}
+	return h, nil
+}
+
+// String writes h the way ParseHotkey reads it.
+func (h Hotkey) String() string {
+	var parts []string
+	for _, m := range modNames {
+		if h.Mods&m.mask != 0 {
+			parts = append(parts, m.name)
+		}
+	}
+	key := ""
+	switch {
+	case h.Sym >= 'a' && h.Sym <= 'z':
+		key = strings.ToUpper(string(rune(h.Sym)))
+	case h.Sym >= '0' && h.Sym <= '9':
+		key = string(rune(h.Sym))
+	default:
+		for name, sym := range keysyms {
+			if sym == h.Sym {
+				key = name
+			}
+		}
+	}
+	return strings.Join(append(parts, key), "+")
+}
+
+// HotkeyConflict lists the hotkeys another client already holds.
+type HotkeyConflict struct {
+	Keys []Hotkey
+}
+
+func (e *HotkeyConflict) Error() string {
+	names := make([]string, len(e.Keys))
+	for i, k := range e.Keys {
+		names[i] = k.String()
+	}
+	return fmt.Sprintf("already taken by another application: %s", strings.Join(names, ", "))
+}
+
+// ignoredMods are the lock modifiers; a hotkey fires whether or not Caps
+// Lock or Num Lock (Mod2 on nearly every keymap) is on.
+var ignoredMods = []uint16{0, xproto.ModMaskLock, xproto.ModMask2, xproto.ModMaskLock | xproto.ModMask2}
+
+// Hotkeys is a set of grabbed keys on their own connection.
+type Hotkeys struct {
+	c    *Conn
+	keys []Hotkey
+	code []xproto.Keycode // per key, 0 where the grab failed
+}
+
+// GrabHotkeys grabs keys and calls fn with the index of each one pressed,
+// from its own goroutine, until Close. keys that can't be grabbed are left
+// out and reported in a *HotkeyConflict; the rest still work.
+func GrabHotkeys(keys []Hotkey, fn func(i int)) (*Hotkeys, error) {
+	c, err := Open()
+	if err != nil {
+		return nil, err
+	}
+	h := &Hotkeys{c: c, keys: keys, code: make([]xproto.Keycode, len(keys))}
+	var taken []Hotkey
+	var errs []error
+	for i, k := range keys {
+		code := c.keycode(k.Sym)
+		if code == 0 {
+			errs = append(errs, fmt.Errorf("%s: no key on this keyboard makes it", k))
+			continue
+		}
+		ok := true
+		for _, lock := range ignoredMods {
+			err := xproto.GrabKeyChecked(c.X, true, c.Root, k.Mods|lock, code,
+				xproto.GrabModeAsync, xproto.GrabModeAsync).Check()
+			if err != nil {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			h.ungrab(k, code)
+			taken = append(taken, k)
+			continue
+		}
+		h.code[i] = code
+	}
+	go h.listen(fn)
+	if len(taken) > 0 {
+		errs = append(errs, &HotkeyConflict{Keys: taken})
+	}
+	return h, errors.Join(errs...)
+}
+
+func (h *Hotkeys) listen(fn func(i int)) {
+	for {
+		ev, xerr := h.c.X.WaitForEvent()
+		if ev == nil && xerr == nil {
+			return // closed
+		}
+		e, ok := ev.(xproto.KeyPressEvent)
+		if !ok {
+			continue
+		}
+		state := e.State &^ (xproto.ModMaskLock | xproto.ModMask2)
+		for i, k := range h.keys {
+			if h.code[i] != 0 && h.code[i] == e.Detail && k.Mods == state {
+				fn(i)
+			}
+		}
+	}
+}
+
+func (h *Hotkeys) ungrab(k Hotkey, code xproto.Keycode) {
+	for _, lock := range ignoredMods {
+		xproto.UngrabKey(h.c.X, code, h.c.Root, k.Mods|lock)
+	}
+}
+
+// Close releases the keys.
+func (h *Hotkeys) Close() {
+	for i, k := range h.keys {
+		if h.code[i] != 0 {
+			h.ungrab(k, h.code[i])
+		}
+	}
+	h.c.Close()
+}
@@ -0,0 +1,199 @@
+/*
+	monitor enumeration. we ask RandR for the active monitors and turn them
+	into regions the recorder understands.
+*/
+
+package x11
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/jezek/xgb/randr"
+)
+
+// Monitor is one active RandR monitor.
+type Monitor struct {
+	Index    int     `json:"index"`
+	Name     string  `json:"name"`
+	X        int     `json:"x"`
+	Y        int     `json:"y"`
+	Width    int     `json:"width"`
+	Height   int     `json:"height"`
+	WidthMM  int     `json:"width_mm"`
+	HeightMM int     `json:"height_mm"`
+	Scale    float64 `json:"scale"`
+	Primary  bool    `json:"primary"`
+}
+
+// Region returns the monitor as a WxH+X+Y region string.
+func (m Monitor) Region() string {
+	return fmt.Sprintf("%dx%d+%d+%d", m.Width, m.Height, m.X, m.Y)
+}
+
+// Contains reports whether the screen point x,y lies on the monitor.
+func (m Monitor) Contains(x, y int) bool {
+	return x >= m.X && x < m.X+m.Width && y >= m.Y && y < m.Y+m.Height
+}
+
+// Monitors lists the active monitors in RandR order.
+func Monitors() ([]Monitor, error) {
+	c, err := Open()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return c.Monitors()
+}
+
+// Monitors lists the active monitors. RandR 1.5 servers describe them
+// directly; older ones only have outputs and CRTCs, and servers without RandR
+// at all get the root window as a single monitor.
+func (c *Conn) Monitors() ([]Monitor, error) {
+	var mons []Monitor
+	if err := randr.Init(c.X); err == nil {
+		if v, err := randr.QueryVersion(c.X, 1, 5).Reply(); err == nil {
+			if v.MajorVersion > 1 || v.MinorVersion >= 5 {
+				mons = c.randrMonitors()
+			}
+			if len(mons) == 0 && (v.MajorVersion > 1 || v.MinorVersion >= 3) {
+				mons = c.randrOutputs()
+			}
+		}
+	}
+	if len(mons) == 0 {
+		w, h, err := c.ScreenSize()
+		if err != nil {
+			return nil, err
+		}
+		mons = []Monitor{{
+			Name:     "screen",
+			Width:    w,
+			Height:   h,
+			WidthMM:  int(c.Screen.WidthInMillimeters),
+			HeightMM: int(c.Screen.HeightInMillimeters),
+			Primary:  true,
+		}}
+	}
+	for i := range mons {
+		mons[i].Index = i
+		mons[i].Scale = scaleFor(mons[i].Width, mons[i].WidthMM)
+	}
+	return mons, nil
+}
+
+// randrMonitors uses RRGetMonitors (RandR 1.5).
+func (c *Conn) randrMonitors() []Monitor {
+	r, err := randr.GetMonitors(c.X, c.Root, true).Reply()
+	if err != nil {
+		return nil
+	}
+	var mons []Monitor
+	for _, info := range r.Monitors {
+		mons = append(mons, Monitor{
+			Name:     c.atomName(info.Name),
+			X:        int(info.X),
+			Y:        int(info.Y),
+			Width:    int(info.Width),
+			Height:   int(info.Height),
+			WidthMM:  int(info.WidthInMillimeters),
+			HeightMM: int(info.HeightInMillimeters),
+			Primary:  info.Primary,
+		})
+	}
+	return mons
+}
+
+// randrOutputs walks the connected outputs that have a CRTC (RandR 1.3).
+func (c *Conn) randrOutputs() []Monitor {
+	res, err := randr.GetScreenResourcesCurrent(c.X, c.Root).Reply()
+	if err != nil {
+		return nil
+	}
+	var primary randr.Output
+	if p, err := randr.GetOutputPrimary(c.X, c.Root).Reply(); err == nil {
+		primary = p.Output
+	}
+	var mons []Monitor
+	for _, out := range res.Outputs {
+		info, err := randr.GetOutputInfo(c.X, out, res.ConfigTimestamp).Reply()
+		if err != nil || info.Connection != randr.ConnectionConnected || info.Crtc == 0 {
+			continue // unplugged or switched off
+		}
+		crtc, err := randr.GetCrtcInfo(c.X, info.Crtc, res.ConfigTimestamp).Reply()
+		if err != nil || crtc.Width == 0 {
+			continue
+		}
+		mons = append(mons, Monitor{
+			Name:     string(info.Name),
+			X:        int(crtc.X),
+			Y:        int(crtc.Y),
+			Width:    int(crtc.Width),
+			Height:   int(crtc.Height),
+			WidthMM:  int(info.MmWidth),
+			HeightMM: int(info.MmHeight),
+			Primary:  out == primary,
+		})
+	}
+	return mons
+}
+
+// scaleFor guesses the HiDPI scale from the output's DPI, in quarter steps
+// relative to 96 dpi. projectors and TVs often report 0mm; those get 1.
+func scaleFor(px, mm int) float64 {
+	if mm <= 0 {
+		return 1
+	}
+	dpi := float64(px) / (float64(mm) / 25.4)
+	s := math.Round(dpi/96*4) / 4
+	if s < 1 {
+		return 1
+	}
+	return s
+}
+
+// ResolveMonitor finds the monitor named by spec: an output name, an index
+// from Monitors, "primary" or "focused" (the one under the pointer).
+func ResolveMonitor(spec string) (Monitor, error) {
+	mons, err := Monitors()
+	if err != nil {
+		return Monitor{}, err
+	}
+	switch spec {
+	case "", "primary":
+		return primaryOf(mons), nil
+	case "focused":
+		if x, y, err := PointerPosition(); err == nil {
+			for _, m := range mons {
+				if m.Contains(x, y) {
+					return m, nil
+				}
+			}
+		}
+		return primaryOf(mons), nil
+	}
+	if idx, err := strconv.Atoi(spec); err == nil {
+		if idx < 0 || idx >= len(mons) {
+			return Monitor{}, fmt.Errorf("monitor index %d out of range (0-%d)", idx, len(mons)-1)
+		}
+		return mons[idx], nil
+	}
+	for _, m := range mons {
+		if m.Name == spec {
+			return m, nil
+		}
+	}
+	return Monitor{}, fmt.Errorf("no active monitor named %q", spec)
+}
+
+// primaryOf returns the primary monitor, or the first one when none is
+// flagged (common with a single output).
+func primaryOf(mons []Monitor) Monitor {
+	for _, m := range mons {
+		if m.Primary {
+			return m
+		}
+	}
+	return mons[0]
+}
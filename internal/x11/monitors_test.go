@@ -0,0 +1,52 @@
+package x11
+
+import "testing"
+
+func TestScaleFor(t *testing.T) {
+	tests := []struct {
+		px, mm int
+		want   float64
+	}{
+		{1920, 509, 1},    // 24" 1080p, 96 dpi
+		{3840, 597, 1.75}, // 27" 4k, 163 dpi
+		{2560, 286, 2.25},
+		{3840, 344, 3}, // 15.6" 4k laptop
+		{1366, 0, 1},   // a projector that doesn't say
+		{800, 600, 1},  // low dpi never scales below 1
+	}
+	for _, tt := range tests {
+		if got := scaleFor(tt.px, tt.mm); got != tt.want {
+			t.Errorf("scaleFor(%d, %d) = %v, want %v", tt.px, tt.mm, got, tt.want)
+		}
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	left := Monitor{Index: 0, Name: "DP-1", Width: 1920, Height: 1080}
+	right := Monitor{Index: 1, Name: "HDMI-1", X: 1920, Y: -200, Width: 2560, Height: 1440, Primary: true}
+	if r := right.Region(); r != "2560x1440+1920+-200" {
+		t.Errorf("Region = %q", r)
+	}
+	points := []struct {
+		x, y        int
+		left, right bool
+	}{
+		{0, 0, true, false},
+		{1919, 1079, true, false},
+		{1920, 0, false, true},
+		{1920, -200, false, true},
+		{1919, 1080, false, false},
+		{4480, 0, false, false},
+	}
+	for _, p := range points {
+		if left.Contains(p.x, p.y) != p.left || right.Contains(p.x, p.y) != p.right {
+			t.Errorf("%d,%d: on the left %v, the right %v; want %v, %v", p.x, p.y, left.Contains(p.x, p.y), right.Contains(p.x, p.y), p.left, p.right)
+		}
+	}
+	if m := primaryOf([]Monitor{left, right}); m.Name != "HDMI-1" {
+		t.Errorf("primaryOf = %s, want the flagged HDMI-1", m.Name)
+	}
+	if m := primaryOf([]Monitor{left}); m.Name != "DP-1" {
+		t.Errorf("primaryOf with none flagged = %s, want the first", m.Name)
+	}
+}
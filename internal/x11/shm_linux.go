@@ -0,0 +1,59 @@
+package x11
+
+import (
+	"image"
+
+	"github.com/jezek/xgb/shm"
+	"github.com/jezek/xgb/xproto"
+	"golang.org/x/sys/unix"
+)
+
+// shmSegment is a SysV shared memory segment the X server writes frames into.
+type shmSegment struct {
+	seg  shm.Seg
+	id   int
+	data []byte
+}
+
+// attachShm sets up a segment of size bytes, or returns nil when the server
+// has no MIT-SHM or cannot see our memory (remote displays).
+func (c *Conn) attachShm(size int) *shmSegment {
+	if shm.Init(c.X) != nil {
+		return nil
+	}
+	id, err := unix.SysvShmGet(unix.IPC_PRIVATE, size, unix.IPC_CREAT|0o600)
+	if err != nil {
+		return nil
+	}
+	data, err := unix.SysvShmAttach(id, 0, 0)
+	if err != nil {
+		unix.SysvShmCtl(id, unix.IPC_RMID, nil)
+		return nil
+	}
+	s := &shmSegment{id: id, data: data}
+	if s.seg, err = shm.NewSegId(c.X); err == nil {
+		err = shm.AttachChecked(c.X, s.seg, uint32(id), false).Check()
+	}
+	// marked for removal now; it goes away once both sides detach
+	unix.SysvShmCtl(id, unix.IPC_RMID, nil)
+	if err != nil {
+		unix.SysvShmDetach(data)
+		return nil
+	}
+	return s
+}
+
+// grab has the server copy rect into the segment and returns its contents.
+func (s *shmSegment) grab(c *Conn, rect image.Rectangle) ([]byte, error) {
+	_, err := shm.GetImage(c.X, xproto.Drawable(c.Root), int16(rect.Min.X), int16(rect.Min.Y),
+		uint16(rect.Dx()), uint16(rect.Dy()), 0xffffffff, xproto.ImageFormatZPixmap, s.seg, 0).Reply()
+	if err != nil {
+		return nil, err
+	}
+	return s.data, nil
+}
+
+func (s *shmSegment) close(c *Conn) {
+	shm.Detach(c.X, s.seg)
+	unix.SysvShmDetach(s.data)
+}
@@ -0,0 +1,283 @@
+/*
+	window capture targets: the focused window, one the user clicks on, or
+	one named by id or class. the result is a rectangle to grab, with or
+	without the window manager's decorations.
+*/
+
+package x11
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jezek/xgb/xproto"
+)
+
+// ErrPickCancelled means the user backed out of pick-window.
+var ErrPickCancelled = errors.New("window pick cancelled")
+
+// IsWindowTarget reports whether spec is one of the window --target forms.
+func IsWindowTarget(spec string) bool {
+	return spec == "active-window" || spec == "pick-window" || strings.HasPrefix(spec, "window:")
+}
+
+// ResolveWindow finds the window a --target spec names and returns it with
+// X, Y, Width and Height set to the rectangle to capture, clipped to the
+// screen.
+func ResolveWindow(spec string, decorations bool) (Window, error) {
+	return ResolveWindowContext(context.Background(), spec, decorations)
+}
+
+// ResolveWindowContext is ResolveWindow that gives up when ctx is done,
+// letting go of the pointer if pick-window is waiting for a click.
+func ResolveWindowContext(ctx context.Context, spec string, decorations bool) (Window, error) {
+	c, err := Open()
+	if err != nil {
+		return Window{}, err
+	}
+	defer c.Close()
+	// hanging up ends the grab and wakes PickWindow
+	stop := context.AfterFunc(ctx, c.Close)
+	defer stop()
+	w, err := resolveWindow(c, spec, decorations)
+	if ctx.Err() != nil {
+		return Window{}, ctx.Err()
+	}
+	return w, err
+}
+
+func resolveWindow(c *Conn, spec string, decorations bool) (Window, error) {
+	var (
+		w   Window
+		err error
+	)
+	switch {
+	case spec == "active-window":
+		w, err = c.ActiveWindow()
+	case spec == "pick-window":
+		w, err = c.PickWindow()
+	case strings.HasPrefix(spec, "window:"):
+		w, err = c.FindWindow(strings.TrimPrefix(spec, "window:"))
+	default:
+		err = fmt.Errorf("unknown window target %q", spec)
+	}
+	if err != nil {
+		return Window{}, err
+	}
+	if w, err = c.Bounds(w, decorations); err != nil {
+		return Window{}, err
+	}
+	sw, sh, err := c.ScreenSize()
+	if err != nil {
+		return Window{}, err
+	}
+	x0, y0 := max(w.X, 0), max(w.Y, 0)
+	x1, y1 := min(w.X+w.Width, sw), min(w.Y+w.Height, sh)
+	if x1 <= x0 || y1 <= y0 {
+		return Window{}, fmt.Errorf("window 0x%x is off screen", w.ID)
+	}
+	w.X, w.Y, w.Width, w.Height = x0, y0, x1-x0, y1-y0
+	return w, nil
+}
+
+// ActiveWindow returns the window the window manager says has focus.
+func (c *Conn) ActiveWindow() (Window, error) {
+	v := c.property(c.Root, "_NET_ACTIVE_WINDOW")
+	if len(v) < 4 || binary.LittleEndian.Uint32(v) == 0 {
+		return Window{}, fmt.Errorf("no active window (the window manager doesn't set _NET_ACTIVE_WINDOW)")
+	}
+	return c.windowByID(binary.LittleEndian.Uint32(v))
+}
+
+// FindWindow looks a window up by id (decimal or 0x hex, client or frame)
+// or by WM_CLASS, case-insensitively. several matches by class give the
+// topmost one.
+func (c *Conn) FindWindow(spec string) (Window, error) {
+	if spec == "" {
+		return Window{}, fmt.Errorf("window: needs an id or a class")
+	}
+	if id, err := strconv.ParseUint(spec, 0, 32); err == nil {
+		return c.windowByID(uint32(id))
+	}
+	wins, err := c.Windows()
+	if err != nil {
+		return Window{}, err
+	}
+	for i := len(wins) - 1; i >= 0; i-- {
+		if strings.EqualFold(wins[i].Class, spec) {
+			return wins[i], nil
+		}
+	}
+	return Window{}, fmt.Errorf("no window with class %q", spec)
+}
+
+func (c *Conn) windowByID(id uint32) (Window, error) {
+	wins, err := c.Windows()
+	if err != nil {
+		return Window{}, err
+	}
+	for _, w := range wins {
+		if w.ID == id || w.Frame == id {
+			return w, nil
+		}
+	}
+	return Window{}, fmt.Errorf("no mapped window 0x%x", id)
+}
+
+// Bounds sets w's geometry to its frame (decorations) or its client area.
+// window managers that don't reparent, and client-side decorations, leave
+// frame == client; _NET_FRAME_EXTENTS then says how far the frame reaches.
+func (c *Conn) Bounds(w Window, decorations bool) (Window, error) {
+	if !decorations {
+		x, y, cw, ch, err := c.rootGeometry(xproto.Window(w.ID))
+		if err != nil {
+			return Window{}, err
+		}
+		w.X, w.Y, w.Width, w.Height = x, y, cw, ch
+		return w, nil
+	}
+	if w.Frame != w.ID {
+		return w, nil // describe already measured the frame
+	}
+	if v := c.property(xproto.Window(w.ID), "_NET_FRAME_EXTENTS"); len(v) >= 16 {
+		left := int(binary.LittleEndian.Uint32(v[0:]))
+		right := int(binary.LittleEndian.Uint32(v[4:]))
+		top := int(binary.LittleEndian.Uint32(v[8:]))
+		bottom := int(binary.LittleEndian.Uint32(v[12:]))
+		w.X -= left
+		w.Y -= top
+		w.Width += left + right
+		w.Height += top + bottom
+	}
+	return w, nil
+}
+
+// PickWindow turns the pointer into a crosshair and returns the window the
+// user clicks. escape or any button but the first cancels.
+func (c *Conn) PickWindow() (Window, error) {
+	cursor, err := c.crosshair()
+	if err != nil {
+		cursor = xproto.CursorNone // keep whatever pointer is showing
+	} else {
+		defer xproto.FreeCursor(c.X, cursor)
+	}
+	g, err := xproto.GrabPointer(c.X, false, c.Root, xproto.EventMaskButtonPress,
+		xproto.GrabModeAsync, xproto.GrabModeAsync, xproto.WindowNone, cursor, xproto.TimeCurrentTime).Reply()
+	if err != nil {
+		return Window{}, fmt.Errorf("x11: grab pointer: %w", err)
+	}
+	if g.Status != xproto.GrabStatusSuccess {
+		return Window{}, fmt.Errorf("x11: pointer is grabbed by another client")
+	}
+	defer xproto.UngrabPointer(c.X, xproto.TimeCurrentTime)
+	escape := c.keycode(0xff1b) // XK_Escape
+	if k, err := xproto.GrabKeyboard(c.X, false, c.Root, xproto.TimeCurrentTime,
+		xproto.GrabModeAsync, xproto.GrabModeAsync).Reply(); err == nil && k.Status == xproto.GrabStatusSuccess {
+		defer xproto.UngrabKeyboard(c.X, xproto.TimeCurrentTime)
+	}
+
+	for {
+		ev, xerr := c.X.WaitForEvent()
+		if ev == nil && xerr == nil {
+			return Window{}, fmt.Errorf("x11: connection closed")
+		}
+		switch e := ev.(type) {
+		case xproto.KeyPressEvent:
+			if e.Detail == escape {
+				return Window{}, ErrPickCancelled
+			}
+		case xproto.ButtonPressEvent:
+			if e.Detail != 1 {
+				return Window{}, ErrPickCancelled
+			}
+			if e.Child == 0 {
+				return Window{}, fmt.Errorf("clicked on the desktop, not a window")
+			}
+			return c.windowByID(uint32(e.Child))
+		}
+	}
+}
+
+// crosshair makes the classic X crosshair cursor from the cursor font.
+func (c *Conn) crosshair() (xproto.Cursor, error) {
+	font, err := xproto.NewFontId(c.X)
+	if err != nil {
+		return 0, err
+	}
+	if err := xproto.OpenFontChecked(c.X, font, uint16(len("cursor")), "cursor").Check(); err != nil {
+		return 0, err
+	}
+	defer xproto.CloseFont(c.X, font)
+	cur, err := xproto.NewCursorId(c.X)
+	if err != nil {
+		return 0, err
+	}
+	const xcCrosshair = 34
+	err = xproto.CreateGlyphCursorChecked(c.X, cur, font, font, xcCrosshair, xcCrosshair+1,
+		0, 0, 0, 0xffff, 0xffff, 0xffff).Check()
+	return cur, err
+}
+
+// keycode returns the first keycode that produces keysym, or 0.
+func (c *Conn) keycode(keysym uint32) xproto.Keycode {
+	setup := xproto.Setup(c.X)
+	first, count := setup.MinKeycode, byte(setup.MaxKeycode-setup.MinKeycode+1)
+	m, err := xproto.GetKeyboardMapping(c.X, first, count).Reply()
+	if err != nil || m.KeysymsPerKeycode == 0 {
+		return 0
+	}
+	per := int(m.KeysymsPerKeycode)
+	for i, ks := range m.Keysyms {
+		if uint32(ks) == keysym {
+			return first + xproto.Keycode(i/per)
+		}
+	}
+	return 0
+}
+
+// RegionSource names what a WxH+X+Y region shows, for output file names: the
+// monitor its centre is on, empty when the region is the whole screen, and
+// the class of the window it frames, empty when it doesn't frame one.
+func RegionSource(region string) (monitor, class string) {
+	c, err := Open()
+	if err != nil {
+		return "", ""
+	}
+	defer c.Close()
+	var w, h, x, y int
+	if n, _ := fmt.Sscanf(region, "%dx%d+%d+%d", &w, &h, &x, &y); n != 4 || w <= 0 || h <= 0 {
+		return "", "" // no region: the whole screen
+	}
+	if sw, sh, err := c.ScreenSize(); err == nil && w >= sw && h >= sh {
+		return "", ""
+	}
+	cx, cy := x+w/2, y+h/2
+	if mons, err := c.Monitors(); err == nil {
+		for _, m := range mons {
+			if m.Contains(cx, cy) {
+				monitor = m.Name
+				break
+			}
+		}
+	}
+	// the topmost window holding the region counts when the region is
+	// most of it; a grab with or without decorations both qualify
+	if wins, err := c.Windows(); err == nil {
+		for i := len(wins) - 1; i >= 0; i-- {
+			win := wins[i]
+			if !win.Contains(cx, cy) {
+				continue
+			}
+			inside := x >= win.X && y >= win.Y && x+w <= win.X+win.Width && y+h <= win.Y+win.Height
+			if inside && w*h*10 >= win.Width*win.Height*8 {
+				class = win.Class
+			}
+			break
+		}
+	}
+	return monitor, class
+}
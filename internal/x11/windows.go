@@ -0,0 +1,172 @@
+/*
+	window tree. walks the root window's children in stacking order and finds
+	the client window (the one with WM_STATE) inside each window manager frame.
+*/
+
+package x11
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/jezek/xgb/xproto"
+)
+
+// Window is one top-level window. X, Y, Width and Height are the frame's
+// root coordinates, decorations included.
+type Window struct {
+	ID     uint32 `json:"id"`    // client window
+	Frame  uint32 `json:"frame"` // window manager frame, == ID when undecorated
+	Name   string `json:"name"`  // _NET_WM_NAME, falling back to WM_NAME
+	Class  string `json:"class"` // WM_CLASS class part
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Region returns the window as a WxH+X+Y region string.
+func (w Window) Region() string {
+	return fmt.Sprintf("%dx%d+%d+%d", w.Width, w.Height, w.X, w.Y)
+}
+
+// Contains reports whether the screen point x,y lies on the window.
+func (w Window) Contains(x, y int) bool {
+	return x >= w.X && x < w.X+w.Width && y >= w.Y && y < w.Y+w.Height
+}
+
+// Windows lists the mapped top-level windows, bottom of the stack first.
+func (c *Conn) Windows() ([]Window, error) {
+	tree, err := xproto.QueryTree(c.X, c.Root).Reply()
+	if err != nil {
+		return nil, fmt.Errorf("x11: query tree: %w", err)
+	}
+	var wins []Window
+	for _, frame := range tree.Children {
+		attr, err := xproto.GetWindowAttributes(c.X, frame).Reply()
+		if err != nil || attr.MapState != xproto.MapStateViewable || attr.OverrideRedirect {
+			continue // menus, tooltips and unmapped windows
+		}
+		client := c.findClient(frame, 4)
+		if client == 0 {
+			continue
+		}
+		w, err := c.describe(client, frame)
+		if err != nil {
+			continue
+		}
+		wins = append(wins, w)
+	}
+	return wins, nil
+}
+
+// WindowAt returns the topmost window containing the screen point x,y.
+func (c *Conn) WindowAt(x, y int) (Window, bool) {
+	wins, err := c.Windows()
+	if err != nil {
+		return Window{}, false
+	}
+	for i := len(wins) - 1; i >= 0; i-- {
+		if wins[i].Contains(x, y) {
+			return wins[i], true
+		}
+	}
+	return Window{}, false
+}
+
+// Windows lists the mapped top-level windows, bottom of the stack first.
+func Windows() ([]Window, error) {
+	c, err := Open()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return c.Windows()
+}
+
+// findClient looks for the window carrying WM_STATE at or below w. Without a
+// window manager nothing sets WM_STATE, so a childless w counts as its own
+// client.
+func (c *Conn) findClient(w xproto.Window, depth int) xproto.Window {
+	wmState, err := c.atom("WM_STATE")
+	if err != nil {
+		return 0
+	}
+	if p, err := xproto.GetProperty(c.X, false, w, wmState, xproto.GetPropertyTypeAny, 0, 0).Reply(); err == nil && p.Type != 0 {
+		return w
+	}
+	tree, err := xproto.QueryTree(c.X, w).Reply()
+	if err != nil {
+		return 0
+	}
+	if len(tree.Children) == 0 {
+		return w
+	}
+	if depth == 0 {
+		return 0
+	}
+	for i := len(tree.Children) - 1; i >= 0; i-- {
+		if found := c.findClient(tree.Children[i], depth-1); found != 0 {
+			return found
+		}
+	}
+	return 0
+}
+
+// describe fills a Window for client framed by frame.
+func (c *Conn) describe(client, frame xproto.Window) (Window, error) {
+	x, y, w, h, err := c.rootGeometry(frame)
+	if err != nil {
+		return Window{}, err
+	}
+	return Window{
+		ID:     uint32(client),
+		Frame:  uint32(frame),
+		Name:   c.windowName(client),
+		Class:  c.windowClass(client),
+		X:      x,
+		Y:      y,
+		Width:  w,
+		Height: h,
+	}, nil
+}
+
+// rootGeometry returns w's outer rectangle in root coordinates.
+func (c *Conn) rootGeometry(w xproto.Window) (int, int, int, int, error) {
+	g, err := xproto.GetGeometry(c.X, xproto.Drawable(w)).Reply()
+	if err != nil {
+		return 0, 0, 0, 0, fmt.Errorf("x11: geometry of 0x%x: %w", w, err)
+	}
+	t, err := xproto.TranslateCoordinates(c.X, w, c.Root, 0, 0).Reply()
+	if err != nil {
+		return 0, 0, 0, 0, fmt.Errorf("x11: translate 0x%x: %w", w, err)
+	}
+	bw := int(g.BorderWidth)
+	return int(t.DstX) - bw, int(t.DstY) - bw, int(g.Width) + 2*bw, int(g.Height) + 2*bw, nil
+}
+
+// property reads a whole property of w, or nil.
+func (c *Conn) property(w xproto.Window, name string) []byte {
+	a, err := c.atom(name)
+	if err != nil {
+		return nil
+	}
+	p, err := xproto.GetProperty(c.X, false, w, a, xproto.GetPropertyTypeAny, 0, 1<<16).Reply()
+	if err != nil {
+		return nil
+	}
+	return p.Value
+}
+
+func (c *Conn) windowName(w xproto.Window) string {
+	if v := c.property(w, "_NET_WM_NAME"); len(v) > 0 {
+		return string(v)
+	}
+	return string(c.property(w, "WM_NAME"))
+}
+
+// windowClass returns the class half of WM_CLASS ("instance\0Class\0").
+func (c *Conn) windowClass(w xproto.Window) string {
+	parts := bytes.Split(bytes.TrimRight(c.property(w, "WM_CLASS"), "\x00"), []byte{0})
+	return string(parts[len(parts)-1])
+}
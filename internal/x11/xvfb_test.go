@@ -0,0 +1,152 @@
+package x11
+
+import (
+	"image"
+	"image/color"
+	"os/exec"
+	"testing"
+
+	"github.com/jezek/xgb/xproto"
+
+	"github.com/almightynan/swiftcap/internal/headless"
+)
+
+// xvfb starts a 1280x720 Xvfb for the test, points DISPLAY at it and
+// connects. It skips the test when Xvfb isn't installed.
+func xvfb(t *testing.T) *Conn {
+	t.Helper()
+	if _, err := exec.LookPath("Xvfb"); err != nil {
+		t.Skip("Xvfb is not installed")
+	}
+	d, err := headless.Start(headless.Options{Width: 1280, Height: 720})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	t.Setenv("DISPLAY", d.Name)
+	c, err := Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+// fill paints r of the root window in pixel, a 24-bit TrueColor value.
+func fill(t *testing.T, c *Conn, pixel uint32, r image.Rectangle) {
+	t.Helper()
+	gc, err := xproto.NewGcontextId(c.X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := xproto.CreateGCChecked(c.X, gc, xproto.Drawable(c.Root), xproto.GcForeground, []uint32{pixel}).Check(); err != nil {
+		t.Fatal(err)
+	}
+	defer xproto.FreeGC(c.X, gc)
+	rect := xproto.Rectangle{X: int16(r.Min.X), Y: int16(r.Min.Y), Width: uint16(r.Dx()), Height: uint16(r.Dy())}
+	if err := xproto.PolyFillRectangleChecked(c.X, xproto.Drawable(c.Root), gc, []xproto.Rectangle{rect}).Check(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestXvfbScreen(t *testing.T) {
+	xvfb(t)
+	w, h, err := ScreenSize()
+	if err != nil || w != 1280 || h != 720 {
+		t.Errorf("ScreenSize = %d, %d, %v; want 1280, 720", w, h, err)
+	}
+	if region, err := GetGeometry(); err != nil || region != "1280x720+0+0" {
+		t.Errorf("GetGeometry = %q, %v", region, err)
+	}
+
+	mons, err := Monitors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mons) != 1 {
+		t.Fatalf("Monitors = %+v, want Xvfb's one screen", mons)
+	}
+	if m := mons[0]; m.Region() != "1280x720+0+0" || m.Index != 0 || m.Name == "" || m.Scale != 1 {
+		t.Errorf("monitor %+v, want 1280x720+0+0 at scale 1", m)
+	}
+	for _, spec := range []string{"", "primary", "focused", "0", mons[0].Name} {
+		if m, err := ResolveMonitor(spec); err != nil || m.Region() != "1280x720+0+0" {
+			t.Errorf("ResolveMonitor(%q) = %+v, %v", spec, m, err)
+		}
+	}
+	if _, err := ResolveMonitor("1"); err == nil {
+		t.Error("ResolveMonitor found a second monitor")
+	}
+}
+
+func TestXvfbPointer(t *testing.T) {
+	c := xvfb(t)
+	if err := xproto.WarpPointerChecked(c.X, xproto.WindowNone, c.Root, 0, 0, 0, 0, 300, 200).Check(); err != nil {
+		t.Fatal(err)
+	}
+	// through a connection of its own, as the cli asks
+	if x, y, err := PointerPosition(); err != nil || x != 300 || y != 200 {
+		t.Errorf("PointerPosition = %d, %d, %v; want 300, 200", x, y, err)
+	}
+}
+
+func TestXvfbCapture(t *testing.T) {
+	c := xvfb(t)
+	fill(t, c, 0x0000ff, image.Rect(0, 0, 1280, 720))
+	fill(t, c, 0xff0000, image.Rect(100, 50, 300, 150))
+	fill(t, c, 0x80ff40, image.Rect(300, 150, 400, 200))
+	blue := color.RGBA{0, 0, 0xff, 0xff}
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	green := color.RGBA{0x80, 0xff, 0x40, 0xff}
+
+	for _, shm := range []bool{true, false} {
+		g, err := c.NewGrabber(image.Rect(90, 40, 410, 210))
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case shm && g.shm == nil:
+			g.Close()
+			t.Log("the server has no MIT-SHM for us; only GetImage is tested")
+			continue
+		case !shm:
+			g.Close() // GetImage from here on
+		}
+		img, err := g.Grab(nil)
+		g.Close()
+		if err != nil {
+			t.Fatalf("shm %v: %v", shm, err)
+		}
+		if img.Bounds() != image.Rect(0, 0, 320, 170) {
+			t.Errorf("shm %v: grabbed %v, want 320x170", shm, img.Bounds())
+		}
+		for _, p := range []struct {
+			x, y int
+			want color.RGBA
+		}{{0, 0, blue}, {10, 10, red}, {209, 109, red}, {210, 110, green}, {309, 159, green}, {319, 169, blue}} {
+			if got := img.RGBAAt(p.x, p.y); got != p.want {
+				t.Errorf("shm %v: pixel %d,%d = %v, want %v", shm, p.x, p.y, got, p.want)
+			}
+		}
+	}
+
+	img, err := Capture(image.Rectangle{})
+	if err != nil || img.Bounds() != image.Rect(0, 0, 1280, 720) {
+		t.Fatalf("Capture of everything = %v, %v", img.Bounds(), err)
+	}
+	if got := img.RGBAAt(150, 100); got != red {
+		t.Errorf("pixel 150,100 = %v, want red", got)
+	}
+
+	g, err := c.NewGrabber(image.Rect(1200, 700, 1400, 800))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Close()
+	if r := g.Rect(); r != image.Rect(1200, 700, 1280, 720) {
+		t.Errorf("a region past the edge is clipped to %v", r)
+	}
+	if _, err := c.NewGrabber(image.Rect(1300, 0, 1400, 100)); err == nil {
+		t.Error("grabbed a region off the screen")
+	}
+}
@@ -9,4 +9,5 @@ if [ -n "$missing" ]; then
   exit 1
 else
   echo "All dependencies present."
+  echo "Run swiftcap doctor to check encoders, portals and the rest."
 fi